@@ -0,0 +1,233 @@
+// Command chorusctl runs administrative tasks against the controller database.
+//
+// It reads the same environment as the controller (POSTGRES_DSN, ENCRYPTION_KEY, ...):
+//
+//	go run ./cmd/chorusctl export-storages -o storages.yaml
+//	go run ./cmd/chorusctl import-storages -f config.yaml -dry-run
+//	go run ./cmd/chorusctl rotate-keys -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/config"
+	"github.com/hantdev/chorus-controller/internal/crypto"
+	"github.com/hantdev/chorus-controller/internal/db"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/service"
+)
+
+// commands maps subcommand names to their implementation
+var commands = map[string]struct {
+	usage string
+	run   func(ctx context.Context, cfg *config.Config, args []string) error
+}{
+	"export-storages": {"render stored storages as Chorus worker storage config", exportStorages},
+	"import-storages": {"create or update storages from Chorus worker config", importStorages},
+	"rotate-keys":     {"re-encrypt storage secrets with the active encryption key", rotateKeys},
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := db.Open(cfg.PostgresDSN); err != nil {
+		log.Fatal(err)
+	}
+	if err := cmd.run(context.Background(), cfg, os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: chorusctl <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", name, commands[name].usage)
+	}
+}
+
+// exportStorages writes the worker storage config to a file or stdout
+func exportStorages(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export-storages", flag.ExitOnError)
+	var (
+		output = fs.String("o", "-", "output file, - for stdout")
+		req    domain.ExportStoragesRequest
+	)
+	fs.StringVar(&req.DefaultRegion, "default-region", "", "fallback region of the worker")
+	fs.BoolVar(&req.CreateRouting, "create-routing", true, "create routing rules to the main storage")
+	fs.BoolVar(&req.CreateReplication, "create-replication", false, "create replication rules from the main storage")
+	fs.StringVar(&req.Selector, "selector", "", "export only the storages whose labels match, e.g. env=prod")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	storages, err := newStorageService(cfg)
+	if err != nil {
+		return err
+	}
+	out, err := storages.ExportWorkerConfig(ctx, &req, &domain.AuditActor{TokenName: "chorusctl"})
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	// The file holds plain-text credentials
+	return os.WriteFile(*output, out, 0o600)
+}
+
+// importStorages creates or updates the storages of a worker config file and prints the outcome
+func importStorages(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import-storages", flag.ExitOnError)
+	var (
+		input = fs.String("f", "-", "worker config file, - for stdin")
+		opts  domain.ImportStoragesOptions
+	)
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "report the changes without saving them")
+	fs.StringVar(&opts.User, "user", "", "own user of storages with several users, the others are stored as credentials")
+	fs.BoolVar(&opts.ReplaceMain, "replace-main", false, "demote a main storage missing from the file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if *input == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*input)
+	}
+	if err != nil {
+		return err
+	}
+
+	storages, err := newStorageService(cfg)
+	if err != nil {
+		return err
+	}
+	report, err := storages.ImportStorages(ctx, data, &opts)
+	if err != nil {
+		var apiErr *errors.APIError
+		if errors.As(err, &apiErr) {
+			return fmt.Errorf("%s", apiErr.Message)
+		}
+		return err
+	}
+
+	for _, entry := range report.Storages {
+		line := fmt.Sprintf("%-10s %s", entry.Action, entry.Name)
+		if len(entry.Changed) > 0 {
+			line += " (" + strings.Join(entry.Changed, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+	suffix := ""
+	if report.DryRun {
+		suffix = ", dry run"
+	}
+	fmt.Printf("%d created, %d updated, %d unchanged%s\n", report.Created, report.Updated, report.Unchanged, suffix)
+	return nil
+}
+
+// rotateKeys re-encrypts the storage secrets with the active key and prints the outcome
+func rotateKeys(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+	var (
+		after = fs.String("after", "", "resume after the storage with this ID")
+		req   domain.RotateKeysRequest
+	)
+	fs.IntVar(&req.BatchSize, "batch-size", 100, "storages re-encrypted per transaction")
+	fs.BoolVar(&req.DryRun, "dry-run", false, "only count the secrets per key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if req.BatchSize < 1 {
+		return fmt.Errorf("-batch-size must be positive")
+	}
+	if *after != "" {
+		id, err := uuid.Parse(*after)
+		if err != nil {
+			return fmt.Errorf("invalid -after: %w", err)
+		}
+		req.After = id
+	}
+
+	storages, err := newStorageService(cfg)
+	if err != nil {
+		return err
+	}
+	report, err := storages.RotateKeys(ctx, &req)
+	if err != nil {
+		// The message holds the storage ID to resume after
+		var apiErr *errors.APIError
+		if errors.As(err, &apiErr) {
+			return fmt.Errorf("%s: %w", apiErr.Message, err)
+		}
+		return err
+	}
+
+	ids := make([]string, 0, len(report.Keys))
+	for id := range report.Keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Printf("key %-18s %d secrets\n", id, report.Keys[id])
+	}
+	formats := make([]string, 0, len(report.Formats))
+	for format := range report.Formats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	for _, format := range formats {
+		fmt.Printf("format %-15s %d secrets\n", format, report.Formats[format])
+	}
+	verb := "rotated"
+	if report.DryRun {
+		verb = "to rotate"
+	}
+	fmt.Printf("%d storages scanned in %d batches, %d secrets %s to key %q\n",
+		report.Scanned, report.Batches, report.Rotated, verb, report.ActiveKey)
+	return nil
+}
+
+// newStorageService creates a storage service for the database, the commands need no worker connection
+func newStorageService(cfg *config.Config) (*service.StorageService, error) {
+	cipher, err := crypto.NewFromConfig(crypto.Config(cfg.Encryption))
+	if err != nil {
+		return nil, fmt.Errorf("encryption keys: %w", err)
+	}
+	return service.NewStorageService(nil, cipher), nil
+}
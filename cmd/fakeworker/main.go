@@ -0,0 +1,84 @@
+// Command fakeworker runs an in-memory Chorus worker for local development.
+//
+// It serves the worker gRPC API with simulated storages, buckets and
+// replication progress, so the controller can be run without S3 storages
+// or a real Chorus deployment:
+//
+//	go run ./cmd/fakeworker -listen :9670
+//	WORKER_GRPC_ADDR=localhost:9670 go run ./cmd/main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/fakeworker"
+)
+
+func main() {
+	var (
+		listen   = flag.String("listen", ":9670", "gRPC listen address")
+		storages = flag.String("storages", "main,follower", "comma separated storage names, the first one is the main storage")
+		users    = flag.String("users", "admin", "comma separated users with credentials on every storage")
+		buckets  = flag.String("buckets", "bucket1,bucket2,bucket3", "comma separated buckets of every user on the main storage")
+		objects  = flag.Int64("objects", 1000, "number of objects in every bucket")
+		size     = flag.Int64("object-size", 1<<20, "size of every object in bytes")
+		rate     = flag.Float64("rate", 50, "replicated objects per second and bucket")
+	)
+	flag.Parse()
+
+	srv := fakeworker.New(fakeworker.Options{
+		ObjectsPerBucket: *objects,
+		ObjectSize:       *size,
+		ObjectsPerSecond: *rate,
+	})
+
+	storageNames := splitList(*storages)
+	userNames := splitList(*users)
+	for i, name := range storageNames {
+		srv.AddStorage(name, name+".s3.local", pb.Storage_Other, i == 0, userNames...)
+	}
+	if len(storageNames) > 0 {
+		for _, user := range userNames {
+			if err := srv.AddBuckets(storageNames[0], user, splitList(*buckets)...); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down...")
+		srv.Stop()
+	}()
+
+	log.Printf("Fake worker listening on %s with storages %v", lis.Addr(), storageNames)
+	if err := srv.Serve(lis); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -17,9 +17,15 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/hantdev/chorus-controller/internal/config"
+	"github.com/hantdev/chorus-controller/internal/crypto"
 	"github.com/hantdev/chorus-controller/internal/db"
 	"github.com/hantdev/chorus-controller/internal/handler"
 	"github.com/hantdev/chorus-controller/internal/repository"
@@ -47,22 +53,33 @@ func main() {
 	// }
 
 	// Initialize repository layer
-	workerRepo := repository.NewWorkerRepository(cfg.WorkerGRPCAddr)
+	workerRepo, err := repository.NewWorkerRepository(cfg.WorkerGRPCAddr, repository.WorkerTLSConfig(cfg.WorkerTLS))
+	if err != nil {
+		log.Fatal(err)
+	}
 	tokenRepo := repository.NewTokenDBRepository()
 
+	cipher, err := crypto.NewFromConfig(crypto.Config(cfg.Encryption))
+	if err != nil {
+		log.Fatalf("encryption keys: %v", err)
+	}
+
 	// Initialize service layer
-	replicationService := service.NewReplicationService(workerRepo)
-	storageService := service.NewStorageService(workerRepo, cfg.EncryptionKey)
+	workerService := service.NewWorkerService(cfg.WorkerName, workerRepo, repository.ResilienceConfig(cfg.WorkerRetry))
+	defer workerService.Close()
+	replicationService := service.NewReplicationService(workerService)
+	storageService := service.NewStorageService(workerService, cipher)
 	tokenService := service.NewTokenService(tokenRepo, cfg.JWTSecret, cfg.JWTExpiry)
 
 	// Initialize handler layer
-	healthHandler := handler.NewHealthHandler()
+	healthHandler := handler.NewHealthHandler(workerRepo)
 	storageHandler := handler.NewStorageHandler(storageService)
 	replicationHandler := handler.NewReplicationHandler(replicationService)
+	workerHandler := handler.NewWorkerHandler(workerService)
 	authHandler := handler.NewAuthHandler(tokenService)
 
 	// Initialize server
-	srv := server.New(healthHandler, storageHandler, replicationHandler, authHandler, tokenService, cfg.HTTPPort)
+	srv := server.New(healthHandler, storageHandler, replicationHandler, workerHandler, authHandler, tokenService, cfg.HTTPPort)
 
 	// Initialize system token
 	if err := srv.Initialize(); err != nil {
@@ -72,7 +89,32 @@ func main() {
 	// Configure Swagger runtime options
 	docs.SwaggerInfo.BasePath = "/"
 
-	if err := srv.Run(); err != nil {
-		log.Fatal(err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.DriftCheckInterval > 0 {
+		go service.NewDriftMonitor(storageService, cfg.DriftCheckInterval).Run(ctx)
+	}
+	if cfg.HealthProbeConcurrency > 0 {
+		go service.NewHealthMonitor(storageService, cfg.HealthProbeConcurrency, cfg.HealthRetention, cfg.HealthMinInterval).Run(ctx)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Run()
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			log.Fatal(err)
+		}
+	case <-ctx.Done():
+		log.Println("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("HTTP server shutdown: %v", err)
+		}
 	}
 }
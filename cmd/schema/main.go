@@ -45,8 +45,14 @@ CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 	// Define all models
 	models := []interface{}{
 		&domain.Storage{},
+		&domain.StorageCredential{},
 		&domain.ReplicateJob{},
 		&domain.TokenInfo{},
+		&domain.Worker{},
+		&domain.BucketComparison{},
+		&domain.StorageDriftEvent{},
+		&domain.AuditEvent{},
+		&domain.StorageHealthCheck{},
 	}
 
 	// Generate schema for each model
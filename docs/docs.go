@@ -22,22 +22,19 @@ const docTemplate = `{
                         "TokenAuth": []
                     }
                 ],
-                "description": "Disables an API token, making it unusable",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Disables an API token by its ID",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "auth"
                 ],
-                "summary": "Revoke an API token",
+                "summary": "Revoke an API token by ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Token to revoke",
-                        "name": "token",
+                        "description": "Token ID to revoke",
+                        "name": "token_id",
                         "in": "query",
                         "required": true
                     }
@@ -62,6 +59,13 @@ const docTemplate = `{
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
             }
@@ -248,6 +252,12 @@ const docTemplate = `{
                 ],
                 "summary": "List buckets available for replication",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Worker name (default worker when empty)",
+                        "name": "worker",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "User identifier",
@@ -293,7 +303,7 @@ const docTemplate = `{
         },
         "/health": {
             "get": {
-                "description": "Returns the health status of the controller",
+                "description": "Returns the health status of the controller and the connection state of its default worker.\nIt neither reads the database nor dials registered workers, GET /workers/status reports every worker.",
                 "consumes": [
                     "application/json"
                 ],
@@ -317,7 +327,7 @@ const docTemplate = `{
         },
         "/replications": {
             "get": {
-                "description": "Returns a list of all configured replication jobs with their statuses",
+                "description": "Returns a list of all configured replication jobs with their statuses.\nWorkers which cannot be reached are named in the X-Unreachable-Workers header, their replications are missing.",
                 "consumes": [
                     "application/json"
                 ],
@@ -336,6 +346,12 @@ const docTemplate = `{
                             "items": {
                                 "type": "object"
                             }
+                        },
+                        "headers": {
+                            "X-Unreachable-Workers": {
+                                "type": "string",
+                                "description": "Comma separated workers which could not be listed"
+                            }
                         }
                     },
                     "502": {
@@ -389,12 +405,40 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "502": {
                         "description": "Bad Gateway",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
             },
@@ -440,50 +484,100 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "502": {
                         "description": "Bad Gateway",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
             }
         },
-        "/replications/pause": {
-            "post": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Pauses an active replication job",
-                "consumes": [
-                    "application/json"
-                ],
+        "/replications/compare": {
+            "get": {
+                "description": "Returns stored bucket comparisons, newest first",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "replications"
                 ],
-                "summary": "Pause a replication job",
+                "summary": "List bucket comparisons",
                 "parameters": [
                     {
-                        "description": "Replication identifier",
-                        "name": "replication",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/domain.ReplicationIdentifier"
-                        }
+                        "type": "string",
+                        "description": "Worker name",
+                        "name": "worker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bucket",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Source storage",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Destination storage",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of comparisons (default 50)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Replication paused successfully",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.BucketComparison"
+                            }
                         }
                     },
                     "400": {
@@ -493,24 +587,22 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
-                    "502": {
-                        "description": "Bad Gateway",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     }
                 }
-            }
-        },
-        "/replications/resume": {
+            },
             "post": {
                 "security": [
                     {
-                        "BearerAuth": []
+                        "TokenAuth": []
                     }
                 ],
-                "description": "Resumes a paused replication job",
+                "description": "Compares the source and destination buckets object by object and stores the result.\nThe first page of every kind of mismatched keys is included when show_keys is set, further pages are served by /replications/compare/{id}/keys.",
                 "consumes": [
                     "application/json"
                 ],
@@ -520,23 +612,23 @@ const docTemplate = `{
                 "tags": [
                     "replications"
                 ],
-                "summary": "Resume a paused replication job",
+                "summary": "Compare the buckets of a replication",
                 "parameters": [
                     {
-                        "description": "Replication identifier",
-                        "name": "replication",
+                        "description": "Replication identifier and key paging",
+                        "name": "comparison",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/domain.ReplicationIdentifier"
+                            "$ref": "#/definitions/domain.CompareBucketRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Replication resumed successfully",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/domain.BucketComparisonResult"
                         }
                     },
                     "400": {
@@ -546,50 +638,61 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "502": {
                         "description": "Bad Gateway",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
             }
         },
-        "/replications/switch/zero-downtime": {
-            "post": {
-                "security": [
-                    {
-                        "TokenAuth": []
-                    }
-                ],
-                "description": "Switches main and follower buckets for a replication job without blocking writes",
-                "consumes": [
-                    "application/json"
-                ],
+        "/replications/compare/{id}": {
+            "get": {
+                "description": "Returns the counts of a stored bucket comparison",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "replications"
                 ],
-                "summary": "Switch main and follower buckets without downtime",
+                "summary": "Get a bucket comparison",
                 "parameters": [
                     {
-                        "description": "Replication identifier",
-                        "name": "replication",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/domain.ReplicationIdentifier"
-                        }
+                        "type": "string",
+                        "description": "Comparison ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Switch initiated successfully",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/domain.BucketComparison"
                         }
                     },
                     "400": {
@@ -599,8 +702,8 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
-                    "502": {
-                        "description": "Bad Gateway",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -609,43 +712,82 @@ const docTemplate = `{
                 }
             }
         },
-        "/storages": {
+        "/replications/compare/{id}/keys": {
             "get": {
-                "description": "Returns a list of all configured storage backends",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Returns a page of the keys missing in the source (miss_from) or destination (miss_to), differing keys (differ) or comparison errors (errors)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "storages"
+                    "replications"
+                ],
+                "summary": "List mismatched keys of a bucket comparison",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comparison ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "miss_from",
+                            "miss_to",
+                            "differ",
+                            "errors"
+                        ],
+                        "type": "string",
+                        "description": "Key kind",
+                        "name": "kind",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset of the first key",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "List all storages",
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ComparisonKeysPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "502": {
-                        "description": "Bad Gateway",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     }
                 }
-            },
+            }
+        },
+        "/replications/pause": {
             "post": {
                 "security": [
                     {
-                        "TokenAuth": []
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Creates a storage with simplified parameters. All other settings use default values.",
+                "description": "Pauses an active replication job",
                 "consumes": [
                     "application/json"
                 ],
@@ -653,23 +795,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "storages"
+                    "replications"
                 ],
-                "summary": "Create a storage configuration",
+                "summary": "Pause a replication job",
                 "parameters": [
                     {
-                        "description": "Storage creation request",
-                        "name": "request",
+                        "description": "Replication identifier",
+                        "name": "replication",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/domain.CreateStorageRequest"
+                            "$ref": "#/definitions/domain.ReplicationIdentifier"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Storage created successfully",
+                    "200": {
+                        "description": "Replication paused successfully",
                         "schema": {
                             "type": "string"
                         }
@@ -680,55 +822,86 @@ const docTemplate = `{
                             "type": "object",
                             "additionalProperties": true
                         }
-                    }
-                }
-            }
-        },
-        "/storages/db": {
-            "get": {
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "storages"
-                ],
-                "summary": "List storages from DB",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/domain.Storage"
-                            }
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     }
                 }
             }
         },
-        "/storages/{id}": {
-            "get": {
+        "/replications/resume": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Resumes a paused replication job",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "storages"
+                    "replications"
                 ],
-                "summary": "Get storage by ID",
+                "summary": "Resume a paused replication job",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Storage ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Replication identifier",
+                        "name": "replication",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.ReplicationIdentifier"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Replication resumed successfully",
                         "schema": {
-                            "$ref": "#/definitions/domain.Storage"
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "404": {
@@ -737,49 +910,175 @@ const docTemplate = `{
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
-            },
-            "put": {
-                "security": [
+            }
+        },
+        "/replications/stream": {
+            "get": {
+                "description": "Pushes replication changes as Server-Sent Events until the client disconnects.\nEvents are \"replication\" (created or changed), \"removed\" and \"error\", each carrying a domain.ReplicationEvent.\nFiltering on user, bucket, from and to selects a single replication which is streamed by the worker directly.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "replications"
+                ],
+                "summary": "Stream replication progress",
+                "parameters": [
                     {
-                        "TokenAuth": []
+                        "type": "string",
+                        "description": "Worker name",
+                        "name": "worker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bucket",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Source storage",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Destination storage",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
-                "description": "Updates an existing storage with new parameters",
-                "consumes": [
-                    "application/json"
-                ],
+                "responses": {
+                    "200": {
+                        "description": "Server-Sent Events stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/replications/switch": {
+            "get": {
+                "description": "Returns the status, downtime window and history of the switch of a replication",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "storages"
+                    "replications"
                 ],
-                "summary": "Update a storage configuration",
+                "summary": "Get bucket switch status",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Storage ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Worker name",
+                        "name": "worker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User",
+                        "name": "user",
+                        "in": "query",
                         "required": true
                     },
                     {
-                        "description": "Storage update request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/domain.CreateStorageRequest"
-                        }
+                        "type": "string",
+                        "description": "Bucket",
+                        "name": "bucket",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Source storage",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Destination storage",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Destination bucket",
+                        "name": "to_bucket",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Storage updated successfully",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/domain.BucketSwitch"
                         }
                     },
                     "400": {
@@ -795,220 +1094,3354 @@ const docTemplate = `{
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
             },
-            "delete": {
+            "post": {
                 "security": [
                     {
                         "TokenAuth": []
                     }
                 ],
-                "description": "Deletes a storage and all its credentials",
+                "description": "Schedules a switch which blocks bucket writes while it runs. Without a downtime window the switch starts right away.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "storages"
+                    "replications"
                 ],
-                "summary": "Delete a storage configuration",
+                "summary": "Switch main and follower buckets with a downtime window",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Storage ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Replication identifier and downtime window",
+                        "name": "switch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.SwitchBucketRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Storage deleted successfully",
+                    "202": {
+                        "description": "Switch scheduled successfully",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Aborts a pending or running switch and removes its metadata. Bucket writes are unblocked and the old main stays main.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replications"
+                ],
+                "summary": "Abort and delete a bucket switch",
+                "parameters": [
+                    {
+                        "description": "Replication identifier",
+                        "name": "replication",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.ReplicationIdentifier"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Switch deleted successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/replications/switch/zero-downtime": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Switches main and follower buckets for a replication job without blocking writes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replications"
+                ],
+                "summary": "Switch main and follower buckets without downtime",
+                "parameters": [
+                    {
+                        "description": "Replication identifier",
+                        "name": "replication",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.ReplicationIdentifier"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Switch initiated successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/replications/switches": {
+            "get": {
+                "description": "Returns the switches of all workers with their status",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replications"
+                ],
+                "summary": "List bucket switches",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.BucketSwitch"
+                            }
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/replications/users": {
+            "get": {
+                "description": "Returns the bucket replications grouped by user, source and destination storage.\nall_buckets is set for replications created for all buckets of a user.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replications"
+                ],
+                "summary": "List replications of users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Worker name",
+                        "name": "worker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Source storage",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Destination storage",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.UserReplication"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Deletes the user replication and every bucket replication of a user between two storages.\nWorkers without the user replication are handled bucket by bucket, responds with 207 when some buckets failed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replications"
+                ],
+                "summary": "Delete all replications of a user",
+                "parameters": [
+                    {
+                        "description": "User replication identifier",
+                        "name": "replication",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserReplicationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserReplicationReport"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserReplicationReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/replications/users/pause": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Pauses every bucket replication of a user between two storages and reports the outcome per bucket.\nResponds with 207 when some buckets failed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replications"
+                ],
+                "summary": "Pause all replications of a user",
+                "parameters": [
+                    {
+                        "description": "User replication identifier",
+                        "name": "replication",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserReplicationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserReplicationReport"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserReplicationReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/replications/users/resume": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Resumes every paused bucket replication of a user between two storages and reports the outcome per bucket.\nResponds with 207 when some buckets failed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replications"
+                ],
+                "summary": "Resume all replications of a user",
+                "parameters": [
+                    {
+                        "description": "User replication identifier",
+                        "name": "replication",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserReplicationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserReplicationReport"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/domain.UserReplicationReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages": {
+            "get": {
+                "description": "Returns the storage backends configured on every registered worker.\nWorkers which cannot be reached are listed with their error in unreachable_workers, their storages are missing.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "List all storages",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Creates a storage. Omitted settings use default values: health check every 5s, HTTP timeout 5m, no rate limit.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Create a storage configuration",
+                "parameters": [
+                    {
+                        "description": "Storage creation request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.CreateStorageRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Check the S3 endpoint and credentials before saving",
+                        "name": "validate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Demote the current main storage when this storage becomes main",
+                        "name": "replace_main",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Storage created successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/db": {
+            "get": {
+                "description": "Secrets are masked and identified by their fingerprint, use the reveal endpoint to read them.\nStorages are listed in name order. With a limit, the X-Next-Cursor header holds the cursor of the next page when more storages match.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "List storages from DB",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Label selector, e.g. env=prod,region!=eu,tier,!legacy",
+                        "name": "selector",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Provider, case-insensitive",
+                        "name": "provider",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name prefix",
+                        "name": "name_prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, at most 1000, all matching storages when 0",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of the page, from X-Next-Cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.Storage"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/drift": {
+            "get": {
+                "description": "Reports storages missing on either side and storages with a different address, provider or main flag.\nUnreachable workers are listed separately and not compared.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Compare stored storages with the workers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StorageDriftReport"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/drift/events": {
+            "get": {
+                "description": "Returns drift events recorded by the periodic drift check, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "List storage drift events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Worker name",
+                        "name": "worker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Storage name",
+                        "name": "storage",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only unresolved events",
+                        "name": "open",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of events (default 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.StorageDriftEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/export": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Renders the stored storages with decrypted credentials as the storage section of the Chorus worker config.\nThe output is deterministic, the same storages always produce the same file.\nRequires a system token, every export is recorded in the audit log.",
+                "produces": [
+                    "application/x-yaml"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Export storages as worker configuration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Fallback region of the worker",
+                        "name": "default_region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Create routing rules to the main storage (default true)",
+                        "name": "create_routing",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Create replication rules from the main storage",
+                        "name": "create_replication",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Export only the storages whose labels match, e.g. env=prod",
+                        "name": "selector",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Worker storage config",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/import": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Creates or updates the storages of a Chorus worker config, matched by name. The body is the whole config or its storage section.\nStorages and users missing from the file are kept. Every user is stored, user selects the storage's own user of new storages with several users.\nA dry run reports the changes without saving them.",
+                "consumes": [
+                    "application/x-yaml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Import storages from worker configuration",
+                "parameters": [
+                    {
+                        "description": "Worker config YAML",
+                        "name": "config",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Report the changes without saving",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Own user of storages with several users",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Demote a main storage missing from the file",
+                        "name": "replace_main",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StorageImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/providers": {
+            "get": {
+                "description": "Returns the providers a storage can use with their defaults and rules: addressing style, whether https\nand a region are required, the region format and the endpoint used when a storage has no address.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "List storage providers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/providers.Provider"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/rotate-keys": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Re-encrypts every storage secret which is not encrypted with the active key, in batches of one transaction each.\nA failed rotation reports the last committed storage, pass it as after to resume. A dry run only counts the secrets per key.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Re-encrypt storage secrets with the active key",
+                "parameters": [
+                    {
+                        "description": "Rotation options",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/domain.RotateKeysRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.KeyRotationReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/{id}": {
+            "get": {
+                "description": "The secret is masked and identified by its fingerprint",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Get storage by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Storage"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Replaces the name, address, provider and credentials of a storage. Omitted settings keep their current value.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Replace a storage configuration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Storage update request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.CreateStorageRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Check the S3 endpoint and credentials before saving",
+                        "name": "validate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Demote the current main storage when this storage becomes main",
+                        "name": "replace_main",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Storage updated successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Deletes a storage and all its credentials.\nStorages used by replication jobs are only deleted with force, which deletes the jobs as well; otherwise 409 lists the jobs.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Delete a storage configuration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Delete the replication jobs using the storage as well",
+                        "name": "force",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Storage deleted successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Changes only the fields present in the request",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Partially update a storage configuration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.UpdateStorageRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Check the S3 endpoint and credentials before saving",
+                        "name": "validate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Demote the current main storage when this storage becomes main",
+                        "name": "replace_main",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Storage updated successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/{id}/credentials": {
+            "get": {
+                "description": "Lists the storage's own user, marked primary, then its other users. Secrets are masked and identified by their fingerprint.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "List the users of a storage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.StorageCredential"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Replications of the user need it on both storages",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Add a user to a storage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User and keys",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.CreateStorageCredentialRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StorageCredential"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/{id}/credentials/{user}": {
+            "put": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Works for the storage's own user as well. A masked secret keeps the stored one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Replace the keys of a storage user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User",
+                        "name": "user",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New keys",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.UpdateStorageCredentialRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Storage user updated successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "The storage's own user cannot be removed. Users with replication jobs on the storage are refused with 409 listing the jobs.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Remove a user from a storage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User",
+                        "name": "user",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Storage user deleted successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/{id}/health": {
+            "get": {
+                "description": "Returns the state of the storage's S3 endpoint as probed by the health monitor at the storage's\nhealth check interval, the uptime percentage in a window and the recent probes, newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Get a storage's health",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Uptime window, e.g. 1h (default 24h, at most 720h)",
+                        "name": "window",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of probes (default 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only list the probes which changed the state",
+                        "name": "changes",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StorageHealth"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/{id}/promote": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Makes the storage the main storage and demotes the current main in one transaction.\nBuckets of any user of the current main which are not replicated to the storage block the promotion with 409 unless force is set.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Promote a storage to main",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Promotion options",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/domain.PromoteStorageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.PromotionReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/domain.PromotionReport"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/{id}/reveal": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Returns the plain-text secret access key. Requires a system token, every reveal is recorded in the audit log.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Reveal the secret of a storage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StorageSecret"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/storages/{id}/test": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Connects to the S3 endpoint with the stored credentials and checks connectivity, signature version,\nregion, authentication and bucket listing. Failed checks are reported with status 200.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storages"
+                ],
+                "summary": "Test a storage's S3 endpoint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Storage ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StorageTestReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/workers": {
+            "get": {
+                "description": "Returns the default worker and all registered workers",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workers"
+                ],
+                "summary": "List workers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.Worker"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Registers a Chorus worker the controller can manage. The names default and status are reserved.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workers"
+                ],
+                "summary": "Register a worker",
+                "parameters": [
+                    {
+                        "description": "Worker registration request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.CreateWorkerRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Worker"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/workers/status": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Returns the address, connection state and circuit breaker of every worker",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workers"
+                ],
+                "summary": "Worker connection status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.WorkerStatus"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/workers/{name}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workers"
+                ],
+                "summary": "Get worker by name",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Worker name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Worker"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Updates a registered worker and reconnects with the new settings",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workers"
+                ],
+                "summary": "Update a worker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Worker name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Worker update request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.CreateWorkerRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Worker updated successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Removes a registered worker that does not own replication jobs",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workers"
+                ],
+                "summary": "Delete a worker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Worker name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Worker deleted successfully",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "domain.BreakerState": {
+            "type": "object",
+            "properties": {
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "opened_at": {
+                    "type": "string"
+                },
+                "retry_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string",
+                    "example": "closed"
+                }
+            }
+        },
+        "domain.BucketComparison": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "differ_count": {
+                    "type": "integer"
+                },
+                "error_count": {
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "is_match": {
+                    "type": "boolean"
+                },
+                "match_count": {
+                    "type": "integer"
+                },
+                "miss_from_count": {
+                    "type": "integer"
+                },
+                "miss_to_count": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "to_bucket": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                },
+                "worker": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.BucketComparisonResult": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "differ_count": {
+                    "type": "integer"
+                },
+                "error_count": {
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "is_match": {
+                    "type": "boolean"
+                },
+                "keys": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/domain.ComparisonKeysPage"
+                    }
+                },
+                "match_count": {
+                    "type": "integer"
+                },
+                "miss_from_count": {
+                    "type": "integer"
+                },
+                "miss_to_count": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "to_bucket": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                },
+                "worker": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.BucketOperationResult": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "done"
+                },
+                "to_bucket": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.BucketSwitch": {
+            "type": "object",
+            "properties": {
+                "done_at": {
+                    "type": "string"
+                },
+                "downtime": {
+                    "$ref": "#/definitions/domain.SwitchDowntimeWindow"
+                },
+                "history": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "last_started_at": {
+                    "type": "string"
+                },
+                "multipart_ttl": {
+                    "type": "string",
+                    "example": "1h0m0s"
+                },
+                "replication": {
+                    "$ref": "#/definitions/domain.ReplicationIdentifier"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "in_progress"
+                },
+                "zero_downtime": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "domain.CompareBucketRequest": {
+            "type": "object",
+            "required": [
+                "bucket",
+                "from",
+                "to",
+                "user"
+            ],
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer",
+                    "maximum": 10000,
+                    "minimum": 0,
+                    "example": 100
+                },
+                "show_keys": {
+                    "type": "boolean"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "to_bucket": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                },
+                "worker": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.ComparisonKeysPage": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "miss_to"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.CreateReplicationRequest": {
+            "type": "object",
+            "required": [
+                "from",
+                "to",
+                "user"
+            ],
+            "properties": {
+                "agent_url": {
+                    "type": "string"
+                },
+                "buckets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "to_bucket": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                },
+                "worker": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.CreateStorageCredentialRequest": {
+            "type": "object",
+            "required": [
+                "access_key",
+                "secret_key",
+                "user"
+            ],
+            "properties": {
+                "access_key": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "AKIA456"
+                },
+                "secret_key": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "SECRET456"
+                },
+                "user": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "backup"
+                }
+            }
+        },
+        "domain.CreateStorageRequest": {
+            "type": "object",
+            "required": [
+                "access_key",
+                "name",
+                "provider",
+                "secret_key",
+                "user"
+            ],
+            "properties": {
+                "access_key": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "AKIA123"
+                },
+                "address": {
+                    "type": "string",
+                    "maxLength": 1024,
+                    "example": "http://localhost:9000"
+                },
+                "default_region": {
+                    "type": "string",
+                    "maxLength": 128,
+                    "example": "us-east-1"
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 500
+                },
+                "health_check_interval_ms": {
+                    "type": "integer",
+                    "maximum": 86400000,
+                    "minimum": 1000,
+                    "example": 5000
+                },
+                "http_timeout_ms": {
+                    "type": "integer",
+                    "maximum": 3600000,
+                    "minimum": 1000,
+                    "example": 300000
+                },
+                "is_main": {
+                    "type": "boolean"
+                },
+                "is_secure": {
+                    "type": "boolean"
+                },
+                "labels": {
+                    "description": "Labels replace the labels of the storage, checked by labels.Validate",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "example": {
+                        "env": "prod",
+                        "region": "us"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "my-storage"
+                },
+                "provider": {
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "minio"
+                },
+                "rate_limit_enabled": {
+                    "type": "boolean"
+                },
+                "rate_limit_rpm": {
+                    "type": "integer",
+                    "maximum": 10000000,
+                    "minimum": 0,
+                    "example": 600
+                },
+                "secret_key": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "SECRET123"
+                },
+                "user": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "myuser"
+                }
+            }
+        },
+        "domain.CreateWorkerRequest": {
+            "type": "object",
+            "required": [
+                "address",
+                "name"
+            ],
+            "properties": {
+                "address": {
+                    "type": "string",
+                    "example": "chorus-worker-eu:9670"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "eu-west"
+                },
+                "tls_ca_file": {
+                    "type": "string",
+                    "example": "/etc/chorus/tls/ca.pem"
+                },
+                "tls_cert_file": {
+                    "type": "string"
+                },
+                "tls_enabled": {
+                    "type": "boolean"
+                },
+                "tls_key_file": {
+                    "type": "string"
+                },
+                "tls_server_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.KeyRotationReport": {
+            "type": "object",
+            "properties": {
+                "active_key": {
+                    "type": "string"
+                },
+                "batches": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "formats": {
+                    "description": "Formats counts the secrets found per ciphertext format before they were rotated, older formats than v2 are upgraded",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "keys": {
+                    "description": "Keys counts the secrets found per key ID before they were rotated",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "last_id": {
+                    "description": "LastID is the last storage of the last committed batch, pass it as after to resume",
+                    "type": "string"
+                },
+                "rotated": {
+                    "description": "Rotated counts the secrets re-encrypted, or to be re-encrypted on a dry run",
+                    "type": "integer"
+                },
+                "scanned": {
+                    "description": "Scanned counts the storages, the other counters include the secrets of their credentials",
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.PromoteStorageRequest": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "description": "DryRun only reports the bucket coverage",
+                    "type": "boolean"
+                },
+                "force": {
+                    "description": "Force promotes even when buckets of the current main are not replicated to the storage",
+                    "type": "boolean"
+                },
+                "worker": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.PromotionReport": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "previous_main": {
+                    "type": "string"
+                },
+                "promoted": {
+                    "type": "boolean"
+                },
+                "storage": {
+                    "type": "string"
+                },
+                "users": {
+                    "description": "Users lists the coverage of every user of the previous main, its own user first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.UserCoverage"
+                    }
+                },
+                "worker": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.ReplicationIdentifier": {
+            "type": "object",
+            "required": [
+                "bucket",
+                "from",
+                "to",
+                "user"
+            ],
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "to_bucket": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                },
+                "worker": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.RotateKeysRequest": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "type": "string",
+                    "example": "7f1d3a52-3c1e-4a45-a0f2-6a7b3a0c2d11"
+                },
+                "batch_size": {
+                    "type": "integer",
+                    "maximum": 1000,
+                    "minimum": 1,
+                    "example": 100
+                },
+                "dry_run": {
+                    "description": "DryRun only counts the secrets per key",
+                    "type": "boolean"
+                }
+            }
+        },
+        "domain.Storage": {
+            "type": "object",
+            "properties": {
+                "access_key_id": {
+                    "type": "string"
+                },
+                "address": {
+                    "type": "string"
+                },
+                "default_region": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "health_check_interval_ms": {
+                    "type": "integer"
+                },
+                "http_timeout_ms": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "is_main": {
+                    "type": "boolean"
+                },
+                "is_secure": {
+                    "type": "boolean"
+                },
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "rate_limit_enabled": {
+                    "type": "boolean"
+                },
+                "rate_limit_rpm": {
+                    "type": "integer"
+                },
+                "secret_access_key": {
+                    "type": "string"
+                },
+                "secret_fingerprint": {
+                    "description": "SecretFingerprint identifies the secret on read endpoints, where the secret itself is masked",
+                    "type": "string",
+                    "example": "sha256:5e884898da280471"
+                },
+                "user": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.StorageCheck": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "InvalidAccessKeyId"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "authentication"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "domain.StorageCredential": {
+            "type": "object",
+            "properties": {
+                "access_key_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "primary": {
+                    "description": "Primary marks the storage's own user in listings, it is changed through the storage itself",
+                    "type": "boolean"
+                },
+                "secret_access_key": {
+                    "type": "string"
+                },
+                "secret_fingerprint": {
+                    "type": "string",
+                    "example": "sha256:5e884898da280471"
+                },
+                "storage_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.StorageDrift": {
+            "type": "object",
+            "properties": {
+                "db_value": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "address"
+                },
+                "storage": {
+                    "type": "string"
+                },
+                "worker": {
+                    "type": "string"
+                },
+                "worker_value": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.StorageDriftEvent": {
+            "type": "object",
+            "properties": {
+                "db_value": {
+                    "type": "string"
+                },
+                "detected_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "resolved_at": {
+                    "type": "string"
+                },
+                "storage": {
+                    "type": "string"
+                },
+                "worker": {
+                    "type": "string"
+                },
+                "worker_value": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.StorageDriftReport": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "drifts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.StorageDrift"
+                    }
+                },
+                "in_sync": {
+                    "type": "boolean"
+                },
+                "unreachable_workers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "workers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "domain.StorageHealth": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "integer"
+                },
+                "history": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.StorageHealthCheck"
+                    }
+                },
+                "last_checked_at": {
+                    "type": "string"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "up"
+                },
+                "storage": {
+                    "type": "string"
+                },
+                "storage_id": {
+                    "type": "string"
+                },
+                "uptime_percent": {
+                    "type": "number",
+                    "example": 99.5
+                },
+                "window": {
+                    "type": "string",
+                    "example": "24h0m0s"
+                }
+            }
+        },
+        "domain.StorageHealthCheck": {
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "type": "boolean"
+                },
+                "checked_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "integer"
+                },
+                "storage_id": {
+                    "type": "string"
+                },
+                "up": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "domain.StorageImportEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "updated"
+                },
+                "changed": {
+                    "description": "Changed lists the fields an update changes",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "address",
+                        "secret_access_key"
+                    ]
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.StorageImportReport": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "storages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.StorageImportEntry"
+                    }
+                },
+                "unchanged": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.StorageSecret": {
+            "type": "object",
+            "properties": {
+                "access_key_id": {
+                    "type": "string"
+                },
+                "secret_access_key": {
+                    "type": "string"
+                },
+                "secret_fingerprint": {
+                    "type": "string"
+                },
+                "storage": {
+                    "type": "string"
+                },
+                "storage_id": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.StorageTestReport": {
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "type": "integer"
+                },
+                "checked_at": {
+                    "type": "string"
+                },
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.StorageCheck"
+                    }
+                },
+                "endpoint": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "integer"
+                },
+                "ok": {
+                    "type": "boolean"
+                },
+                "region": {
+                    "type": "string"
+                },
+                "storage": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.SwitchBucketRequest": {
+            "type": "object",
+            "required": [
+                "bucket",
+                "from",
+                "to",
+                "user"
+            ],
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "downtime": {
+                    "$ref": "#/definitions/domain.SwitchDowntimeWindow"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "to_bucket": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                },
+                "worker": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.SwitchDowntimeWindow": {
+            "type": "object",
+            "properties": {
+                "continue_replication": {
+                    "description": "ContinueReplication replicates writes from the new main back to the old main bucket",
+                    "type": "boolean"
+                },
+                "cron": {
+                    "description": "Cron retries the switch on the schedule until it is done, exclusive with StartAt",
+                    "type": "string",
+                    "example": "0 2 * * *"
+                },
+                "max_duration": {
+                    "description": "MaxDuration aborts the switch when it takes longer, e.g. \"30m\"",
+                    "type": "string",
+                    "example": "30m"
+                },
+                "max_event_lag": {
+                    "description": "MaxEventLag skips the switch when more events are waiting to be replicated",
+                    "type": "integer"
+                },
+                "skip_bucket_check": {
+                    "description": "SkipBucketCheck skips comparing bucket contents before completing the switch",
+                    "type": "boolean"
+                },
+                "start_at": {
+                    "description": "StartAt runs the switch at the given time, exclusive with Cron",
+                    "type": "string",
+                    "example": "2024-12-31T02:00:00Z"
+                },
+                "start_on_init_done": {
+                    "description": "StartOnInitDone starts the switch once the initial replication is done",
+                    "type": "boolean"
+                }
+            }
+        },
+        "domain.TokenInfoWithValue": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "is_active": {
+                    "type": "boolean"
+                },
+                "is_system": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
             }
-        }
-    },
-    "definitions": {
-        "domain.CreateReplicationRequest": {
+        },
+        "domain.TokenRequest": {
             "type": "object",
             "required": [
-                "from",
-                "to",
-                "user"
+                "name"
             ],
             "properties": {
-                "agent_url": {
-                    "type": "string"
+                "description": {
+                    "type": "string",
+                    "example": "Token for API access"
                 },
-                "buckets": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "expires_at": {
+                    "type": "string",
+                    "example": "2024-12-31T23:59:59Z"
                 },
-                "from": {
+                "name": {
+                    "type": "string",
+                    "example": "api-client"
+                }
+            }
+        },
+        "domain.TokenResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
                     "type": "string"
                 },
-                "to": {
+                "expires_at": {
                     "type": "string"
                 },
-                "to_bucket": {
+                "name": {
                     "type": "string"
                 },
-                "user": {
+                "token": {
                     "type": "string"
                 }
             }
         },
-        "domain.CreateStorageRequest": {
+        "domain.UpdateStorageCredentialRequest": {
             "type": "object",
             "required": [
                 "access_key",
-                "address",
-                "name",
-                "provider",
-                "secret_key",
-                "user"
+                "secret_key"
             ],
             "properties": {
                 "access_key": {
                     "type": "string",
+                    "maxLength": 255,
+                    "example": "AKIA456"
+                },
+                "secret_key": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "SECRET456"
+                }
+            }
+        },
+        "domain.UpdateStorageRequest": {
+            "type": "object",
+            "properties": {
+                "access_key": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "minLength": 1,
                     "example": "AKIA123"
                 },
                 "address": {
                     "type": "string",
+                    "maxLength": 1024,
+                    "minLength": 1,
                     "example": "http://localhost:9000"
                 },
+                "default_region": {
+                    "type": "string",
+                    "maxLength": 128,
+                    "example": "us-east-1"
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 500
+                },
+                "health_check_interval_ms": {
+                    "type": "integer",
+                    "maximum": 86400000,
+                    "minimum": 1000,
+                    "example": 5000
+                },
+                "http_timeout_ms": {
+                    "type": "integer",
+                    "maximum": 3600000,
+                    "minimum": 1000,
+                    "example": 300000
+                },
+                "is_main": {
+                    "type": "boolean"
+                },
+                "is_secure": {
+                    "type": "boolean"
+                },
+                "labels": {
+                    "description": "Labels replace the labels of the storage, checked by labels.Validate",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "example": {
+                        "env": "prod",
+                        "region": "us"
+                    }
+                },
                 "name": {
                     "type": "string",
+                    "maxLength": 255,
+                    "minLength": 1,
                     "example": "my-storage"
                 },
                 "provider": {
                     "type": "string",
+                    "maxLength": 64,
+                    "minLength": 1,
                     "example": "minio"
                 },
+                "rate_limit_enabled": {
+                    "type": "boolean"
+                },
+                "rate_limit_rpm": {
+                    "type": "integer",
+                    "maximum": 10000000,
+                    "minimum": 0,
+                    "example": 600
+                },
                 "secret_key": {
                     "type": "string",
+                    "maxLength": 255,
+                    "minLength": 1,
                     "example": "SECRET123"
                 },
                 "user": {
                     "type": "string",
+                    "maxLength": 255,
+                    "minLength": 1,
                     "example": "myuser"
                 }
             }
         },
-        "domain.ReplicationIdentifier": {
+        "domain.UserCoverage": {
             "type": "object",
-            "required": [
-                "bucket",
-                "from",
-                "to",
-                "user"
-            ],
             "properties": {
-                "bucket": {
+                "covered": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "uncovered": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user": {
                     "type": "string"
+                }
+            }
+        },
+        "domain.UserReplication": {
+            "type": "object",
+            "properties": {
+                "all_buckets": {
+                    "type": "boolean"
                 },
                 "from": {
                     "type": "string"
                 },
+                "replications": {
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
+                },
                 "to": {
                     "type": "string"
                 },
-                "to_bucket": {
+                "user": {
                     "type": "string"
                 },
-                "user": {
+                "worker": {
                     "type": "string"
                 }
             }
         },
-        "domain.Storage": {
+        "domain.UserReplicationReport": {
             "type": "object",
             "properties": {
-                "access_key_id": {
-                    "type": "string"
-                },
-                "address": {
-                    "type": "string"
-                },
-                "default_region": {
-                    "type": "string"
+                "action": {
+                    "type": "string",
+                    "example": "pause"
                 },
-                "description": {
-                    "type": "string"
+                "buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.BucketOperationResult"
+                    }
                 },
-                "health_check_interval_ms": {
+                "done": {
                     "type": "integer"
                 },
-                "http_timeout_ms": {
+                "failed": {
                     "type": "integer"
                 },
-                "id": {
+                "from": {
                     "type": "string"
                 },
-                "is_main": {
-                    "type": "boolean"
-                },
-                "is_secure": {
-                    "type": "boolean"
+                "skipped": {
+                    "type": "integer"
                 },
-                "name": {
+                "to": {
                     "type": "string"
                 },
-                "provider": {
+                "user": {
                     "type": "string"
                 },
-                "rate_limit_enabled": {
+                "user_level": {
                     "type": "boolean"
                 },
-                "rate_limit_rpm": {
-                    "type": "integer"
+                "worker": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.UserReplicationRequest": {
+            "type": "object",
+            "required": [
+                "from",
+                "to",
+                "user"
+            ],
+            "properties": {
+                "from": {
+                    "type": "string"
                 },
-                "secret_access_key": {
+                "to": {
                     "type": "string"
                 },
                 "user": {
                     "type": "string"
+                },
+                "worker": {
+                    "type": "string"
                 }
             }
         },
-        "domain.TokenInfoWithValue": {
+        "domain.Worker": {
             "type": "object",
             "properties": {
+                "address": {
+                    "type": "string"
+                },
                 "created_at": {
                     "type": "string"
                 },
                 "description": {
                     "type": "string"
                 },
-                "expires_at": {
+                "id": {
                     "type": "string"
                 },
-                "id": {
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
                     "type": "string"
                 },
-                "is_active": {
-                    "type": "boolean"
+                "tls_ca_file": {
+                    "type": "string"
                 },
-                "is_system": {
+                "tls_cert_file": {
+                    "type": "string"
+                },
+                "tls_enabled": {
                     "type": "boolean"
                 },
-                "name": {
+                "tls_key_file": {
                     "type": "string"
                 },
-                "token": {
+                "tls_server_name": {
                     "type": "string"
                 },
                 "updated_at": {
@@ -1016,40 +4449,69 @@ const docTemplate = `{
                 }
             }
         },
-        "domain.TokenRequest": {
+        "domain.WorkerStatus": {
             "type": "object",
-            "required": [
-                "name"
-            ],
             "properties": {
-                "description": {
-                    "type": "string",
-                    "example": "Token for API access"
+                "address": {
+                    "type": "string"
                 },
-                "expires_at": {
-                    "type": "string",
-                    "example": "2024-12-31T23:59:59Z"
+                "breaker": {
+                    "$ref": "#/definitions/domain.BreakerState"
+                },
+                "builtin": {
+                    "type": "boolean"
                 },
                 "name": {
-                    "type": "string",
-                    "example": "api-client"
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
                 }
             }
         },
-        "domain.TokenResponse": {
+        "providers.Provider": {
             "type": "object",
             "properties": {
-                "created_at": {
+                "address_template": {
+                    "type": "string",
+                    "example": "https://s3.{region}.amazonaws.com"
+                },
+                "addressing": {
+                    "type": "string",
+                    "example": "virtual-host"
+                },
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "default_region": {
                     "type": "string"
                 },
-                "expires_at": {
+                "description": {
                     "type": "string"
                 },
+                "example_region": {
+                    "type": "string",
+                    "example": "eu-central-1"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "aws"
+                },
                 "name": {
-                    "type": "string"
+                    "type": "string",
+                    "example": "AWS"
                 },
-                "token": {
+                "region_pattern": {
                     "type": "string"
+                },
+                "requires_region": {
+                    "type": "boolean"
+                },
+                "secure_only": {
+                    "type": "boolean"
                 }
             }
         }
@@ -1,121 +1,373 @@
 package crypto
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hkdf"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 )
 
-// Crypto handles encryption and decryption operations
+// DefaultKeyID names the key configured with ENCRYPTION_KEY.
+// Ciphertexts written before key IDs existed carry no prefix and are decrypted with it.
+const DefaultKeyID = "default"
+
+// keyIDPattern restricts key IDs to characters which never appear in base64 and are safe in env variables
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Config selects the encryption keys, every key ID is configured with exactly one provider
+type Config struct {
+	// Key is the passphrase used before key IDs existed, registered as DefaultKeyID
+	Key string
+	// Keys maps key IDs to passphrases
+	Keys map[string]string
+	// KeyFiles maps key IDs to files holding a key-encryption key
+	KeyFiles map[string]string
+	// VaultKeys maps key IDs to the names of Vault transit keys
+	VaultKeys      map[string]string
+	VaultAddress   string
+	VaultToken     string
+	VaultMount     string
+	VaultNamespace string
+	// ActiveKey is the ID of the key new secrets are encrypted with
+	ActiveKey string
+}
+
+// Crypto handles encryption and decryption operations with envelope encryption.
+// Every secret is encrypted with a data key, which is wrapped by the key provider of the active key ID.
+//
+// Ciphertexts are "<key id>:v2.<wrapped data key>.<salt>.<sealed secret>", all parts in base64.
+// The secret is sealed with a key derived from the data key and the salt with HKDF,
+// and bound to the place it is stored in through the associated data of the AEAD.
+// Older formats are still read, see Version.
 type Crypto struct {
-	key []byte
+	providers map[string]KeyProvider
+	active    string
+
+	mu sync.Mutex
+	// current is the data key of the active key ID used for new secrets
+	current *dataKey
+	// unwrapped caches data keys by key ID and wrapped key, unwrapping may be a call to Vault
+	unwrapped map[string][]byte
+}
+
+// Ciphertext format versions
+const (
+	// VersionDirect is encrypted with the passphrase key itself, with or without a key ID prefix
+	VersionDirect = 0
+	// VersionEnvelope is "<key id>:<wrapped data key>.<sealed secret>", without associated data
+	VersionEnvelope = 1
+	// VersionBound is the current format, bound to its storage location
+	VersionBound = 2
+)
+
+// hkdfInfo separates the keys derived for secrets from other uses of the data key
+const hkdfInfo = "chorus-controller secret v2"
+
+type dataKey struct {
+	key     []byte
+	wrapped []byte
 }
 
-// New creates a new Crypto instance with the given key
+// New creates a new Crypto instance with the given passphrase
 func New(key string) (*Crypto, error) {
-	// Hash the key to get a 32-byte key for AES-256
-	hasher := sha256.New()
-	hasher.Write([]byte(key))
-	hashedKey := hasher.Sum(nil)
+	return NewFromConfig(Config{Key: key})
+}
+
+// NewFromConfig creates a Crypto instance with the providers of the config, new secrets are encrypted with the active key
+func NewFromConfig(cfg Config) (*Crypto, error) {
+	providers := make(map[string]KeyProvider)
+	add := func(id string, provider func() (KeyProvider, error)) error {
+		if !keyIDPattern.MatchString(id) {
+			return fmt.Errorf("invalid key ID %q: use up to 32 letters, digits, '-' or '_'", id)
+		}
+		if _, ok := providers[id]; ok {
+			return fmt.Errorf("key %q is configured twice", id)
+		}
+		p, err := provider()
+		if err != nil {
+			return err
+		}
+		providers[id] = p
+		return nil
+	}
+
+	if cfg.Key != "" {
+		if err := add(DefaultKeyID, func() (KeyProvider, error) { return NewPassphraseProvider(cfg.Key), nil }); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range sortedKeys(cfg.Keys) {
+		passphrase := cfg.Keys[id]
+		err := add(id, func() (KeyProvider, error) {
+			if passphrase == "" {
+				return nil, fmt.Errorf("key %q is empty", id)
+			}
+			return NewPassphraseProvider(passphrase), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range sortedKeys(cfg.KeyFiles) {
+		path := cfg.KeyFiles[id]
+		if err := add(id, func() (KeyProvider, error) { return NewKeyfileProvider(path) }); err != nil {
+			return nil, err
+		}
+	}
+	vault := VaultConfig{Address: cfg.VaultAddress, Token: cfg.VaultToken, Mount: cfg.VaultMount, Namespace: cfg.VaultNamespace}
+	for _, id := range sortedKeys(cfg.VaultKeys) {
+		name := cfg.VaultKeys[id]
+		if err := add(id, func() (KeyProvider, error) { return NewVaultTransitProvider(vault, name, nil) }); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewWithProviders(providers, cfg.ActiveKey)
+}
+
+// NewWithProviders creates a Crypto instance with a provider per key ID.
+// The active key may be empty when a single key is configured.
+func NewWithProviders(providers map[string]KeyProvider, active string) (*Crypto, error) {
+	c := &Crypto{providers: providers, active: active, unwrapped: make(map[string][]byte)}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no encryption key configured")
+	}
+	if c.active == "" {
+		if len(providers) > 1 {
+			return nil, fmt.Errorf("several keys configured, the active key must be set: %s", strings.Join(c.KeyIDs(), ", "))
+		}
+		for id := range providers {
+			c.active = id
+		}
+	}
+	if _, ok := providers[c.active]; !ok {
+		return nil, fmt.Errorf("active key %q is not configured", c.active)
+	}
+	return c, nil
+}
+
+// deriveKey hashes the key to get a 32-byte key for AES-256
+func deriveKey(key string) []byte {
+	hashedKey := sha256.Sum256([]byte(key))
+	return hashedKey[:]
+}
 
-	return &Crypto{
-		key: hashedKey,
-	}, nil
+// ActiveKeyID returns the ID of the key new secrets are encrypted with
+func (c *Crypto) ActiveKeyID() string {
+	return c.active
 }
 
-// Encrypt encrypts the given plaintext using AES-256-GCM
-func (c *Crypto) Encrypt(plaintext string) (string, error) {
+// KeyIDs returns the IDs of all configured keys, sorted
+func (c *Crypto) KeyIDs() []string {
+	return sortedKeys(c.providers)
+}
+
+// KeyID returns the ID of the key a ciphertext was encrypted with
+func KeyID(ciphertext string) string {
+	if id, _, ok := strings.Cut(ciphertext, ":"); ok {
+		return id
+	}
+	return DefaultKeyID
+}
+
+// Version returns the format version of a ciphertext
+func Version(ciphertext string) int {
+	_, body, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		return VersionDirect
+	}
+	switch {
+	case strings.HasPrefix(body, "v2."):
+		return VersionBound
+	case strings.Contains(body, "."):
+		return VersionEnvelope
+	default:
+		return VersionDirect
+	}
+}
+
+// AAD returns the associated data binding a ciphertext to a column of a table row
+func AAD(table, id, column string) []byte {
+	return []byte(table + "\x00" + id + "\x00" + column)
+}
+
+// NeedsRotation reports whether a ciphertext is not encrypted with the active key in the current format
+func (c *Crypto) NeedsRotation(ciphertext string) bool {
+	if ciphertext == "" {
+		return false
+	}
+	return KeyID(ciphertext) != c.active || Version(ciphertext) != VersionBound
+}
+
+// Encrypt encrypts the given plaintext using AES-256-GCM with a data key of the active key.
+// aad binds the ciphertext to where it is stored, see AAD, Decrypt fails with any other aad.
+func (c *Crypto) Encrypt(ctx context.Context, plaintext string, aad []byte) (string, error) {
 	if plaintext == "" {
 		return "", nil
 	}
 
-	// Create a new cipher block
-	block, err := aes.NewCipher(c.key)
+	dk, err := c.dataKey(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		return "", err
 	}
-
-	// Create a new GCM mode
-	gcm, err := cipher.NewGCM(block)
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := hkdf.Key(sha256.New, dk.key, salt, hkdfInfo, 32)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return "", fmt.Errorf("failed to derive key: %w", err)
 	}
-
-	// Create a random nonce
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	ciphertext, err := seal(key, []byte(plaintext), aad)
+	if err != nil {
+		return "", err
 	}
 
-	// Encrypt the plaintext
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-
 	// Encode to base64
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return c.active + ":v2." + strings.Join([]string{
+		base64.StdEncoding.EncodeToString(dk.wrapped),
+		base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(ciphertext),
+	}, "."), nil
 }
 
-// Decrypt decrypts the given ciphertext using AES-256-GCM
-func (c *Crypto) Decrypt(ciphertext string) (string, error) {
+// Decrypt decrypts the given ciphertext using AES-256-GCM with the key named by its prefix.
+// The aad is only checked for the current format, older ciphertexts are not bound to their location.
+func (c *Crypto) Decrypt(ctx context.Context, ciphertext string, aad []byte) (string, error) {
 	if ciphertext == "" {
 		return "", nil
 	}
 
-	// Decode from base64
-	data, err := base64.StdEncoding.DecodeString(ciphertext)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode base64: %w", err)
+	id, body, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		id, body = DefaultKeyID, ciphertext
+	}
+	provider, ok := c.providers[id]
+	if !ok {
+		return "", fmt.Errorf("encryption key %q is not configured", id)
 	}
 
-	// Create a new cipher block
-	block, err := aes.NewCipher(c.key)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+	var (
+		key   []byte
+		err   error
+		parts []string
+	)
+	switch Version(ciphertext) {
+	case VersionBound:
+		parts, err = decodeParts(strings.TrimPrefix(body, "v2."), 3)
+		if err != nil {
+			return "", err
+		}
+		dk, err := c.unwrap(ctx, id, provider, []byte(parts[0]))
+		if err != nil {
+			return "", err
+		}
+		if key, err = hkdf.Key(sha256.New, dk, []byte(parts[1]), hkdfInfo, 32); err != nil {
+			return "", fmt.Errorf("failed to derive key: %w", err)
+		}
+		parts = parts[2:]
+	case VersionEnvelope:
+		if parts, err = decodeParts(body, 2); err != nil {
+			return "", err
+		}
+		if key, err = c.unwrap(ctx, id, provider, []byte(parts[0])); err != nil {
+			return "", err
+		}
+		parts, aad = parts[1:], nil
+	default:
+		// Written before envelope encryption, with the passphrase key itself
+		passphrase, ok := provider.(*PassphraseProvider)
+		if !ok {
+			return "", fmt.Errorf("encryption key %q has no data key in the ciphertext", id)
+		}
+		if parts, err = decodeParts(body, 1); err != nil {
+			return "", err
+		}
+		key, aad = passphrase.key, nil
 	}
 
-	// Create a new GCM mode
-	gcm, err := cipher.NewGCM(block)
+	plaintext, err := open(key, []byte(parts[0]), aad)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return "", err
 	}
+	return string(plaintext), nil
+}
 
-	// Check if data is long enough for nonce + ciphertext
-	if len(data) < gcm.NonceSize() {
-		return "", fmt.Errorf("ciphertext too short")
+// decodeParts decodes the n base64 parts of a ciphertext separated by dots
+func decodeParts(s string, n int) ([]string, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != n {
+		return nil, fmt.Errorf("malformed ciphertext: expected %d parts, got %d", n, len(parts))
 	}
+	for i, part := range parts {
+		// Decode from base64
+		data, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64: %w", err)
+		}
+		parts[i] = string(data)
+	}
+	return parts, nil
+}
 
-	// Extract nonce and ciphertext
-	nonce := data[:gcm.NonceSize()]
-	ciphertextBytes := data[gcm.NonceSize():]
+// dataKey returns the data key for new secrets, it is generated and wrapped on first use
+func (c *Crypto) dataKey(ctx context.Context) (*dataKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current != nil {
+		return c.current, nil
+	}
 
-	// Decrypt the ciphertext
-	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped, err := c.providers[c.active].WrapKey(ctx, key)
 	if err != nil {
-		return "", fmt.Errorf("failed to decrypt: %w", err)
+		return nil, fmt.Errorf("failed to wrap data key with key %q: %w", c.active, err)
 	}
-
-	return string(plaintext), nil
+	c.current = &dataKey{key: key, wrapped: wrapped}
+	c.unwrapped[c.active+"\x00"+string(wrapped)] = key
+	return c.current, nil
 }
 
-// EncryptBytes encrypts byte data
-func (c *Crypto) EncryptBytes(plaintext []byte) ([]byte, error) {
-	if len(plaintext) == 0 {
-		return nil, nil
+// unwrap returns the data key of a ciphertext, unwrapped keys are cached
+func (c *Crypto) unwrap(ctx context.Context, id string, provider KeyProvider, wrapped []byte) ([]byte, error) {
+	cacheKey := id + "\x00" + string(wrapped)
+	c.mu.Lock()
+	key, ok := c.unwrapped[cacheKey]
+	c.mu.Unlock()
+	if ok {
+		return key, nil
 	}
 
-	// Create a new cipher block
-	block, err := aes.NewCipher(c.key)
+	key, err := provider.UnwrapKey(ctx, wrapped)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+		return nil, fmt.Errorf("failed to unwrap data key with key %q: %w", id, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key %q unwrapped a data key of %d bytes", id, len(key))
 	}
+	c.mu.Lock()
+	c.unwrapped[cacheKey] = key
+	c.mu.Unlock()
+	return key, nil
+}
 
-	// Create a new GCM mode
-	gcm, err := cipher.NewGCM(block)
+// seal encrypts plaintext with AES-256-GCM and prepends the nonce
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
 
 	// Create a random nonce
@@ -124,20 +376,35 @@ func (c *Crypto) EncryptBytes(plaintext []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	// Encrypt the plaintext
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
-
-	return ciphertext, nil
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
 }
 
-// DecryptBytes decrypts byte data
-func (c *Crypto) DecryptBytes(ciphertext []byte) ([]byte, error) {
-	if len(ciphertext) == 0 {
-		return nil, nil
+// open decrypts data produced by seal
+func open(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
 	}
 
+	// Check if data is long enough for nonce + ciphertext
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	// Extract nonce and ciphertext
+	nonce := data[:gcm.NonceSize()]
+	ciphertextBytes := data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
 	// Create a new cipher block
-	block, err := aes.NewCipher(c.key)
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
@@ -147,21 +414,14 @@ func (c *Crypto) DecryptBytes(ciphertext []byte) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
+	return gcm, nil
+}
 
-	// Check if data is long enough for nonce + ciphertext
-	if len(ciphertext) < gcm.NonceSize() {
-		return nil, fmt.Errorf("ciphertext too short")
-	}
-
-	// Extract nonce and ciphertext
-	nonce := ciphertext[:gcm.NonceSize()]
-	ciphertextBytes := ciphertext[gcm.NonceSize():]
-
-	// Decrypt the ciphertext
-	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt: %w", err)
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-
-	return plaintext, nil
+	sort.Strings(keys)
+	return keys
 }
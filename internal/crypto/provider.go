@@ -0,0 +1,122 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeyProvider protects data keys with a key-encryption key.
+// Secrets are encrypted with data keys, only wrapped data keys are stored next to them.
+type KeyProvider interface {
+	// WrapKey encrypts a data key with the key-encryption key
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key wrapped by WrapKey
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// scrypt parameters of passphrase keys, the recommendation for interactive logins
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// scryptVersion starts data keys wrapped with a scrypt key: version, 16 bytes of salt and the sealed data key
+const scryptVersion = 2
+
+// PassphraseProvider derives the key-encryption key from a passphrase.
+// It is kept for backward compatibility: secrets written before envelope encryption were encrypted
+// with the SHA-256 of the passphrase directly, and data keys were wrapped with it before scrypt was used.
+type PassphraseProvider struct {
+	passphrase string
+	// key is the SHA-256 of the passphrase, only used to read old ciphertexts
+	key []byte
+}
+
+// NewPassphraseProvider creates a provider from a passphrase
+func NewPassphraseProvider(passphrase string) *PassphraseProvider {
+	return &PassphraseProvider{passphrase: passphrase, key: deriveKey(passphrase)}
+}
+
+// WrapKey implements KeyProvider with a key derived by scrypt from the passphrase and a random salt stored with the wrapped key
+func (p *PassphraseProvider) WrapKey(_ context.Context, dataKey []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := scrypt.Key([]byte(p.passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	sealed, err := seal(key, dataKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{scryptVersion}, salt...), sealed...), nil
+}
+
+// UnwrapKey implements KeyProvider
+func (p *PassphraseProvider) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	// Keys wrapped with the SHA-256 key are exactly nonce, data key and tag long
+	if len(wrapped) == 12+32+16 {
+		return open(p.key, wrapped, nil)
+	}
+	if len(wrapped) < 17 || wrapped[0] != scryptVersion {
+		return nil, fmt.Errorf("unknown wrapped key format")
+	}
+	key, err := scrypt.Key([]byte(p.passphrase), wrapped[1:17], scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return open(key, wrapped[17:], nil)
+}
+
+// KeyfileProvider holds a 256-bit key-encryption key read from a local file
+type KeyfileProvider struct {
+	key []byte
+}
+
+// NewKeyfileProvider reads the key-encryption key from a file holding 32 raw bytes, 64 hex digits or base64
+func NewKeyfileProvider(path string) (*KeyfileProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key, err := parseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	return &KeyfileProvider{key: key}, nil
+}
+
+// parseKey decodes a 256-bit key
+func parseKey(data []byte) ([]byte, error) {
+	if len(data) == 32 {
+		return data, nil
+	}
+	text := string(bytes.TrimSpace(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("expected a 256-bit key as 32 raw bytes, 64 hex digits or base64, e.g. from `openssl rand -hex 32`")
+}
+
+// WrapKey implements KeyProvider
+func (p *KeyfileProvider) WrapKey(_ context.Context, dataKey []byte) ([]byte, error) {
+	return seal(p.key, dataKey, nil)
+}
+
+// UnwrapKey implements KeyProvider
+func (p *KeyfileProvider) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	return open(p.key, wrapped, nil)
+}
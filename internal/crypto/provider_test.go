@@ -0,0 +1,153 @@
+package crypto
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestKeyfileProvider(t *testing.T) {
+	ctx := context.Background()
+	raw := []byte("0123456789abcdef0123456789abcdef")
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"raw": raw,
+		"hex": []byte(hex.EncodeToString(raw) + "\n"),
+		"b64": []byte(base64.StdEncoding.EncodeToString(raw)),
+	}
+	var wrapped []byte
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		p, err := NewKeyfileProvider(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if wrapped == nil {
+			if wrapped, err = p.WrapKey(ctx, []byte("data key")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Every encoding yields the same key
+		if key, err := p.UnwrapKey(ctx, wrapped); err != nil || string(key) != "data key" {
+			t.Fatalf("%s: unwrap: %q, %v", name, key, err)
+		}
+	}
+
+	short := filepath.Join(dir, "short")
+	if err := os.WriteFile(short, []byte("too short"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKeyfileProvider(short); err == nil {
+		t.Fatal("expected an error for a short key")
+	}
+	if _, err := NewKeyfileProvider(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
+
+func TestVaultTransitProvider(t *testing.T) {
+	vault, calls := newFakeVault(t, "vault-token", "chorus")
+	defer vault.Close()
+
+	ctx := context.Background()
+	p, err := NewVaultTransitProvider(VaultConfig{Address: vault.URL, Token: "vault-token"}, "chorus", vault.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := p.WrapKey(ctx, []byte("data key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(wrapped), "vault:v1:") {
+		t.Fatalf("unexpected wrapped key %q", wrapped)
+	}
+	if key, err := p.UnwrapKey(ctx, wrapped); err != nil || string(key) != "data key" {
+		t.Fatalf("unwrap: %q, %v", key, err)
+	}
+
+	// Secrets share the data key of the process, the cache spares Vault a call per secret
+	c, err := NewWithProviders(map[string]KeyProvider{"vault": p}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := calls.Load()
+	for _, secret := range []string{"a", "b", "c"} {
+		encrypted, err := c.Encrypt(ctx, secret, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted, err := c.Decrypt(ctx, encrypted, nil); err != nil || decrypted != secret {
+			t.Fatalf("decrypt: %q, %v", decrypted, err)
+		}
+	}
+	if n := calls.Load() - before; n != 1 {
+		t.Fatalf("expected one vault call, got %d", n)
+	}
+
+	denied, err := NewVaultTransitProvider(VaultConfig{Address: vault.URL, Token: "wrong"}, "chorus", vault.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := denied.WrapKey(ctx, []byte("data key")); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if _, err := NewVaultTransitProvider(VaultConfig{}, "chorus", nil); err == nil {
+		t.Fatal("expected an error without address and token")
+	}
+}
+
+// newFakeVault serves the encrypt and decrypt endpoints of a transit engine mounted at /transit
+func newFakeVault(t *testing.T, token, key string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	kek := &KeyfileProvider{key: []byte("vault key-encryption key 32 byte")}
+	calls := &atomic.Int64{}
+	reply := func(w http.ResponseWriter, status int, body any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("X-Vault-Token") != token {
+			reply(w, http.StatusForbidden, map[string][]string{"errors": {"permission denied"}})
+			return
+		}
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			reply(w, http.StatusBadRequest, map[string][]string{"errors": {err.Error()}})
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/" + key:
+			plaintext, _ := base64.StdEncoding.DecodeString(req["plaintext"])
+			sealed, _ := kek.WrapKey(r.Context(), plaintext)
+			reply(w, http.StatusOK, map[string]any{"data": map[string]string{
+				"ciphertext": "vault:v1:" + base64.StdEncoding.EncodeToString(sealed),
+			}})
+		case "/v1/transit/decrypt/" + key:
+			sealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(req["ciphertext"], "vault:v1:"))
+			plaintext, err := kek.UnwrapKey(r.Context(), sealed)
+			if err != nil {
+				reply(w, http.StatusBadRequest, map[string][]string{"errors": {"cipher: message authentication failed"}})
+				return
+			}
+			reply(w, http.StatusOK, map[string]any{"data": map[string]string{
+				"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+			}})
+		default:
+			reply(w, http.StatusNotFound, map[string][]string{"errors": {}})
+		}
+	}))
+	return srv, calls
+}
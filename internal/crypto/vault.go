@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// VaultConfig locates a Vault transit secrets engine
+type VaultConfig struct {
+	Address string
+	Token   string
+	// Mount is the path the transit engine is mounted at, "transit" when empty
+	Mount string
+	// Namespace is the Vault Enterprise namespace, if any
+	Namespace string
+}
+
+// VaultTransitProvider wraps data keys with a named key of the Vault transit engine.
+// The key-encryption key never leaves Vault.
+type VaultTransitProvider struct {
+	cfg    VaultConfig
+	key    string
+	client *http.Client
+}
+
+// NewVaultTransitProvider creates a provider for the transit key with the given name
+func NewVaultTransitProvider(cfg VaultConfig, key string, client *http.Client) (*VaultTransitProvider, error) {
+	if cfg.Address == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("vault address and token are required for transit key %q", key)
+	}
+	if _, err := url.Parse(cfg.Address); err != nil {
+		return nil, fmt.Errorf("invalid vault address: %w", err)
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "transit"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &VaultTransitProvider{cfg: cfg, key: key, client: client}, nil
+}
+
+// WrapKey implements KeyProvider with the transit encrypt endpoint
+func (p *VaultTransitProvider) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var resp struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	body := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := p.call(ctx, "encrypt", body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Ciphertext == "" {
+		return nil, fmt.Errorf("vault transit encrypt returned no ciphertext")
+	}
+	return []byte(resp.Ciphertext), nil
+}
+
+// UnwrapKey implements KeyProvider with the transit decrypt endpoint
+func (p *VaultTransitProvider) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := p.call(ctx, "decrypt", map[string]string{"ciphertext": string(wrapped)}, &resp); err != nil {
+		return nil, err
+	}
+	dataKey, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("vault transit decrypt returned invalid plaintext: %w", err)
+	}
+	return dataKey, nil
+}
+
+// call posts to a transit endpoint and decodes the data of the response
+func (p *VaultTransitProvider) call(ctx context.Context, op string, body any, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", strings.TrimSuffix(p.cfg.Address, "/"),
+		strings.Trim(p.cfg.Mount, "/"), op, url.PathEscape(p.key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", p.cfg.Token)
+	if p.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.cfg.Namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault transit %s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("vault transit %s: %w", op, err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("vault transit %s: invalid response: %w", op, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("vault transit %s: %s: %s", op, resp.Status, strings.Join(result.Errors, "; "))
+		}
+		return fmt.Errorf("vault transit %s: %s", op, resp.Status)
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return fmt.Errorf("vault transit %s: invalid response data: %w", op, err)
+	}
+	return nil
+}
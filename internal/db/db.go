@@ -25,7 +25,7 @@ func Open(dsn string) (*gorm.DB, error) {
 			ParameterizedQueries:      true,
 		},
 	)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormLogger})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormLogger, TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
@@ -4,6 +4,8 @@ import (
 	"context"
 
 	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/providers"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -13,32 +15,99 @@ type WorkerClient interface {
 	ListBucketsForReplication(ctx context.Context, req *pb.ListBucketsForReplicationRequest) (*pb.ListBucketsForReplicationResponse, error)
 	AddReplication(ctx context.Context, req *pb.AddReplicationRequest) (*emptypb.Empty, error)
 	ListReplications(ctx context.Context) (*pb.ListReplicationsResponse, error)
+	ListUserReplications(ctx context.Context) (*pb.ListUserReplicationsResponse, error)
+	DeleteUserReplication(ctx context.Context, req *pb.DeleteUserReplicationRequest) (*emptypb.Empty, error)
 	PauseReplication(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error)
 	ResumeReplication(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error)
 	DeleteReplication(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error)
 	SwitchBucketZeroDowntime(ctx context.Context, req *pb.SwitchBucketZeroDowntimeRequest) (*emptypb.Empty, error)
+	StreamBucketReplication(ctx context.Context, req *pb.ReplicationRequest) (ReplicationStream, error)
+	SwitchBucket(ctx context.Context, req *pb.SwitchBucketRequest) (*emptypb.Empty, error)
+	DeleteBucketSwitch(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error)
+	GetBucketSwitchStatus(ctx context.Context, req *pb.ReplicationRequest) (*pb.GetBucketSwitchStatusResponse, error)
+	ListReplicationSwitches(ctx context.Context) (*pb.ListSwitchResponse, error)
+	CompareBucket(ctx context.Context, req *pb.CompareBucketRequest) (*pb.CompareBucketResponse, error)
+}
+
+// ReplicationStream receives replication updates from a worker until the context is done
+type ReplicationStream interface {
+	Recv() (*pb.Replication, error)
+}
+
+// WorkerConnection exposes the state of a managed worker connection
+type WorkerConnection interface {
+	Addr() string
+	State() connectivity.State
+	Close() error
+}
+
+// WorkerResolver resolves worker clients by registered worker name
+// An empty name resolves to the default worker
+type WorkerResolver interface {
+	Resolve(ctx context.Context, name string) (WorkerClient, error)
+	WorkerNames(ctx context.Context) ([]string, error)
+	DefaultWorker() string
+}
+
+// WorkerService defines the interface for the worker registry
+type WorkerService interface {
+	WorkerResolver
+	CreateWorker(ctx context.Context, req *CreateWorkerRequest) (*Worker, error)
+	ListWorkers(ctx context.Context) ([]Worker, error)
+	GetWorker(ctx context.Context, name string) (*Worker, error)
+	UpdateWorker(ctx context.Context, name string, req *CreateWorkerRequest) error
+	DeleteWorker(ctx context.Context, name string) error
+	WorkerStatuses(ctx context.Context) ([]WorkerStatus, error)
 }
 
 // ReplicationService defines the interface for replication business logic
 type ReplicationService interface {
 	CreateReplication(ctx context.Context, req *CreateReplicationRequest) error
-	ListReplications(ctx context.Context) ([]*pb.Replication, error)
+	ListReplications(ctx context.Context) ([]WorkerReplication, map[string]string, error)
 	PauseReplication(ctx context.Context, id *ReplicationIdentifier) error
 	ResumeReplication(ctx context.Context, id *ReplicationIdentifier) error
 	DeleteReplication(ctx context.Context, id *ReplicationIdentifier) error
 	SwitchZeroDowntime(ctx context.Context, id *ReplicationIdentifier) error
+	StreamReplications(ctx context.Context, filter *ReplicationFilter) (<-chan ReplicationEvent, error)
+	SwitchWithDowntime(ctx context.Context, req *SwitchBucketRequest) error
+	GetSwitchStatus(ctx context.Context, id *ReplicationIdentifier) (*BucketSwitch, error)
+	ListSwitches(ctx context.Context) ([]BucketSwitch, error)
+	DeleteSwitch(ctx context.Context, id *ReplicationIdentifier) error
+	CompareBucket(ctx context.Context, req *CompareBucketRequest) (*BucketComparisonResult, error)
+	ListComparisons(ctx context.Context, filter *ComparisonFilter) ([]BucketComparison, error)
+	GetComparison(ctx context.Context, id string) (*BucketComparison, error)
+	GetComparisonKeys(ctx context.Context, id string, req *ComparisonKeysRequest) (*ComparisonKeysPage, error)
+	ListUserReplications(ctx context.Context, filter *UserReplicationFilter) ([]UserReplication, error)
+	PauseUserReplication(ctx context.Context, req *UserReplicationRequest) (*UserReplicationReport, error)
+	ResumeUserReplication(ctx context.Context, req *UserReplicationRequest) (*UserReplicationReport, error)
+	DeleteUserReplication(ctx context.Context, req *UserReplicationRequest) (*UserReplicationReport, error)
 }
 
 // StorageService defines the interface for storage business logic
 type StorageService interface {
-	ListStorages(ctx context.Context) (*pb.GetStoragesResponse, error)
+	ListStorages(ctx context.Context) ([]WorkerStorage, map[string]string, error)
 	ListBuckets(ctx context.Context, req *ListBucketsRequest) (*pb.ListBucketsForReplicationResponse, error)
 	CreateStorage(ctx context.Context, storage *Storage) error
 	CreateStorageFromRequest(ctx context.Context, req *CreateStorageRequest) error
-	ListStorageFromDB(ctx context.Context) ([]Storage, error)
+	ListStorageFromDB(ctx context.Context, req *ListStoragesRequest) ([]Storage, string, error)
 	GetStorageByID(ctx context.Context, id string) (*Storage, error)
 	UpdateStorageByID(ctx context.Context, id string, req *CreateStorageRequest) error
-	DeleteStorageByID(ctx context.Context, id string) error
+	PatchStorageByID(ctx context.Context, id string, req *UpdateStorageRequest) error
+	DeleteStorageByID(ctx context.Context, id string, force bool) error
+	ListStorageCredentials(ctx context.Context, id string) ([]StorageCredential, error)
+	CreateStorageCredential(ctx context.Context, id string, req *CreateStorageCredentialRequest) (*StorageCredential, error)
+	UpdateStorageCredential(ctx context.Context, id, user string, req *UpdateStorageCredentialRequest) error
+	DeleteStorageCredential(ctx context.Context, id, user string) error
+	ExportWorkerConfig(ctx context.Context, req *ExportStoragesRequest, actor *AuditActor) ([]byte, error)
+	ImportStorages(ctx context.Context, data []byte, opts *ImportStoragesOptions) (*StorageImportReport, error)
+	DetectDrift(ctx context.Context) (*StorageDriftReport, error)
+	ListDriftEvents(ctx context.Context, filter *DriftEventFilter) ([]StorageDriftEvent, error)
+	TestStorage(ctx context.Context, id string) (*StorageTestReport, error)
+	ListProviders(ctx context.Context) []providers.Provider
+	GetStorageHealth(ctx context.Context, id string, req *StorageHealthRequest) (*StorageHealth, error)
+	PromoteStorage(ctx context.Context, id string, req *PromoteStorageRequest) (*PromotionReport, error)
+	RevealStorageSecret(ctx context.Context, id string, actor *AuditActor) (*StorageSecret, error)
+	RotateKeys(ctx context.Context, req *RotateKeysRequest) (*KeyRotationReport, error)
 }
 
 // TokenService defines the interface for token management
@@ -3,13 +3,17 @@ package domain
 import (
 	"time"
 
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
 	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/labels"
 )
 
 // Domain models for the chorus controller
 
 // CreateReplicationRequest represents a request to create a new replication job
+// Worker selects the registered worker that owns the replication (default worker when empty)
 type CreateReplicationRequest struct {
+	Worker   string   `json:"worker"`
 	User     string   `json:"user" binding:"required"`
 	From     string   `json:"from" binding:"required"`
 	To       string   `json:"to" binding:"required"`
@@ -18,52 +22,451 @@ type CreateReplicationRequest struct {
 	AgentURL string   `json:"agent_url"`
 }
 
-// CreateStorageRequest represents a simplified request to create a storage
+// CreateStorageRequest represents a request to create or replace a storage.
+// Omitted settings use their defaults on create and keep their current value on replace.
+// The address may be omitted for providers with a well-known endpoint, see GET /storages/providers.
 type CreateStorageRequest struct {
-	Name      string `json:"name" binding:"required" example:"my-storage"`
-	Address   string `json:"address" binding:"required" example:"http://localhost:9000"`
-	Provider  string `json:"provider" binding:"required" example:"minio"`
-	User      string `json:"user" binding:"required" example:"myuser"`
-	AccessKey string `json:"access_key" binding:"required" example:"AKIA123"`
-	SecretKey string `json:"secret_key" binding:"required" example:"SECRET123"`
+	Name      string `json:"name" binding:"required,max=255" example:"my-storage"`
+	Address   string `json:"address" binding:"max=1024" example:"http://localhost:9000"`
+	Provider  string `json:"provider" binding:"required,max=64" example:"minio"`
+	User      string `json:"user" binding:"required,max=255" example:"myuser"`
+	AccessKey string `json:"access_key" binding:"required,max=255" example:"AKIA123"`
+	SecretKey string `json:"secret_key" binding:"required,max=255" example:"SECRET123"`
+	StorageSettings
+	Options StorageWriteOptions `json:"-"`
 }
 
+// UpdateStorageRequest changes only the fields which are set
+type UpdateStorageRequest struct {
+	Name      *string `json:"name,omitempty" binding:"omitempty,min=1,max=255" example:"my-storage"`
+	Address   *string `json:"address,omitempty" binding:"omitempty,min=1,max=1024" example:"http://localhost:9000"`
+	Provider  *string `json:"provider,omitempty" binding:"omitempty,min=1,max=64" example:"minio"`
+	User      *string `json:"user,omitempty" binding:"omitempty,min=1,max=255" example:"myuser"`
+	AccessKey *string `json:"access_key,omitempty" binding:"omitempty,min=1,max=255" example:"AKIA123"`
+	SecretKey *string `json:"secret_key,omitempty" binding:"omitempty,min=1,max=255" example:"SECRET123"`
+	StorageSettings
+	Options StorageWriteOptions `json:"-"`
+}
+
+// StorageWriteOptions are the query parameters of storage writes
+type StorageWriteOptions struct {
+	// Validate saves the storage only when its S3 checks pass
+	Validate bool `form:"validate"`
+	// ReplaceMain demotes the current main storage when the storage becomes main
+	ReplaceMain bool `form:"replace_main"`
+}
+
+// StorageSettings are the optional storage fields, nil fields are left unchanged
+type StorageSettings struct {
+	IsMain                *bool   `json:"is_main,omitempty"`
+	IsSecure              *bool   `json:"is_secure,omitempty"`
+	DefaultRegion         *string `json:"default_region,omitempty" binding:"omitempty,max=128" example:"us-east-1"`
+	HealthCheckIntervalMs *int64  `json:"health_check_interval_ms,omitempty" binding:"omitempty,min=1000,max=86400000" example:"5000"`
+	HttpTimeoutMs         *int64  `json:"http_timeout_ms,omitempty" binding:"omitempty,min=1000,max=3600000" example:"300000"`
+	RateLimitEnabled      *bool   `json:"rate_limit_enabled,omitempty"`
+	RateLimitRPM          *int    `json:"rate_limit_rpm,omitempty" binding:"omitempty,min=0,max=10000000" example:"600"`
+	Description           *string `json:"description,omitempty" binding:"omitempty,max=500"`
+	// Labels replace the labels of the storage, checked by labels.Validate
+	Labels *map[string]string `json:"labels,omitempty" example:"env:prod,region:us"`
+}
+
+// Storage defaults
+const (
+	DefaultHealthCheckIntervalMs int64 = 5000   // 5 seconds
+	DefaultHttpTimeoutMs         int64 = 300000 // 5 minutes
+)
+
 // ReplicationIdentifier represents a replication job identifier
+// Worker is optional, the owning worker is looked up from replication jobs when empty
 type ReplicationIdentifier struct {
-	User     string `json:"user" binding:"required"`
-	Bucket   string `json:"bucket" binding:"required"`
-	From     string `json:"from" binding:"required"`
-	To       string `json:"to" binding:"required"`
-	ToBucket string `json:"to_bucket"`
+	Worker   string `json:"worker" form:"worker"`
+	User     string `json:"user" form:"user" binding:"required"`
+	Bucket   string `json:"bucket" form:"bucket" binding:"required"`
+	From     string `json:"from" form:"from" binding:"required"`
+	To       string `json:"to" form:"to" binding:"required"`
+	ToBucket string `json:"to_bucket" form:"to_bucket"`
+}
+
+// UserReplicationRequest identifies all replications of a user between two storages
+// Worker is optional, the owning worker is looked up from replication jobs when empty
+type UserReplicationRequest struct {
+	Worker string `json:"worker"`
+	User   string `json:"user" binding:"required"`
+	From   string `json:"from" binding:"required"`
+	To     string `json:"to" binding:"required"`
+}
+
+// UserReplicationFilter selects user replications, empty fields match everything
+type UserReplicationFilter struct {
+	Worker string `form:"worker"`
+	User   string `form:"user"`
+	From   string `form:"from"`
+	To     string `form:"to"`
+}
+
+// UserReplication groups the bucket replications of a user between two storages.
+// AllBuckets is set when the worker replicates every bucket of the user,
+// including buckets created later.
+type UserReplication struct {
+	Worker       string            `json:"worker"`
+	User         string            `json:"user"`
+	From         string            `json:"from"`
+	To           string            `json:"to"`
+	AllBuckets   bool              `json:"all_buckets"`
+	Replications []*pb.Replication `json:"replications" swaggertype:"array,object"`
+}
+
+// User replication actions
+const (
+	UserReplicationPause  = "pause"
+	UserReplicationResume = "resume"
+	UserReplicationDelete = "delete"
+)
+
+// Bucket operation statuses
+const (
+	BucketOperationDone    = "done"
+	BucketOperationSkipped = "skipped"
+	BucketOperationFailed  = "failed"
+)
+
+// BucketOperationResult is the outcome of a user replication action for one bucket
+type BucketOperationResult struct {
+	Bucket   string `json:"bucket"`
+	ToBucket string `json:"to_bucket,omitempty"`
+	Status   string `json:"status" example:"done"`
+	Error    string `json:"error,omitempty"`
+}
+
+// UserReplicationReport reports the outcome of a user replication action.
+// UserLevel is set when the worker applied the action to the user replication
+// as a unit, otherwise it was applied bucket by bucket.
+type UserReplicationReport struct {
+	Worker    string                  `json:"worker"`
+	User      string                  `json:"user"`
+	From      string                  `json:"from"`
+	To        string                  `json:"to"`
+	Action    string                  `json:"action" example:"pause"`
+	UserLevel bool                    `json:"user_level"`
+	Done      int                     `json:"done"`
+	Skipped   int                     `json:"skipped"`
+	Failed    int                     `json:"failed"`
+	Buckets   []BucketOperationResult `json:"buckets"`
+}
+
+// SwitchDowntimeWindow configures when a switch with downtime runs.
+// Writes to the bucket are blocked while the switch is in progress.
+// Without StartOnInitDone, Cron or StartAt the switch starts right away.
+type SwitchDowntimeWindow struct {
+	// StartOnInitDone starts the switch once the initial replication is done
+	StartOnInitDone bool `json:"start_on_init_done"`
+	// Cron retries the switch on the schedule until it is done, exclusive with StartAt
+	Cron string `json:"cron,omitempty" example:"0 2 * * *"`
+	// StartAt runs the switch at the given time, exclusive with Cron
+	StartAt *time.Time `json:"start_at,omitempty" example:"2024-12-31T02:00:00Z"`
+	// MaxDuration aborts the switch when it takes longer, e.g. "30m"
+	MaxDuration string `json:"max_duration,omitempty" example:"30m"`
+	// MaxEventLag skips the switch when more events are waiting to be replicated
+	MaxEventLag *uint32 `json:"max_event_lag,omitempty"`
+	// SkipBucketCheck skips comparing bucket contents before completing the switch
+	SkipBucketCheck bool `json:"skip_bucket_check"`
+	// ContinueReplication replicates writes from the new main back to the old main bucket
+	ContinueReplication bool `json:"continue_replication"`
+}
+
+// SwitchBucketRequest represents a request to switch buckets with a downtime window
+type SwitchBucketRequest struct {
+	ReplicationIdentifier
+	Downtime *SwitchDowntimeWindow `json:"downtime,omitempty"`
+}
+
+// Bucket switch statuses
+const (
+	SwitchStatusNotStarted      = "not_started"
+	SwitchStatusInProgress      = "in_progress"
+	SwitchStatusCheckInProgress = "check_in_progress"
+	SwitchStatusError           = "error"
+	SwitchStatusSkipped         = "skipped"
+	SwitchStatusDone            = "done"
+)
+
+// BucketSwitch is the state of a bucket switch reported by a worker
+type BucketSwitch struct {
+	Replication   ReplicationIdentifier `json:"replication"`
+	Status        string                `json:"status" example:"in_progress"`
+	ZeroDowntime  bool                  `json:"zero_downtime"`
+	MultipartTTL  string                `json:"multipart_ttl,omitempty" example:"1h0m0s"`
+	Downtime      *SwitchDowntimeWindow `json:"downtime,omitempty"`
+	LastStartedAt *time.Time            `json:"last_started_at,omitempty"`
+	DoneAt        *time.Time            `json:"done_at,omitempty"`
+	History       []string              `json:"history"`
+}
+
+// CompareBucketRequest represents a request to compare the buckets of a replication
+// Mismatched keys are always stored, ShowKeys includes their first page in the response
+type CompareBucketRequest struct {
+	ReplicationIdentifier
+	ShowKeys bool `json:"show_keys"`
+	Limit    int  `json:"limit" binding:"min=0,max=10000" example:"100"`
+}
+
+// Bucket comparison key kinds
+const (
+	ComparisonKeysMissFrom = "miss_from"
+	ComparisonKeysMissTo   = "miss_to"
+	ComparisonKeysDiffer   = "differ"
+	ComparisonKeysErrors   = "errors"
+)
+
+// BucketComparison is the result of a bucket consistency check persisted in DB
+// Key lists are only served page by page, see ComparisonKeysPage
+// MatchCount is null unless the worker lists the matched keys, the controller does not request them
+type BucketComparison struct {
+	ID            uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Worker        string    `gorm:"size:255;not null" json:"worker"`
+	User          string    `gorm:"size:255;not null" json:"user"`
+	Bucket        string    `gorm:"size:255;index;not null" json:"bucket"`
+	From          string    `gorm:"size:255;not null" json:"from"`
+	To            string    `gorm:"size:255;not null" json:"to"`
+	ToBucket      string    `gorm:"size:255" json:"to_bucket"`
+	IsMatch       bool      `json:"is_match"`
+	MatchCount    *int64    `json:"match_count"`
+	MissFromCount int64     `json:"miss_from_count"`
+	MissToCount   int64     `json:"miss_to_count"`
+	DifferCount   int64     `json:"differ_count"`
+	ErrorCount    int64     `json:"error_count"`
+	MissFrom      []string  `gorm:"serializer:json;type:jsonb" json:"-"`
+	MissTo        []string  `gorm:"serializer:json;type:jsonb" json:"-"`
+	Differ        []string  `gorm:"serializer:json;type:jsonb" json:"-"`
+	Errors        []string  `gorm:"serializer:json;type:jsonb" json:"-"`
+	CreatedAt     time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName returns the table name for BucketComparison
+func (BucketComparison) TableName() string {
+	return "bucket_comparison"
+}
+
+// Keys returns the stored keys of the given kind
+func (c *BucketComparison) Keys(kind string) ([]string, bool) {
+	switch kind {
+	case ComparisonKeysMissFrom:
+		return c.MissFrom, true
+	case ComparisonKeysMissTo:
+		return c.MissTo, true
+	case ComparisonKeysDiffer:
+		return c.Differ, true
+	case ComparisonKeysErrors:
+		return c.Errors, true
+	}
+	return nil, false
+}
+
+// ComparisonKeysPage is a page of the mismatched keys of a bucket comparison
+type ComparisonKeysPage struct {
+	Kind   string   `json:"kind" example:"miss_to"`
+	Total  int      `json:"total"`
+	Offset int      `json:"offset"`
+	Limit  int      `json:"limit"`
+	Keys   []string `json:"keys"`
+}
+
+// BucketComparisonResult is a bucket comparison with the first pages of its mismatched keys
+type BucketComparisonResult struct {
+	BucketComparison
+	Keys map[string]*ComparisonKeysPage `json:"keys,omitempty"`
+}
+
+// ComparisonFilter selects persisted bucket comparisons, empty fields match everything
+type ComparisonFilter struct {
+	Worker string `form:"worker"`
+	User   string `form:"user"`
+	Bucket string `form:"bucket"`
+	From   string `form:"from"`
+	To     string `form:"to"`
+	Limit  int    `form:"limit" binding:"min=0,max=1000"`
+}
+
+// ComparisonKeysRequest selects a page of the mismatched keys of a bucket comparison
+type ComparisonKeysRequest struct {
+	Kind   string `form:"kind" binding:"required,oneof=miss_from miss_to differ errors"`
+	Offset int    `form:"offset" binding:"min=0"`
+	Limit  int    `form:"limit" binding:"min=0,max=10000"`
 }
 
 // ListBucketsRequest represents parameters for listing buckets
 type ListBucketsRequest struct {
+	Worker         string `form:"worker"`
 	User           string `form:"user"`
 	From           string `form:"from"`
 	To             string `form:"to"`
 	ShowReplicated bool   `form:"show_replicated"`
 }
 
+// ExportStoragesRequest represents options of the worker storage config export
+type ExportStoragesRequest struct {
+	DefaultRegion     string `form:"default_region"`
+	CreateRouting     bool   `form:"create_routing,default=true"`
+	CreateReplication bool   `form:"create_replication"`
+	// Selector exports only the storages whose labels match, see package labels
+	Selector string `form:"selector" example:"env=prod,region!=eu"`
+}
+
+// ListStoragesRequest selects a page of stored storages, empty fields match everything
+type ListStoragesRequest struct {
+	// Selector matches the storage labels, see package labels
+	Selector   string `form:"selector" example:"env=prod,region!=eu"`
+	Provider   string `form:"provider" example:"minio"`
+	NamePrefix string `form:"name_prefix" example:"eu-"`
+	// Limit bounds the page, 0 lists every matching storage
+	Limit int `form:"limit" binding:"min=0,max=1000"`
+	// Cursor continues a listing, as returned in the X-Next-Cursor header of the previous page
+	Cursor string `form:"cursor"`
+}
+
+// StorageFilter is a parsed ListStoragesRequest
+type StorageFilter struct {
+	Selector   labels.Selector
+	Provider   string
+	NamePrefix string
+	// After is the name the page starts after
+	After string
+	Limit int
+}
+
+// ImportStoragesOptions are the query parameters of a storage import
+type ImportStoragesOptions struct {
+	// DryRun only reports what the import would change
+	DryRun bool `form:"dry_run"`
+	// User selects the storage's own user when a storage has several, stored storages keep theirs when the file has it.
+	// The other users are stored as credentials of the storage.
+	User string `form:"user"`
+	// ReplaceMain demotes a main storage missing from the file when the file has a main storage
+	ReplaceMain bool `form:"replace_main"`
+}
+
+// Storage import actions
+const (
+	StorageImportCreated   = "created"
+	StorageImportUpdated   = "updated"
+	StorageImportUnchanged = "unchanged"
+)
+
+// StorageImportEntry is the outcome of the import of one storage
+type StorageImportEntry struct {
+	Name   string `json:"name"`
+	Action string `json:"action" example:"updated"`
+	// Changed lists the fields an update changes
+	Changed []string `json:"changed,omitempty" example:"address,secret_access_key"`
+}
+
+// StorageImportReport summarizes a storage import
+type StorageImportReport struct {
+	DryRun    bool                 `json:"dry_run"`
+	Created   int                  `json:"created"`
+	Updated   int                  `json:"updated"`
+	Unchanged int                  `json:"unchanged"`
+	Storages  []StorageImportEntry `json:"storages"`
+}
+
 // Storage represents a storage configuration persisted in DB
 // Mirrors fields from chorus-worker's s3.Storage and adds Name
-// Each storage has one user with embedded credentials
+// The storage's own user has embedded credentials, further users are StorageCredential rows
 type Storage struct {
-	ID                    uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
-	Name                  string    `gorm:"uniqueIndex;size:255;not null" json:"name"`
-	Address               string    `gorm:"size:1024;not null" json:"address"`
-	Provider              string    `gorm:"size:64;not null" json:"provider"`
-	IsMain                bool      `json:"is_main"`
-	IsSecure              bool      `json:"is_secure"`
-	DefaultRegion         string    `gorm:"size:128" json:"default_region"`
-	HealthCheckIntervalMs int64     `json:"health_check_interval_ms"`
-	HttpTimeoutMs         int64     `json:"http_timeout_ms"`
-	RateLimitEnabled      bool      `json:"rate_limit_enabled"`
-	RateLimitRPM          int       `json:"rate_limit_rpm"`
-	User                  string    `gorm:"size:255;not null" json:"user"`
-	AccessKeyID           string    `gorm:"size:255;not null" json:"access_key_id"`
-	SecretAccessKey       string    `gorm:"size:255;not null" json:"secret_access_key"`
-	Description           string    `gorm:"size:500" json:"description"`
+	ID                    uuid.UUID         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Name                  string            `gorm:"uniqueIndex;size:255;not null" json:"name"`
+	Address               string            `gorm:"size:1024;not null" json:"address"`
+	Provider              string            `gorm:"size:64;not null" json:"provider"`
+	IsMain                bool              `gorm:"uniqueIndex:idx_storage_single_main,where:is_main" json:"is_main"`
+	IsSecure              bool              `json:"is_secure"`
+	DefaultRegion         string            `gorm:"size:128" json:"default_region"`
+	HealthCheckIntervalMs int64             `json:"health_check_interval_ms"`
+	HttpTimeoutMs         int64             `json:"http_timeout_ms"`
+	RateLimitEnabled      bool              `json:"rate_limit_enabled"`
+	RateLimitRPM          int               `json:"rate_limit_rpm"`
+	User                  string            `gorm:"size:255;not null" json:"user"`
+	AccessKeyID           string            `gorm:"size:255;not null" json:"access_key_id"`
+	SecretAccessKey       string            `gorm:"size:1024;not null" json:"secret_access_key"`
+	Description           string            `gorm:"size:500" json:"description"`
+	Labels                map[string]string `gorm:"serializer:json;type:jsonb;index:idx_storage_labels,type:gin" json:"labels"`
+	// SecretFingerprint identifies the secret on read endpoints, where the secret itself is masked
+	SecretFingerprint string `gorm:"-" json:"secret_fingerprint,omitempty" example:"sha256:5e884898da280471"`
+}
+
+// MaskedSecret replaces secrets in API responses
+const MaskedSecret = "********"
+
+// StorageCredential is a user of a storage besides the storage's own user.
+// Chorus replicates per user, a user is replicated between two storages which both have it.
+type StorageCredential struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	StorageID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_storage_credential_storage_user,priority:1" json:"storage_id"`
+	User            string    `gorm:"size:255;not null;uniqueIndex:idx_storage_credential_storage_user,priority:2" json:"user"`
+	AccessKeyID     string    `gorm:"size:255;not null" json:"access_key_id"`
+	SecretAccessKey string    `gorm:"size:1024;not null" json:"secret_access_key"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	// Primary marks the storage's own user in listings, it is changed through the storage itself
+	Primary           bool   `gorm:"-" json:"primary"`
+	SecretFingerprint string `gorm:"-" json:"secret_fingerprint,omitempty" example:"sha256:5e884898da280471"`
+}
+
+// TableName returns the table name for StorageCredential
+func (StorageCredential) TableName() string {
+	return "storage_credential"
+}
+
+// CreateStorageCredentialRequest adds a user to a storage
+type CreateStorageCredentialRequest struct {
+	User      string `json:"user" binding:"required,max=255" example:"backup"`
+	AccessKey string `json:"access_key" binding:"required,max=255" example:"AKIA456"`
+	SecretKey string `json:"secret_key" binding:"required,max=255" example:"SECRET456"`
+}
+
+// UpdateStorageCredentialRequest replaces the keys of a storage user.
+// A masked secret, as returned by the read endpoints, keeps the stored one.
+type UpdateStorageCredentialRequest struct {
+	AccessKey string `json:"access_key" binding:"required,max=255" example:"AKIA456"`
+	SecretKey string `json:"secret_key" binding:"required,max=255" example:"SECRET456"`
+}
+
+// StorageSecret is the plain-text secret of a storage, returned by the audited reveal endpoint
+type StorageSecret struct {
+	StorageID         uuid.UUID `json:"storage_id"`
+	Storage           string    `json:"storage"`
+	User              string    `json:"user"`
+	AccessKeyID       string    `json:"access_key_id"`
+	SecretAccessKey   string    `json:"secret_access_key"`
+	SecretFingerprint string    `json:"secret_fingerprint"`
+}
+
+// Audit actions
+const (
+	AuditRevealStorageSecret = "storage.reveal_secret"
+	AuditExportStorages      = "storage.export"
+)
+
+// AuditActor identifies who performed an audited action
+type AuditActor struct {
+	TokenID   uuid.UUID
+	TokenName string
+	ClientIP  string
+}
+
+// AuditEvent records a sensitive action
+type AuditEvent struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Action    string     `gorm:"size:64;index;not null" json:"action"`
+	Resource  string     `gorm:"size:255;index;not null" json:"resource"`
+	TokenID   *uuid.UUID `gorm:"type:uuid" json:"token_id,omitempty"`
+	TokenName string     `gorm:"size:255" json:"token_name"`
+	ClientIP  string     `gorm:"size:64" json:"client_ip"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName returns the table name for AuditEvent
+func (AuditEvent) TableName() string {
+	return "audit_event"
 }
 
 // TableName returns the table name for Storage
@@ -71,10 +474,207 @@ func (Storage) TableName() string {
 	return "storage"
 }
 
+// Storage drift kinds
+const (
+	DriftMissingInWorker = "missing_in_worker"
+	DriftMissingInDB     = "missing_in_db"
+	DriftAddress         = "address"
+	DriftProvider        = "provider"
+	DriftMain            = "main"
+)
+
+// StorageDrift is a difference between a stored storage and the storage configured on a worker
+type StorageDrift struct {
+	Worker      string `json:"worker"`
+	Storage     string `json:"storage"`
+	Kind        string `json:"kind" example:"address"`
+	DBValue     string `json:"db_value,omitempty"`
+	WorkerValue string `json:"worker_value,omitempty"`
+}
+
+// PromoteStorageRequest promotes a storage to main.
+// Worker is the worker whose replications are checked, the default worker when empty.
+type PromoteStorageRequest struct {
+	Worker string `json:"worker"`
+	// Force promotes even when buckets of the current main are not replicated to the storage
+	Force bool `json:"force"`
+	// DryRun only reports the bucket coverage
+	DryRun bool `json:"dry_run"`
+}
+
+// PromotionReport tells which buckets of the previous main are replicated to the promoted storage
+type PromotionReport struct {
+	Storage      string `json:"storage"`
+	PreviousMain string `json:"previous_main,omitempty"`
+	Worker       string `json:"worker,omitempty"`
+	// Users lists the coverage of every user of the previous main, its own user first
+	Users    []UserCoverage `json:"users"`
+	DryRun   bool           `json:"dry_run"`
+	Promoted bool           `json:"promoted"`
+}
+
+// Uncovered tells whether any user has buckets which are not replicated to the promoted storage
+func (r *PromotionReport) Uncovered() bool {
+	for _, u := range r.Users {
+		if len(u.Uncovered) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// UserCoverage tells which buckets of a user are replicated to the promoted storage
+type UserCoverage struct {
+	User      string   `json:"user"`
+	Covered   []string `json:"covered"`
+	Uncovered []string `json:"uncovered"`
+}
+
+// RotateKeysRequest re-encrypts the storage secrets with the active encryption key.
+// Storages are processed in ID order, After resumes an interrupted rotation after the given storage.
+type RotateKeysRequest struct {
+	BatchSize int       `json:"batch_size" binding:"omitempty,min=1,max=1000" example:"100"`
+	After     uuid.UUID `json:"after" example:"7f1d3a52-3c1e-4a45-a0f2-6a7b3a0c2d11"`
+	// DryRun only counts the secrets per key
+	DryRun bool `json:"dry_run"`
+}
+
+// KeyRotationReport summarizes a key rotation
+type KeyRotationReport struct {
+	ActiveKey string `json:"active_key"`
+	DryRun    bool   `json:"dry_run"`
+	// Keys counts the secrets found per key ID before they were rotated
+	Keys map[string]int `json:"keys"`
+	// Formats counts the secrets found per ciphertext format before they were rotated, older formats than v2 are upgraded
+	Formats map[string]int `json:"formats"`
+	// Scanned counts the storages, the other counters include the secrets of their credentials
+	Scanned int `json:"scanned"`
+	// Rotated counts the secrets re-encrypted, or to be re-encrypted on a dry run
+	Rotated int `json:"rotated"`
+	Batches int `json:"batches"`
+	// LastID is the last storage of the last committed batch, pass it as after to resume
+	LastID uuid.UUID `json:"last_id"`
+}
+
+// Storage check statuses
+const (
+	StorageCheckOK      = "ok"
+	StorageCheckWarning = "warning"
+	StorageCheckFailed  = "failed"
+	StorageCheckSkipped = "skipped"
+)
+
+// StorageCheck is the outcome of a single pre-flight check of a storage.
+// Code is the S3 error code returned by the storage, if any.
+type StorageCheck struct {
+	Name    string `json:"name" example:"authentication"`
+	Status  string `json:"status" example:"ok"`
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty" example:"InvalidAccessKeyId"`
+}
+
+// StorageTestReport is the outcome of the pre-flight checks of a storage's S3 endpoint
+type StorageTestReport struct {
+	Storage   string         `json:"storage"`
+	Endpoint  string         `json:"endpoint"`
+	Region    string         `json:"region"`
+	OK        bool           `json:"ok"`
+	LatencyMs int64          `json:"latency_ms"`
+	Buckets   int            `json:"buckets"`
+	Checks    []StorageCheck `json:"checks"`
+	CheckedAt time.Time      `json:"checked_at"`
+}
+
+// StorageDriftReport lists the differences between the storage table and the workers.
+// Unreachable workers are reported separately and not compared.
+type StorageDriftReport struct {
+	CheckedAt          time.Time         `json:"checked_at"`
+	InSync             bool              `json:"in_sync"`
+	Workers            []string          `json:"workers"`
+	UnreachableWorkers map[string]string `json:"unreachable_workers,omitempty"`
+	Drifts             []StorageDrift    `json:"drifts"`
+}
+
+// StorageDriftEvent records a drift found by the periodic check until it is resolved
+type StorageDriftEvent struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Worker      string     `gorm:"size:255;index;not null" json:"worker"`
+	Storage     string     `gorm:"size:255;index;not null" json:"storage"`
+	Kind        string     `gorm:"size:64;not null" json:"kind"`
+	DBValue     string     `gorm:"size:1024" json:"db_value"`
+	WorkerValue string     `gorm:"size:1024" json:"worker_value"`
+	DetectedAt  time.Time  `gorm:"index;not null" json:"detected_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+// TableName returns the table name for StorageDriftEvent
+func (StorageDriftEvent) TableName() string {
+	return "storage_drift_event"
+}
+
+// DriftEventFilter selects drift events, Open only returns unresolved events
+type DriftEventFilter struct {
+	Worker  string `form:"worker"`
+	Storage string `form:"storage"`
+	Open    bool   `form:"open"`
+	Limit   int    `form:"limit" binding:"min=0,max=1000"`
+}
+
+// Storage health states
+const (
+	StorageHealthUp      = "up"
+	StorageHealthDown    = "down"
+	StorageHealthUnknown = "unknown"
+)
+
+// StorageHealthCheck records a probe of a storage's S3 endpoint by the health monitor.
+// Changed marks the probes which changed the state of the storage, the first probe of a storage included.
+type StorageHealthCheck struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	StorageID uuid.UUID `gorm:"type:uuid;not null;index:idx_storage_health_check_storage_checked_at,priority:1" json:"storage_id"`
+	Up        bool      `gorm:"not null" json:"up"`
+	LatencyMs int64     `gorm:"not null" json:"latency_ms"`
+	Error     string    `gorm:"size:1024" json:"error,omitempty"`
+	Changed   bool      `gorm:"not null;default:false" json:"changed"`
+	CheckedAt time.Time `gorm:"not null;index;index:idx_storage_health_check_storage_checked_at,priority:2" json:"checked_at"`
+}
+
+// TableName returns the table name for StorageHealthCheck
+func (StorageHealthCheck) TableName() string {
+	return "storage_health_check"
+}
+
+// StorageHealthRequest selects the uptime window and the history of a storage's health.
+// Changes only lists the probes which changed the state.
+type StorageHealthRequest struct {
+	Window  time.Duration `form:"window" binding:"min=0" swaggertype:"string" example:"24h"`
+	Limit   int           `form:"limit" binding:"min=0,max=1000"`
+	Changes bool          `form:"changes"`
+}
+
+// StorageHealth is the state of a storage's S3 endpoint as probed by the health monitor.
+// UptimePercent is the share of successful probes in the window, omitted without probes.
+type StorageHealth struct {
+	StorageID     uuid.UUID            `json:"storage_id"`
+	Storage       string               `json:"storage"`
+	Status        string               `json:"status" example:"up"`
+	Since         *time.Time           `json:"since,omitempty"`
+	LastCheckedAt *time.Time           `json:"last_checked_at,omitempty"`
+	Window        string               `json:"window" example:"24h0m0s"`
+	Checks        int64                `json:"checks"`
+	UptimePercent *float64             `json:"uptime_percent,omitempty" example:"99.5"`
+	History       []StorageHealthCheck `json:"history"`
+}
+
+// ReplicateJobCreated is the status of the jobs written by the controller.
+// Jobs are deleted with their replication, so every stored job keeps its storages in use.
+const ReplicateJobCreated = "created"
+
 // ReplicateJob represents a replication job persisted in DB
 // Each bucket under a user/from/to is a row; ToBucket can be empty for same name.
 type ReplicateJob struct {
 	ID       uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Worker   string    `gorm:"size:255;index;not null;default:'default'" json:"worker"`
 	User     string    `gorm:"size:255;index;not null" json:"user"`
 	Bucket   string    `gorm:"size:255;index;not null" json:"bucket"`
 	From     string    `gorm:"size:255;index;not null" json:"from"`
@@ -88,6 +688,98 @@ func (ReplicateJob) TableName() string {
 	return "replicate_job"
 }
 
+// LegacyDefaultWorker is the worker of replication jobs recorded before the worker registry.
+// It stands for the configured default worker, whatever its name, and cannot be registered.
+const LegacyDefaultWorker = "default"
+
+// Worker represents a Chorus worker registered in the controller
+// TLS file paths are resolved on the controller host
+type Worker struct {
+	ID            uuid.UUID         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Name          string            `gorm:"uniqueIndex;size:255;not null" json:"name"`
+	Address       string            `gorm:"size:1024;not null" json:"address"`
+	TlsEnabled    bool              `json:"tls_enabled"`
+	TlsCaFile     string            `gorm:"size:1024" json:"tls_ca_file"`
+	TlsCertFile   string            `gorm:"size:1024" json:"tls_cert_file"`
+	TlsKeyFile    string            `gorm:"size:1024" json:"tls_key_file"`
+	TlsServerName string            `gorm:"size:255" json:"tls_server_name"`
+	Labels        map[string]string `gorm:"serializer:json;type:jsonb" json:"labels"`
+	Description   string            `gorm:"size:500" json:"description"`
+	CreatedAt     time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName returns the table name for Worker
+func (Worker) TableName() string {
+	return "worker"
+}
+
+// CreateWorkerRequest represents a request to register or update a worker
+type CreateWorkerRequest struct {
+	Name          string            `json:"name" binding:"required" example:"eu-west"`
+	Address       string            `json:"address" binding:"required" example:"chorus-worker-eu:9670"`
+	TlsEnabled    bool              `json:"tls_enabled"`
+	TlsCaFile     string            `json:"tls_ca_file" example:"/etc/chorus/tls/ca.pem"`
+	TlsCertFile   string            `json:"tls_cert_file"`
+	TlsKeyFile    string            `json:"tls_key_file"`
+	TlsServerName string            `json:"tls_server_name"`
+	Labels        map[string]string `json:"labels"`
+	Description   string            `json:"description"`
+}
+
+// WorkerStatus represents the connection and circuit breaker state of a worker
+type WorkerStatus struct {
+	Name    string        `json:"name"`
+	Address string        `json:"address"`
+	Builtin bool          `json:"builtin"`
+	State   string        `json:"state"`
+	Breaker *BreakerState `json:"breaker,omitempty"`
+}
+
+// BreakerState represents the circuit breaker guarding calls to a worker
+type BreakerState struct {
+	State               string     `json:"state" example:"closed"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+}
+
+// WorkerReplication is a replication reported by a worker tagged with the worker name
+type WorkerReplication struct {
+	Worker string `json:"worker"`
+	*pb.Replication
+}
+
+// ReplicationFilter selects replications, empty fields match everything
+type ReplicationFilter struct {
+	Worker string `form:"worker"`
+	User   string `form:"user"`
+	Bucket string `form:"bucket"`
+	From   string `form:"from"`
+	To     string `form:"to"`
+}
+
+// Replication stream event types
+const (
+	ReplicationEventUpdated = "replication"
+	ReplicationEventRemoved = "removed"
+	ReplicationEventError   = "error"
+)
+
+// ReplicationEvent is a change of a replication pushed to stream subscribers
+type ReplicationEvent struct {
+	Type        string             `json:"type"`
+	Replication *WorkerReplication `json:"replication,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// WorkerStorage is a storage reported by a worker tagged with the worker name
+type WorkerStorage struct {
+	Worker string `json:"worker"`
+	*pb.Storage
+}
+
 // TokenRequest represents a request to generate a token
 type TokenRequest struct {
 	Name        string     `json:"name" binding:"required" example:"api-client"`
@@ -15,9 +15,34 @@ var (
 
 // APIError represents an API error with HTTP status code
 type APIError struct {
-	Code    int    `json:"code"`
+	Code     int            `json:"code"`
+	Message  string         `json:"message"`
+	Upstream *UpstreamError `json:"upstream,omitempty"`
+	// Details carries data clients need to resolve the error, e.g. the resources causing a conflict
+	Details any   `json:"details,omitempty"`
+	Err     error `json:"-"`
+}
+
+// UpstreamError describes the error reported by a dependency such as the worker
+type UpstreamError struct {
+	// Source names the dependency that failed
+	Source string `json:"source"`
+	// Status is the dependency's own status code, e.g. the gRPC code name
+	Status string `json:"status"`
+	// Message is the error message returned by the dependency
 	Message string `json:"message"`
-	Err     error  `json:"-"`
+	// Retryable tells clients whether the same request may succeed later
+	Retryable bool `json:"retryable"`
+}
+
+// Is reports whether any error in err's chain matches target
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As finds the first error in err's chain that matches target
+func As(err error, target any) bool {
+	return errors.As(err, target)
 }
 
 func (e *APIError) Error() string {
@@ -36,6 +61,18 @@ func NewAPIError(code int, message string, err error) *APIError {
 	}
 }
 
+// WithUpstream attaches the dependency error details to the API error
+func (e *APIError) WithUpstream(upstream *UpstreamError) *APIError {
+	e.Upstream = upstream
+	return e
+}
+
+// WithDetails attaches data about the cause of the error
+func (e *APIError) WithDetails(details any) *APIError {
+	e.Details = details
+	return e
+}
+
 // Common error constructors
 func NewBadRequestError(message string, err error) *APIError {
 	return NewAPIError(http.StatusBadRequest, message, err)
@@ -56,3 +93,19 @@ func NewNotFoundError(message string, err error) *APIError {
 func NewUnauthorizedError(message string, err error) *APIError {
 	return NewAPIError(http.StatusUnauthorized, message, err)
 }
+
+func NewConflictError(message string, err error) *APIError {
+	return NewAPIError(http.StatusConflict, message, err)
+}
+
+func NewServiceUnavailableError(message string, err error) *APIError {
+	return NewAPIError(http.StatusServiceUnavailable, message, err)
+}
+
+func NewPreconditionFailedError(message string, err error) *APIError {
+	return NewAPIError(http.StatusPreconditionFailed, message, err)
+}
+
+func NewGatewayTimeoutError(message string, err error) *APIError {
+	return NewAPIError(http.StatusGatewayTimeout, message, err)
+}
@@ -0,0 +1,164 @@
+// Package fakes3 provides an in-process S3 endpoint for tests.
+//
+// It verifies Signature Version 4 like a real storage and answers the read-only
+// requests used to check storages: ListBuckets and GetBucketLocation.
+package fakes3
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hantdev/chorus-controller/internal/sigv4"
+)
+
+// Server is a fake S3 endpoint serving a single region
+type Server struct {
+	region string
+
+	mu      sync.Mutex
+	users   map[string]string
+	buckets map[string]string
+	denied  map[string]bool
+}
+
+// New creates a fake S3 endpoint accepting requests signed for region
+func New(region string) *Server {
+	return &Server{
+		region:  region,
+		users:   make(map[string]string),
+		buckets: make(map[string]string),
+		denied:  make(map[string]bool),
+	}
+}
+
+// AddUser adds S3 credentials
+func (s *Server) AddUser(accessKeyID, secretAccessKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[accessKeyID] = secretAccessKey
+}
+
+// DenyListBuckets makes ListBuckets fail with AccessDenied for a user
+func (s *Server) DenyListBuckets(accessKeyID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.denied[accessKeyID] = true
+}
+
+// AddBucket adds a bucket, region defaults to the region of the server
+func (s *Server) AddBucket(name, region string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if region == "" {
+		region = s.region
+	}
+	s.buckets[name] = region
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+	Region  string   `xml:"Region,omitempty"`
+}
+
+type listBucketsResponse struct {
+	XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+	Buckets []bucket `xml:"Buckets>Bucket"`
+}
+
+type bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type locationResponse struct {
+	XMLName  xml.Name `xml:"LocationConstraint"`
+	Location string   `xml:",chardata"`
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accessKeyID, failed := s.authenticateLocked(w, r)
+	if failed {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeXML(w, http.StatusNotImplemented, errorResponse{Code: "NotImplemented", Message: "A header you provided implies functionality that is not implemented."})
+		return
+	}
+
+	name := strings.Trim(r.URL.Path, "/")
+	switch {
+	case name == "":
+		if s.denied[accessKeyID] {
+			writeXML(w, http.StatusForbidden, errorResponse{Code: "AccessDenied", Message: "Access Denied."})
+			return
+		}
+		resp := listBucketsResponse{}
+		for name := range s.buckets {
+			resp.Buckets = append(resp.Buckets, bucket{Name: name, CreationDate: time.Unix(0, 0).UTC().Format(time.RFC3339)})
+		}
+		sort.Slice(resp.Buckets, func(i, j int) bool { return resp.Buckets[i].Name < resp.Buckets[j].Name })
+		writeXML(w, http.StatusOK, resp)
+	case r.URL.Query().Has("location"):
+		region, ok := s.buckets[name]
+		if !ok {
+			writeXML(w, http.StatusNotFound, errorResponse{Code: "NoSuchBucket", Message: "The specified bucket does not exist."})
+			return
+		}
+		// Like AWS, the default region is reported as an empty constraint
+		if region == "us-east-1" {
+			region = ""
+		}
+		writeXML(w, http.StatusOK, locationResponse{Location: region})
+	default:
+		writeXML(w, http.StatusNotImplemented, errorResponse{Code: "NotImplemented", Message: "A header you provided implies functionality that is not implemented."})
+	}
+}
+
+// authenticateLocked verifies the signature of a request and writes the S3 error when it is invalid
+func (s *Server) authenticateLocked(w http.ResponseWriter, r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		writeXML(w, http.StatusForbidden, errorResponse{Code: "AccessDenied", Message: "Access Denied."})
+		return "", true
+	}
+	auth, err := sigv4.Parse(header)
+	if err != nil {
+		writeXML(w, http.StatusBadRequest, errorResponse{Code: "InvalidRequest", Message: "The authorization mechanism you have provided is not supported. Please use AWS4-HMAC-SHA256."})
+		return "", true
+	}
+	secret, ok := s.users[auth.AccessKeyID]
+	if !ok {
+		writeXML(w, http.StatusForbidden, errorResponse{Code: "InvalidAccessKeyId", Message: "The AWS Access Key Id you provided does not exist in our records."})
+		return "", true
+	}
+	if auth.Region != s.region {
+		writeXML(w, http.StatusBadRequest, errorResponse{
+			Code:    "AuthorizationHeaderMalformed",
+			Message: "The authorization header is malformed; the region '" + auth.Region + "' is wrong; expecting '" + s.region + "'",
+			Region:  s.region,
+		})
+		return "", true
+	}
+	if sigv4.Signature(r, secret, auth) != auth.Signature {
+		writeXML(w, http.StatusForbidden, errorResponse{Code: "SignatureDoesNotMatch", Message: "The request signature we calculated does not match the signature you provided."})
+		return "", true
+	}
+	return auth.AccessKeyID, false
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,457 @@
+package fakeworker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// streamInterval is how often StreamBucketReplication checks for progress
+const streamInterval = 100 * time.Millisecond
+
+// replicationKey identifies a bucket replication, toBucket equals bucket unless renamed
+type replicationKey struct {
+	user, bucket, from, to, toBucket string
+}
+
+// userReplicationKey identifies a replication of all buckets of a user
+type userReplicationKey struct {
+	user, from, to string
+}
+
+// replication is the simulated state of a bucket replication
+type replication struct {
+	createdAt   time.Time
+	updatedAt   time.Time
+	initDoneAt  time.Time
+	archivedAt  time.Time
+	objectsDone float64
+	paused      bool
+	archived    bool
+	agentURL    *string
+	renamed     bool
+}
+
+// GetAppVersion returns the version of the fake worker
+func (s *Server) GetAppVersion(context.Context, *emptypb.Empty) (*pb.GetAppVersionResponse, error) {
+	return &pb.GetAppVersionResponse{Version: "fake", Commit: "none", Date: "unknown"}, nil
+}
+
+// GetStorages returns all storages ordered by name
+func (s *Server) GetStorages(context.Context, *emptypb.Empty) (*pb.GetStoragesResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := &pb.GetStoragesResponse{}
+	for _, st := range s.storages {
+		resp.Storages = append(resp.Storages, proto.Clone(st.info).(*pb.Storage))
+	}
+	sort.Slice(resp.Storages, func(i, j int) bool {
+		return resp.Storages[i].Name < resp.Storages[j].Name
+	})
+	return resp, nil
+}
+
+// GetAgents returns no agents, the fake worker only supports proxy replication
+func (s *Server) GetAgents(context.Context, *emptypb.Empty) (*pb.GetAgentsResponse, error) {
+	return &pb.GetAgentsResponse{}, nil
+}
+
+// ListBucketsForReplication lists the user's buckets on the source storage
+func (s *Server) ListBucketsForReplication(_ context.Context, req *pb.ListBucketsForReplicationRequest) (*pb.ListBucketsForReplicationResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.validateUserLocked(req.User, req.From, req.To); err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ListBucketsForReplicationResponse{}
+	for _, bucket := range sortedKeys(s.storages[req.From].buckets[req.User]) {
+		if s.isReplicatedLocked(req.User, bucket, req.From, req.To) {
+			if req.ShowReplicated {
+				resp.ReplicatedBuckets = append(resp.ReplicatedBuckets, bucket)
+			}
+			continue
+		}
+		resp.Buckets = append(resp.Buckets, bucket)
+	}
+	return resp, nil
+}
+
+// AddReplication creates replications for the given buckets or for all buckets of the user
+func (s *Server) AddReplication(_ context.Context, req *pb.AddReplicationRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.validateUserLocked(req.User, req.From, req.To); err != nil {
+		return nil, err
+	}
+
+	buckets := req.Buckets
+	if req.IsForAllBuckets {
+		if len(buckets) > 0 {
+			return nil, status.Error(codes.InvalidArgument, "buckets must be empty when replicating all buckets")
+		}
+		key := userReplicationKey{user: req.User, from: req.From, to: req.To}
+		if _, ok := s.userReplications[key]; ok {
+			return nil, status.Errorf(codes.AlreadyExists, "user replication %s from %s to %s already exists", req.User, req.From, req.To)
+		}
+		s.userReplications[key] = &pb.UserReplication{User: req.User, From: req.From, To: req.To}
+		buckets = sortedKeys(s.storages[req.From].buckets[req.User])
+	} else if len(buckets) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "buckets are required")
+	}
+	if req.ToBucket != nil && *req.ToBucket != "" && len(buckets) != 1 {
+		return nil, status.Error(codes.InvalidArgument, "to_bucket requires exactly one bucket")
+	}
+
+	keys := make([]replicationKey, 0, len(buckets))
+	for _, bucket := range buckets {
+		key := newReplicationKey(req.User, bucket, req.From, req.To, req.ToBucket)
+		if err := s.checkNewReplicationLocked(key, !req.IsForAllBuckets); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		if _, ok := s.replications[key]; ok {
+			// Already replicated buckets are kept as is when replicating all buckets
+			continue
+		}
+		s.addReplicationLocked(key, req.AgentUrl)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// AddBucketReplication creates a replication of a single bucket
+func (s *Server) AddBucketReplication(_ context.Context, req *pb.AddBucketReplicationRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.validateUserLocked(req.User, req.FromStorage, req.ToStorage); err != nil {
+		return nil, err
+	}
+	key := newReplicationKey(req.User, req.FromBucket, req.FromStorage, req.ToStorage, req.ToBucket)
+	if err := s.checkNewReplicationLocked(key, true); err != nil {
+		return nil, err
+	}
+	s.addReplicationLocked(key, req.AgentUrl)
+	return &emptypb.Empty{}, nil
+}
+
+// ListReplications returns all bucket replications with simulated progress
+func (s *Server) ListReplications(context.Context, *emptypb.Empty) (*pb.ListReplicationsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshLocked()
+
+	keys := make([]replicationKey, 0, len(s.replications))
+	for key := range s.replications {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.user != b.user {
+			return a.user < b.user
+		}
+		if a.bucket != b.bucket {
+			return a.bucket < b.bucket
+		}
+		if a.from != b.from {
+			return a.from < b.from
+		}
+		if a.to != b.to {
+			return a.to < b.to
+		}
+		return a.toBucket < b.toBucket
+	})
+
+	resp := &pb.ListReplicationsResponse{}
+	for _, key := range keys {
+		resp.Replications = append(resp.Replications, s.replicationProtoLocked(key))
+	}
+	return resp, nil
+}
+
+// ListUserReplications returns all replications created for all buckets of a user
+func (s *Server) ListUserReplications(context.Context, *emptypb.Empty) (*pb.ListUserReplicationsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := &pb.ListUserReplicationsResponse{}
+	for _, r := range s.userReplications {
+		resp.Replications = append(resp.Replications, proto.Clone(r).(*pb.UserReplication))
+	}
+	sort.Slice(resp.Replications, func(i, j int) bool {
+		a, b := resp.Replications[i], resp.Replications[j]
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return resp, nil
+}
+
+// GetReplication returns a single bucket replication
+func (s *Server) GetReplication(_ context.Context, req *pb.ReplicationRequest) (*pb.Replication, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshLocked()
+
+	key, _, err := s.findReplicationLocked(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.replicationProtoLocked(key), nil
+}
+
+// StreamBucketReplication sends the replication state whenever it changes until the client disconnects
+func (s *Server) StreamBucketReplication(req *pb.ReplicationRequest, stream grpc.ServerStreamingServer[pb.Replication]) error {
+	ticker := time.NewTicker(streamInterval)
+	defer ticker.Stop()
+
+	var last *pb.Replication
+	for {
+		s.mu.Lock()
+		s.refreshLocked()
+		key, _, err := s.findReplicationLocked(req)
+		var current *pb.Replication
+		if err == nil {
+			current = s.replicationProtoLocked(key)
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			// Like the real worker, the stream fails once the replication is deleted
+			return err
+		}
+		if last == nil || !proto.Equal(last, current) {
+			if err := stream.Send(current); err != nil {
+				return err
+			}
+			last = current
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// PauseReplication stops the simulated progress of a replication
+func (s *Server) PauseReplication(_ context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	return s.setPaused(req, true)
+}
+
+// ResumeReplication continues the simulated progress of a replication
+func (s *Server) ResumeReplication(_ context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	return s.setPaused(req, false)
+}
+
+func (s *Server) setPaused(req *pb.ReplicationRequest, paused bool) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshLocked()
+
+	_, r, err := s.findReplicationLocked(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.archived {
+		return nil, status.Error(codes.FailedPrecondition, "replication is archived")
+	}
+	r.paused = paused
+	return &emptypb.Empty{}, nil
+}
+
+// DeleteReplication removes a bucket replication and its switch
+func (s *Server) DeleteReplication(_ context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, _, err := s.findReplicationLocked(req)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.replications, key)
+	delete(s.switches, key)
+	return &emptypb.Empty{}, nil
+}
+
+// DeleteUserReplication removes a user replication and optionally its bucket replications
+func (s *Server) DeleteUserReplication(_ context.Context, req *pb.DeleteUserReplicationRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := userReplicationKey{user: req.User, from: req.From, to: req.To}
+	if _, ok := s.userReplications[key]; !ok {
+		return nil, status.Errorf(codes.NotFound, "user replication %s from %s to %s not found", req.User, req.From, req.To)
+	}
+	delete(s.userReplications, key)
+
+	if req.DeleteBucketReplications {
+		for rk := range s.replications {
+			if rk.user == req.User && rk.from == req.From && rk.to == req.To {
+				delete(s.replications, rk)
+				delete(s.switches, rk)
+			}
+		}
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// validateUserLocked checks that both storages exist and know the user
+func (s *Server) validateUserLocked(user, from, to string) error {
+	if user == "" || from == "" || to == "" {
+		return status.Error(codes.InvalidArgument, "user, from and to are required")
+	}
+	for _, name := range []string{from, to} {
+		st, ok := s.storages[name]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "unknown storage %q", name)
+		}
+		if _, ok := st.buckets[user]; !ok {
+			return status.Errorf(codes.InvalidArgument, "user %q has no credentials for storage %q", user, name)
+		}
+	}
+	return nil
+}
+
+// checkNewReplicationLocked validates that a replication can be created for key
+func (s *Server) checkNewReplicationLocked(key replicationKey, mustBeNew bool) error {
+	if _, ok := s.storages[key.from].buckets[key.user][key.bucket]; !ok {
+		return status.Errorf(codes.NotFound, "bucket %q not found on storage %q", key.bucket, key.from)
+	}
+	if key.from == key.to && key.bucket == key.toBucket {
+		return status.Error(codes.InvalidArgument, "cannot replicate a bucket to itself")
+	}
+	if _, ok := s.replications[key]; ok && mustBeNew {
+		return status.Errorf(codes.AlreadyExists, "replication of bucket %q from %s to %s already exists", key.bucket, key.from, key.to)
+	}
+	return nil
+}
+
+// addReplicationLocked creates the replication and the destination bucket
+func (s *Server) addReplicationLocked(key replicationKey, agentURL *string) {
+	now := s.opts.Now()
+	s.replications[key] = &replication{
+		createdAt: now,
+		updatedAt: now,
+		agentURL:  agentURL,
+		renamed:   key.toBucket != key.bucket,
+	}
+	s.storages[key.to].buckets[key.user][key.toBucket] = struct{}{}
+}
+
+// isReplicatedLocked reports whether a bucket already has an active replication between the storages
+func (s *Server) isReplicatedLocked(user, bucket, from, to string) bool {
+	for key, r := range s.replications {
+		if key.user == user && key.bucket == bucket && key.from == from && key.to == to && !r.archived {
+			return true
+		}
+	}
+	return false
+}
+
+// findReplicationLocked looks up the replication identified by req
+func (s *Server) findReplicationLocked(req *pb.ReplicationRequest) (replicationKey, *replication, error) {
+	key := newReplicationKey(req.User, req.Bucket, req.From, req.To, req.ToBucket)
+	r, ok := s.replications[key]
+	if !ok {
+		return key, nil, status.Errorf(codes.NotFound, "replication of bucket %q from %s to %s not found", req.Bucket, req.From, req.To)
+	}
+	return key, r, nil
+}
+
+// refreshLocked advances the simulated replications and switches to the current time
+func (s *Server) refreshLocked() {
+	now := s.opts.Now()
+	total := float64(s.opts.ObjectsPerBucket)
+
+	for _, r := range s.replications {
+		if !r.paused && !r.archived && r.objectsDone < total {
+			elapsed := now.Sub(r.updatedAt).Seconds()
+			if elapsed > 0 {
+				r.objectsDone += elapsed * s.opts.ObjectsPerSecond
+			}
+			if r.objectsDone >= total {
+				r.objectsDone = total
+				r.initDoneAt = now
+			}
+		}
+		r.updatedAt = now
+	}
+
+	s.refreshSwitchesLocked(now)
+}
+
+// replicationProtoLocked converts the simulated state into the worker API model
+func (s *Server) replicationProtoLocked(key replicationKey) *pb.Replication {
+	r := s.replications[key]
+	done := int64(r.objectsDone)
+
+	resp := &pb.Replication{
+		User:          key.user,
+		Bucket:        key.bucket,
+		From:          key.from,
+		To:            key.to,
+		CreatedAt:     timestamppb.New(r.createdAt),
+		IsPaused:      r.paused,
+		InitObjListed: s.opts.ObjectsPerBucket,
+		InitObjDone:   done,
+		// Bucket listing is instant in the simulation
+		InitBytesListed: s.opts.ObjectsPerBucket * s.opts.ObjectSize,
+		InitBytesDone:   done * s.opts.ObjectSize,
+		AgentUrl:        r.agentURL,
+		IsArchived:      r.archived,
+	}
+	if r.renamed {
+		toBucket := key.toBucket
+		resp.ToBucket = &toBucket
+	}
+	if !r.initDoneAt.IsZero() {
+		resp.IsInitDone = true
+		resp.InitDoneAt = timestamppb.New(r.initDoneAt)
+		resp.LastProcessedAt = timestamppb.New(r.initDoneAt)
+	}
+	if r.archived {
+		resp.ArchivedAt = timestamppb.New(r.archivedAt)
+	}
+	if _, ok := s.switches[key]; ok {
+		resp.HasSwitch = true
+	}
+	return resp
+}
+
+// newReplicationKey builds a key, an empty destination bucket means the source bucket name
+func newReplicationKey(user, bucket, from, to string, toBucket *string) replicationKey {
+	key := replicationKey{user: user, bucket: bucket, from: from, to: to, toBucket: bucket}
+	if toBucket != nil && *toBucket != "" {
+		key.toBucket = *toBucket
+	}
+	return key
+}
+
+// objectKey returns the name of the n-th simulated object of a bucket
+func objectKey(n int64) string {
+	return fmt.Sprintf("object-%06d", n)
+}
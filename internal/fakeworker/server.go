@@ -0,0 +1,163 @@
+// Package fakeworker provides an in-memory implementation of the Chorus worker
+// gRPC API for tests and local development.
+//
+// Storages, buckets and replications live in memory. Initial replication
+// progress is simulated from the elapsed time, so replications move from
+// listed to done without any real S3 traffic.
+package fakeworker
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"google.golang.org/grpc"
+)
+
+// Options configures the simulated worker
+type Options struct {
+	// ObjectsPerBucket is the number of objects every bucket reports to the initial replication
+	ObjectsPerBucket int64
+	// ObjectSize is the size in bytes of every simulated object
+	ObjectSize int64
+	// ObjectsPerSecond is the simulated initial replication throughput per bucket
+	ObjectsPerSecond float64
+	// Now returns the current time, tests can replace it to control progress
+	Now func() time.Time
+}
+
+// Server implements pb.ChorusServer with in-memory state
+type Server struct {
+	pb.UnimplementedChorusServer
+
+	opts Options
+
+	mu               sync.Mutex
+	storages         map[string]*storage
+	replications     map[replicationKey]*replication
+	userReplications map[userReplicationKey]*pb.UserReplication
+	switches         map[replicationKey]*bucketSwitch
+
+	grpcServer *grpc.Server
+}
+
+// storage is a simulated S3 storage with buckets per user
+type storage struct {
+	info    *pb.Storage
+	buckets map[string]map[string]struct{}
+}
+
+// New creates a fake worker without storages
+func New(opts Options) *Server {
+	if opts.ObjectsPerBucket <= 0 {
+		opts.ObjectsPerBucket = 1000
+	}
+	if opts.ObjectSize <= 0 {
+		opts.ObjectSize = 1 << 20
+	}
+	if opts.ObjectsPerSecond <= 0 {
+		opts.ObjectsPerSecond = 100
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Server{
+		opts:             opts,
+		storages:         make(map[string]*storage),
+		replications:     make(map[replicationKey]*replication),
+		userReplications: make(map[userReplicationKey]*pb.UserReplication),
+		switches:         make(map[replicationKey]*bucketSwitch),
+	}
+}
+
+// AddStorage registers a storage with credentials for the given users
+func (s *Server) AddStorage(name, address string, provider pb.Storage_Provider, isMain bool, users ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := &pb.Storage{
+		Name:     name,
+		IsMain:   isMain,
+		Address:  address,
+		Provider: provider,
+	}
+	buckets := make(map[string]map[string]struct{})
+	for _, user := range users {
+		info.Credentials = append(info.Credentials, &pb.Credential{
+			Alias:     user,
+			AccessKey: user + "-access-key",
+		})
+		buckets[user] = make(map[string]struct{})
+	}
+	s.storages[name] = &storage{info: info, buckets: buckets}
+}
+
+// AddBuckets creates buckets of a user on a storage
+func (s *Server) AddBuckets(storageName, user string, buckets ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.storages[storageName]
+	if !ok {
+		return fmt.Errorf("storage %q not found", storageName)
+	}
+	userBuckets, ok := st.buckets[user]
+	if !ok {
+		return fmt.Errorf("user %q not found on storage %q", user, storageName)
+	}
+	for _, b := range buckets {
+		userBuckets[b] = struct{}{}
+	}
+	return nil
+}
+
+// Listen serves the worker API on addr in the background and returns the bound address
+// Use "127.0.0.1:0" to pick a free loopback port
+func (s *Server) Listen(addr string) (net.Addr, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	return lis.Addr(), nil
+}
+
+// Serve serves the worker API on lis and blocks until Stop is called
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	if s.grpcServer == nil {
+		s.grpcServer = grpc.NewServer()
+		pb.RegisterChorusServer(s.grpcServer, s)
+	}
+	srv := s.grpcServer
+	s.mu.Unlock()
+
+	return srv.Serve(lis)
+}
+
+// Stop stops serving and closes all client connections
+func (s *Server) Stop() {
+	s.mu.Lock()
+	srv := s.grpcServer
+	s.mu.Unlock()
+
+	if srv != nil {
+		srv.Stop()
+	}
+}
+
+// sortedKeys returns the keys of a set in lexical order
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
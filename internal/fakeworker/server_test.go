@@ -0,0 +1,185 @@
+package fakeworker_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/fakeworker"
+	"github.com/hantdev/chorus-controller/internal/repository"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// clock is a manually advanced time source
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func startWorker(t *testing.T) (*repository.WorkerRepository, *clock) {
+	t.Helper()
+
+	clk := &clock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	srv := fakeworker.New(fakeworker.Options{
+		ObjectsPerBucket: 100,
+		ObjectSize:       10,
+		ObjectsPerSecond: 10,
+		Now:              clk.Now,
+	})
+	srv.AddStorage("main", "main.s3.local", pb.Storage_Ceph, true, "admin")
+	srv.AddStorage("follower", "follower.s3.local", pb.Storage_Minio, false, "admin")
+	if err := srv.AddBuckets("main", "admin", "photos", "logs"); err != nil {
+		t.Fatal(err)
+	}
+
+	addr, err := srv.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+
+	client, err := repository.NewWorkerRepository(addr.String(), repository.WorkerTLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, clk
+}
+
+func TestReplicationProgress(t *testing.T) {
+	client, clk := startWorker(t)
+	ctx := context.Background()
+
+	_, err := client.AddReplication(ctx, &pb.AddReplicationRequest{
+		User: "admin", From: "main", To: "follower", Buckets: []string{"photos"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Advance(5 * time.Second)
+	resp, err := client.ListReplications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Replications) != 1 {
+		t.Fatalf("expected 1 replication, got %d", len(resp.Replications))
+	}
+	r := resp.Replications[0]
+	if r.InitObjDone != 50 || r.IsInitDone {
+		t.Fatalf("expected half done replication, got %d/%d", r.InitObjDone, r.InitObjListed)
+	}
+
+	// Paused replications do not progress
+	req := &pb.ReplicationRequest{User: "admin", Bucket: "photos", From: "main", To: "follower"}
+	if _, err := client.PauseReplication(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Minute)
+	resp, _ = client.ListReplications(ctx)
+	if got := resp.Replications[0].InitObjDone; got != 50 {
+		t.Fatalf("expected paused replication to stay at 50, got %d", got)
+	}
+
+	if _, err := client.ResumeReplication(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Minute)
+	resp, _ = client.ListReplications(ctx)
+	if r := resp.Replications[0]; !r.IsInitDone || r.InitObjDone != 100 || r.InitBytesDone != 1000 {
+		t.Fatalf("expected finished replication, got %+v", r)
+	}
+
+	buckets, err := client.ListBucketsForReplication(ctx, &pb.ListBucketsForReplicationRequest{
+		User: "admin", From: "main", To: "follower", ShowReplicated: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets.Buckets) != 1 || buckets.Buckets[0] != "logs" || len(buckets.ReplicatedBuckets) != 1 {
+		t.Fatalf("unexpected buckets: %+v", buckets)
+	}
+}
+
+func TestReplicationErrors(t *testing.T) {
+	client, _ := startWorker(t)
+	ctx := context.Background()
+
+	add := &pb.AddReplicationRequest{User: "admin", From: "main", To: "follower", Buckets: []string{"photos"}}
+	if _, err := client.AddReplication(ctx, add); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"duplicate", func() error {
+			_, err := client.AddReplication(ctx, add)
+			return err
+		}, codes.AlreadyExists},
+		{"unknown storage", func() error {
+			_, err := client.AddReplication(ctx, &pb.AddReplicationRequest{User: "admin", From: "main", To: "missing", Buckets: []string{"photos"}})
+			return err
+		}, codes.InvalidArgument},
+		{"missing replication", func() error {
+			_, err := client.PauseReplication(ctx, &pb.ReplicationRequest{User: "admin", Bucket: "logs", From: "main", To: "follower"})
+			return err
+		}, codes.NotFound},
+		{"switch before init done", func() error {
+			_, err := client.SwitchBucketZeroDowntime(ctx, &pb.SwitchBucketZeroDowntimeRequest{
+				ReplicationId: &pb.ReplicationRequest{User: "admin", Bucket: "photos", From: "main", To: "follower"},
+			})
+			return err
+		}, codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestZeroDowntimeSwitch(t *testing.T) {
+	client, clk := startWorker(t)
+	ctx := context.Background()
+
+	if _, err := client.AddReplication(ctx, &pb.AddReplicationRequest{
+		User: "admin", From: "main", To: "follower", Buckets: []string{"logs"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Minute)
+
+	id := &pb.ReplicationRequest{User: "admin", Bucket: "logs", From: "main", To: "follower"}
+	if _, err := client.SwitchBucketZeroDowntime(ctx, &pb.SwitchBucketZeroDowntimeRequest{ReplicationId: id}); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Minute)
+
+	resp, err := client.ListReplications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := resp.Replications[0]; !r.HasSwitch || !r.IsArchived {
+		t.Fatalf("expected switched and archived replication, got %+v", r)
+	}
+}
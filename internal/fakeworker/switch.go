@@ -0,0 +1,284 @@
+package fakeworker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// switchDuration is how long a started switch stays in progress
+const switchDuration = time.Second
+
+// bucketSwitch is the simulated state of a bucket switch
+type bucketSwitch struct {
+	zeroDowntime bool
+	multipartTTL *durationpb.Duration
+	downtime     *pb.SwitchDowntimeOpts
+	status       pb.GetBucketSwitchStatusResponse_Status
+	startedAt    time.Time
+	doneAt       time.Time
+	history      []string
+}
+
+// SwitchBucketZeroDowntime switches the bucket right away, the initial replication must be done
+func (s *Server) SwitchBucketZeroDowntime(_ context.Context, req *pb.SwitchBucketZeroDowntimeRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshLocked()
+
+	key, r, err := s.newSwitchLocked(req.ReplicationId)
+	if err != nil {
+		return nil, err
+	}
+	if r.initDoneAt.IsZero() {
+		return nil, status.Error(codes.FailedPrecondition, "initial replication is not done")
+	}
+
+	sw := &bucketSwitch{zeroDowntime: true, multipartTTL: req.MultipartTtl}
+	s.switches[key] = sw
+	s.setSwitchStatusLocked(sw, pb.GetBucketSwitchStatusResponse_InProgress, s.opts.Now(), "zero downtime switch started")
+	return &emptypb.Empty{}, nil
+}
+
+// SwitchBucket schedules a switch with downtime according to the downtime options
+func (s *Server) SwitchBucket(_ context.Context, req *pb.SwitchBucketRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshLocked()
+
+	opts := req.DowntimeOpts
+	if opts != nil && opts.Cron != nil && opts.StartAt != nil {
+		return nil, status.Error(codes.InvalidArgument, "either cron or start_at can be set, but not both")
+	}
+	key, _, err := s.newSwitchLocked(req.ReplicationId)
+	if err != nil {
+		return nil, err
+	}
+
+	sw := &bucketSwitch{downtime: opts}
+	s.switches[key] = sw
+	now := s.opts.Now()
+	if opts == nil {
+		s.setSwitchStatusLocked(sw, pb.GetBucketSwitchStatusResponse_InProgress, now, "switch started")
+	} else {
+		s.setSwitchStatusLocked(sw, pb.GetBucketSwitchStatusResponse_NotStarted, now, "switch scheduled")
+	}
+	s.refreshSwitchesLocked(now)
+	return &emptypb.Empty{}, nil
+}
+
+// DeleteBucketSwitch aborts a switch and removes its metadata
+func (s *Server) DeleteBucketSwitch(_ context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := newReplicationKey(req.User, req.Bucket, req.From, req.To, req.ToBucket)
+	if _, ok := s.switches[key]; !ok {
+		return nil, status.Errorf(codes.NotFound, "switch for bucket %q from %s to %s not found", req.Bucket, req.From, req.To)
+	}
+	delete(s.switches, key)
+	return &emptypb.Empty{}, nil
+}
+
+// GetBucketSwitchStatus returns the state of a switch
+func (s *Server) GetBucketSwitchStatus(_ context.Context, req *pb.ReplicationRequest) (*pb.GetBucketSwitchStatusResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshLocked()
+
+	key := newReplicationKey(req.User, req.Bucket, req.From, req.To, req.ToBucket)
+	if _, ok := s.switches[key]; !ok {
+		return nil, status.Errorf(codes.NotFound, "switch for bucket %q from %s to %s not found", req.Bucket, req.From, req.To)
+	}
+	return s.switchProtoLocked(key), nil
+}
+
+// ListReplicationSwitches returns all switches
+func (s *Server) ListReplicationSwitches(context.Context, *emptypb.Empty) (*pb.ListSwitchResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshLocked()
+
+	resp := &pb.ListSwitchResponse{}
+	for key := range s.switches {
+		resp.Switches = append(resp.Switches, s.switchProtoLocked(key))
+	}
+	sort.Slice(resp.Switches, func(i, j int) bool {
+		a, b := resp.Switches[i].ReplicationId, resp.Switches[j].ReplicationId
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		if a.Bucket != b.Bucket {
+			return a.Bucket < b.Bucket
+		}
+		return a.To < b.To
+	})
+	return resp, nil
+}
+
+// CompareBucket compares the simulated objects of the source and destination buckets.
+// Objects not yet copied by the initial replication are missing in the destination.
+func (s *Server) CompareBucket(_ context.Context, req *pb.CompareBucketRequest) (*pb.CompareBucketResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshLocked()
+
+	if err := s.validateUserLocked(req.User, req.From, req.To); err != nil {
+		return nil, err
+	}
+	key := newReplicationKey(req.User, req.Bucket, req.From, req.To, req.ToBucket)
+	if _, ok := s.storages[key.from].buckets[key.user][key.bucket]; !ok {
+		return nil, status.Errorf(codes.NotFound, "bucket %q not found on storage %q", key.bucket, key.from)
+	}
+
+	var copied int64
+	if r, ok := s.replications[key]; ok {
+		copied = int64(r.objectsDone)
+	}
+
+	resp := &pb.CompareBucketResponse{}
+	for n := int64(0); n < s.opts.ObjectsPerBucket; n++ {
+		if n < copied {
+			if req.ShowMatch {
+				resp.Match = append(resp.Match, objectKey(n))
+			}
+			continue
+		}
+		resp.MissTo = append(resp.MissTo, objectKey(n))
+	}
+	resp.IsMatch = len(resp.MissTo) == 0
+	return resp, nil
+}
+
+// newSwitchLocked validates that a switch can be created for the replication
+func (s *Server) newSwitchLocked(id *pb.ReplicationRequest) (replicationKey, *replication, error) {
+	if id == nil {
+		return replicationKey{}, nil, status.Error(codes.InvalidArgument, "replication_id is required")
+	}
+	key, r, err := s.findReplicationLocked(id)
+	if err != nil {
+		return key, nil, err
+	}
+	if r.archived {
+		return key, nil, status.Error(codes.FailedPrecondition, "replication is archived")
+	}
+	if sw, ok := s.switches[key]; ok && sw.status != pb.GetBucketSwitchStatusResponse_Error {
+		return key, nil, status.Errorf(codes.AlreadyExists, "switch for bucket %q already exists", key.bucket)
+	}
+	return key, r, nil
+}
+
+// refreshSwitchesLocked moves switches through their lifecycle
+func (s *Server) refreshSwitchesLocked(now time.Time) {
+	for key, sw := range s.switches {
+		r := s.replications[key]
+		switch sw.status {
+		case pb.GetBucketSwitchStatusResponse_NotStarted:
+			opts := sw.downtime
+			initDone := !r.initDoneAt.IsZero()
+			switch {
+			case opts.StartAt != nil:
+				if now.Before(opts.StartAt.AsTime()) {
+					continue
+				}
+				if !initDone {
+					s.setSwitchStatusLocked(sw, pb.GetBucketSwitchStatusResponse_Error, now, "initial replication is not done at start time")
+					continue
+				}
+			case opts.StartOnInitDone || opts.Cron != nil:
+				if !initDone {
+					continue
+				}
+			}
+			s.setSwitchStatusLocked(sw, pb.GetBucketSwitchStatusResponse_InProgress, now, "switch started")
+		case pb.GetBucketSwitchStatusResponse_InProgress, pb.GetBucketSwitchStatusResponse_CheckInProgress:
+			elapsed := now.Sub(sw.startedAt)
+			checked := sw.zeroDowntime || (sw.downtime != nil && sw.downtime.SkipBucketCheck)
+			if !checked && sw.status == pb.GetBucketSwitchStatusResponse_InProgress && elapsed >= switchDuration/2 {
+				s.setSwitchStatusLocked(sw, pb.GetBucketSwitchStatusResponse_CheckInProgress, now, "checking bucket contents")
+			}
+			if elapsed >= switchDuration {
+				s.completeSwitchLocked(key, sw, now)
+			}
+		}
+	}
+}
+
+// completeSwitchLocked archives the switched replication and optionally replicates back to the old main
+func (s *Server) completeSwitchLocked(key replicationKey, sw *bucketSwitch, now time.Time) {
+	s.setSwitchStatusLocked(sw, pb.GetBucketSwitchStatusResponse_Done, now, "switch done")
+	sw.doneAt = now
+
+	r := s.replications[key]
+	r.archived = true
+	r.archivedAt = now
+
+	if sw.downtime != nil && sw.downtime.ContinueReplication {
+		reverse := replicationKey{user: key.user, bucket: key.toBucket, from: key.to, to: key.from, toBucket: key.bucket}
+		if _, ok := s.replications[reverse]; !ok {
+			s.replications[reverse] = &replication{
+				createdAt:   now,
+				updatedAt:   now,
+				initDoneAt:  now,
+				objectsDone: float64(s.opts.ObjectsPerBucket),
+				renamed:     reverse.toBucket != reverse.bucket,
+			}
+		}
+	}
+}
+
+// setSwitchStatusLocked changes the status of a switch and records it in the history
+func (s *Server) setSwitchStatusLocked(sw *bucketSwitch, st pb.GetBucketSwitchStatusResponse_Status, now time.Time, message string) {
+	if st == pb.GetBucketSwitchStatusResponse_InProgress {
+		sw.startedAt = now
+	}
+	sw.status = st
+	sw.history = append(sw.history, fmt.Sprintf("%s | %s: %s", now.UTC().Format(time.RFC3339), st, message))
+}
+
+// switchProtoLocked converts the simulated switch into the worker API model
+func (s *Server) switchProtoLocked(key replicationKey) *pb.GetBucketSwitchStatusResponse {
+	sw := s.switches[key]
+	resp := &pb.GetBucketSwitchStatusResponse{
+		LastStatus:   sw.status,
+		ZeroDowntime: sw.zeroDowntime,
+		History:      append([]string(nil), sw.history...),
+		ReplicationId: &pb.ReplicationRequest{
+			User:   key.user,
+			Bucket: key.bucket,
+			From:   key.from,
+			To:     key.to,
+		},
+	}
+	if key.toBucket != key.bucket {
+		toBucket := key.toBucket
+		resp.ReplicationId.ToBucket = &toBucket
+	}
+	if sw.multipartTTL != nil {
+		resp.MultipartTtl = proto.Clone(sw.multipartTTL).(*durationpb.Duration)
+	}
+	if sw.downtime != nil {
+		resp.DowntimeOpts = proto.Clone(sw.downtime).(*pb.SwitchDowntimeOpts)
+	}
+	if !sw.startedAt.IsZero() {
+		resp.LastStartedAt = timestamppb.New(sw.startedAt)
+	}
+	if !sw.doneAt.IsZero() {
+		resp.DoneAt = timestamppb.New(sw.doneAt)
+	}
+	return resp
+}
@@ -4,24 +4,35 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hantdev/chorus-controller/internal/domain"
 )
 
 // HealthHandler handles health check endpoints
-type HealthHandler struct{}
+type HealthHandler struct {
+	worker domain.WorkerConnection
+}
 
-// NewHealthHandler creates a new health handler
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+// NewHealthHandler creates a new health handler on the connection of the default worker
+func NewHealthHandler(worker domain.WorkerConnection) *HealthHandler {
+	return &HealthHandler{
+		worker: worker,
+	}
 }
 
 // Health
 // @Summary		Health check
-// @Description	Returns the health status of the controller
+// @Description	Returns the health status of the controller and the connection state of its default worker.
+// @Description	It neither reads the database nor dials registered workers, GET /workers/status reports every worker.
 // @Tags			health
 // @Accept			json
 // @Produce		json
 // @Success		200	{object}	map[string]interface{}
 // @Router			/health [get]
 func (h *HealthHandler) Health(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ok",
+		"worker": gin.H{
+			"state": h.worker.State().String(),
+		},
+	})
 }
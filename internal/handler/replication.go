@@ -1,13 +1,20 @@
 package handler
 
 import (
+	"io"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hantdev/chorus-controller/internal/domain"
 	"github.com/hantdev/chorus-controller/internal/middleware"
 )
 
+// streamHeartbeatInterval is how often idle replication streams send a keepalive comment
+const streamHeartbeatInterval = 15 * time.Second
+
 // ReplicationHandler handles replication-related endpoints
 type ReplicationHandler struct {
 	replicationService domain.ReplicationService
@@ -30,7 +37,11 @@ func NewReplicationHandler(replicationService domain.ReplicationService) *Replic
 // @Param			replication	body		domain.CreateReplicationRequest	true	"Replication configuration"
 // @Success		201			{string}	string				"Replication job created successfully"
 // @Failure		400			{object}	map[string]interface{}
+// @Failure		409			{object}	map[string]interface{}
+// @Failure		412			{object}	map[string]interface{}
 // @Failure		502			{object}	map[string]interface{}
+// @Failure		503			{object}	map[string]interface{}
+// @Failure		504			{object}	map[string]interface{}
 // @Router			/replications [post]
 func (h *ReplicationHandler) CreateReplication(c *gin.Context) {
 	var req domain.CreateReplicationRequest
@@ -50,23 +61,92 @@ func (h *ReplicationHandler) CreateReplication(c *gin.Context) {
 
 // ListReplications
 // @Summary		List all replication jobs
-// @Description	Returns a list of all configured replication jobs with their statuses
+// @Description	Returns a list of all configured replication jobs with their statuses.
+// @Description	Workers which cannot be reached are named in the X-Unreachable-Workers header, their replications are missing.
 // @Tags			replications
 // @Accept			json
 // @Produce		json
-// @Success		200	{array}	object
+// @Success		200	{array}		object
+// @Header			200	{string}	X-Unreachable-Workers	"Comma separated workers which could not be listed"
 // @Failure		502	{object}	map[string]interface{}
 // @Router			/replications [get]
 func (h *ReplicationHandler) ListReplications(c *gin.Context) {
-	replications, err := h.replicationService.ListReplications(c.Request.Context())
+	replications, unreachable, err := h.replicationService.ListReplications(c.Request.Context())
 	if err != nil {
 		middleware.HandleError(c, err)
 		return
 	}
 
+	if len(unreachable) > 0 {
+		names := make([]string, 0, len(unreachable))
+		for name := range unreachable {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		c.Header("X-Unreachable-Workers", strings.Join(names, ","))
+	}
 	c.JSON(http.StatusOK, replications)
 }
 
+// StreamReplications
+// @Summary		Stream replication progress
+// @Description	Pushes replication changes as Server-Sent Events until the client disconnects.
+// @Description	Events are "replication" (created or changed), "removed" and "error", each carrying a domain.ReplicationEvent.
+// @Description	Filtering on user, bucket, from and to selects a single replication which is streamed by the worker directly.
+// @Tags			replications
+// @Produce		text/event-stream
+// @Param			worker	query		string	false	"Worker name"
+// @Param			user	query		string	false	"User"
+// @Param			bucket	query		string	false	"Bucket"
+// @Param			from	query		string	false	"Source storage"
+// @Param			to		query		string	false	"Destination storage"
+// @Success		200		{string}	string	"Server-Sent Events stream"
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		404		{object}	map[string]interface{}
+// @Failure		502		{object}	map[string]interface{}
+// @Failure		503		{object}	map[string]interface{}
+// @Router			/replications/stream [get]
+func (h *ReplicationHandler) StreamReplications(c *gin.Context) {
+	var filter domain.ReplicationFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	events, err := h.replicationService.StreamReplications(ctx, &filter)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Disable response buffering in reverse proxies
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(ev.Type, ev)
+			return true
+		case <-heartbeat.C:
+			// Comment lines keep idle connections open and detect gone clients
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
 // PauseReplication
 // @Summary        Pause a replication job
 // @Description    Pauses an active replication job
@@ -77,7 +157,11 @@ func (h *ReplicationHandler) ListReplications(c *gin.Context) {
 // @Param          replication body domain.ReplicationIdentifier true "Replication identifier"
 // @Success        200 {string} string "Replication paused successfully"
 // @Failure        400 {object} map[string]interface{}
+// @Failure        404 {object} map[string]interface{}
+// @Failure        412 {object} map[string]interface{}
 // @Failure        502 {object} map[string]interface{}
+// @Failure        503 {object} map[string]interface{}
+// @Failure        504 {object} map[string]interface{}
 // @Router         /replications/pause [post]
 func (h *ReplicationHandler) PauseReplication(c *gin.Context) {
 	h.replicationAction(c, "pause")
@@ -93,7 +177,11 @@ func (h *ReplicationHandler) PauseReplication(c *gin.Context) {
 // @Param          replication body domain.ReplicationIdentifier true "Replication identifier"
 // @Success        200 {string} string "Replication resumed successfully"
 // @Failure        400 {object} map[string]interface{}
+// @Failure        404 {object} map[string]interface{}
+// @Failure        412 {object} map[string]interface{}
 // @Failure        502 {object} map[string]interface{}
+// @Failure        503 {object} map[string]interface{}
+// @Failure        504 {object} map[string]interface{}
 // @Router         /replications/resume [post]
 func (h *ReplicationHandler) ResumeReplication(c *gin.Context) {
 	h.replicationAction(c, "resume")
@@ -109,7 +197,11 @@ func (h *ReplicationHandler) ResumeReplication(c *gin.Context) {
 // @Param          replication body domain.ReplicationIdentifier true "Replication identifier"
 // @Success        200 {string} string "Replication deleted successfully"
 // @Failure        400 {object} map[string]interface{}
+// @Failure        404 {object} map[string]interface{}
+// @Failure        412 {object} map[string]interface{}
 // @Failure        502 {object} map[string]interface{}
+// @Failure        503 {object} map[string]interface{}
+// @Failure        504 {object} map[string]interface{}
 // @Router         /replications [delete]
 func (h *ReplicationHandler) DeleteReplication(c *gin.Context) {
 	h.replicationAction(c, "delete")
@@ -125,7 +217,11 @@ func (h *ReplicationHandler) DeleteReplication(c *gin.Context) {
 // @Param			replication	body		domain.ReplicationIdentifier	true	"Replication identifier"
 // @Success		202			{string}	string				"Switch initiated successfully"
 // @Failure		400			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]interface{}
+// @Failure		412			{object}	map[string]interface{}
 // @Failure		502			{object}	map[string]interface{}
+// @Failure		503			{object}	map[string]interface{}
+// @Failure		504			{object}	map[string]interface{}
 // @Router			/replications/switch/zero-downtime [post]
 func (h *ReplicationHandler) SwitchZeroDowntime(c *gin.Context) {
 	var id domain.ReplicationIdentifier
@@ -143,6 +239,350 @@ func (h *ReplicationHandler) SwitchZeroDowntime(c *gin.Context) {
 	c.Status(http.StatusAccepted)
 }
 
+// SwitchWithDowntime
+// @Summary		Switch main and follower buckets with a downtime window
+// @Description	Schedules a switch which blocks bucket writes while it runs. Without a downtime window the switch starts right away.
+// @Tags			replications
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			switch	body		domain.SwitchBucketRequest	true	"Replication identifier and downtime window"
+// @Success		202		{string}	string						"Switch scheduled successfully"
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		404		{object}	map[string]interface{}
+// @Failure		409		{object}	map[string]interface{}
+// @Failure		412		{object}	map[string]interface{}
+// @Failure		502		{object}	map[string]interface{}
+// @Failure		503		{object}	map[string]interface{}
+// @Router			/replications/switch [post]
+func (h *ReplicationHandler) SwitchWithDowntime(c *gin.Context) {
+	var req domain.SwitchBucketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	if err := h.replicationService.SwitchWithDowntime(c.Request.Context(), &req); err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// GetSwitchStatus
+// @Summary		Get bucket switch status
+// @Description	Returns the status, downtime window and history of the switch of a replication
+// @Tags			replications
+// @Produce		json
+// @Param			worker		query		string	false	"Worker name"
+// @Param			user		query		string	true	"User"
+// @Param			bucket		query		string	true	"Bucket"
+// @Param			from		query		string	true	"Source storage"
+// @Param			to			query		string	true	"Destination storage"
+// @Param			to_bucket	query		string	false	"Destination bucket"
+// @Success		200			{object}	domain.BucketSwitch
+// @Failure		400			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]interface{}
+// @Failure		502			{object}	map[string]interface{}
+// @Router			/replications/switch [get]
+func (h *ReplicationHandler) GetSwitchStatus(c *gin.Context) {
+	var id domain.ReplicationIdentifier
+	if err := c.ShouldBindQuery(&id); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	sw, err := h.replicationService.GetSwitchStatus(c.Request.Context(), &id)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sw)
+}
+
+// ListSwitches
+// @Summary		List bucket switches
+// @Description	Returns the switches of all workers with their status
+// @Tags			replications
+// @Produce		json
+// @Success		200	{array}		domain.BucketSwitch
+// @Failure		502	{object}	map[string]interface{}
+// @Router			/replications/switches [get]
+func (h *ReplicationHandler) ListSwitches(c *gin.Context) {
+	switches, err := h.replicationService.ListSwitches(c.Request.Context())
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, switches)
+}
+
+// DeleteSwitch
+// @Summary		Abort and delete a bucket switch
+// @Description	Aborts a pending or running switch and removes its metadata. Bucket writes are unblocked and the old main stays main.
+// @Tags			replications
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			replication	body		domain.ReplicationIdentifier	true	"Replication identifier"
+// @Success		200			{string}	string							"Switch deleted successfully"
+// @Failure		400			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]interface{}
+// @Failure		502			{object}	map[string]interface{}
+// @Router			/replications/switch [delete]
+func (h *ReplicationHandler) DeleteSwitch(c *gin.Context) {
+	var id domain.ReplicationIdentifier
+	if err := c.ShouldBindJSON(&id); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	if err := h.replicationService.DeleteSwitch(c.Request.Context(), &id); err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// CompareBucket
+// @Summary		Compare the buckets of a replication
+// @Description	Compares the source and destination buckets object by object and stores the result.
+// @Description	The first page of every kind of mismatched keys is included when show_keys is set, further pages are served by /replications/compare/{id}/keys.
+// @Tags			replications
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			comparison	body		domain.CompareBucketRequest	true	"Replication identifier and key paging"
+// @Success		200			{object}	domain.BucketComparisonResult
+// @Failure		400			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]interface{}
+// @Failure		502			{object}	map[string]interface{}
+// @Failure		503			{object}	map[string]interface{}
+// @Failure		504			{object}	map[string]interface{}
+// @Router			/replications/compare [post]
+func (h *ReplicationHandler) CompareBucket(c *gin.Context) {
+	var req domain.CompareBucketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	result, err := h.replicationService.CompareBucket(c.Request.Context(), &req)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListComparisons
+// @Summary		List bucket comparisons
+// @Description	Returns stored bucket comparisons, newest first
+// @Tags			replications
+// @Produce		json
+// @Param			worker	query		string	false	"Worker name"
+// @Param			user	query		string	false	"User"
+// @Param			bucket	query		string	false	"Bucket"
+// @Param			from	query		string	false	"Source storage"
+// @Param			to		query		string	false	"Destination storage"
+// @Param			limit	query		int		false	"Maximum number of comparisons (default 50)"
+// @Success		200		{array}		domain.BucketComparison
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		500		{object}	map[string]interface{}
+// @Router			/replications/compare [get]
+func (h *ReplicationHandler) ListComparisons(c *gin.Context) {
+	var filter domain.ComparisonFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	comparisons, err := h.replicationService.ListComparisons(c.Request.Context(), &filter)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comparisons)
+}
+
+// GetComparison
+// @Summary		Get a bucket comparison
+// @Description	Returns the counts of a stored bucket comparison
+// @Tags			replications
+// @Produce		json
+// @Param			id	path		string	true	"Comparison ID"
+// @Success		200	{object}	domain.BucketComparison
+// @Failure		400	{object}	map[string]interface{}
+// @Failure		404	{object}	map[string]interface{}
+// @Router			/replications/compare/{id} [get]
+func (h *ReplicationHandler) GetComparison(c *gin.Context) {
+	comparison, err := h.replicationService.GetComparison(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comparison)
+}
+
+// GetComparisonKeys
+// @Summary		List mismatched keys of a bucket comparison
+// @Description	Returns a page of the keys missing in the source (miss_from) or destination (miss_to), differing keys (differ) or comparison errors (errors)
+// @Tags			replications
+// @Produce		json
+// @Param			id		path		string	true	"Comparison ID"
+// @Param			kind	query		string	true	"Key kind"	Enums(miss_from, miss_to, differ, errors)
+// @Param			offset	query		int		false	"Offset of the first key"
+// @Param			limit	query		int		false	"Page size (default 100)"
+// @Success		200		{object}	domain.ComparisonKeysPage
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		404		{object}	map[string]interface{}
+// @Router			/replications/compare/{id}/keys [get]
+func (h *ReplicationHandler) GetComparisonKeys(c *gin.Context) {
+	var req domain.ComparisonKeysRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	page, err := h.replicationService.GetComparisonKeys(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// ListUserReplications
+// @Summary		List replications of users
+// @Description	Returns the bucket replications grouped by user, source and destination storage.
+// @Description	all_buckets is set for replications created for all buckets of a user.
+// @Tags			replications
+// @Produce		json
+// @Param			worker	query		string	false	"Worker name"
+// @Param			user	query		string	false	"User"
+// @Param			from	query		string	false	"Source storage"
+// @Param			to		query		string	false	"Destination storage"
+// @Success		200		{array}		domain.UserReplication
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		404		{object}	map[string]interface{}
+// @Failure		502		{object}	map[string]interface{}
+// @Router			/replications/users [get]
+func (h *ReplicationHandler) ListUserReplications(c *gin.Context) {
+	var filter domain.UserReplicationFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	replications, err := h.replicationService.ListUserReplications(c.Request.Context(), &filter)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, replications)
+}
+
+// PauseUserReplication
+// @Summary		Pause all replications of a user
+// @Description	Pauses every bucket replication of a user between two storages and reports the outcome per bucket.
+// @Description	Responds with 207 when some buckets failed.
+// @Tags			replications
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			replication	body		domain.UserReplicationRequest	true	"User replication identifier"
+// @Success		200			{object}	domain.UserReplicationReport
+// @Success		207			{object}	domain.UserReplicationReport
+// @Failure		400			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]interface{}
+// @Failure		502			{object}	map[string]interface{}
+// @Failure		503			{object}	map[string]interface{}
+// @Router			/replications/users/pause [post]
+func (h *ReplicationHandler) PauseUserReplication(c *gin.Context) {
+	h.userReplicationAction(c, domain.UserReplicationPause)
+}
+
+// ResumeUserReplication
+// @Summary		Resume all replications of a user
+// @Description	Resumes every paused bucket replication of a user between two storages and reports the outcome per bucket.
+// @Description	Responds with 207 when some buckets failed.
+// @Tags			replications
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			replication	body		domain.UserReplicationRequest	true	"User replication identifier"
+// @Success		200			{object}	domain.UserReplicationReport
+// @Success		207			{object}	domain.UserReplicationReport
+// @Failure		400			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]interface{}
+// @Failure		502			{object}	map[string]interface{}
+// @Failure		503			{object}	map[string]interface{}
+// @Router			/replications/users/resume [post]
+func (h *ReplicationHandler) ResumeUserReplication(c *gin.Context) {
+	h.userReplicationAction(c, domain.UserReplicationResume)
+}
+
+// DeleteUserReplication
+// @Summary		Delete all replications of a user
+// @Description	Deletes the user replication and every bucket replication of a user between two storages.
+// @Description	Workers without the user replication are handled bucket by bucket, responds with 207 when some buckets failed.
+// @Tags			replications
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			replication	body		domain.UserReplicationRequest	true	"User replication identifier"
+// @Success		200			{object}	domain.UserReplicationReport
+// @Success		207			{object}	domain.UserReplicationReport
+// @Failure		400			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]interface{}
+// @Failure		502			{object}	map[string]interface{}
+// @Failure		503			{object}	map[string]interface{}
+// @Router			/replications/users [delete]
+func (h *ReplicationHandler) DeleteUserReplication(c *gin.Context) {
+	h.userReplicationAction(c, domain.UserReplicationDelete)
+}
+
+// userReplicationAction handles pause, resume, and delete actions on all replications of a user
+func (h *ReplicationHandler) userReplicationAction(c *gin.Context, action string) {
+	var req domain.UserReplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	var (
+		report *domain.UserReplicationReport
+		err    error
+	)
+	switch action {
+	case domain.UserReplicationPause:
+		report, err = h.replicationService.PauseUserReplication(c.Request.Context(), &req)
+	case domain.UserReplicationResume:
+		report, err = h.replicationService.ResumeUserReplication(c.Request.Context(), &req)
+	case domain.UserReplicationDelete:
+		report, err = h.replicationService.DeleteUserReplication(c.Request.Context(), &req)
+	}
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	code := http.StatusOK
+	if report.Failed > 0 {
+		code = http.StatusMultiStatus
+	}
+	c.JSON(code, report)
+}
+
 // replicationAction handles pause, resume, and delete actions
 func (h *ReplicationHandler) replicationAction(c *gin.Context, action string) {
 	var id domain.ReplicationIdentifier
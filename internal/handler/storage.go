@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -22,7 +24,8 @@ func NewStorageHandler(storageService domain.StorageService) *StorageHandler {
 
 // ListStorages
 // @Summary		List all storages
-// @Description	Returns a list of all configured storage backends
+// @Description	Returns the storage backends configured on every registered worker.
+// @Description	Workers which cannot be reached are listed with their error in unreachable_workers, their storages are missing.
 // @Tags			storages
 // @Accept			json
 // @Produce		json
@@ -30,12 +33,16 @@ func NewStorageHandler(storageService domain.StorageService) *StorageHandler {
 // @Failure		502	{object}	map[string]interface{}
 // @Router			/storages [get]
 func (h *StorageHandler) ListStorages(c *gin.Context) {
-	resp, err := h.storageService.ListStorages(c.Request.Context())
+	storages, unreachable, err := h.storageService.ListStorages(c.Request.Context())
 	if err != nil {
 		middleware.HandleError(c, err)
 		return
 	}
 
+	resp := gin.H{"storages": storages}
+	if len(unreachable) > 0 {
+		resp["unreachable_workers"] = unreachable
+	}
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -45,6 +52,7 @@ func (h *StorageHandler) ListStorages(c *gin.Context) {
 // @Tags			buckets
 // @Accept			json
 // @Produce		json
+// @Param			worker				query		string	false	"Worker name (default worker when empty)"
 // @Param			user				query		string	false	"User identifier"
 // @Param			from				query		string	false	"Source storage name"
 // @Param			to					query		string	false	"Destination storage name"
@@ -73,14 +81,17 @@ func (h *StorageHandler) ListBuckets(c *gin.Context) {
 
 // CreateStorage
 // @Summary		Create a storage configuration
-// @Description	Creates a storage with simplified parameters. All other settings use default values.
+// @Description	Creates a storage. Omitted settings use default values: health check every 5s, HTTP timeout 5m, no rate limit.
 // @Tags			storages
 // @Accept			json
 // @Produce		json
 // @Security		TokenAuth
 // @Param			request			body		domain.CreateStorageRequest	true	"Storage creation request"
+// @Param			validate		query		bool	false	"Check the S3 endpoint and credentials before saving"
+// @Param			replace_main	query		bool	false	"Demote the current main storage when this storage becomes main"
 // @Success		201				{string}	string	"Storage created successfully"
 // @Failure		400				{object}	map[string]interface{}
+// @Failure		409				{object}	map[string]interface{}
 // @Router			/storages [post]
 func (h *StorageHandler) CreateStorage(c *gin.Context) {
 	var req domain.CreateStorageRequest
@@ -88,6 +99,10 @@ func (h *StorageHandler) CreateStorage(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
 		return
 	}
+	if err := c.ShouldBindQuery(&req.Options); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
 	if err := h.storageService.CreateStorageFromRequest(c.Request.Context(), &req); err != nil {
 		middleware.HandleError(c, err)
 		return
@@ -97,21 +112,39 @@ func (h *StorageHandler) CreateStorage(c *gin.Context) {
 
 // ListStoragesDB
 // @Summary		List storages from DB
+// @Description	Secrets are masked and identified by their fingerprint, use the reveal endpoint to read them.
+// @Description	Storages are listed in name order. With a limit, the X-Next-Cursor header holds the cursor of the next page when more storages match.
 // @Tags			storages
 // @Produce		json
-// @Success		200 {array} domain.Storage
+// @Param			selector	query		string	false	"Label selector, e.g. env=prod,region!=eu,tier,!legacy"
+// @Param			provider	query		string	false	"Provider, case-insensitive"
+// @Param			name_prefix	query		string	false	"Name prefix"
+// @Param			limit		query		int		false	"Page size, at most 1000, all matching storages when 0"
+// @Param			cursor		query		string	false	"Cursor of the page, from X-Next-Cursor"
+// @Success		200			{array}		domain.Storage
+// @Header			200			{string}	X-Next-Cursor	"Cursor of the next page"
+// @Failure		400			{object}	map[string]interface{}
 // @Router			/storages/db [get]
 func (h *StorageHandler) ListStoragesDB(c *gin.Context) {
-	items, err := h.storageService.ListStorageFromDB(c.Request.Context())
+	var req domain.ListStoragesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+	items, next, err := h.storageService.ListStorageFromDB(c.Request.Context(), &req)
 	if err != nil {
 		middleware.HandleError(c, err)
 		return
 	}
+	if next != "" {
+		c.Header("X-Next-Cursor", next)
+	}
 	c.JSON(http.StatusOK, items)
 }
 
 // GetStorage
 // @Summary		Get storage by ID
+// @Description	The secret is masked and identified by its fingerprint
 // @Tags			storages
 // @Produce		json
 // @Param			id			path		string	true	"Storage ID"
@@ -129,17 +162,20 @@ func (h *StorageHandler) GetStorage(c *gin.Context) {
 }
 
 // UpdateStorage
-// @Summary		Update a storage configuration
-// @Description	Updates an existing storage with new parameters
+// @Summary		Replace a storage configuration
+// @Description	Replaces the name, address, provider and credentials of a storage. Omitted settings keep their current value.
 // @Tags			storages
 // @Accept			json
 // @Produce		json
 // @Security		TokenAuth
 // @Param			id			path		string	true	"Storage ID"
 // @Param			request			body		domain.CreateStorageRequest	true	"Storage update request"
+// @Param			validate		query		bool	false	"Check the S3 endpoint and credentials before saving"
+// @Param			replace_main	query		bool	false	"Demote the current main storage when this storage becomes main"
 // @Success		200				{string}	string	"Storage updated successfully"
 // @Failure		400				{object}	map[string]interface{}
 // @Failure		404				{object}	map[string]interface{}
+// @Failure		409				{object}	map[string]interface{}
 // @Router			/storages/{id} [put]
 func (h *StorageHandler) UpdateStorage(c *gin.Context) {
 	id := c.Param("id")
@@ -148,6 +184,10 @@ func (h *StorageHandler) UpdateStorage(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
 		return
 	}
+	if err := c.ShouldBindQuery(&req.Options); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
 	if err := h.storageService.UpdateStorageByID(c.Request.Context(), id, &req); err != nil {
 		middleware.HandleError(c, err)
 		return
@@ -155,21 +195,459 @@ func (h *StorageHandler) UpdateStorage(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+// PatchStorage
+// @Summary		Partially update a storage configuration
+// @Description	Changes only the fields present in the request
+// @Tags			storages
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			id			path		string	true	"Storage ID"
+// @Param			request		body		domain.UpdateStorageRequest	true	"Fields to change"
+// @Param			validate		query		bool	false	"Check the S3 endpoint and credentials before saving"
+// @Param			replace_main	query		bool	false	"Demote the current main storage when this storage becomes main"
+// @Success		200			{string}	string	"Storage updated successfully"
+// @Failure		400			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]interface{}
+// @Failure		409			{object}	map[string]interface{}
+// @Router			/storages/{id} [patch]
+func (h *StorageHandler) PatchStorage(c *gin.Context) {
+	id := c.Param("id")
+	var req domain.UpdateStorageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+	if err := c.ShouldBindQuery(&req.Options); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+	if err := h.storageService.PatchStorageByID(c.Request.Context(), id, &req); err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
 // DeleteStorage
 // @Summary		Delete a storage configuration
-// @Description	Deletes a storage and all its credentials
+// @Description	Deletes a storage and all its credentials.
+// @Description	Storages used by replication jobs are only deleted with force, which deletes the jobs as well; otherwise 409 lists the jobs.
 // @Tags			storages
 // @Produce		json
 // @Security		TokenAuth
-// @Param			id			path		string	true	"Storage ID"
-// @Success		200				{string}	string	"Storage deleted successfully"
-// @Failure		404				{object}	map[string]interface{}
+// @Param			id		path		string	true	"Storage ID"
+// @Param			force	query		bool	false	"Delete the replication jobs using the storage as well"
+// @Success		200		{string}	string	"Storage deleted successfully"
+// @Failure		404		{object}	map[string]interface{}
+// @Failure		409		{object}	map[string]interface{}
 // @Router			/storages/{id} [delete]
 func (h *StorageHandler) DeleteStorage(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.storageService.DeleteStorageByID(c.Request.Context(), id); err != nil {
+	var query struct {
+		Force bool `form:"force"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+	if err := h.storageService.DeleteStorageByID(c.Request.Context(), id, query.Force); err != nil {
 		middleware.HandleError(c, err)
 		return
 	}
 	c.Status(http.StatusOK)
 }
+
+// ListStorageCredentials
+// @Summary		List the users of a storage
+// @Description	Lists the storage's own user, marked primary, then its other users. Secrets are masked and identified by their fingerprint.
+// @Tags			storages
+// @Produce		json
+// @Param			id	path		string	true	"Storage ID"
+// @Success		200	{array}		domain.StorageCredential
+// @Failure		400	{object}	map[string]interface{}
+// @Failure		404	{object}	map[string]interface{}
+// @Router			/storages/{id}/credentials [get]
+func (h *StorageHandler) ListStorageCredentials(c *gin.Context) {
+	creds, err := h.storageService.ListStorageCredentials(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, creds)
+}
+
+// CreateStorageCredential
+// @Summary		Add a user to a storage
+// @Description	Replications of the user need it on both storages
+// @Tags			storages
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			id		path		string									true	"Storage ID"
+// @Param			request	body		domain.CreateStorageCredentialRequest	true	"User and keys"
+// @Success		201		{object}	domain.StorageCredential
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		404		{object}	map[string]interface{}
+// @Failure		409		{object}	map[string]interface{}
+// @Router			/storages/{id}/credentials [post]
+func (h *StorageHandler) CreateStorageCredential(c *gin.Context) {
+	var req domain.CreateStorageCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+	cred, err := h.storageService.CreateStorageCredential(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, cred)
+}
+
+// UpdateStorageCredential
+// @Summary		Replace the keys of a storage user
+// @Description	Works for the storage's own user as well. A masked secret keeps the stored one.
+// @Tags			storages
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			id		path		string									true	"Storage ID"
+// @Param			user	path		string									true	"User"
+// @Param			request	body		domain.UpdateStorageCredentialRequest	true	"New keys"
+// @Success		200		{string}	string	"Storage user updated successfully"
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		404		{object}	map[string]interface{}
+// @Router			/storages/{id}/credentials/{user} [put]
+func (h *StorageHandler) UpdateStorageCredential(c *gin.Context) {
+	var req domain.UpdateStorageCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+	if err := h.storageService.UpdateStorageCredential(c.Request.Context(), c.Param("id"), c.Param("user"), &req); err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// DeleteStorageCredential
+// @Summary		Remove a user from a storage
+// @Description	The storage's own user cannot be removed. Users with replication jobs on the storage are refused with 409 listing the jobs.
+// @Tags			storages
+// @Produce		json
+// @Security		TokenAuth
+// @Param			id		path		string	true	"Storage ID"
+// @Param			user	path		string	true	"User"
+// @Success		200		{string}	string	"Storage user deleted successfully"
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		404		{object}	map[string]interface{}
+// @Failure		409		{object}	map[string]interface{}
+// @Router			/storages/{id}/credentials/{user} [delete]
+func (h *StorageHandler) DeleteStorageCredential(c *gin.Context) {
+	if err := h.storageService.DeleteStorageCredential(c.Request.Context(), c.Param("id"), c.Param("user")); err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// ExportStorages
+// @Summary		Export storages as worker configuration
+// @Description	Renders the stored storages with decrypted credentials as the storage section of the Chorus worker config.
+// @Description	The output is deterministic, the same storages always produce the same file.
+// @Description	Requires a system token, every export is recorded in the audit log.
+// @Tags			storages
+// @Produce		application/x-yaml
+// @Security		TokenAuth
+// @Param			default_region		query		string	false	"Fallback region of the worker"
+// @Param			create_routing		query		bool	false	"Create routing rules to the main storage (default true)"
+// @Param			create_replication	query		bool	false	"Create replication rules from the main storage"
+// @Param			selector			query		string	false	"Export only the storages whose labels match, e.g. env=prod"
+// @Success		200					{string}	string	"Worker storage config"
+// @Failure		400					{object}	map[string]interface{}
+// @Failure		401					{object}	map[string]interface{}
+// @Failure		409					{object}	map[string]interface{}
+// @Failure		500					{object}	map[string]interface{}
+// @Router			/storages/export [get]
+func (h *StorageHandler) ExportStorages(c *gin.Context) {
+	var req domain.ExportStoragesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	out, err := h.storageService.ExportWorkerConfig(c.Request.Context(), &req, auditActorOf(c))
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="storages.yaml"`)
+	c.Data(http.StatusOK, "application/x-yaml", out)
+}
+
+// maxImportSize bounds the worker config accepted by ImportStorages
+const maxImportSize = 1 << 20
+
+// ImportStorages
+// @Summary		Import storages from worker configuration
+// @Description	Creates or updates the storages of a Chorus worker config, matched by name. The body is the whole config or its storage section.
+// @Description	Storages and users missing from the file are kept. Every user is stored, user selects the storage's own user of new storages with several users.
+// @Description	A dry run reports the changes without saving them.
+// @Tags			storages
+// @Accept			application/x-yaml
+// @Produce		json
+// @Security		TokenAuth
+// @Param			config			body		string	true	"Worker config YAML"
+// @Param			dry_run			query		bool	false	"Report the changes without saving"
+// @Param			user			query		string	false	"Own user of storages with several users"
+// @Param			replace_main	query		bool	false	"Demote a main storage missing from the file"
+// @Success		200				{object}	domain.StorageImportReport
+// @Failure		400				{object}	map[string]interface{}
+// @Failure		401				{object}	map[string]interface{}
+// @Failure		409				{object}	map[string]interface{}
+// @Failure		413				{object}	map[string]interface{}
+// @Failure		500				{object}	map[string]interface{}
+// @Router			/storages/import [post]
+func (h *StorageHandler) ImportStorages(c *gin.Context) {
+	var opts domain.ImportStoragesOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, middleware.ErrorResponse(err))
+			return
+		}
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	report, err := h.storageService.ImportStorages(c.Request.Context(), data, &opts)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetStorageDrift
+// @Summary		Compare stored storages with the workers
+// @Description	Reports storages missing on either side and storages with a different address, provider or main flag.
+// @Description	Unreachable workers are listed separately and not compared.
+// @Tags			storages
+// @Produce		json
+// @Success		200	{object}	domain.StorageDriftReport
+// @Failure		500	{object}	map[string]interface{}
+// @Failure		502	{object}	map[string]interface{}
+// @Router			/storages/drift [get]
+func (h *StorageHandler) GetStorageDrift(c *gin.Context) {
+	report, err := h.storageService.DetectDrift(c.Request.Context())
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ListDriftEvents
+// @Summary		List storage drift events
+// @Description	Returns drift events recorded by the periodic drift check, newest first
+// @Tags			storages
+// @Produce		json
+// @Param			worker	query		string	false	"Worker name"
+// @Param			storage	query		string	false	"Storage name"
+// @Param			open	query		bool	false	"Only unresolved events"
+// @Param			limit	query		int		false	"Maximum number of events (default 100)"
+// @Success		200		{array}		domain.StorageDriftEvent
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		500		{object}	map[string]interface{}
+// @Router			/storages/drift/events [get]
+func (h *StorageHandler) ListDriftEvents(c *gin.Context) {
+	var filter domain.DriftEventFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	events, err := h.storageService.ListDriftEvents(c.Request.Context(), &filter)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// ListProviders
+// @Summary		List storage providers
+// @Description	Returns the providers a storage can use with their defaults and rules: addressing style, whether https
+// @Description	and a region are required, the region format and the endpoint used when a storage has no address.
+// @Tags			storages
+// @Produce		json
+// @Success		200	{array}	providers.Provider
+// @Router			/storages/providers [get]
+func (h *StorageHandler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, h.storageService.ListProviders(c.Request.Context()))
+}
+
+// TestStorage
+// @Summary		Test a storage's S3 endpoint
+// @Description	Connects to the S3 endpoint with the stored credentials and checks connectivity, signature version,
+// @Description	region, authentication and bucket listing. Failed checks are reported with status 200.
+// @Tags			storages
+// @Produce		json
+// @Security		TokenAuth
+// @Param			id	path		string	true	"Storage ID"
+// @Success		200	{object}	domain.StorageTestReport
+// @Failure		400	{object}	map[string]interface{}
+// @Failure		404	{object}	map[string]interface{}
+// @Router			/storages/{id}/test [post]
+func (h *StorageHandler) TestStorage(c *gin.Context) {
+	report, err := h.storageService.TestStorage(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetStorageHealth
+// @Summary		Get a storage's health
+// @Description	Returns the state of the storage's S3 endpoint as probed by the health monitor at the storage's
+// @Description	health check interval, the uptime percentage in a window and the recent probes, newest first.
+// @Tags			storages
+// @Produce		json
+// @Param			id		path		string	true	"Storage ID"
+// @Param			window	query		string	false	"Uptime window, e.g. 1h (default 24h, at most 720h)"
+// @Param			limit	query		int		false	"Maximum number of probes (default 100)"
+// @Param			changes	query		bool	false	"Only list the probes which changed the state"
+// @Success		200		{object}	domain.StorageHealth
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		404		{object}	map[string]interface{}
+// @Router			/storages/{id}/health [get]
+func (h *StorageHandler) GetStorageHealth(c *gin.Context) {
+	var req domain.StorageHealthRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+
+	health, err := h.storageService.GetStorageHealth(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+}
+
+// PromoteStorage
+// @Summary		Promote a storage to main
+// @Description	Makes the storage the main storage and demotes the current main in one transaction.
+// @Description	Buckets of any user of the current main which are not replicated to the storage block the promotion with 409 unless force is set.
+// @Tags			storages
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			id		path		string							true	"Storage ID"
+// @Param			request	body		domain.PromoteStorageRequest	false	"Promotion options"
+// @Success		200		{object}	domain.PromotionReport
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		404		{object}	map[string]interface{}
+// @Failure		409		{object}	domain.PromotionReport
+// @Failure		502		{object}	map[string]interface{}
+// @Router			/storages/{id}/promote [post]
+func (h *StorageHandler) PromoteStorage(c *gin.Context) {
+	var req domain.PromoteStorageRequest
+	// The body is optional
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+			return
+		}
+	}
+
+	report, err := h.storageService.PromoteStorage(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if !report.Promoted && !report.DryRun {
+		status = http.StatusConflict
+	}
+	c.JSON(status, report)
+}
+
+// RevealStorageSecret
+// @Summary		Reveal the secret of a storage
+// @Description	Returns the plain-text secret access key. Requires a system token, every reveal is recorded in the audit log.
+// @Tags			storages
+// @Produce		json
+// @Security		TokenAuth
+// @Param			id	path		string	true	"Storage ID"
+// @Success		200	{object}	domain.StorageSecret
+// @Failure		400	{object}	map[string]interface{}
+// @Failure		401	{object}	map[string]interface{}
+// @Failure		404	{object}	map[string]interface{}
+// @Router			/storages/{id}/reveal [post]
+func (h *StorageHandler) RevealStorageSecret(c *gin.Context) {
+	secret, err := h.storageService.RevealStorageSecret(c.Request.Context(), c.Param("id"), auditActorOf(c))
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, secret)
+}
+
+// auditActorOf identifies the token and client of a request for the audit log
+func auditActorOf(c *gin.Context) *domain.AuditActor {
+	actor := &domain.AuditActor{ClientIP: c.ClientIP()}
+	if tokenInfo, err := middleware.GetTokenInfoFromContext(c); err == nil {
+		actor.TokenID, actor.TokenName = tokenInfo.ID, tokenInfo.Name
+	}
+	return actor
+}
+
+// RotateKeys
+// @Summary		Re-encrypt storage secrets with the active key
+// @Description	Re-encrypts every storage secret which is not encrypted with the active key, in batches of one transaction each.
+// @Description	A failed rotation reports the last committed storage, pass it as after to resume. A dry run only counts the secrets per key.
+// @Tags			storages
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			request	body		domain.RotateKeysRequest	false	"Rotation options"
+// @Success		200		{object}	domain.KeyRotationReport
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		401		{object}	map[string]interface{}
+// @Failure		500		{object}	map[string]interface{}
+// @Router			/storages/rotate-keys [post]
+func (h *StorageHandler) RotateKeys(c *gin.Context) {
+	var req domain.RotateKeysRequest
+	// The body is optional
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+			return
+		}
+	}
+
+	report, err := h.storageService.RotateKeys(c.Request.Context(), &req)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
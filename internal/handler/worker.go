@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/middleware"
+)
+
+// WorkerHandler handles worker registry endpoints
+type WorkerHandler struct {
+	workerService domain.WorkerService
+}
+
+// NewWorkerHandler creates a new worker handler
+func NewWorkerHandler(workerService domain.WorkerService) *WorkerHandler {
+	return &WorkerHandler{
+		workerService: workerService,
+	}
+}
+
+// ListWorkers
+// @Summary		List workers
+// @Description	Returns the default worker and all registered workers
+// @Tags			workers
+// @Produce		json
+// @Success		200	{array}		domain.Worker
+// @Failure		500	{object}	map[string]interface{}
+// @Router			/workers [get]
+func (h *WorkerHandler) ListWorkers(c *gin.Context) {
+	workers, err := h.workerService.ListWorkers(c.Request.Context())
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, workers)
+}
+
+// WorkerStatuses
+// @Summary		Worker connection status
+// @Description	Returns the address, connection state and circuit breaker of every worker
+// @Tags			workers
+// @Produce		json
+// @Security		TokenAuth
+// @Success		200	{array}		domain.WorkerStatus
+// @Failure		401	{object}	map[string]interface{}
+// @Failure		500	{object}	map[string]interface{}
+// @Router			/workers/status [get]
+func (h *WorkerHandler) WorkerStatuses(c *gin.Context) {
+	statuses, err := h.workerService.WorkerStatuses(c.Request.Context())
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, statuses)
+}
+
+// GetWorker
+// @Summary		Get worker by name
+// @Tags			workers
+// @Produce		json
+// @Param			name	path		string	true	"Worker name"
+// @Success		200		{object}	domain.Worker
+// @Failure		404		{object}	map[string]interface{}
+// @Router			/workers/{name} [get]
+func (h *WorkerHandler) GetWorker(c *gin.Context) {
+	worker, err := h.workerService.GetWorker(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, worker)
+}
+
+// CreateWorker
+// @Summary		Register a worker
+// @Description	Registers a Chorus worker the controller can manage. The names default and status are reserved.
+// @Tags			workers
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			request	body		domain.CreateWorkerRequest	true	"Worker registration request"
+// @Success		201		{object}	domain.Worker
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		409		{object}	map[string]interface{}
+// @Router			/workers [post]
+func (h *WorkerHandler) CreateWorker(c *gin.Context) {
+	var req domain.CreateWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+	worker, err := h.workerService.CreateWorker(c.Request.Context(), &req)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, worker)
+}
+
+// UpdateWorker
+// @Summary		Update a worker
+// @Description	Updates a registered worker and reconnects with the new settings
+// @Tags			workers
+// @Accept			json
+// @Produce		json
+// @Security		TokenAuth
+// @Param			name	path		string						true	"Worker name"
+// @Param			request	body		domain.CreateWorkerRequest	true	"Worker update request"
+// @Success		200		{string}	string	"Worker updated successfully"
+// @Failure		400		{object}	map[string]interface{}
+// @Failure		404		{object}	map[string]interface{}
+// @Failure		409		{object}	map[string]interface{}
+// @Router			/workers/{name} [put]
+func (h *WorkerHandler) UpdateWorker(c *gin.Context) {
+	var req domain.CreateWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse(err))
+		return
+	}
+	if err := h.workerService.UpdateWorker(c.Request.Context(), c.Param("name"), &req); err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// DeleteWorker
+// @Summary		Delete a worker
+// @Description	Removes a registered worker that does not own replication jobs
+// @Tags			workers
+// @Produce		json
+// @Security		TokenAuth
+// @Param			name	path		string	true	"Worker name"
+// @Success		200		{string}	string	"Worker deleted successfully"
+// @Failure		404		{object}	map[string]interface{}
+// @Failure		409		{object}	map[string]interface{}
+// @Router			/workers/{name} [delete]
+func (h *WorkerHandler) DeleteWorker(c *gin.Context) {
+	if err := h.workerService.DeleteWorker(c.Request.Context(), c.Param("name")); err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,170 @@
+// Package labels validates free-form key/value labels and parses the selectors matching them.
+//
+// A selector is a comma-separated list of requirements which all have to match:
+//
+//	env=prod        label env is prod (== works as well)
+//	region!=eu      label region is not eu, or not set
+//	tier            label tier is set
+//	!legacy         label legacy is not set
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxLabels bounds the number of labels of an object
+const MaxLabels = 64
+
+var (
+	keyPattern   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$`)
+	valuePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+// Validate checks the number of labels and the format of their keys and values
+func Validate(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("at most %d labels are allowed, got %d", MaxLabels, len(labels))
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := validateKey(k); err != nil {
+			return err
+		}
+		if err := validateValue(k, labels[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q: up to 63 letters, digits, '.', '_', '-' or '/', starting and ending with a letter or digit", key)
+	}
+	return nil
+}
+
+func validateValue(key, value string) error {
+	if !valuePattern.MatchString(value) {
+		return fmt.Errorf("invalid value %q of label %q: up to 63 letters, digits, '.', '_' or '-', starting and ending with a letter or digit", value, key)
+	}
+	return nil
+}
+
+// Operator is the comparison of a requirement
+type Operator string
+
+// Requirement operators
+const (
+	Equals    Operator = "="
+	NotEquals Operator = "!="
+	Exists    Operator = "exists"
+	NotExists Operator = "!exists"
+)
+
+// Requirement is one condition of a selector
+type Requirement struct {
+	Key      string
+	Operator Operator
+	// Value is empty for Exists and NotExists
+	Value string
+}
+
+// Matches tells whether labels satisfy the requirement
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case Equals:
+		return ok && value == r.Value
+	case NotEquals:
+		return !ok || value != r.Value
+	case Exists:
+		return ok
+	case NotExists:
+		return !ok
+	}
+	return false
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key
+	case NotExists:
+		return "!" + r.Key
+	}
+	return r.Key + string(r.Operator) + r.Value
+}
+
+// Selector is a conjunction of requirements, the empty selector matches everything
+type Selector []Requirement
+
+// Parse parses a selector such as "env=prod,region!=eu"
+func Parse(s string) (Selector, error) {
+	var sel Selector
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		r, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+func parseRequirement(s string) (Requirement, error) {
+	var r Requirement
+	switch {
+	case strings.Contains(s, "!="):
+		key, value, _ := strings.Cut(s, "!=")
+		r = Requirement{Key: key, Operator: NotEquals, Value: value}
+	case strings.Contains(s, "=="):
+		key, value, _ := strings.Cut(s, "==")
+		r = Requirement{Key: key, Operator: Equals, Value: value}
+	case strings.Contains(s, "="):
+		key, value, _ := strings.Cut(s, "=")
+		r = Requirement{Key: key, Operator: Equals, Value: value}
+	case strings.HasPrefix(s, "!"):
+		r = Requirement{Key: strings.TrimPrefix(s, "!"), Operator: NotExists}
+	default:
+		r = Requirement{Key: s, Operator: Exists}
+	}
+
+	r.Key, r.Value = strings.TrimSpace(r.Key), strings.TrimSpace(r.Value)
+	if err := validateKey(r.Key); err != nil {
+		return Requirement{}, fmt.Errorf("selector %q: %w", s, err)
+	}
+	if err := validateValue(r.Key, r.Value); err != nil {
+		return Requirement{}, fmt.Errorf("selector %q: %w", s, err)
+	}
+	return r, nil
+}
+
+// Matches tells whether labels satisfy every requirement
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,71 @@
+package labels
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	sel, err := Parse(" env=prod, region!=eu,tier,!legacy,team==storage ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sel.String(); got != "env=prod,region!=eu,tier,!legacy,team=storage" {
+		t.Fatalf("unexpected selector %q", got)
+	}
+
+	tests := []struct {
+		labels map[string]string
+		match  bool
+	}{
+		{map[string]string{"env": "prod", "tier": "hot", "team": "storage"}, true},
+		{map[string]string{"env": "prod", "region": "us", "tier": "hot", "team": "storage"}, true},
+		{map[string]string{"env": "prod", "region": "eu", "tier": "hot", "team": "storage"}, false},
+		{map[string]string{"env": "dev", "tier": "hot", "team": "storage"}, false},
+		{map[string]string{"env": "prod", "team": "storage"}, false},
+		{map[string]string{"env": "prod", "tier": "hot", "team": "storage", "legacy": ""}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := sel.Matches(tt.labels); got != tt.match {
+			t.Errorf("%v: expected %v, got %v", tt.labels, tt.match, got)
+		}
+	}
+
+	if empty, err := Parse(""); err != nil || !empty.Matches(nil) {
+		t.Fatalf("expected the empty selector to match everything: %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{"=prod", "env=pr od", "!", "-env", "env=prod,region!=-eu"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(map[string]string{"env": "prod", "example.com/team": "storage", "empty": ""}); err != nil {
+		t.Fatal(err)
+	}
+	for _, labels := range []map[string]string{
+		{"": "x"},
+		{"env": "prod!"},
+		{strings.Repeat("k", 64): "x"},
+		{"env": strings.Repeat("v", 64)},
+	} {
+		if err := Validate(labels); err == nil {
+			t.Errorf("%v: expected an error", labels)
+		}
+	}
+
+	many := map[string]string{}
+	for i := 0; i <= MaxLabels; i++ {
+		many[fmt.Sprintf("k%d", i)] = "v"
+	}
+	if err := Validate(many); err == nil {
+		t.Error("expected an error for too many labels")
+	}
+}
@@ -0,0 +1,212 @@
+// Package metrics exposes controller metrics in the Prometheus text format.
+//
+// Only gauges and counters with labels are supported, which is all the
+// controller needs, without pulling in the Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types
+const (
+	typeGauge   = "gauge"
+	typeCounter = "counter"
+)
+
+// Default is the registry served on /metrics
+var Default = NewRegistry()
+
+// Registry holds metric families
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*Vec
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*Vec)}
+}
+
+// Vec is a metric family partitioned by label values
+type Vec struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	mu      sync.Mutex
+	samples map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// Gauge registers a gauge family, registering the same name twice returns the existing family
+func (r *Registry) Gauge(name, help string, labels ...string) *Vec {
+	return r.register(name, help, typeGauge, labels)
+}
+
+// Counter registers a counter family, registering the same name twice returns the existing family
+func (r *Registry) Counter(name, help string, labels ...string) *Vec {
+	return r.register(name, help, typeCounter, labels)
+}
+
+func (r *Registry) register(name, help, typ string, labels []string) *Vec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if v, ok := r.families[name]; ok {
+		return v
+	}
+	v := &Vec{name: name, help: help, typ: typ, labels: labels, samples: make(map[string]*sample)}
+	r.families[name] = v
+	return v
+}
+
+// Set sets the value of the sample with the given label values
+func (v *Vec) Set(value float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.sample(labelValues).value = value
+}
+
+// Add adds delta to the sample with the given label values
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.sample(labelValues).value += delta
+}
+
+// Inc increments the sample with the given label values
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Reset removes all samples, e.g. before gauges are recomputed
+func (v *Vec) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.samples = make(map[string]*sample)
+}
+
+// Delete removes the sample with the given label values, e.g. of a deleted storage
+func (v *Vec) Delete(labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.samples, strings.Join(labelValues, "\x00"))
+}
+
+// Value returns the value of the sample with the given label values
+func (v *Vec) Value(labelValues ...string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.samples[strings.Join(labelValues, "\x00")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (v *Vec) sample(labelValues []string) *sample {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\x00")
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		v.samples[key] = s
+	}
+	return s
+}
+
+// WriteTo writes all families in the Prometheus text format, sorted by name and labels
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]*Vec, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, r.families[name])
+	}
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, v := range families {
+		v.write(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+func (v *Vec) write(w *countingWriter) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	w.printf("# HELP %s %s\n", v.name, escapeHelp(v.help))
+	w.printf("# TYPE %s %s\n", v.name, v.typ)
+
+	keys := make([]string, 0, len(v.samples))
+	for key := range v.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := v.samples[key]
+		w.printf("%s%s %s\n", v.name, formatLabels(v.labels, s.labelValues), strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+}
+
+// ServeHTTP serves the metrics of the registry
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+// countingWriter keeps the first write error and the number of bytes written
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) printf(format string, args ...any) {
+	if c.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(c.w, format, args...)
+	c.n += int64(n)
+	c.err = err
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	drifts := r.Gauge("storage_drifts", "Current drifts.", "worker", "kind")
+	checks := r.Counter("drift_checks_total", "Completed checks.")
+
+	drifts.Set(2, "default", "address")
+	drifts.Set(1, "eu\"west", "main")
+	checks.Inc()
+	checks.Inc()
+	if r.Gauge("storage_drifts", "ignored") != drifts {
+		t.Fatal("registering a family twice must return the existing family")
+	}
+
+	var out strings.Builder
+	if _, err := r.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP drift_checks_total Completed checks.
+# TYPE drift_checks_total counter
+drift_checks_total 2
+# HELP storage_drifts Current drifts.
+# TYPE storage_drifts gauge
+storage_drifts{worker="default",kind="address"} 2
+storage_drifts{worker="eu\"west",kind="main"} 1
+`
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	drifts.Delete("eu\"west", "main")
+	if v := drifts.Value("eu\"west", "main"); v != 0 || drifts.Value("default", "address") != 2 {
+		t.Fatalf("expected only the deleted sample to be gone, got %v", v)
+	}
+
+	drifts.Reset()
+	if v := drifts.Value("default", "address"); v != 0 {
+		t.Fatalf("expected reset gauge, got %v", v)
+	}
+}
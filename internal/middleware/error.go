@@ -25,10 +25,18 @@ func ErrorHandler() gin.HandlerFunc {
 
 // HandleError handles API errors and returns appropriate HTTP responses
 func HandleError(c *gin.Context, err error) {
-	if apiErr, ok := err.(*errors.APIError); ok {
-		c.JSON(apiErr.Code, gin.H{
+	var apiErr *errors.APIError
+	if errors.As(err, &apiErr) {
+		resp := gin.H{
 			"error": apiErr.Message,
-		})
+		}
+		if apiErr.Upstream != nil {
+			resp["upstream"] = apiErr.Upstream
+		}
+		if apiErr.Details != nil {
+			resp["details"] = apiErr.Details
+		}
+		c.JSON(apiErr.Code, resp)
 		return
 	}
 
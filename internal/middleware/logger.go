@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redacted replaces secret values in logs
+const redacted = "[REDACTED]"
+
+var (
+	// secretJSONField matches secret fields of JSON bodies, e.g. in binding errors
+	secretJSONField = regexp.MustCompile(`("(?:secret_key|secret_access_key|secretAccessKey|SecretAccessKey)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// secretQueryParam matches secret query parameters
+	secretQueryParam = regexp.MustCompile(`(?i)((?:^|[?&])(?:secret_key|secret_access_key|secretAccessKey)=)[^&#\s]*`)
+)
+
+// Redact removes secret values from text written to logs
+func Redact(s string) string {
+	s = secretJSONField.ReplaceAllString(s, `$1"`+redacted+`"`)
+	return secretQueryParam.ReplaceAllString(s, `${1}`+redacted)
+}
+
+// RequestLogger is gin's request logger with secrets redacted from paths and error messages
+func RequestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		p.Path = Redact(p.Path)
+		p.ErrorMessage = Redact(p.ErrorMessage)
+		return formatLog(p)
+	})
+}
+
+// formatLog is gin's default log line format
+func formatLog(p gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if p.IsOutputColor() {
+		statusColor = p.StatusCodeColor()
+		methodColor = p.MethodColor()
+		resetColor = p.ResetColor()
+	}
+
+	if p.Latency > time.Minute {
+		p.Latency = p.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, p.StatusCode, resetColor,
+		p.Latency,
+		p.ClientIP,
+		methodColor, p.Method, resetColor,
+		p.Path,
+		p.ErrorMessage,
+	)
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedact(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{`{"name":"main","secret_key":"abc\"def"}`, `{"name":"main","secret_key":"[REDACTED]"}`},
+		{`{"SecretAccessKey": "abc"}`, `{"SecretAccessKey": "[REDACTED]"}`},
+		{`/storages?secret_key=abc&name=main`, `/storages?secret_key=[REDACTED]&name=main`},
+		{`/storages?name=main`, `/storages?name=main`},
+	} {
+		if got := Redact(tc.in); got != tc.want {
+			t.Errorf("Redact(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	out := gin.DefaultWriter
+	gin.DefaultWriter = &buf
+	defer func() { gin.DefaultWriter = out }()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestLogger())
+	r.POST("/storages", func(c *gin.Context) {
+		_ = c.Error(errors.New(`invalid body {"secret_key":"plain"}`))
+		c.Status(http.StatusBadRequest)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/storages?secret_key=plain", nil))
+	if strings.Contains(buf.String(), "plain") || !strings.Contains(buf.String(), "[REDACTED]") {
+		t.Fatalf("secret not redacted: %s", buf.String())
+	}
+}
@@ -37,6 +37,10 @@ func SystemTokenAuth(tokenService domain.TokenService) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// Handlers auditing privileged actions need to know the token
+		if tokenInfo, err := tokenService.ValidateToken(c.Request.Context(), token); err == nil {
+			c.Set("token_info", tokenInfo)
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,185 @@
+// Package providers is the catalog of the S3 providers a storage can use,
+// with the defaults and rules the controller applies to their storages.
+//
+// A provider is looked up by its ID, its worker name or an alias, case-insensitively.
+// Storages store the worker name, which the worker and rclone know the provider by.
+package providers
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Addressing styles of bucket requests
+const (
+	PathStyle   = "path"
+	VirtualHost = "virtual-host"
+)
+
+// Provider describes an S3 provider.
+// Regions of the provider match RegionPattern when it is set.
+// AddressTemplate is the endpoint of a region, with {region} replaced by the storage's region.
+type Provider struct {
+	ID              string   `json:"id" example:"aws"`
+	Name            string   `json:"name" example:"AWS"`
+	Description     string   `json:"description"`
+	Aliases         []string `json:"aliases,omitempty"`
+	Addressing      string   `json:"addressing" example:"virtual-host"`
+	SecureOnly      bool     `json:"secure_only"`
+	RequiresRegion  bool     `json:"requires_region"`
+	DefaultRegion   string   `json:"default_region,omitempty"`
+	RegionPattern   string   `json:"region_pattern,omitempty"`
+	ExampleRegion   string   `json:"example_region,omitempty" example:"eu-central-1"`
+	AddressTemplate string   `json:"address_template,omitempty" example:"https://s3.{region}.amazonaws.com"`
+
+	region *regexp.Regexp
+}
+
+var catalog = []Provider{
+	{
+		ID: "minio", Name: "Minio", Description: "MinIO",
+		Addressing: PathStyle,
+	},
+	{
+		ID: "ceph", Name: "Ceph", Description: "Ceph Object Gateway (RGW)", Aliases: []string{"rgw"},
+		Addressing: PathStyle,
+	},
+	{
+		ID: "aws", Name: "AWS", Description: "Amazon S3", Aliases: []string{"s3", "amazon"},
+		Addressing: VirtualHost, SecureOnly: true, RequiresRegion: true,
+		RegionPattern: `^[a-z]{2}(-[a-z]+)+-\d+$`, ExampleRegion: "eu-central-1",
+		AddressTemplate: "https://s3.{region}.amazonaws.com",
+	},
+	{
+		ID: "gcs-interop", Name: "GCS", Description: "Google Cloud Storage XML API with HMAC keys", Aliases: []string{"gcs", "google"},
+		Addressing: VirtualHost, SecureOnly: true, DefaultRegion: "auto",
+		AddressTemplate: "https://storage.googleapis.com",
+	},
+	{
+		ID: "wasabi", Name: "Wasabi", Description: "Wasabi Hot Cloud Storage",
+		Addressing: PathStyle, SecureOnly: true, RequiresRegion: true,
+		RegionPattern: `^[a-z]{2}-[a-z]+-\d+$`, ExampleRegion: "eu-central-1",
+		AddressTemplate: "https://s3.{region}.wasabisys.com",
+	},
+	{
+		ID: "cloudflare-r2", Name: "Cloudflare", Description: "Cloudflare R2, the address holds the account ID", Aliases: []string{"cloudflare", "r2"},
+		Addressing: PathStyle, SecureOnly: true, DefaultRegion: "auto",
+	},
+	{
+		ID: "digitalocean", Name: "DigitalOcean", Description: "DigitalOcean Spaces", Aliases: []string{"spaces"},
+		Addressing: VirtualHost, SecureOnly: true, RequiresRegion: true,
+		RegionPattern: `^[a-z]{3}\d$`, ExampleRegion: "nyc3",
+		AddressTemplate: "https://{region}.digitaloceanspaces.com",
+	},
+	{
+		ID: "alibaba", Name: "Alibaba", Description: "Alibaba Cloud OSS", Aliases: []string{"oss", "aliyun"},
+		Addressing: VirtualHost, SecureOnly: true, RequiresRegion: true,
+		RegionPattern: `^oss-[a-z0-9-]+$`, ExampleRegion: "oss-cn-hangzhou",
+		AddressTemplate: "https://{region}.aliyuncs.com",
+	},
+	{
+		ID: "other", Name: "Other", Description: "Any other S3 compatible storage",
+		Addressing: PathStyle,
+	},
+}
+
+func init() {
+	for i := range catalog {
+		if catalog[i].RegionPattern != "" {
+			catalog[i].region = regexp.MustCompile(catalog[i].RegionPattern)
+		}
+	}
+}
+
+// All returns the catalog in a stable order
+func All() []Provider {
+	return append([]Provider(nil), catalog...)
+}
+
+// IDs returns the IDs of the catalog, e.g. to list them in an error
+func IDs() []string {
+	ids := make([]string, len(catalog))
+	for i, p := range catalog {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// Lookup finds a provider by ID, worker name or alias, case-insensitively
+func Lookup(name string) (Provider, bool) {
+	name = strings.TrimSpace(name)
+	for _, p := range catalog {
+		if strings.EqualFold(name, p.ID) || strings.EqualFold(name, p.Name) {
+			return p, true
+		}
+		for _, alias := range p.Aliases {
+			if strings.EqualFold(name, alias) {
+				return p, true
+			}
+		}
+	}
+	return Provider{}, false
+}
+
+// CheckRegion validates the region of a storage, an empty region is only allowed when none is required
+func (p Provider) CheckRegion(region string) error {
+	if region == "" {
+		if p.RequiresRegion {
+			return fmt.Errorf("provider %s requires default_region", p.ID)
+		}
+		return nil
+	}
+	if p.region != nil && !p.region.MatchString(region) {
+		return fmt.Errorf("invalid region %q for provider %s, expected a region like %s", region, p.ID, p.ExampleRegion)
+	}
+	return nil
+}
+
+// Address returns the endpoint of a region, empty when the provider has no well-known endpoint
+func (p Provider) Address(region string) string {
+	if p.AddressTemplate == "" || (strings.Contains(p.AddressTemplate, "{region}") && region == "") {
+		return ""
+	}
+	return strings.ReplaceAll(p.AddressTemplate, "{region}", region)
+}
+
+// NormalizeAddress returns an address as scheme://host[:port] in lower case and whether it is secure.
+// An address without a scheme gets https when secure is set, http otherwise.
+// A set secure has to agree with the scheme of the address.
+func NormalizeAddress(address string, secure *bool) (string, bool, error) {
+	a := strings.TrimSpace(address)
+	if a == "" {
+		return "", false, fmt.Errorf("address is required")
+	}
+	if !strings.Contains(a, "://") {
+		if secure != nil && *secure {
+			a = "https://" + a
+		} else {
+			a = "http://" + a
+		}
+	}
+	u, err := url.Parse(a)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid address %q: %w", address, err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	switch {
+	case scheme != "http" && scheme != "https":
+		return "", false, fmt.Errorf("invalid address %q: scheme must be http or https", address)
+	case u.Host == "" || u.Hostname() == "":
+		return "", false, fmt.Errorf("invalid address %q: host is missing", address)
+	case u.User != nil:
+		return "", false, fmt.Errorf("invalid address %q: credentials belong in access_key and secret_key", address)
+	case strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "":
+		return "", false, fmt.Errorf("invalid address %q: only scheme, host and port are allowed", address)
+	}
+
+	isSecure := scheme == "https"
+	if secure != nil && *secure != isSecure {
+		return "", false, fmt.Errorf("is_secure=%t conflicts with the %s address %q", *secure, scheme, address)
+	}
+	return scheme + "://" + strings.ToLower(u.Host), isSecure, nil
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hantdev/chorus-controller/internal/db"
+	"github.com/hantdev/chorus-controller/internal/domain"
+)
+
+// AuditDBRepository stores audit events
+type AuditDBRepository struct{}
+
+// NewAuditDBRepository creates a new audit repository
+func NewAuditDBRepository() *AuditDBRepository { return &AuditDBRepository{} }
+
+// Create stores an audit event
+func (r *AuditDBRepository) Create(ctx context.Context, e *domain.AuditEvent) error {
+	return db.DB().WithContext(ctx).Create(e).Error
+}
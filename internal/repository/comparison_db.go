@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/db"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"gorm.io/gorm"
+)
+
+type BucketComparisonDBRepository struct{}
+
+func NewBucketComparisonDBRepository() *BucketComparisonDBRepository {
+	return &BucketComparisonDBRepository{}
+}
+
+// summaryColumns leaves out the key lists which can hold millions of keys
+var summaryColumns = []string{
+	"id", "worker", `"user"`, "bucket", `"from"`, `"to"`, "to_bucket", "is_match",
+	"match_count", "miss_from_count", "miss_to_count", "differ_count", "error_count", "created_at",
+}
+
+// BucketComparison CRUD
+func (r *BucketComparisonDBRepository) Create(ctx context.Context, c *domain.BucketComparison) error {
+	return db.DB().WithContext(ctx).Create(c).Error
+}
+
+// List returns the summaries of the comparisons matching the filter, newest first
+func (r *BucketComparisonDBRepository) List(ctx context.Context, filter *domain.ComparisonFilter) ([]domain.BucketComparison, error) {
+	q := db.DB().WithContext(ctx).Select(summaryColumns)
+	if filter.Worker != "" {
+		q = q.Where("worker = ?", filter.Worker)
+	}
+	if filter.User != "" {
+		q = q.Where(`"user" = ?`, filter.User)
+	}
+	if filter.Bucket != "" {
+		q = q.Where("bucket = ?", filter.Bucket)
+	}
+	if filter.From != "" {
+		q = q.Where(`"from" = ?`, filter.From)
+	}
+	if filter.To != "" {
+		q = q.Where(`"to" = ?`, filter.To)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	var items []domain.BucketComparison
+	err := q.Order("created_at desc").Find(&items).Error
+	return items, err
+}
+
+func (r *BucketComparisonDBRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.BucketComparison, error) {
+	var c domain.BucketComparison
+	err := db.DB().WithContext(ctx).Where("id = ?", id).First(&c).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetSummaryByID returns a comparison without its key lists
+func (r *BucketComparisonDBRepository) GetSummaryByID(ctx context.Context, id uuid.UUID) (*domain.BucketComparison, error) {
+	var c domain.BucketComparison
+	err := db.DB().WithContext(ctx).Select(summaryColumns).Where("id = ?", id).First(&c).Error
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
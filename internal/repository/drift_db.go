@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/db"
+	"github.com/hantdev/chorus-controller/internal/domain"
+)
+
+type StorageDriftDBRepository struct{}
+
+func NewStorageDriftDBRepository() *StorageDriftDBRepository { return &StorageDriftDBRepository{} }
+
+// StorageDriftEvent CRUD
+func (r *StorageDriftDBRepository) Create(ctx context.Context, e *domain.StorageDriftEvent) error {
+	return db.DB().WithContext(ctx).Create(e).Error
+}
+
+// List returns the events matching the filter, newest first
+func (r *StorageDriftDBRepository) List(ctx context.Context, filter *domain.DriftEventFilter) ([]domain.StorageDriftEvent, error) {
+	q := db.DB().WithContext(ctx)
+	if filter.Worker != "" {
+		q = q.Where("worker = ?", filter.Worker)
+	}
+	if filter.Storage != "" {
+		q = q.Where("storage = ?", filter.Storage)
+	}
+	if filter.Open {
+		q = q.Where("resolved_at IS NULL")
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	var items []domain.StorageDriftEvent
+	err := q.Order("detected_at desc").Find(&items).Error
+	return items, err
+}
+
+// Resolve marks the events as resolved
+func (r *StorageDriftDBRepository) Resolve(ctx context.Context, ids []uuid.UUID, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.DB().WithContext(ctx).Model(&domain.StorageDriftEvent{}).
+		Where("id IN ?", ids).
+		Update("resolved_at", at).Error
+}
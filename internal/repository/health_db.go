@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/db"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"gorm.io/gorm"
+)
+
+type StorageHealthDBRepository struct{}
+
+func NewStorageHealthDBRepository() *StorageHealthDBRepository { return &StorageHealthDBRepository{} }
+
+// StorageHealthCheck CRUD
+func (r *StorageHealthDBRepository) Create(ctx context.Context, c *domain.StorageHealthCheck) error {
+	return db.DB().WithContext(ctx).Create(c).Error
+}
+
+// List returns up to limit probes of a storage, newest first, changes only returns the state changes
+func (r *StorageHealthDBRepository) List(ctx context.Context, storageID uuid.UUID, limit int, changes bool) ([]domain.StorageHealthCheck, error) {
+	q := db.DB().WithContext(ctx).Where("storage_id = ?", storageID)
+	if changes {
+		q = q.Where("changed")
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+
+	var items []domain.StorageHealthCheck
+	err := q.Order("checked_at desc").Find(&items).Error
+	return items, err
+}
+
+// Latest returns the newest probe of a storage, changed selects the newest state change, nil when there is none
+func (r *StorageHealthDBRepository) Latest(ctx context.Context, storageID uuid.UUID, changed bool) (*domain.StorageHealthCheck, error) {
+	q := db.DB().WithContext(ctx).Where("storage_id = ?", storageID)
+	if changed {
+		q = q.Where("changed")
+	}
+
+	var c domain.StorageHealthCheck
+	err := q.Order("checked_at desc").First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// LatestAll returns the newest probe of every probed storage
+func (r *StorageHealthDBRepository) LatestAll(ctx context.Context) ([]domain.StorageHealthCheck, error) {
+	var items []domain.StorageHealthCheck
+	err := db.DB().WithContext(ctx).
+		Raw(`SELECT DISTINCT ON (storage_id) * FROM storage_health_check ORDER BY storage_id, checked_at DESC`).
+		Scan(&items).Error
+	return items, err
+}
+
+// Uptime counts the probes of a storage since a time and the successful ones among them
+func (r *StorageHealthDBRepository) Uptime(ctx context.Context, storageID uuid.UUID, since time.Time) (total, up int64, err error) {
+	var row struct {
+		Total int64
+		Up    int64
+	}
+	err = db.DB().WithContext(ctx).Model(&domain.StorageHealthCheck{}).
+		Select("count(*) AS total, count(*) FILTER (WHERE up) AS up").
+		Where("storage_id = ? AND checked_at >= ?", storageID, since).
+		Scan(&row).Error
+	return row.Total, row.Up, err
+}
+
+// DeleteBefore deletes the probes older than a time and returns their number
+func (r *StorageHealthDBRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res := db.DB().WithContext(ctx).Where("checked_at < ?", before).Delete(&domain.StorageHealthCheck{})
+	return res.RowsAffected, res.Error
+}
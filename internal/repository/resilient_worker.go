@@ -0,0 +1,393 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// ResilienceConfig configures retries and the circuit breaker around worker RPCs
+type ResilienceConfig struct {
+	// MaxAttempts is the number of attempts for idempotent calls (1 disables retries)
+	MaxAttempts int
+	// BaseDelay and MaxDelay bound the jittered exponential backoff between attempts
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting a probe call through
+	OpenTimeout time.Duration
+}
+
+// ResilientWorker implements domain.WorkerClient on top of another client.
+// Idempotent calls are retried with jittered backoff and all calls go through
+// a circuit breaker which fails fast while the worker is unavailable.
+type ResilientWorker struct {
+	name string
+	next domain.WorkerClient
+	cfg  ResilienceConfig
+
+	mu               sync.Mutex
+	state            string
+	failures         int
+	openedAt         time.Time
+	opened           int
+	lastError        string
+	halfOpenInFlight bool
+}
+
+// admission is a call let through the breaker.
+// opened is the number of times the breaker had opened when the call was admitted,
+// probe marks the single call let through while half-open.
+type admission struct {
+	opened int
+	probe  bool
+}
+
+// NewResilientWorker wraps a worker client with retries and a circuit breaker
+func NewResilientWorker(name string, next domain.WorkerClient, cfg ResilienceConfig) *ResilientWorker {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = 1
+	}
+	return &ResilientWorker{
+		name:  name,
+		next:  next,
+		cfg:   cfg,
+		state: BreakerClosed,
+	}
+}
+
+// BreakerState returns a snapshot of the circuit breaker
+func (w *ResilientWorker) BreakerState() domain.BreakerState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	state := domain.BreakerState{
+		State:               w.state,
+		ConsecutiveFailures: w.failures,
+		LastError:           w.lastError,
+	}
+	if w.state != BreakerClosed {
+		openedAt := w.openedAt
+		retryAt := openedAt.Add(w.cfg.OpenTimeout)
+		state.OpenedAt = &openedAt
+		state.RetryAt = &retryAt
+	}
+	return state
+}
+
+// GetStorages retrieves all configured storages
+func (w *ResilientWorker) GetStorages(ctx context.Context) (*pb.GetStoragesResponse, error) {
+	return call(ctx, w, true, func(ctx context.Context) (*pb.GetStoragesResponse, error) {
+		return w.next.GetStorages(ctx)
+	})
+}
+
+// ListBucketsForReplication retrieves buckets available for replication
+func (w *ResilientWorker) ListBucketsForReplication(ctx context.Context, req *pb.ListBucketsForReplicationRequest) (*pb.ListBucketsForReplicationResponse, error) {
+	return call(ctx, w, true, func(ctx context.Context) (*pb.ListBucketsForReplicationResponse, error) {
+		return w.next.ListBucketsForReplication(ctx, req)
+	})
+}
+
+// AddReplication creates a new replication job
+func (w *ResilientWorker) AddReplication(ctx context.Context, req *pb.AddReplicationRequest) (*emptypb.Empty, error) {
+	return call(ctx, w, false, func(ctx context.Context) (*emptypb.Empty, error) {
+		return w.next.AddReplication(ctx, req)
+	})
+}
+
+// ListReplications retrieves all replication jobs
+func (w *ResilientWorker) ListReplications(ctx context.Context) (*pb.ListReplicationsResponse, error) {
+	return call(ctx, w, true, func(ctx context.Context) (*pb.ListReplicationsResponse, error) {
+		return w.next.ListReplications(ctx)
+	})
+}
+
+// ListUserReplications retrieves the replications created for all buckets of a user
+func (w *ResilientWorker) ListUserReplications(ctx context.Context) (*pb.ListUserReplicationsResponse, error) {
+	return call(ctx, w, true, func(ctx context.Context) (*pb.ListUserReplicationsResponse, error) {
+		return w.next.ListUserReplications(ctx)
+	})
+}
+
+// DeleteUserReplication deletes a replication created for all buckets of a user
+func (w *ResilientWorker) DeleteUserReplication(ctx context.Context, req *pb.DeleteUserReplicationRequest) (*emptypb.Empty, error) {
+	return call(ctx, w, false, func(ctx context.Context) (*emptypb.Empty, error) {
+		return w.next.DeleteUserReplication(ctx, req)
+	})
+}
+
+// PauseReplication pauses a replication job
+func (w *ResilientWorker) PauseReplication(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	return call(ctx, w, false, func(ctx context.Context) (*emptypb.Empty, error) {
+		return w.next.PauseReplication(ctx, req)
+	})
+}
+
+// ResumeReplication resumes a replication job
+func (w *ResilientWorker) ResumeReplication(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	return call(ctx, w, false, func(ctx context.Context) (*emptypb.Empty, error) {
+		return w.next.ResumeReplication(ctx, req)
+	})
+}
+
+// DeleteReplication deletes a replication job
+func (w *ResilientWorker) DeleteReplication(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	return call(ctx, w, false, func(ctx context.Context) (*emptypb.Empty, error) {
+		return w.next.DeleteReplication(ctx, req)
+	})
+}
+
+// SwitchBucketZeroDowntime switches buckets without downtime
+func (w *ResilientWorker) SwitchBucketZeroDowntime(ctx context.Context, req *pb.SwitchBucketZeroDowntimeRequest) (*emptypb.Empty, error) {
+	return call(ctx, w, false, func(ctx context.Context) (*emptypb.Empty, error) {
+		return w.next.SwitchBucketZeroDowntime(ctx, req)
+	})
+}
+
+// SwitchBucket switches buckets with a downtime window
+func (w *ResilientWorker) SwitchBucket(ctx context.Context, req *pb.SwitchBucketRequest) (*emptypb.Empty, error) {
+	return call(ctx, w, false, func(ctx context.Context) (*emptypb.Empty, error) {
+		return w.next.SwitchBucket(ctx, req)
+	})
+}
+
+// DeleteBucketSwitch aborts a bucket switch and removes its metadata
+func (w *ResilientWorker) DeleteBucketSwitch(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	return call(ctx, w, false, func(ctx context.Context) (*emptypb.Empty, error) {
+		return w.next.DeleteBucketSwitch(ctx, req)
+	})
+}
+
+// GetBucketSwitchStatus retrieves the status of a bucket switch
+func (w *ResilientWorker) GetBucketSwitchStatus(ctx context.Context, req *pb.ReplicationRequest) (*pb.GetBucketSwitchStatusResponse, error) {
+	return call(ctx, w, true, func(ctx context.Context) (*pb.GetBucketSwitchStatusResponse, error) {
+		return w.next.GetBucketSwitchStatus(ctx, req)
+	})
+}
+
+// ListReplicationSwitches retrieves all bucket switches
+func (w *ResilientWorker) ListReplicationSwitches(ctx context.Context) (*pb.ListSwitchResponse, error) {
+	return call(ctx, w, true, func(ctx context.Context) (*pb.ListSwitchResponse, error) {
+		return w.next.ListReplicationSwitches(ctx)
+	})
+}
+
+// CompareBucket compares the contents of the source and destination buckets
+func (w *ResilientWorker) CompareBucket(ctx context.Context, req *pb.CompareBucketRequest) (*pb.CompareBucketResponse, error) {
+	return call(ctx, w, true, func(ctx context.Context) (*pb.CompareBucketResponse, error) {
+		return w.next.CompareBucket(ctx, req)
+	})
+}
+
+// StreamBucketReplication opens a replication stream through the circuit breaker.
+// Streams are long-lived and never retried, failures while receiving still count against the breaker.
+func (w *ResilientWorker) StreamBucketReplication(ctx context.Context, req *pb.ReplicationRequest) (domain.ReplicationStream, error) {
+	a, err := w.allow()
+	if err != nil {
+		return nil, err
+	}
+	stream, err := w.next.StreamBucketReplication(ctx, req)
+	w.record(ctx, a, err)
+	if err != nil {
+		return nil, err
+	}
+	// Receive failures are not the probe, which ended when the stream was opened
+	return &resilientStream{ReplicationStream: stream, worker: w, ctx: ctx, admission: admission{opened: a.opened}}, nil
+}
+
+// resilientStream reports receive failures to the circuit breaker.
+// ctx is the context the stream was opened with, it tells the caller's deadline from the worker's.
+type resilientStream struct {
+	domain.ReplicationStream
+	worker    *ResilientWorker
+	ctx       context.Context
+	admission admission
+}
+
+// Recv receives the next replication update
+func (s *resilientStream) Recv() (*pb.Replication, error) {
+	resp, err := s.ReplicationStream.Recv()
+	if isWorkerFailure(s.ctx, err) {
+		s.worker.record(s.ctx, s.admission, err)
+	}
+	return resp, err
+}
+
+// call runs fn through the circuit breaker, retrying idempotent calls on transient errors
+func call[T any](ctx context.Context, w *ResilientWorker, idempotent bool, fn func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+
+	attempts := 1
+	if idempotent {
+		attempts = w.cfg.MaxAttempts
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepCtx(ctx, w.backoff(attempt)); err != nil {
+				return zero, err
+			}
+		}
+
+		var a admission
+		if a, err = w.allow(); err != nil {
+			return zero, err
+		}
+
+		var resp T
+		resp, err = fn(ctx)
+		w.record(ctx, a, err)
+		if err == nil {
+			return resp, nil
+		}
+		if !isRetryable(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return zero, err
+}
+
+// allow checks whether a call may go through the breaker
+func (w *ResilientWorker) allow() (admission, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch w.state {
+	case BreakerOpen:
+		if time.Since(w.openedAt) < w.cfg.OpenTimeout {
+			return admission{}, fmt.Errorf("%w: worker %q circuit breaker is open: %s", errors.ErrWorkerUnavailable, w.name, w.lastError)
+		}
+		// Open timeout elapsed, let a single probe call through
+		w.state = BreakerHalfOpen
+		w.halfOpenInFlight = true
+		return admission{opened: w.opened, probe: true}, nil
+	case BreakerHalfOpen:
+		if w.halfOpenInFlight {
+			return admission{}, fmt.Errorf("%w: worker %q circuit breaker is half-open", errors.ErrWorkerUnavailable, w.name)
+		}
+		w.halfOpenInFlight = true
+		return admission{opened: w.opened, probe: true}, nil
+	default:
+		return admission{opened: w.opened}, nil
+	}
+}
+
+// record updates the breaker with the outcome of a call made with ctx.
+// Calls admitted before the breaker last opened are ignored, only the probe closes a half-open breaker.
+func (w *ResilientWorker) record(ctx context.Context, a admission, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if a.probe {
+		w.halfOpenInFlight = false
+	}
+	if a.opened != w.opened || isNeutral(ctx, err) {
+		return
+	}
+	if !isWorkerFailure(ctx, err) {
+		// Successful calls and client errors prove the worker is reachable
+		if w.state == BreakerHalfOpen && !a.probe {
+			return
+		}
+		w.state = BreakerClosed
+		w.failures = 0
+		return
+	}
+
+	w.failures++
+	w.lastError = err.Error()
+	if w.state == BreakerHalfOpen || w.failures >= w.cfg.FailureThreshold {
+		w.state = BreakerOpen
+		w.openedAt = time.Now()
+		w.opened++
+	}
+}
+
+// backoff returns the jittered delay before the given retry attempt
+func (w *ResilientWorker) backoff(attempt int) time.Duration {
+	delay := w.cfg.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > w.cfg.MaxDelay {
+		delay = w.cfg.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Full jitter spreads retries of concurrent callers
+	return time.Duration(rand.Int64N(int64(delay)) + 1)
+}
+
+// isWorkerFailure reports whether an error indicates that the worker itself is failing.
+// Errors the worker answered with, e.g. Internal or Aborted, prove it is reachable.
+// Errors without a gRPC status, such as io.EOF ending a stream, are reported as Unknown and do not count either.
+// DeadlineExceeded only counts while ctx is alive, a call outliving the caller's own deadline says nothing about the worker.
+func isWorkerFailure(ctx context.Context, err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	case codes.DeadlineExceeded:
+		return ctx.Err() == nil
+	default:
+		return false
+	}
+}
+
+// isNeutral reports whether the outcome of a call says nothing about the worker:
+// the caller went away (Canceled, or DeadlineExceeded past the caller's deadline) or the error has no gRPC status (Unknown)
+func isNeutral(ctx context.Context, err error) bool {
+	switch status.Code(err) {
+	case codes.Canceled, codes.Unknown:
+		return true
+	case codes.DeadlineExceeded:
+		return ctx.Err() != nil
+	default:
+		return false
+	}
+}
+
+// isRetryable reports whether a failed idempotent call may be retried
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleepCtx waits for d or until ctx is done
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Ensure ResilientWorker implements domain.WorkerClient interface
+var _ domain.WorkerClient = (*ResilientWorker)(nil)
@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// flakyWorker fails ListReplications and PauseReplication with the queued errors
+type flakyWorker struct {
+	domain.WorkerClient
+	errs  []error
+	calls int
+}
+
+func (f *flakyWorker) next() error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flakyWorker) ListReplications(context.Context) (*pb.ListReplicationsResponse, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &pb.ListReplicationsResponse{}, nil
+}
+
+func (f *flakyWorker) PauseReplication(context.Context, *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func testResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		MaxAttempts:      3,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         5 * time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      50 * time.Millisecond,
+	}
+}
+
+func TestResilientWorkerRetriesIdempotentCalls(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	next := &flakyWorker{errs: []error{unavailable, unavailable}}
+	w := NewResilientWorker("test", next, testResilienceConfig())
+	w.cfg.FailureThreshold = 10
+
+	if _, err := w.ListReplications(context.Background()); err != nil {
+		t.Fatalf("expected retries to succeed: %v", err)
+	}
+	if next.calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", next.calls)
+	}
+}
+
+func TestResilientWorkerDoesNotRetryMutations(t *testing.T) {
+	next := &flakyWorker{errs: []error{status.Error(codes.Unavailable, "connection refused")}}
+	w := NewResilientWorker("test", next, testResilienceConfig())
+
+	if _, err := w.PauseReplication(context.Background(), &pb.ReplicationRequest{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if next.calls != 1 {
+		t.Fatalf("expected a single attempt, got %d", next.calls)
+	}
+}
+
+func TestResilientWorkerCircuitBreaker(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	next := &flakyWorker{errs: []error{unavailable, unavailable}}
+	cfg := testResilienceConfig()
+	cfg.MaxAttempts = 1
+	w := NewResilientWorker("test", next, cfg)
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.ListReplications(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if state := w.BreakerState(); state.State != BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", state.State)
+	}
+
+	// Open breaker fails fast without calling the worker
+	_, err := w.ListReplications(context.Background())
+	if !errors.Is(err, errors.ErrWorkerUnavailable) {
+		t.Fatalf("expected ErrWorkerUnavailable, got %v", err)
+	}
+	if next.calls != 2 {
+		t.Fatalf("expected worker not to be called while open, got %d calls", next.calls)
+	}
+
+	// After the open timeout a probe call closes the breaker again
+	time.Sleep(cfg.OpenTimeout)
+	if _, err := w.ListReplications(context.Background()); err != nil {
+		t.Fatalf("expected probe call to succeed: %v", err)
+	}
+	if state := w.BreakerState(); state.State != BreakerClosed {
+		t.Fatalf("expected closed breaker, got %s", state.State)
+	}
+}
+
+func TestIsWorkerFailure(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, false},
+		{fmt.Errorf("decode failed"), false},
+		{status.Error(codes.Unknown, "panic in handler"), false},
+		{status.Error(codes.Internal, "storage error"), false},
+		{status.Error(codes.Aborted, "conflict"), false},
+		{status.Error(codes.NotFound, "replication not found"), false},
+		{status.Error(codes.Unavailable, "connection refused"), true},
+		{status.Error(codes.DeadlineExceeded, "deadline exceeded"), true},
+		{status.Error(codes.ResourceExhausted, "too many requests"), true},
+	} {
+		if got := isWorkerFailure(context.Background(), tc.err); got != tc.want {
+			t.Errorf("isWorkerFailure(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestResilientWorkerIgnoresCallerDeadlines(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	// The caller's deadline expired, the worker was merely slow
+	deadline := status.Error(codes.DeadlineExceeded, "context deadline exceeded")
+	if isWorkerFailure(ctx, deadline) {
+		t.Fatal("expected the caller's deadline not to count as a worker failure")
+	}
+
+	next := &flakyWorker{errs: []error{deadline, deadline, deadline}}
+	w := NewResilientWorker("test", next, testResilienceConfig())
+	for i := 0; i < 3; i++ {
+		_, _ = w.PauseReplication(ctx, &pb.ReplicationRequest{})
+	}
+	if state := w.BreakerState(); state.State != BreakerClosed || state.ConsecutiveFailures != 0 {
+		t.Fatalf("caller deadlines must not open the breaker, got %+v", state)
+	}
+}
+
+func TestResilientWorkerCanceledProbe(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	canceled := status.Error(codes.Canceled, "context canceled")
+	next := &flakyWorker{errs: []error{unavailable, unavailable, canceled}}
+	cfg := testResilienceConfig()
+	cfg.MaxAttempts = 1
+	w := NewResilientWorker("test", next, cfg)
+
+	for i := 0; i < 2; i++ {
+		_, _ = w.ListReplications(context.Background())
+	}
+	time.Sleep(cfg.OpenTimeout + 10*time.Millisecond)
+
+	// A client disconnecting during the probe proves nothing, the next call probes again
+	if _, err := w.ListReplications(context.Background()); status.Code(err) != codes.Canceled {
+		t.Fatalf("expected the canceled probe, got %v", err)
+	}
+	if state := w.BreakerState(); state.State != BreakerHalfOpen {
+		t.Fatalf("expected half-open breaker after a canceled probe, got %s", state.State)
+	}
+	if _, err := w.ListReplications(context.Background()); err != nil {
+		t.Fatalf("expected the second probe to succeed: %v", err)
+	}
+	if state := w.BreakerState(); state.State != BreakerClosed {
+		t.Fatalf("expected closed breaker, got %s", state.State)
+	}
+}
+
+func TestResilientWorkerIgnoresLateResults(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	next := &flakyWorker{errs: []error{unavailable, unavailable}}
+	cfg := testResilienceConfig()
+	cfg.MaxAttempts = 1
+	w := NewResilientWorker("test", next, cfg)
+
+	// A slow call is admitted while the breaker is closed
+	slow, err := w.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		_, _ = w.ListReplications(context.Background())
+	}
+	if state := w.BreakerState(); state.State != BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", state.State)
+	}
+
+	// Its success arrives after the breaker opened
+	w.record(context.Background(), slow, nil)
+	if state := w.BreakerState(); state.State != BreakerOpen {
+		t.Fatalf("a call admitted before the breaker opened must not close it, got %s", state.State)
+	}
+
+	// Nor does it close the breaker once half-open, only the probe does
+	time.Sleep(cfg.OpenTimeout + 10*time.Millisecond)
+	probe, err := w.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.record(context.Background(), slow, nil)
+	if state := w.BreakerState(); state.State != BreakerHalfOpen {
+		t.Fatalf("expected half-open breaker while the probe runs, got %s", state.State)
+	}
+	w.record(context.Background(), probe, nil)
+	if state := w.BreakerState(); state.State != BreakerClosed {
+		t.Fatalf("expected the probe to close the breaker, got %s", state.State)
+	}
+}
+
+func TestResilientWorkerIgnoresClientErrors(t *testing.T) {
+	notFound := status.Error(codes.NotFound, "replication not found")
+	next := &flakyWorker{errs: []error{notFound, notFound, notFound}}
+	w := NewResilientWorker("test", next, testResilienceConfig())
+
+	for i := 0; i < 3; i++ {
+		_, _ = w.PauseReplication(context.Background(), &pb.ReplicationRequest{})
+	}
+	if state := w.BreakerState(); state.State != BreakerClosed {
+		t.Fatalf("client errors must not open the breaker, got %s", state.State)
+	}
+}
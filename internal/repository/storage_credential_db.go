@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/db"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCredentialInUse is returned when a storage user is deleted while replication jobs of the user reference the storage
+var ErrCredentialInUse = errors.New("storage user is referenced by replication jobs")
+
+// StorageCredentialDBRepository stores the users of storages besides their own user
+type StorageCredentialDBRepository struct{}
+
+// NewStorageCredentialDBRepository creates a new storage credential repository
+func NewStorageCredentialDBRepository() *StorageCredentialDBRepository {
+	return &StorageCredentialDBRepository{}
+}
+
+// List returns the credentials of a storage ordered by user
+func (r *StorageCredentialDBRepository) List(ctx context.Context, storageID uuid.UUID) ([]domain.StorageCredential, error) {
+	var items []domain.StorageCredential
+	err := db.DB().WithContext(ctx).Where("storage_id = ?", storageID).Order(`"user"`).Find(&items).Error
+	return items, err
+}
+
+// ListAll returns the credentials of every storage ordered by storage and user
+func (r *StorageCredentialDBRepository) ListAll(ctx context.Context) ([]domain.StorageCredential, error) {
+	var items []domain.StorageCredential
+	err := db.DB().WithContext(ctx).Order(`storage_id, "user"`).Find(&items).Error
+	return items, err
+}
+
+// Get returns a credential of a storage by user
+func (r *StorageCredentialDBRepository) Get(ctx context.Context, storageID uuid.UUID, user string) (*domain.StorageCredential, error) {
+	var c domain.StorageCredential
+	if err := db.DB().WithContext(ctx).Where(`storage_id = ? AND "user" = ?`, storageID, user).First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *StorageCredentialDBRepository) Create(ctx context.Context, c *domain.StorageCredential) error {
+	return db.DB().WithContext(ctx).Create(c).Error
+}
+
+func (r *StorageCredentialDBRepository) Update(ctx context.Context, c *domain.StorageCredential) error {
+	return db.DB().WithContext(ctx).Save(c).Error
+}
+
+// Delete deletes a credential unless replication jobs of the user reference the storage,
+// in which case the jobs are returned with ErrCredentialInUse
+func (r *StorageCredentialDBRepository) Delete(ctx context.Context, storageID uuid.UUID, user string) ([]domain.ReplicateJob, error) {
+	var jobs []domain.ReplicateJob
+	err := db.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The lock keeps the storage from being renamed while its jobs are checked
+		var s domain.Storage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("name").Where("id = ?", storageID).First(&s).Error; err != nil {
+			return err
+		}
+		if err := tx.Where(`"user" = ? AND ("from" = ? OR "to" = ?)`, user, s.Name, s.Name).Order("bucket").Find(&jobs).Error; err != nil {
+			return err
+		}
+		if len(jobs) > 0 {
+			return ErrCredentialInUse
+		}
+
+		res := tx.Where(`storage_id = ? AND "user" = ?`, storageID, user).Delete(&domain.StorageCredential{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// UsersByStorage returns the users of the stored storages with the given names, their own user first.
+// Names which are not stored are missing from the result.
+func (r *StorageCredentialDBRepository) UsersByStorage(ctx context.Context, names []string) (map[string][]string, error) {
+	var storages []domain.Storage
+	if err := db.DB().WithContext(ctx).Select("id", "name", "user").Where("name IN ?", names).Find(&storages).Error; err != nil {
+		return nil, err
+	}
+	users := make(map[string][]string, len(storages))
+	ids := make([]uuid.UUID, 0, len(storages))
+	byID := make(map[uuid.UUID]string, len(storages))
+	for _, s := range storages {
+		users[s.Name] = []string{s.User}
+		ids = append(ids, s.ID)
+		byID[s.ID] = s.Name
+	}
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	var creds []domain.StorageCredential
+	if err := db.DB().WithContext(ctx).Select("storage_id", "user").Where("storage_id IN ?", ids).Order(`"user"`).Find(&creds).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range creds {
+		users[byID[c.StorageID]] = append(users[byID[c.StorageID]], c.User)
+	}
+	return users, nil
+}
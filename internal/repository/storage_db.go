@@ -2,13 +2,21 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/hantdev/chorus-controller/internal/db"
 	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/labels"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrStorageInUse is returned when a storage is deleted while replication jobs reference it
+var ErrStorageInUse = errors.New("storage is referenced by replication jobs")
+
 type StorageDBRepository struct{}
 
 type ReplicateJobDBRepository struct{}
@@ -27,6 +35,47 @@ func (r *StorageDBRepository) List(ctx context.Context) ([]domain.Storage, error
 	return items, err
 }
 
+// ListFiltered lists the storages matching a filter in name order
+func (r *StorageDBRepository) ListFiltered(ctx context.Context, filter *domain.StorageFilter) ([]domain.Storage, error) {
+	q := db.DB().WithContext(ctx)
+	for _, req := range filter.Selector {
+		switch req.Operator {
+		case labels.Equals:
+			// Containment uses the GIN index
+			doc, err := json.Marshal(map[string]string{req.Key: req.Value})
+			if err != nil {
+				return nil, err
+			}
+			q = q.Where("labels @> CAST(? AS jsonb)", string(doc))
+		case labels.NotEquals:
+			q = q.Where("(labels ->> ?) IS DISTINCT FROM ?", req.Key, req.Value)
+		case labels.Exists:
+			q = q.Where("labels ->> ? IS NOT NULL", req.Key)
+		case labels.NotExists:
+			q = q.Where("labels ->> ? IS NULL", req.Key)
+		}
+	}
+	if filter.Provider != "" {
+		q = q.Where("lower(provider) = lower(?)", filter.Provider)
+	}
+	if filter.NamePrefix != "" {
+		q = q.Where(`name LIKE ? ESCAPE '\'`, likeEscaper.Replace(filter.NamePrefix)+"%")
+	}
+	if filter.After != "" {
+		q = q.Where("name > ?", filter.After)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	var items []domain.Storage
+	err := q.Order("name asc").Find(&items).Error
+	return items, err
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 func (r *StorageDBRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Storage, error) {
 	var s domain.Storage
 	err := db.DB().WithContext(ctx).Where("id = ?", id).First(&s).Error
@@ -39,19 +88,181 @@ func (r *StorageDBRepository) GetByID(ctx context.Context, id uuid.UUID) (*domai
 	return &s, nil
 }
 
+// Update saves a storage, a new name is propagated to the replication jobs in the same transaction
 func (r *StorageDBRepository) Update(ctx context.Context, s *domain.Storage) error {
-	return db.DB().WithContext(ctx).Save(s).Error
+	return db.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return saveStorage(tx, s)
+	})
 }
 
-func (r *StorageDBRepository) DeleteByID(ctx context.Context, id uuid.UUID) error {
-	result := db.DB().WithContext(ctx).Where("id = ?", id).Delete(&domain.Storage{})
-	if result.Error != nil {
-		return result.Error
+// ListMain returns the main storages, at most one unless the invariant was broken before it was enforced
+func (r *StorageDBRepository) ListMain(ctx context.Context) ([]domain.Storage, error) {
+	var items []domain.Storage
+	err := db.DB().WithContext(ctx).Where("is_main").Order("name asc").Find(&items).Error
+	return items, err
+}
+
+// SaveAsMain creates or updates a main storage and demotes every other main storage in one transaction
+func (r *StorageDBRepository) SaveAsMain(ctx context.Context, s *domain.Storage, create bool) error {
+	return db.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Demote first, the single main index rejects two main rows at any time
+		if err := tx.Model(&domain.Storage{}).Where("is_main AND id <> ?", s.ID).Update("is_main", false).Error; err != nil {
+			return err
+		}
+		s.IsMain = true
+		if create {
+			return tx.Create(s).Error
+		}
+		return saveStorage(tx, s)
+	})
+}
+
+// Upsert creates and updates storages and saves credentials, by ID, in one transaction.
+// When one of the storages is main every other main storage is demoted first.
+func (r *StorageDBRepository) Upsert(ctx context.Context, create, update []*domain.Storage, credentials []*domain.StorageCredential) error {
+	return db.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, s := range append(append([]*domain.Storage(nil), create...), update...) {
+			if !s.IsMain {
+				continue
+			}
+			// Demote first, the single main index rejects two main rows at any time
+			if err := tx.Model(&domain.Storage{}).Where("is_main AND id <> ?", s.ID).Update("is_main", false).Error; err != nil {
+				return err
+			}
+		}
+		for _, s := range update {
+			if err := saveStorage(tx, s); err != nil {
+				return err
+			}
+		}
+		for _, s := range create {
+			if err := tx.Create(s).Error; err != nil {
+				return err
+			}
+		}
+		for _, c := range credentials {
+			// Stored credentials only get their keys replaced, created_at stays
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"access_key_id", "secret_access_key", "updated_at"}),
+			}).Create(c).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saveStorage updates a storage and renames it in the replication jobs referencing it
+func saveStorage(tx *gorm.DB, s *domain.Storage) error {
+	var current domain.Storage
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("name").Where("id = ?", s.ID).First(&current).Error; err != nil {
+		return err
+	}
+	if err := tx.Save(s).Error; err != nil {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if current.Name == s.Name {
+		return nil
 	}
-	return nil
+	if err := tx.Model(&domain.ReplicateJob{}).Where(`"from" = ?`, current.Name).Update("from", s.Name).Error; err != nil {
+		return err
+	}
+	return tx.Model(&domain.ReplicateJob{}).Where(`"to" = ?`, current.Name).Update("to", s.Name).Error
+}
+
+// RotateSecrets passes up to limit storages with an ID greater than after to rotate, in ID order and in one transaction,
+// then their credentials to rotateCredential.
+// The callbacks return the new secret of a row, or an empty string to keep it.
+// It returns the number of storages passed and the ID of the last one.
+func (r *StorageDBRepository) RotateSecrets(ctx context.Context, after uuid.UUID, limit int,
+	rotate func(s *domain.Storage) (string, error), rotateCredential func(c *domain.StorageCredential) (string, error)) (int, uuid.UUID, error) {
+	var items []domain.Storage
+	err := db.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "name", "secret_access_key").
+			Where("id > ?", after).Order("id").Limit(limit).
+			Find(&items).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			secret, err := rotate(&items[i])
+			if err != nil {
+				return err
+			}
+			if secret == "" {
+				continue
+			}
+			if err := tx.Model(&domain.Storage{}).Where("id = ?", items[i].ID).Update("secret_access_key", secret).Error; err != nil {
+				return err
+			}
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(items))
+		for i := range items {
+			ids[i] = items[i].ID
+		}
+		var creds []domain.StorageCredential
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "storage_id", "user", "secret_access_key").
+			Where("storage_id IN ?", ids).Order(`storage_id, "user"`).
+			Find(&creds).Error; err != nil {
+			return err
+		}
+		for i := range creds {
+			secret, err := rotateCredential(&creds[i])
+			if err != nil {
+				return err
+			}
+			if secret == "" {
+				continue
+			}
+			if err := tx.Model(&domain.StorageCredential{}).Where("id = ?", creds[i].ID).Update("secret_access_key", secret).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || len(items) == 0 {
+		return 0, after, err
+	}
+	return len(items), items[len(items)-1].ID, nil
+}
+
+// DeleteByID deletes a storage unless replication jobs reference it, in which case the jobs are returned with ErrStorageInUse.
+// With force the jobs are deleted along with the storage.
+func (r *StorageDBRepository) DeleteByID(ctx context.Context, id uuid.UUID, force bool) ([]domain.ReplicateJob, error) {
+	var jobs []domain.ReplicateJob
+	err := db.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var s domain.Storage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&s).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where(`"from" = ? OR "to" = ?`, s.Name, s.Name).Order(`"user", bucket`).Find(&jobs).Error; err != nil {
+			return err
+		}
+		if len(jobs) > 0 {
+			if !force {
+				return ErrStorageInUse
+			}
+			if err := tx.Where(`"from" = ? OR "to" = ?`, s.Name, s.Name).Delete(&domain.ReplicateJob{}).Error; err != nil {
+				return err
+			}
+		}
+		// The foreign key cascades as well, tables created by AutoMigrate lack it
+		if err := tx.Where("storage_id = ?", s.ID).Delete(&domain.StorageCredential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("storage_id = ?", s.ID).Delete(&domain.StorageHealthCheck{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&s).Error
+	})
+	return jobs, err
 }
 
 // ReplicateJob CRUD
@@ -68,3 +279,46 @@ func (r *ReplicateJobDBRepository) List(ctx context.Context) ([]domain.Replicate
 func (r *ReplicateJobDBRepository) DeleteByID(ctx context.Context, id uuid.UUID) error {
 	return db.DB().WithContext(ctx).Where("id = ?", id).Delete(&domain.ReplicateJob{}).Error
 }
+
+// DeleteByReplication deletes the jobs of a user's replications between two storages.
+// A bucket limits the deletion to the jobs of that bucket, without one every job of the user between the storages is deleted.
+func (r *ReplicateJobDBRepository) DeleteByReplication(ctx context.Context, user, from, to, bucket string) error {
+	q := db.DB().WithContext(ctx).Where(`"user" = ? AND "from" = ? AND "to" = ?`, user, from, to)
+	if bucket != "" {
+		q = q.Where("bucket = ?", bucket)
+	}
+	return q.Delete(&domain.ReplicateJob{}).Error
+}
+
+// FindWorker returns the worker owning the replication of the given bucket.
+// Rows for all-buckets replications (empty bucket) match any bucket.
+func (r *ReplicateJobDBRepository) FindWorker(ctx context.Context, user, bucket, from, to string) (string, error) {
+	var job domain.ReplicateJob
+	err := db.DB().WithContext(ctx).
+		Where(`"user" = ? AND "from" = ? AND "to" = ? AND (bucket = ? OR bucket = '')`, user, from, to, bucket).
+		Order("bucket desc").
+		First(&job).Error
+	if err != nil {
+		return "", err
+	}
+	return job.Worker, nil
+}
+
+// FindUserWorker returns the worker owning any replication of the user between the storages
+func (r *ReplicateJobDBRepository) FindUserWorker(ctx context.Context, user, from, to string) (string, error) {
+	var job domain.ReplicateJob
+	err := db.DB().WithContext(ctx).
+		Where(`"user" = ? AND "from" = ? AND "to" = ?`, user, from, to).
+		Order("bucket asc").
+		First(&job).Error
+	if err != nil {
+		return "", err
+	}
+	return job.Worker, nil
+}
+
+func (r *ReplicateJobDBRepository) CountByWorker(ctx context.Context, worker string) (int64, error) {
+	var count int64
+	err := db.DB().WithContext(ctx).Model(&domain.ReplicateJob{}).Where("worker = ?", worker).Count(&count).Error
+	return count, err
+}
@@ -2,120 +2,192 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	pb "github.com/clyso/chorus/proto/gen/go/chorus"
 	"github.com/hantdev/chorus-controller/internal/domain"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
+const (
+	// Keepalive settings match the worker's enforcement policy (MinTime 30s, PermitWithoutStream)
+	workerKeepaliveTime    = 30 * time.Second
+	workerKeepaliveTimeout = 10 * time.Second
+
+	// Upper bound for the reconnect backoff
+	workerMaxBackoffDelay = 30 * time.Second
+	// Minimum time given to a single connection attempt
+	workerMinConnectTimeout = 5 * time.Second
+
+	// Bucket comparisons list every mismatched key, which exceeds the default 4 MiB for large buckets
+	compareMaxRecvMsgSize = 256 << 20
+)
+
 // WorkerRepository implements domain.WorkerClient interface
+// It owns a single long-lived gRPC connection which is safe for concurrent use
 type WorkerRepository struct {
-	addr string
+	addr   string
+	conn   *grpc.ClientConn
+	client pb.ChorusClient
 }
 
-// NewWorkerRepository creates a new worker repository
-func NewWorkerRepository(addr string) *WorkerRepository {
-	return &WorkerRepository{addr: addr}
-}
+// NewWorkerRepository creates a new worker repository and starts connecting to the worker
+func NewWorkerRepository(addr string, tlsCfg WorkerTLSConfig) (*WorkerRepository, error) {
+	creds := insecure.NewCredentials()
+	if tlsCfg.IsEnabled() {
+		var err error
+		creds, err = newWorkerTransportCredentials(tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-// dial creates a gRPC connection to the worker service
-func (r *WorkerRepository) dial(ctx context.Context) (pb.ChorusClient, *grpc.ClientConn, error) {
-	conn, err := grpc.NewClient(r.addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(addr, dialOptions(creds)...)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	return pb.NewChorusClient(conn), conn, nil
+
+	// grpc.NewClient is lazy, kick off the first connection attempt right away
+	// so the connection state is meaningful before the first RPC
+	conn.Connect()
+
+	return &WorkerRepository{
+		addr:   addr,
+		conn:   conn,
+		client: pb.NewChorusClient(conn),
+	}, nil
 }
 
-// GetStorages retrieves all configured storages
-func (r *WorkerRepository) GetStorages(ctx context.Context) (*pb.GetStoragesResponse, error) {
-	client, conn, err := r.dial(ctx)
-	if err != nil {
-		return nil, err
+// dialOptions returns the gRPC options used for the worker connection
+func dialOptions(creds credentials.TransportCredentials) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                workerKeepaliveTime,
+			Timeout:             workerKeepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  time.Second,
+				Multiplier: 1.6,
+				Jitter:     0.2,
+				MaxDelay:   workerMaxBackoffDelay,
+			},
+			MinConnectTimeout: workerMinConnectTimeout,
+		}),
 	}
-	defer conn.Close()
+}
 
-	return client.GetStorages(ctx, &emptypb.Empty{})
+// Addr returns the worker address
+func (r *WorkerRepository) Addr() string {
+	return r.addr
 }
 
-// ListBucketsForReplication retrieves buckets available for replication
-func (r *WorkerRepository) ListBucketsForReplication(ctx context.Context, req *pb.ListBucketsForReplicationRequest) (*pb.ListBucketsForReplicationResponse, error) {
-	client, conn, err := r.dial(ctx)
-	if err != nil {
-		return nil, err
+// State returns the current state of the worker connection (e.g. READY, TRANSIENT_FAILURE)
+func (r *WorkerRepository) State() connectivity.State {
+	state := r.conn.GetState()
+	if state == connectivity.Idle {
+		// An idle channel only reconnects on demand, ask it to reconnect so
+		// the reported state recovers without waiting for the next RPC
+		r.conn.Connect()
 	}
-	defer conn.Close()
+	return state
+}
+
+// Close closes the underlying gRPC connection
+func (r *WorkerRepository) Close() error {
+	return r.conn.Close()
+}
+
+// GetStorages retrieves all configured storages
+func (r *WorkerRepository) GetStorages(ctx context.Context) (*pb.GetStoragesResponse, error) {
+	return r.client.GetStorages(ctx, &emptypb.Empty{})
+}
 
-	return client.ListBucketsForReplication(ctx, req)
+// ListBucketsForReplication retrieves buckets available for replication
+func (r *WorkerRepository) ListBucketsForReplication(ctx context.Context, req *pb.ListBucketsForReplicationRequest) (*pb.ListBucketsForReplicationResponse, error) {
+	return r.client.ListBucketsForReplication(ctx, req)
 }
 
 // AddReplication creates a new replication job
 func (r *WorkerRepository) AddReplication(ctx context.Context, req *pb.AddReplicationRequest) (*emptypb.Empty, error) {
-	client, conn, err := r.dial(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
-
-	return client.AddReplication(ctx, req)
+	return r.client.AddReplication(ctx, req)
 }
 
 // ListReplications retrieves all replication jobs
 func (r *WorkerRepository) ListReplications(ctx context.Context) (*pb.ListReplicationsResponse, error) {
-	client, conn, err := r.dial(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
+	return r.client.ListReplications(ctx, &emptypb.Empty{})
+}
+
+// ListUserReplications retrieves the replications created for all buckets of a user
+func (r *WorkerRepository) ListUserReplications(ctx context.Context) (*pb.ListUserReplicationsResponse, error) {
+	return r.client.ListUserReplications(ctx, &emptypb.Empty{})
+}
 
-	return client.ListReplications(ctx, &emptypb.Empty{})
+// DeleteUserReplication deletes a replication created for all buckets of a user
+func (r *WorkerRepository) DeleteUserReplication(ctx context.Context, req *pb.DeleteUserReplicationRequest) (*emptypb.Empty, error) {
+	return r.client.DeleteUserReplication(ctx, req)
 }
 
 // PauseReplication pauses a replication job
 func (r *WorkerRepository) PauseReplication(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
-	client, conn, err := r.dial(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
-
-	return client.PauseReplication(ctx, req)
+	return r.client.PauseReplication(ctx, req)
 }
 
 // ResumeReplication resumes a replication job
 func (r *WorkerRepository) ResumeReplication(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
-	client, conn, err := r.dial(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
-
-	return client.ResumeReplication(ctx, req)
+	return r.client.ResumeReplication(ctx, req)
 }
 
 // DeleteReplication deletes a replication job
 func (r *WorkerRepository) DeleteReplication(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
-	client, conn, err := r.dial(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
-
-	return client.DeleteReplication(ctx, req)
+	return r.client.DeleteReplication(ctx, req)
 }
 
 // SwitchBucketZeroDowntime switches buckets without downtime
 func (r *WorkerRepository) SwitchBucketZeroDowntime(ctx context.Context, req *pb.SwitchBucketZeroDowntimeRequest) (*emptypb.Empty, error) {
-	client, conn, err := r.dial(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
+	return r.client.SwitchBucketZeroDowntime(ctx, req)
+}
+
+// StreamBucketReplication streams the progress of a bucket replication
+func (r *WorkerRepository) StreamBucketReplication(ctx context.Context, req *pb.ReplicationRequest) (domain.ReplicationStream, error) {
+	return r.client.StreamBucketReplication(ctx, req)
+}
+
+// SwitchBucket switches buckets with a downtime window
+func (r *WorkerRepository) SwitchBucket(ctx context.Context, req *pb.SwitchBucketRequest) (*emptypb.Empty, error) {
+	return r.client.SwitchBucket(ctx, req)
+}
+
+// DeleteBucketSwitch aborts a bucket switch and removes its metadata
+func (r *WorkerRepository) DeleteBucketSwitch(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	return r.client.DeleteBucketSwitch(ctx, req)
+}
+
+// GetBucketSwitchStatus retrieves the status of a bucket switch
+func (r *WorkerRepository) GetBucketSwitchStatus(ctx context.Context, req *pb.ReplicationRequest) (*pb.GetBucketSwitchStatusResponse, error) {
+	return r.client.GetBucketSwitchStatus(ctx, req)
+}
+
+// ListReplicationSwitches retrieves all bucket switches
+func (r *WorkerRepository) ListReplicationSwitches(ctx context.Context) (*pb.ListSwitchResponse, error) {
+	return r.client.ListReplicationSwitches(ctx, &emptypb.Empty{})
+}
 
-	return client.SwitchBucketZeroDowntime(ctx, req)
+// CompareBucket compares the contents of the source and destination buckets
+func (r *WorkerRepository) CompareBucket(ctx context.Context, req *pb.CompareBucketRequest) (*pb.CompareBucketResponse, error) {
+	return r.client.CompareBucket(ctx, req, grpc.MaxCallRecvMsgSize(compareMaxRecvMsgSize))
 }
 
-// Ensure WorkerRepository implements domain.WorkerClient interface
-var _ domain.WorkerClient = (*WorkerRepository)(nil)
+// Ensure WorkerRepository implements domain.WorkerClient and domain.WorkerConnection interfaces
+var (
+	_ domain.WorkerClient     = (*WorkerRepository)(nil)
+	_ domain.WorkerConnection = (*WorkerRepository)(nil)
+)
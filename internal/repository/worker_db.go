@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hantdev/chorus-controller/internal/db"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"gorm.io/gorm"
+)
+
+type WorkerDBRepository struct{}
+
+func NewWorkerDBRepository() *WorkerDBRepository { return &WorkerDBRepository{} }
+
+// Worker CRUD
+func (r *WorkerDBRepository) Create(ctx context.Context, w *domain.Worker) error {
+	return db.DB().WithContext(ctx).Create(w).Error
+}
+
+func (r *WorkerDBRepository) List(ctx context.Context) ([]domain.Worker, error) {
+	var items []domain.Worker
+	err := db.DB().WithContext(ctx).Order("name asc").Find(&items).Error
+	return items, err
+}
+
+func (r *WorkerDBRepository) GetByName(ctx context.Context, name string) (*domain.Worker, error) {
+	var w domain.Worker
+	err := db.DB().WithContext(ctx).Where("name = ?", name).First(&w).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *WorkerDBRepository) Update(ctx context.Context, w *domain.Worker) error {
+	return db.DB().WithContext(ctx).Save(w).Error
+}
+
+func (r *WorkerDBRepository) DeleteByName(ctx context.Context, name string) error {
+	result := db.DB().WithContext(ctx).Where("name = ?", name).Delete(&domain.Worker{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// WorkerTLSConfig holds TLS settings for the controller->worker gRPC channel
+type WorkerTLSConfig struct {
+	// Enabled turns TLS on even when no files are given (system roots are used)
+	Enabled bool
+	// CAFile is a PEM bundle used to verify the worker certificate
+	CAFile string
+	// CertFile and KeyFile hold the client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name used to verify the worker certificate
+	ServerName string
+}
+
+// IsEnabled reports whether TLS should be used for the worker connection.
+// Any file turns TLS on, so that half a key pair is rejected rather than ignored.
+func (c WorkerTLSConfig) IsEnabled() bool {
+	return c.Enabled || c.CAFile != "" || c.CertFile != "" || c.KeyFile != ""
+}
+
+// newWorkerTransportCredentials builds gRPC transport credentials which pick up
+// certificate changes on disk without restarting the controller
+func newWorkerTransportCredentials(cfg WorkerTLSConfig) (credentials.TransportCredentials, error) {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, fmt.Errorf("worker TLS: both certificate and key files are required for mutual TLS")
+	}
+
+	reloader := &tlsReloader{cfg: cfg}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return &reloadingCredentials{reloader: reloader}, nil
+}
+
+// reloadingCredentials builds a fresh TLS configuration for every handshake so
+// reconnects use the latest certificates and CA bundle
+type reloadingCredentials struct {
+	reloader *tlsReloader
+}
+
+// ClientHandshake performs the TLS handshake with the current TLS material
+func (c *reloadingCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	c.reloader.refresh()
+	return credentials.NewTLS(c.reloader.tlsConfig()).ClientHandshake(ctx, authority, rawConn)
+}
+
+// ServerHandshake is not supported, the credentials are client-side only
+func (c *reloadingCredentials) ServerHandshake(net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, fmt.Errorf("worker TLS: server handshake not supported")
+}
+
+// Info returns protocol information for the credentials
+func (c *reloadingCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{
+		SecurityProtocol: "tls",
+		SecurityVersion:  "1.2",
+		ServerName:       c.reloader.cfg.ServerName,
+	}
+}
+
+// Clone returns a copy of the credentials sharing the same reloader
+func (c *reloadingCredentials) Clone() credentials.TransportCredentials {
+	return &reloadingCredentials{reloader: c.reloader}
+}
+
+// OverrideServerName is deprecated in gRPC, use WorkerTLSConfig.ServerName instead
+func (c *reloadingCredentials) OverrideServerName(string) error {
+	return fmt.Errorf("worker TLS: server name override is configured through WorkerTLSConfig")
+}
+
+// fileStamp identifies a version of a file on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// tlsReloader caches TLS material and reloads it when the underlying files change
+type tlsReloader struct {
+	cfg WorkerTLSConfig
+
+	mu     sync.RWMutex
+	stamps map[string]fileStamp
+	cert   *tls.Certificate
+	roots  *x509.CertPool
+}
+
+// changed reports whether any of the configured files differ from the loaded version
+func (r *tlsReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			// Keep using loaded material while files are being replaced
+			continue
+		}
+		if stamp, ok := r.stamps[path]; !ok || !stamp.modTime.Equal(info.ModTime()) || stamp.size != info.Size() {
+			return true
+		}
+	}
+	return false
+}
+
+// files returns the configured file paths
+func (r *tlsReloader) files() []string {
+	var files []string
+	for _, path := range []string{r.cfg.CAFile, r.cfg.CertFile, r.cfg.KeyFile} {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// reload reads all configured files and replaces the cached material
+func (r *tlsReloader) reload() error {
+	stamps := make(map[string]fileStamp)
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("worker TLS: %w", err)
+		}
+		stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	var cert *tls.Certificate
+	if r.cfg.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("worker TLS: failed to load client certificate: %w", err)
+		}
+		cert = &c
+	}
+
+	var roots *x509.CertPool
+	if r.cfg.CAFile != "" {
+		pem, err := os.ReadFile(r.cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("worker TLS: failed to read CA file: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("worker TLS: no certificates found in CA file %s", r.cfg.CAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.stamps = stamps
+	r.cert = cert
+	r.roots = roots
+	r.mu.Unlock()
+	return nil
+}
+
+// refresh reloads the material if it changed on disk, keeping the old one on failure
+func (r *tlsReloader) refresh() {
+	if !r.changed() {
+		return
+	}
+	if err := r.reload(); err != nil {
+		log.Printf("Warning: keeping previous worker TLS material: %v", err)
+	}
+}
+
+// tlsConfig returns a TLS configuration built from the currently loaded material
+func (r *tlsReloader) tlsConfig() *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: r.cfg.ServerName,
+		RootCAs:    r.roots,
+	}
+	if r.cert != nil {
+		cfg.Certificates = []tls.Certificate{*r.cert}
+	}
+	return cfg
+}
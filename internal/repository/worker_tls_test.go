@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// testCA is a locally generated certificate authority
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue signs a leaf certificate and returns its PEM encoded certificate and key
+func (ca *testCA) issue(t *testing.T, cn string, dnsNames []string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// tlsTestWorker answers GetStorages so the connection can be exercised
+type tlsTestWorker struct {
+	pb.UnimplementedChorusServer
+}
+
+func (w *tlsTestWorker) GetStorages(context.Context, *emptypb.Empty) (*pb.GetStoragesResponse, error) {
+	return &pb.GetStoragesResponse{Storages: []*pb.Storage{{Name: "main", IsMain: true}}}, nil
+}
+
+// startTLSWorker serves the test worker over mutual TLS trusting clientCA for client certificates
+func startTLSWorker(t *testing.T, serverCA, clientCA *testCA, serverName string) string {
+	t.Helper()
+	certPEM, keyPEM := serverCA.issue(t, serverName, []string{serverName}, x509.ExtKeyUsageServerAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPool := x509.NewCertPool()
+	clientPool.AddCert(clientCA.cert)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	pb.RegisterChorusServer(srv, &tlsTestWorker{})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+// writeFile writes data and bumps the modification time so reloads are detected
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(time.Duration(len(data)) * time.Millisecond)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeClientFiles writes CA bundle and client key pair into dir
+func writeClientFiles(t *testing.T, dir string, serverCA, clientCA *testCA) WorkerTLSConfig {
+	t.Helper()
+	certPEM, keyPEM := clientCA.issue(t, "controller", nil, x509.ExtKeyUsageClientAuth)
+	cfg := WorkerTLSConfig{
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		CertFile:   filepath.Join(dir, "client.pem"),
+		KeyFile:    filepath.Join(dir, "client-key.pem"),
+		ServerName: "worker.internal",
+	}
+	writeFile(t, cfg.CAFile, serverCA.pem)
+	writeFile(t, cfg.CertFile, certPEM)
+	writeFile(t, cfg.KeyFile, keyPEM)
+	return cfg
+}
+
+func getStorages(t *testing.T, repo *WorkerRepository, timeout time.Duration) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := repo.GetStorages(ctx)
+	return err
+}
+
+func TestWorkerRepositoryMutualTLS(t *testing.T) {
+	serverCA := newTestCA(t, "server-ca")
+	clientCA := newTestCA(t, "client-ca")
+	addr := startTLSWorker(t, serverCA, clientCA, "worker.internal")
+
+	cfg := writeClientFiles(t, t.TempDir(), serverCA, clientCA)
+	repo, err := NewWorkerRepository(addr, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	resp, err := repo.GetStorages(context.Background())
+	if err != nil {
+		t.Fatalf("GetStorages over mTLS: %v", err)
+	}
+	if len(resp.Storages) != 1 || resp.Storages[0].Name != "main" {
+		t.Fatalf("unexpected storages: %v", resp.Storages)
+	}
+}
+
+func TestWorkerRepositoryTLSRejectsUntrustedWorker(t *testing.T) {
+	serverCA := newTestCA(t, "server-ca")
+	clientCA := newTestCA(t, "client-ca")
+	otherCA := newTestCA(t, "other-ca")
+	addr := startTLSWorker(t, serverCA, clientCA, "worker.internal")
+
+	// Client trusts a different CA than the one that signed the worker certificate
+	cfg := writeClientFiles(t, t.TempDir(), otherCA, clientCA)
+	repo, err := NewWorkerRepository(addr, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	if err := getStorages(t, repo, 2*time.Second); err == nil {
+		t.Fatal("expected handshake failure with untrusted worker certificate")
+	}
+}
+
+func TestWorkerRepositoryTLSServerNameMismatch(t *testing.T) {
+	serverCA := newTestCA(t, "server-ca")
+	clientCA := newTestCA(t, "client-ca")
+	addr := startTLSWorker(t, serverCA, clientCA, "worker.internal")
+
+	cfg := writeClientFiles(t, t.TempDir(), serverCA, clientCA)
+	cfg.ServerName = "other.internal"
+	repo, err := NewWorkerRepository(addr, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	if err := getStorages(t, repo, 2*time.Second); err == nil {
+		t.Fatal("expected handshake failure with mismatching server name")
+	}
+}
+
+func TestWorkerRepositoryTLSReloadsCertificates(t *testing.T) {
+	serverCA := newTestCA(t, "server-ca")
+	clientCA := newTestCA(t, "client-ca")
+	untrustedCA := newTestCA(t, "untrusted-ca")
+	addr := startTLSWorker(t, serverCA, clientCA, "worker.internal")
+
+	// Start with a client certificate the worker does not trust
+	dir := t.TempDir()
+	cfg := writeClientFiles(t, dir, serverCA, untrustedCA)
+	repo, err := NewWorkerRepository(addr, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	if err := getStorages(t, repo, 2*time.Second); err == nil {
+		t.Fatal("expected worker to reject untrusted client certificate")
+	}
+
+	// Replace the client certificate on disk, the next handshake must pick it up
+	writeClientFiles(t, dir, serverCA, clientCA)
+
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		err := getStorages(t, repo, 2*time.Second)
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reloaded certificate was not used: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func TestWorkerTLSConfigRequiresKeyPair(t *testing.T) {
+	for _, cfg := range []WorkerTLSConfig{{CertFile: "client.pem"}, {KeyFile: "client-key.pem"}} {
+		if _, err := NewWorkerRepository("127.0.0.1:1", cfg); err == nil {
+			t.Fatalf("expected an error for half a key pair: %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Package s3check runs pre-flight checks against the S3 endpoint of a storage
+// with the credentials the worker will use.
+package s3check
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hantdev/chorus-controller/internal/sigv4"
+)
+
+// DefaultRegion is used when a storage has no region, the worker signs requests for it as well
+const DefaultRegion = "us-east-1"
+
+// Check names
+const (
+	CheckConnectivity   = "connectivity"
+	CheckSignature      = "signature"
+	CheckRegion         = "region"
+	CheckAuthentication = "authentication"
+	CheckListBuckets    = "list_buckets"
+)
+
+// Check statuses
+const (
+	StatusOK      = "ok"
+	StatusWarning = "warning"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// maxRegionChecks bounds the number of buckets whose location is compared with the region
+const maxRegionChecks = 5
+
+// Target is an S3 endpoint and the credentials to check it with
+type Target struct {
+	Address string
+	Secure  bool
+	// VirtualHost addresses buckets as bucket.host rather than host/bucket
+	VirtualHost     bool
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// Check is the outcome of a single check.
+// Code is the S3 error code returned by the storage, if any.
+type Check struct {
+	Name    string
+	Status  string
+	Message string
+	Code    string
+}
+
+// Result is the outcome of all checks
+type Result struct {
+	Endpoint string
+	Region   string
+	// Latency is the round trip time of the ListBuckets request
+	Latency time.Duration
+	Buckets int
+	Checks  []Check
+}
+
+// OK reports whether no check failed, warnings are allowed
+func (r *Result) OK() bool {
+	return r.Failed() == nil
+}
+
+// Failed returns the first failed check, nil when all checks passed
+func (r *Result) Failed() *Check {
+	for i := range r.Checks {
+		if r.Checks[i].Status == StatusFailed {
+			return &r.Checks[i]
+		}
+	}
+	return nil
+}
+
+// s3Error is the error document returned by S3 compatible storages
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+	Region  string `xml:"Region"`
+}
+
+type listBucketsResult struct {
+	Buckets []struct {
+		Name string `xml:"Name"`
+	} `xml:"Buckets>Bucket"`
+}
+
+type locationConstraint struct {
+	Location string `xml:",chardata"`
+}
+
+// Run checks the endpoint of a storage.
+// All checks are derived from a signed ListBuckets request and the location of a few buckets,
+// checks which cannot run because an earlier one failed are reported as skipped.
+func Run(ctx context.Context, client *http.Client, t Target) *Result {
+	res, names, listed := listBuckets(ctx, client, t)
+	if listed {
+		// Requests are accepted for the region, buckets may still live in another one
+		status, message := checkBucketRegions(ctx, client, res.Endpoint, t, res.Region, names)
+		res.set(CheckRegion, status, message, "")
+	}
+	return res
+}
+
+// Probe checks the endpoint of a storage with the signed ListBuckets request alone.
+// It is the cheap variant of Run for periodic probes, the location of buckets is not compared.
+func Probe(ctx context.Context, client *http.Client, t Target) *Result {
+	res, _, listed := listBuckets(ctx, client, t)
+	if listed {
+		res.set(CheckRegion, StatusOK, fmt.Sprintf("region %q accepted", res.Region), "")
+	}
+	return res
+}
+
+// listBuckets sends the signed ListBuckets request and sets every check but the bucket locations.
+// It returns the bucket names and whether the buckets were listed.
+func listBuckets(ctx context.Context, client *http.Client, t Target) (*Result, []string, bool) {
+	res := &Result{Endpoint: Endpoint(t.Address, t.Secure), Region: t.Region}
+	if res.Region == "" {
+		res.Region = DefaultRegion
+	}
+	for _, name := range []string{CheckConnectivity, CheckSignature, CheckRegion, CheckAuthentication, CheckListBuckets} {
+		res.Checks = append(res.Checks, Check{Name: name, Status: StatusSkipped})
+	}
+
+	start := time.Now()
+	status, body, err := get(ctx, client, res.Endpoint+"/", t, res.Region)
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.set(CheckConnectivity, StatusFailed, err.Error(), "")
+		return res, nil, false
+	}
+	res.set(CheckConnectivity, StatusOK, fmt.Sprintf("endpoint answered in %s", res.Latency.Round(time.Millisecond)), "")
+
+	if status != http.StatusOK {
+		var e s3Error
+		if xml.Unmarshal(body, &e) != nil || e.Code == "" {
+			res.set(CheckListBuckets, StatusFailed, fmt.Sprintf("endpoint answered with HTTP %d and no S3 error, check the address", status), "")
+			return res, nil, false
+		}
+		applyError(res.set, &e, res.Region)
+		return res, nil, false
+	}
+
+	var list listBucketsResult
+	if err := xml.Unmarshal(body, &list); err != nil {
+		res.set(CheckListBuckets, StatusFailed, "endpoint did not answer with an S3 bucket list, check the address", "")
+		return res, nil, false
+	}
+	res.Buckets = len(list.Buckets)
+	res.set(CheckSignature, StatusOK, "AWS Signature Version 4 accepted", "")
+	res.set(CheckAuthentication, StatusOK, "credentials accepted", "")
+	res.set(CheckListBuckets, StatusOK, fmt.Sprintf("%d buckets", res.Buckets), "")
+
+	names := make([]string, 0, len(list.Buckets))
+	for _, b := range list.Buckets {
+		names = append(names, b.Name)
+	}
+	return res, names, true
+}
+
+// set replaces the outcome of a check
+func (r *Result) set(name, status, message, code string) {
+	for i := range r.Checks {
+		if r.Checks[i].Name == name {
+			r.Checks[i] = Check{Name: name, Status: status, Message: message, Code: code}
+			return
+		}
+	}
+}
+
+// applyError maps the S3 error of the ListBuckets request to the failing check
+func applyError(set func(name, status, message, code string), e *s3Error, region string) {
+	switch e.Code {
+	case "AuthorizationHeaderMalformed", "AuthorizationQueryParametersError", "IllegalLocationConstraintException":
+		set(CheckSignature, StatusOK, "AWS Signature Version 4 accepted", "")
+		msg := fmt.Sprintf("requests signed for region %q are rejected", region)
+		if e.Region != "" {
+			msg = fmt.Sprintf("storage expects region %q, requests are signed for %q", e.Region, region)
+		}
+		set(CheckRegion, StatusFailed, msg, e.Code)
+	case "InvalidAccessKeyId":
+		set(CheckSignature, StatusOK, "AWS Signature Version 4 accepted", "")
+		set(CheckRegion, StatusOK, fmt.Sprintf("region %q accepted", region), "")
+		set(CheckAuthentication, StatusFailed, "access key ID is unknown to the storage", e.Code)
+	case "SignatureDoesNotMatch":
+		set(CheckSignature, StatusOK, "AWS Signature Version 4 accepted", "")
+		set(CheckRegion, StatusOK, fmt.Sprintf("region %q accepted", region), "")
+		set(CheckAuthentication, StatusFailed, "secret access key does not match the access key ID", e.Code)
+	case "RequestTimeTooSkewed":
+		set(CheckSignature, StatusFailed, "clock of the controller and the storage differ too much", e.Code)
+	case "InvalidRequest", "InvalidArgument", "NotImplemented", "SignatureVersionNotSupported":
+		set(CheckSignature, StatusFailed, "storage rejected AWS Signature Version 4 used by the worker: "+e.Message, e.Code)
+	case "AccessDenied":
+		set(CheckSignature, StatusOK, "AWS Signature Version 4 accepted", "")
+		set(CheckRegion, StatusOK, fmt.Sprintf("region %q accepted", region), "")
+		set(CheckAuthentication, StatusOK, "credentials accepted", "")
+		set(CheckListBuckets, StatusFailed, "credentials are not allowed to list buckets", e.Code)
+	default:
+		set(CheckListBuckets, StatusFailed, e.Message, e.Code)
+	}
+}
+
+// checkBucketRegions compares the location of the first buckets with the region
+func checkBucketRegions(ctx context.Context, client *http.Client, endpoint string, t Target, region string, buckets []string) (string, string) {
+	if len(buckets) == 0 {
+		return StatusOK, fmt.Sprintf("region %q accepted, no buckets to compare", region)
+	}
+	if len(buckets) > maxRegionChecks {
+		buckets = buckets[:maxRegionChecks]
+	}
+
+	var other []string
+	for _, b := range buckets {
+		status, body, err := get(ctx, client, bucketURL(endpoint, b, t.VirtualHost)+"?location", t, region)
+		if err != nil || status != http.StatusOK {
+			// Many storages do not implement bucket locations or deny them
+			return StatusOK, fmt.Sprintf("region %q accepted, bucket locations not available", region)
+		}
+		var loc locationConstraint
+		if xml.Unmarshal(body, &loc) != nil {
+			continue
+		}
+		location := strings.TrimSpace(loc.Location)
+		if location == "" {
+			location = DefaultRegion
+		}
+		if location != region {
+			other = append(other, fmt.Sprintf("%s (%s)", b, location))
+		}
+	}
+	if len(other) > 0 {
+		return StatusWarning, fmt.Sprintf("region %q accepted, but buckets are located elsewhere: %s", region, strings.Join(other, ", "))
+	}
+	return StatusOK, fmt.Sprintf("region %q matches the bucket locations", region)
+}
+
+// bucketURL addresses a bucket path-style or virtual-host-style.
+// Buckets with dots stay path-style, their virtual host would not match the certificate of the endpoint.
+func bucketURL(endpoint, bucket string, virtualHost bool) string {
+	if virtualHost && !strings.Contains(bucket, ".") {
+		if scheme, host, ok := strings.Cut(endpoint, "://"); ok {
+			return scheme + "://" + bucket + "." + host + "/"
+		}
+	}
+	return endpoint + "/" + bucket
+}
+
+// get sends a signed GET request and returns the status and body
+func get(ctx context.Context, client *http.Client, url string, t Target, region string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	sigv4.Sign(req, sigv4.Credentials{AccessKeyID: t.AccessKeyID, SecretAccessKey: t.SecretAccessKey}, region, time.Now())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// Endpoint returns the base URL of an address, the scheme follows secure when the address has none
+func Endpoint(address string, secure bool) string {
+	address = strings.TrimRight(strings.TrimSpace(address), "/")
+	if strings.Contains(address, "://") {
+		return address
+	}
+	if secure {
+		return "https://" + address
+	}
+	return "http://" + address
+}
@@ -0,0 +1,142 @@
+package s3check
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hantdev/chorus-controller/internal/fakes3"
+)
+
+func newStorage(t *testing.T) (*fakes3.Server, string) {
+	t.Helper()
+
+	s3 := fakes3.New("eu-west-1")
+	s3.AddUser("access", "secret")
+	s3.AddBucket("photos", "")
+	s3.AddBucket("logs", "")
+	srv := httptest.NewServer(s3)
+	t.Cleanup(srv.Close)
+	return s3, srv.URL
+}
+
+func statuses(res *Result) map[string]string {
+	m := make(map[string]string, len(res.Checks))
+	for _, c := range res.Checks {
+		m[c.Name] = c.Status
+	}
+	return m
+}
+
+func TestRun(t *testing.T) {
+	s3, addr := newStorage(t)
+	target := Target{Address: addr, Region: "eu-west-1", AccessKeyID: "access", SecretAccessKey: "secret"}
+
+	res := Run(context.Background(), http.DefaultClient, target)
+	if !res.OK() || res.Buckets != 2 || res.Latency <= 0 {
+		t.Fatalf("expected passing checks, got %+v", res)
+	}
+	for name, status := range statuses(res) {
+		if status != StatusOK {
+			t.Errorf("check %s: expected ok, got %s", name, status)
+		}
+	}
+
+	// A bucket in another region is a warning, the storage is still usable
+	s3.AddBucket("archive", "us-east-1")
+	res = Run(context.Background(), http.DefaultClient, target)
+	if !res.OK() || statuses(res)[CheckRegion] != StatusWarning {
+		t.Fatalf("expected region warning, got %+v", res.Checks)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	s3, addr := newStorage(t)
+	s3.AddUser("readonly", "secret")
+	s3.DenyListBuckets("readonly")
+
+	tests := []struct {
+		name   string
+		target Target
+		failed string
+		code   string
+	}{
+		{"unknown access key", Target{Address: addr, Region: "eu-west-1", AccessKeyID: "typo", SecretAccessKey: "secret"}, CheckAuthentication, "InvalidAccessKeyId"},
+		{"wrong secret", Target{Address: addr, Region: "eu-west-1", AccessKeyID: "access", SecretAccessKey: "typo"}, CheckAuthentication, "SignatureDoesNotMatch"},
+		{"default region", Target{Address: addr, AccessKeyID: "access", SecretAccessKey: "secret"}, CheckRegion, "AuthorizationHeaderMalformed"},
+		{"list denied", Target{Address: addr, Region: "eu-west-1", AccessKeyID: "readonly", SecretAccessKey: "secret"}, CheckListBuckets, "AccessDenied"},
+		{"unreachable", Target{Address: "127.0.0.1:1", Region: "eu-west-1", AccessKeyID: "access", SecretAccessKey: "secret"}, CheckConnectivity, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := Run(context.Background(), http.DefaultClient, tc.target)
+			failed := res.Failed()
+			if failed == nil || failed.Name != tc.failed || failed.Code != tc.code {
+				t.Fatalf("expected %s to fail with %q, got %+v", tc.failed, tc.code, res.Checks)
+			}
+		})
+	}
+
+	res := Run(context.Background(), http.DefaultClient, Target{Address: addr, AccessKeyID: "access", SecretAccessKey: "secret"})
+	if msg := res.Failed().Message; !strings.Contains(msg, `"eu-west-1"`) {
+		t.Fatalf("expected the expected region in %q", msg)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	s3 := fakes3.New("eu-west-1")
+	s3.AddUser("access", "secret")
+	s3.AddBucket("photos", "us-east-1")
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		s3.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	// A single request, the bucket in another region is not looked at
+	res := Probe(context.Background(), http.DefaultClient, Target{Address: srv.URL, Region: "eu-west-1", AccessKeyID: "access", SecretAccessKey: "secret"})
+	if !res.OK() || res.Buckets != 1 || statuses(res)[CheckRegion] != StatusOK || requests.Load() != 1 {
+		t.Fatalf("expected a passing probe with one request, got %+v after %d requests", res.Checks, requests.Load())
+	}
+
+	res = Probe(context.Background(), http.DefaultClient, Target{Address: srv.URL, Region: "eu-west-1", AccessKeyID: "access", SecretAccessKey: "typo"})
+	if failed := res.Failed(); failed == nil || failed.Name != CheckAuthentication {
+		t.Fatalf("expected authentication to fail, got %+v", res.Checks)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		address string
+		secure  bool
+		want    string
+	}{
+		{"s3.local:9000", false, "http://s3.local:9000"},
+		{"s3.local", true, "https://s3.local"},
+		{"http://s3.local/", true, "http://s3.local"},
+	} {
+		if got := Endpoint(tc.address, tc.secure); got != tc.want {
+			t.Errorf("Endpoint(%q, %v) = %q, want %q", tc.address, tc.secure, got, tc.want)
+		}
+	}
+}
+
+func TestBucketURL(t *testing.T) {
+	for _, tc := range []struct {
+		bucket      string
+		virtualHost bool
+		want        string
+	}{
+		{"photos", false, "https://s3.eu-west-1.amazonaws.com/photos"},
+		{"photos", true, "https://photos.s3.eu-west-1.amazonaws.com/"},
+		{"my.photos", true, "https://s3.eu-west-1.amazonaws.com/my.photos"},
+	} {
+		if got := bucketURL("https://s3.eu-west-1.amazonaws.com", tc.bucket, tc.virtualHost); got != tc.want {
+			t.Errorf("bucketURL(%q, %v) = %q, want %q", tc.bucket, tc.virtualHost, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,1030 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/crypto"
+	"github.com/hantdev/chorus-controller/internal/db"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/fakeworker"
+	"github.com/hantdev/chorus-controller/internal/handler"
+	"github.com/hantdev/chorus-controller/internal/repository"
+	"github.com/hantdev/chorus-controller/internal/server"
+	"github.com/hantdev/chorus-controller/internal/service"
+)
+
+// testEnv is a controller wired to a fake worker and a test database
+type testEnv struct {
+	t      *testing.T
+	router *gin.Engine
+	token  string
+	worker *fakeworker.Server
+}
+
+// newTestEnv starts a fake worker and builds the router on top of it.
+// The controller keeps its state in Postgres, so the tests only run
+// when TEST_POSTGRES_DSN points to a disposable database.
+// Routes which do not reach the database are tested with newRouterEnv instead.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	database, err := db.Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.AutoMigrate(&domain.Storage{}, &domain.StorageCredential{}, &domain.ReplicateJob{}, &domain.TokenInfo{}, &domain.Worker{}, &domain.BucketComparison{}, &domain.StorageDriftEvent{}, &domain.AuditEvent{}, &domain.StorageHealthCheck{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Exec(`TRUNCATE storage, storage_credential, replicate_job, token_info, worker, bucket_comparison, storage_drift_event, audit_event, storage_health_check`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	worker, conn, workerService := startWorker(t)
+
+	tokenService := service.NewTokenService(repository.NewTokenDBRepository(), "test-secret", time.Hour)
+	token, err := tokenService.GenerateToken(context.Background(), &domain.TokenRequest{Name: "e2e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cipher, err := crypto.New("test-encryption-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := server.New(
+		handler.NewHealthHandler(conn),
+		handler.NewStorageHandler(service.NewStorageService(workerService, cipher)),
+		handler.NewReplicationHandler(service.NewReplicationService(workerService)),
+		handler.NewWorkerHandler(workerService),
+		handler.NewAuthHandler(tokenService),
+		tokenService,
+		0,
+	)
+
+	gin.SetMode(gin.TestMode)
+	return &testEnv{t: t, router: srv.Router(), token: token.Token, worker: worker}
+}
+
+// startWorker serves a fake worker with the storages main and follower as the default worker
+func startWorker(t *testing.T) (*fakeworker.Server, *repository.WorkerRepository, *service.WorkerService) {
+	t.Helper()
+
+	worker := fakeworker.New(fakeworker.Options{ObjectsPerBucket: 1000, ObjectsPerSecond: 1})
+	worker.AddStorage("main", "main.s3.local", pb.Storage_Ceph, true, "admin")
+	worker.AddStorage("follower", "follower.s3.local", pb.Storage_Minio, false, "admin")
+	if err := worker.AddBuckets("main", "admin", "photos", "logs"); err != nil {
+		t.Fatal(err)
+	}
+	addr, err := worker.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(worker.Stop)
+
+	conn, err := repository.NewWorkerRepository(addr.String(), repository.WorkerTLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerService := service.NewWorkerService("default", conn, repository.ResilienceConfig{
+		MaxAttempts:      1,
+		FailureThreshold: 100,
+		OpenTimeout:      time.Second,
+	})
+	t.Cleanup(func() { workerService.Close() })
+	return worker, conn, workerService
+}
+
+// promoteToken turns the token of the tests into a system token
+func (e *testEnv) promoteToken() {
+	e.t.Helper()
+	if err := db.DB().Model(&domain.TokenInfo{}).Where("name = ?", "e2e").Update("is_system", true).Error; err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+// do sends a request through the router and decodes the JSON response into out
+func (e *testEnv) do(method, path string, body any, out any) int {
+	e.t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			e.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Token "+e.token)
+
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+
+	if out != nil && rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			e.t.Fatalf("%s %s: decode %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestReplicationLifecycle(t *testing.T) {
+	env := newTestEnv(t)
+
+	create := domain.CreateReplicationRequest{User: "admin", From: "main", To: "follower", Buckets: []string{"photos"}}
+	if code := env.do(http.MethodPost, "/replications", create, nil); code != http.StatusCreated {
+		t.Fatalf("create replication: %d", code)
+	}
+
+	var replications []domain.WorkerReplication
+	if code := env.do(http.MethodGet, "/replications", nil, &replications); code != http.StatusOK {
+		t.Fatalf("list replications: %d", code)
+	}
+	if len(replications) != 1 || replications[0].Worker != "default" || replications[0].Bucket != "photos" {
+		t.Fatalf("unexpected replications: %+v", replications)
+	}
+
+	id := domain.ReplicationIdentifier{User: "admin", Bucket: "photos", From: "main", To: "follower"}
+	if code := env.do(http.MethodPost, "/replications/pause", id, nil); code != http.StatusOK {
+		t.Fatalf("pause replication: %d", code)
+	}
+	if code := env.do(http.MethodPost, "/replications/resume", id, nil); code != http.StatusOK {
+		t.Fatalf("resume replication: %d", code)
+	}
+
+	// Worker errors keep their meaning over HTTP
+	var apiErr struct {
+		Error    string `json:"error"`
+		Upstream struct {
+			Status    string `json:"status"`
+			Retryable bool   `json:"retryable"`
+		} `json:"upstream"`
+	}
+	if code := env.do(http.MethodPost, "/replications", create, &apiErr); code != http.StatusConflict {
+		t.Fatalf("duplicate replication: expected 409, got %d", code)
+	}
+	if apiErr.Upstream.Status != "AlreadyExists" || apiErr.Upstream.Retryable {
+		t.Fatalf("unexpected upstream error: %+v", apiErr)
+	}
+
+	missing := domain.ReplicationIdentifier{User: "admin", Bucket: "logs", From: "main", To: "follower"}
+	if code := env.do(http.MethodPost, "/replications/pause", missing, nil); code != http.StatusNotFound {
+		t.Fatalf("pause missing replication: expected 404, got %d", code)
+	}
+	if code := env.do(http.MethodPost, "/replications/switch/zero-downtime", id, nil); code != http.StatusPreconditionFailed {
+		t.Fatalf("switch before initial replication: expected 412, got %d", code)
+	}
+
+	if code := env.do(http.MethodDelete, "/replications", id, nil); code != http.StatusOK {
+		t.Fatalf("delete replication: %d", code)
+	}
+	replications = nil
+	env.do(http.MethodGet, "/replications", nil, &replications)
+	if len(replications) != 0 {
+		t.Fatalf("expected no replications, got %+v", replications)
+	}
+	var jobs []domain.ReplicateJob
+	db.DB().Find(&jobs)
+	if len(jobs) != 0 {
+		t.Fatalf("expected the job to be deleted with the replication, got %+v", jobs)
+	}
+}
+
+func TestStorageCRUD(t *testing.T) {
+	env := newTestEnv(t)
+
+	create := domain.CreateStorageRequest{
+		Name: "main", Address: "http://main.s3.local", Provider: "Ceph",
+		User: "admin", AccessKey: "access", SecretKey: "secret",
+	}
+	if code := env.do(http.MethodPost, "/storages", create, nil); code != http.StatusCreated {
+		t.Fatalf("create storage: %d", code)
+	}
+
+	var storages []domain.Storage
+	if code := env.do(http.MethodGet, "/storages/db", nil, &storages); code != http.StatusOK {
+		t.Fatalf("list storages: %d", code)
+	}
+	if len(storages) != 1 || storages[0].Name != "main" {
+		t.Fatalf("unexpected storages: %+v", storages)
+	}
+	if storages[0].HealthCheckIntervalMs != domain.DefaultHealthCheckIntervalMs || storages[0].HttpTimeoutMs != domain.DefaultHttpTimeoutMs {
+		t.Fatalf("expected default settings: %+v", storages[0])
+	}
+
+	path := "/storages/" + storages[0].ID.String()
+	patch := map[string]any{"default_region": "eu-west-1", "rate_limit_enabled": true, "rate_limit_rpm": 600}
+	if code := env.do(http.MethodPatch, path, patch, nil); code != http.StatusOK {
+		t.Fatalf("patch storage: %d", code)
+	}
+	if code := env.do(http.MethodPatch, path, map[string]any{"http_timeout_ms": 10}, nil); code != http.StatusBadRequest {
+		t.Fatalf("patch out of range timeout: expected 400, got %d", code)
+	}
+	var patched domain.Storage
+	if code := env.do(http.MethodGet, path, nil, &patched); code != http.StatusOK {
+		t.Fatalf("get storage: %d", code)
+	}
+	if patched.DefaultRegion != "eu-west-1" || patched.RateLimitRPM != 600 || patched.Address != create.Address {
+		t.Fatalf("unexpected patched storage: %+v", patched)
+	}
+
+	// Replacing the storage keeps settings which are not part of the request
+	create.Address = "http://main2.s3.local"
+	if code := env.do(http.MethodPut, path, create, nil); code != http.StatusOK {
+		t.Fatalf("update storage: %d", code)
+	}
+	env.do(http.MethodGet, path, nil, &patched)
+	if patched.Address != create.Address || patched.DefaultRegion != "eu-west-1" || !patched.RateLimitEnabled {
+		t.Fatalf("unexpected updated storage: %+v", patched)
+	}
+
+	if code := env.do(http.MethodDelete, path, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete storage: %d", code)
+	}
+	if code := env.do(http.MethodGet, path, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("get deleted storage: expected 404, got %d", code)
+	}
+}
+
+func TestStorageSecrets(t *testing.T) {
+	env := newTestEnv(t)
+
+	create := domain.CreateStorageRequest{
+		Name: "main", Address: "http://main.s3.local", Provider: "Ceph",
+		User: "admin", AccessKey: "access", SecretKey: "secret",
+	}
+	if code := env.do(http.MethodPost, "/storages", create, nil); code != http.StatusCreated {
+		t.Fatalf("create storage: %d", code)
+	}
+
+	var storages []domain.Storage
+	env.do(http.MethodGet, "/storages/db", nil, &storages)
+	if len(storages) != 1 || storages[0].SecretAccessKey != domain.MaskedSecret || storages[0].SecretFingerprint != service.SecretFingerprint("secret") {
+		t.Fatalf("expected masked secret: %+v", storages)
+	}
+	path := "/storages/" + storages[0].ID.String()
+	var storage domain.Storage
+	env.do(http.MethodGet, path, nil, &storage)
+	if storage.SecretAccessKey != domain.MaskedSecret {
+		t.Fatalf("expected masked secret: %+v", storage)
+	}
+
+	if code := env.do(http.MethodPost, path+"/reveal", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("reveal with a regular token: expected 401, got %d", code)
+	}
+	env.promoteToken()
+	var secret domain.StorageSecret
+	if code := env.do(http.MethodPost, path+"/reveal", nil, &secret); code != http.StatusOK {
+		t.Fatalf("reveal: %d", code)
+	}
+	if secret.SecretAccessKey != "secret" || secret.AccessKeyID != "access" {
+		t.Fatalf("unexpected secret: %+v", secret)
+	}
+
+	var events []domain.AuditEvent
+	db.DB().Find(&events)
+	if len(events) != 1 || events[0].Action != domain.AuditRevealStorageSecret || events[0].TokenName != "e2e" {
+		t.Fatalf("expected one audit event, got %+v", events)
+	}
+}
+
+func TestStorageJobReferences(t *testing.T) {
+	env := newTestEnv(t)
+
+	create := domain.CreateStorageRequest{
+		Name: "main", Address: "http://main.s3.local", Provider: "Ceph",
+		User: "admin", AccessKey: "access", SecretKey: "secret",
+	}
+	if code := env.do(http.MethodPost, "/storages", create, nil); code != http.StatusCreated {
+		t.Fatalf("create storage: %d", code)
+	}
+	replication := domain.CreateReplicationRequest{User: "admin", From: "main", To: "follower", Buckets: []string{"photos"}}
+	if code := env.do(http.MethodPost, "/replications", replication, nil); code != http.StatusCreated {
+		t.Fatalf("create replication: %d", code)
+	}
+
+	var storages []domain.Storage
+	env.do(http.MethodGet, "/storages/db", nil, &storages)
+	path := "/storages/" + storages[0].ID.String()
+
+	var conflict struct {
+		Details struct {
+			Jobs []domain.ReplicateJob `json:"jobs"`
+		} `json:"details"`
+	}
+	if code := env.do(http.MethodDelete, path, nil, &conflict); code != http.StatusConflict {
+		t.Fatalf("delete used storage: expected 409, got %d", code)
+	}
+	if len(conflict.Details.Jobs) != 1 || conflict.Details.Jobs[0].Bucket != "photos" {
+		t.Fatalf("expected the photos job, got %+v", conflict.Details.Jobs)
+	}
+
+	// Renames follow into the jobs
+	if code := env.do(http.MethodPatch, path, map[string]any{"name": "primary"}, nil); code != http.StatusOK {
+		t.Fatalf("rename storage: %d", code)
+	}
+	var jobs []domain.ReplicateJob
+	if err := db.DB().Find(&jobs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].From != "primary" {
+		t.Fatalf("expected renamed job, got %+v", jobs)
+	}
+
+	if code := env.do(http.MethodDelete, path+"?force=true", nil, nil); code != http.StatusOK {
+		t.Fatalf("force delete storage: %d", code)
+	}
+	jobs = nil
+	db.DB().Find(&jobs)
+	if len(jobs) != 0 {
+		t.Fatalf("expected jobs to be deleted, got %+v", jobs)
+	}
+
+}
+
+func TestStorageSingleMain(t *testing.T) {
+	env := newTestEnv(t)
+
+	isMain := true
+	main := domain.CreateStorageRequest{
+		Name: "main", Address: "http://main.s3.local", Provider: "Ceph",
+		User: "admin", AccessKey: "access", SecretKey: "secret",
+		StorageSettings: domain.StorageSettings{IsMain: &isMain},
+	}
+	if code := env.do(http.MethodPost, "/storages", main, nil); code != http.StatusCreated {
+		t.Fatalf("create main storage: %d", code)
+	}
+	follower := main
+	follower.Name, follower.Address = "follower", "http://follower.s3.local"
+	if code := env.do(http.MethodPost, "/storages", follower, nil); code != http.StatusConflict {
+		t.Fatalf("create second main storage: expected 409, got %d", code)
+	}
+	if code := env.do(http.MethodPost, "/storages?replace_main=true", follower, nil); code != http.StatusCreated {
+		t.Fatalf("replace main storage: %d", code)
+	}
+
+	var storages []domain.Storage
+	env.do(http.MethodGet, "/storages/db", nil, &storages)
+	if len(storages) != 2 || storages[0].Name != "follower" || !storages[0].IsMain || storages[1].IsMain {
+		t.Fatalf("expected follower to be the only main storage: %+v", storages)
+	}
+
+	// Buckets of follower are not replicated back to main
+	var report domain.PromotionReport
+	path := "/storages/" + storages[1].ID.String() + "/promote"
+	if code := env.do(http.MethodPost, path, domain.PromoteStorageRequest{DryRun: true}, &report); code != http.StatusOK {
+		t.Fatalf("promote dry run: %d", code)
+	}
+	if report.PreviousMain != "follower" || report.Promoted {
+		t.Fatalf("unexpected promotion report: %+v", report)
+	}
+	if code := env.do(http.MethodPost, path, domain.PromoteStorageRequest{Force: true}, &report); code != http.StatusOK || !report.Promoted {
+		t.Fatalf("forced promotion: %d %+v", code, report)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, name := range []string{"a", "b", "c"} {
+		create := domain.CreateStorageRequest{
+			Name: name, Address: "http://" + name + ".s3.local", Provider: "Minio",
+			User: "admin", AccessKey: "access", SecretKey: "secret-" + name,
+		}
+		if code := env.do(http.MethodPost, "/storages", create, nil); code != http.StatusCreated {
+			t.Fatalf("create storage %s: %d", name, code)
+		}
+	}
+
+	if code := env.do(http.MethodPost, "/storages/rotate-keys", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("rotate with a regular token: expected 401, got %d", code)
+	}
+	env.promoteToken()
+	report := &domain.KeyRotationReport{}
+	if code := env.do(http.MethodPost, "/storages/rotate-keys", domain.RotateKeysRequest{DryRun: true}, report); code != http.StatusOK {
+		t.Fatalf("rotate dry run: %d", code)
+	}
+	if report.Scanned != 3 || report.Rotated != 0 || report.Keys[crypto.DefaultKeyID] != 3 || report.Formats["v2"] != 3 {
+		t.Fatalf("expected all secrets on the active key: %+v", report)
+	}
+
+	cipher, err := crypto.NewFromConfig(crypto.Config{
+		Key: "test-encryption-key", Keys: map[string]string{"k2": "next-encryption-key"}, ActiveKey: "k2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storages := service.NewStorageService(nil, cipher)
+
+	report, err = storages.RotateKeys(context.Background(), &domain.RotateKeysRequest{DryRun: true})
+	if err != nil || report.Rotated != 3 {
+		t.Fatalf("rotate dry run with a new key: %+v, %v", report, err)
+	}
+	var rows []domain.Storage
+	db.DB().Where("secret_access_key LIKE ?", "k2:%").Find(&rows)
+	if len(rows) != 0 {
+		t.Fatalf("dry run changed %d secrets", len(rows))
+	}
+
+	// Rotate the first batch only, then resume after it
+	var first []domain.Storage
+	db.DB().Order("id").Limit(2).Find(&first)
+	report, err = storages.RotateKeys(context.Background(), &domain.RotateKeysRequest{BatchSize: 2, After: first[1].ID})
+	if err != nil || report.Rotated != 1 || report.Batches != 1 {
+		t.Fatalf("resumed rotation: %+v, %v", report, err)
+	}
+	report, err = storages.RotateKeys(context.Background(), &domain.RotateKeysRequest{BatchSize: 2})
+	if err != nil || report.Scanned != 3 || report.Rotated != 2 || report.Batches != 2 || report.Keys["k2"] != 1 {
+		t.Fatalf("rotation: %+v, %v", report, err)
+	}
+
+	db.DB().Where("secret_access_key LIKE ?", "k2:%").Find(&rows)
+	if len(rows) != 3 {
+		t.Fatalf("expected every secret on key k2, got %d", len(rows))
+	}
+	for _, row := range rows {
+		secret, err := storages.RevealStorageSecret(context.Background(), row.ID.String(), &domain.AuditActor{})
+		if err != nil || secret.SecretAccessKey != "secret-"+row.Name {
+			t.Fatalf("reveal %s: %+v, %v", row.Name, secret, err)
+		}
+	}
+
+	// Secrets are bound to their storage, a secret copied to another row does not decrypt
+	if err := db.DB().Model(&domain.Storage{}).Where("id = ?", rows[1].ID).Update("secret_access_key", rows[0].SecretAccessKey).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storages.RevealStorageSecret(context.Background(), rows[1].ID.String(), &domain.AuditActor{}); err == nil {
+		t.Fatal("expected a copied secret to fail decryption")
+	}
+}
+
+func TestCompareBucket(t *testing.T) {
+	env := newTestEnv(t)
+
+	create := domain.CreateReplicationRequest{User: "admin", From: "main", To: "follower", Buckets: []string{"photos"}}
+	if code := env.do(http.MethodPost, "/replications", create, nil); code != http.StatusCreated {
+		t.Fatalf("create replication: %d", code)
+	}
+
+	req := domain.CompareBucketRequest{
+		ReplicationIdentifier: domain.ReplicationIdentifier{User: "admin", Bucket: "photos", From: "main", To: "follower"},
+		ShowKeys:              true,
+		Limit:                 10,
+	}
+	var result domain.BucketComparisonResult
+	if code := env.do(http.MethodPost, "/replications/compare", req, &result); code != http.StatusOK {
+		t.Fatalf("compare bucket: %d", code)
+	}
+	// The fake worker copies one object per second, so the destination is still missing objects
+	if result.IsMatch || result.MissToCount == 0 || result.MissToCount > 1000 || result.MatchCount != nil {
+		t.Fatalf("unexpected comparison: %+v", result.BucketComparison)
+	}
+	if page := result.Keys[domain.ComparisonKeysMissTo]; page == nil || len(page.Keys) != 10 {
+		t.Fatalf("unexpected first page: %+v", result.Keys)
+	}
+
+	var page domain.ComparisonKeysPage
+	path := "/replications/compare/" + result.ID.String()
+	if code := env.do(http.MethodGet, path+"/keys?kind=miss_to&offset=10&limit=5", nil, &page); code != http.StatusOK {
+		t.Fatalf("list keys: %d", code)
+	}
+	if page.Total != int(result.MissToCount) || len(page.Keys) != 5 || page.Offset != 10 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if code := env.do(http.MethodGet, path+"/keys?kind=match", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown key kind: expected 400, got %d", code)
+	}
+
+	var history []domain.BucketComparison
+	if code := env.do(http.MethodGet, "/replications/compare?bucket=photos", nil, &history); code != http.StatusOK {
+		t.Fatalf("list comparisons: %d", code)
+	}
+	if len(history) != 1 || history[0].ID != result.ID {
+		t.Fatalf("unexpected comparisons: %+v", history)
+	}
+}
+
+func TestExportStorages(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, name := range []string{"main", "follower"} {
+		create := domain.CreateStorageRequest{
+			Name: name, Address: "http://" + name + ".s3.local", Provider: "Ceph",
+			User: "admin", AccessKey: "access-" + name, SecretKey: "secret-" + name,
+		}
+		if code := env.do(http.MethodPost, "/storages", create, nil); code != http.StatusCreated {
+			t.Fatalf("create storage %s: %d", name, code)
+		}
+	}
+
+	// Exports hold every secret, regular tokens cannot read them
+	if code := env.do(http.MethodGet, "/storages/export", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("export with a regular token: expected 401, got %d", code)
+	}
+	env.promoteToken()
+
+	// Without a main storage the worker would refuse the config
+	if code := env.do(http.MethodGet, "/storages/export", nil, nil); code != http.StatusConflict {
+		t.Fatalf("export without main storage: expected 409, got %d", code)
+	}
+	if err := db.DB().Exec(`UPDATE storage SET is_main = true WHERE name = 'main'`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	export := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/storages/export?default_region=us-east-1", nil)
+		req.Header.Set("Authorization", "Token "+env.token)
+		rec := httptest.NewRecorder()
+		env.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("export storages: %d %s", rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+	first := export()
+	if !strings.Contains(first, "secretAccessKey: secret-follower") || !strings.Contains(first, "defaultRegion: us-east-1") {
+		t.Fatalf("unexpected export:\n%s", first)
+	}
+	if second := export(); second != first {
+		t.Fatal("export is not deterministic")
+	}
+
+	var events []domain.AuditEvent
+	db.DB().Where("action = ?", domain.AuditExportStorages).Find(&events)
+	if len(events) != 2 || events[0].TokenName != "e2e" || events[0].Resource != "storages" {
+		t.Fatalf("expected an audit event per export, got %+v", events)
+	}
+}
+
+func TestImportStorages(t *testing.T) {
+	env := newTestEnv(t)
+
+	config := `storage:
+  storages:
+    main:
+      address: http://main.s3.local
+      provider: Ceph
+      isMain: true
+      credentials:
+        admin: {accessKeyID: access, secretAccessKey: secret}
+    follower:
+      address: http://follower.s3.local
+      provider: Ceph
+      credentials:
+        admin: {accessKeyID: access, secretAccessKey: secret}
+`
+	importConfig := func(query, body string, out any) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/storages/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-yaml")
+		req.Header.Set("Authorization", "Token "+env.token)
+		rec := httptest.NewRecorder()
+		env.router.ServeHTTP(rec, req)
+		if out != nil {
+			if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+				t.Fatalf("decode %q: %v", rec.Body.String(), err)
+			}
+		}
+		return rec.Code
+	}
+
+	var report domain.StorageImportReport
+	if code := importConfig("?dry_run=true", config, &report); code != http.StatusOK || report.Created != 2 {
+		t.Fatalf("dry run: %d %+v", code, report)
+	}
+	var storages []domain.Storage
+	env.do(http.MethodGet, "/storages/db", nil, &storages)
+	if len(storages) != 0 {
+		t.Fatalf("dry run saved storages: %+v", storages)
+	}
+
+	if code := importConfig("", config, &report); code != http.StatusOK || report.Created != 2 {
+		t.Fatalf("import: %d %+v", code, report)
+	}
+	if code := importConfig("", config, &report); code != http.StatusOK || report.Unchanged != 2 {
+		t.Fatalf("import again: %d %+v", code, report)
+	}
+
+	changed := strings.Replace(config, "http://follower.s3.local", "http://follower2.s3.local", 1)
+	if code := importConfig("", changed, &report); code != http.StatusOK || report.Updated != 1 ||
+		report.Storages[0].Name != "follower" || report.Storages[0].Changed[0] != "address" {
+		t.Fatalf("import changed address: %d %+v", code, report)
+	}
+	env.do(http.MethodGet, "/storages/db", nil, &storages)
+	if len(storages) != 2 || storages[0].Address != "http://follower2.s3.local" {
+		t.Fatalf("unexpected storages: %+v", storages)
+	}
+
+	// A rotated credential keeps its row and created_at
+	backup := "        backup: {accessKeyID: backup, secretAccessKey: secret}\n    follower:"
+	withBackup := strings.Replace(changed, "    follower:", backup, 1)
+	if code := importConfig("", withBackup, &report); code != http.StatusOK || report.Updated != 1 {
+		t.Fatalf("import a credential: %d %+v", code, report)
+	}
+	var added domain.StorageCredential
+	db.DB().Where(`"user" = ?`, "backup").First(&added)
+	rotated := strings.Replace(withBackup, "secretAccessKey: secret}\n    follower:", "secretAccessKey: rotated}\n    follower:", 1)
+	if code := importConfig("", rotated, &report); code != http.StatusOK || report.Updated != 1 {
+		t.Fatalf("import a rotated credential: %d %+v", code, report)
+	}
+	var stored domain.StorageCredential
+	db.DB().Where(`"user" = ?`, "backup").First(&stored)
+	if stored.ID != added.ID || !stored.CreatedAt.Equal(added.CreatedAt) || !stored.UpdatedAt.After(added.UpdatedAt) {
+		t.Fatalf("expected the rotated credential to keep its row, got %+v, was %+v", stored, added)
+	}
+
+	// An own user missing from the file is kept as a credential
+	replaced := rotated[:strings.LastIndex(rotated, "admin:")] + "sync: {accessKeyID: sync, secretAccessKey: secret}\n"
+	if code := importConfig("", replaced, &report); code != http.StatusOK || report.Updated != 1 {
+		t.Fatalf("import another own user: %d %+v", code, report)
+	}
+	var previous domain.StorageCredential
+	if err := db.DB().Where("storage_id = ? AND \"user\" = ?", storages[0].ID, "admin").First(&previous).Error; err != nil {
+		t.Fatalf("expected admin to become a credential of follower: %v", err)
+	}
+
+	// The stored main storage is not in this file
+	other := `storages:
+  other:
+    address: http://other.s3.local
+    provider: Ceph
+    isMain: true
+    credentials:
+      admin: {accessKeyID: access, secretAccessKey: secret}
+`
+	if code := importConfig("", other, nil); code != http.StatusConflict {
+		t.Fatalf("import without the main storage: expected 409, got %d", code)
+	}
+	if code := importConfig("?replace_main=true", other, &report); code != http.StatusOK || report.Created != 1 {
+		t.Fatalf("import replacing main: %d %+v", code, report)
+	}
+	if code := importConfig("", "storages: {}", nil); code != http.StatusBadRequest {
+		t.Fatalf("import without storages: expected 400, got %d", code)
+	}
+}
+
+func TestStorageCredentials(t *testing.T) {
+	env := newTestEnv(t)
+
+	isMain := true
+	for _, name := range []string{"main", "follower"} {
+		create := domain.CreateStorageRequest{
+			Name: name, Address: "http://" + name + ".s3.local", Provider: "Ceph",
+			User: "admin", AccessKey: "access", SecretKey: "secret",
+		}
+		if name == "main" {
+			create.IsMain = &isMain
+		}
+		if code := env.do(http.MethodPost, "/storages", create, nil); code != http.StatusCreated {
+			t.Fatalf("create storage %s: %d", name, code)
+		}
+	}
+	var storages []domain.Storage
+	env.do(http.MethodGet, "/storages/db", nil, &storages)
+	follower, main := "/storages/"+storages[0].ID.String(), "/storages/"+storages[1].ID.String()
+
+	backup := domain.CreateStorageCredentialRequest{User: "backup", AccessKey: "backup-access", SecretKey: "backup-secret"}
+	if code := env.do(http.MethodPost, main+"/credentials", backup, nil); code != http.StatusCreated {
+		t.Fatalf("add user: %d", code)
+	}
+	if code := env.do(http.MethodPost, main+"/credentials", backup, nil); code != http.StatusConflict {
+		t.Fatalf("add user twice: expected 409, got %d", code)
+	}
+	own := domain.CreateStorageCredentialRequest{User: "admin", AccessKey: "a", SecretKey: "s"}
+	if code := env.do(http.MethodPost, main+"/credentials", own, nil); code != http.StatusConflict {
+		t.Fatalf("add the storage's own user: expected 409, got %d", code)
+	}
+
+	var creds []domain.StorageCredential
+	if code := env.do(http.MethodGet, main+"/credentials", nil, &creds); code != http.StatusOK {
+		t.Fatalf("list users: %d", code)
+	}
+	if len(creds) != 2 || !creds[0].Primary || creds[0].User != "admin" || creds[1].User != "backup" ||
+		creds[1].SecretAccessKey != domain.MaskedSecret || creds[1].SecretFingerprint == "" {
+		t.Fatalf("unexpected users: %+v", creds)
+	}
+
+	// The user must exist on both storages
+	replication := domain.CreateReplicationRequest{User: "backup", From: "main", To: "follower", Buckets: []string{"photos"}}
+	if code := env.do(http.MethodPost, "/replications", replication, nil); code != http.StatusBadRequest {
+		t.Fatalf("replicate a user missing on follower: expected 400, got %d", code)
+	}
+	if code := env.do(http.MethodPost, follower+"/credentials", backup, nil); code != http.StatusCreated {
+		t.Fatalf("add user to follower: %d", code)
+	}
+	if code := env.do(http.MethodPost, "/replications", replication, nil); code != http.StatusCreated {
+		t.Fatalf("replicate user: %d", code)
+	}
+	if code := env.do(http.MethodDelete, main+"/credentials/backup", nil, nil); code != http.StatusConflict {
+		t.Fatalf("delete a user with jobs: expected 409, got %d", code)
+	}
+	if code := env.do(http.MethodDelete, main+"/credentials/admin", nil, nil); code != http.StatusConflict {
+		t.Fatalf("delete the storage's own user: expected 409, got %d", code)
+	}
+
+	update := domain.UpdateStorageCredentialRequest{AccessKey: "rotated-access", SecretKey: domain.MaskedSecret}
+	if code := env.do(http.MethodPut, main+"/credentials/backup", update, nil); code != http.StatusOK {
+		t.Fatalf("update user: %d", code)
+	}
+	if code := env.do(http.MethodPut, main+"/credentials/missing", update, nil); code != http.StatusNotFound {
+		t.Fatalf("update missing user: expected 404, got %d", code)
+	}
+	env.do(http.MethodGet, main+"/credentials", nil, &creds)
+	if creds[1].AccessKeyID != "rotated-access" || creds[1].SecretFingerprint != service.SecretFingerprint("backup-secret") {
+		t.Fatalf("expected a new access key and the same secret: %+v", creds[1])
+	}
+
+	// Every user is exported and counted by key rotation
+	env.promoteToken()
+	req := httptest.NewRequest(http.MethodGet, "/storages/export", nil)
+	req.Header.Set("Authorization", "Token "+env.token)
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "secretAccessKey: backup-secret") {
+		t.Fatalf("export: %d\n%s", rec.Code, rec.Body.String())
+	}
+	var report domain.KeyRotationReport
+	if code := env.do(http.MethodPost, "/storages/rotate-keys", domain.RotateKeysRequest{DryRun: true}, &report); code != http.StatusOK {
+		t.Fatalf("rotate keys: %d", code)
+	}
+	if report.Scanned != 2 || report.Formats["v2"] != 4 {
+		t.Fatalf("expected 2 storages with 4 secrets: %+v", report)
+	}
+
+	if code := env.do(http.MethodDelete, follower+"?force=true", nil, nil); code != http.StatusOK {
+		t.Fatalf("delete follower: %d", code)
+	}
+	var count int64
+	db.DB().Model(&domain.StorageCredential{}).Where("storage_id = ?", storages[0].ID).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected the credentials of follower to be deleted, %d left", count)
+	}
+}
+
+func TestStorageLabels(t *testing.T) {
+	env := newTestEnv(t)
+
+	isMain := true
+	storages := map[string]map[string]string{
+		"eu-main":    {"env": "prod", "region": "eu"},
+		"eu-archive": {"env": "prod", "region": "eu", "tier": "cold"},
+		"us-main":    {"env": "prod", "region": "us"},
+		"us-dev":     {"env": "dev", "region": "us"},
+	}
+	for name, labels := range storages {
+		create := domain.CreateStorageRequest{
+			Name: name, Address: "http://" + name + ".s3.local", Provider: "Ceph",
+			User: "admin", AccessKey: "access", SecretKey: "secret",
+			StorageSettings: domain.StorageSettings{Labels: &labels},
+		}
+		if name == "us-main" {
+			create.Provider, create.IsMain = "MinIO", &isMain
+		}
+		if code := env.do(http.MethodPost, "/storages", create, nil); code != http.StatusCreated {
+			t.Fatalf("create storage %s: %d", name, code)
+		}
+	}
+	invalid := domain.CreateStorageRequest{
+		Name: "invalid", Address: "http://invalid.s3.local", Provider: "Ceph",
+		User: "admin", AccessKey: "access", SecretKey: "secret",
+		StorageSettings: domain.StorageSettings{Labels: &map[string]string{"env": "not valid"}},
+	}
+	if code := env.do(http.MethodPost, "/storages", invalid, nil); code != http.StatusBadRequest {
+		t.Fatalf("create storage with an invalid label: expected 400, got %d", code)
+	}
+
+	names := func(query string) []string {
+		t.Helper()
+		var items []domain.Storage
+		if code := env.do(http.MethodGet, "/storages/db"+query, nil, &items); code != http.StatusOK {
+			t.Fatalf("list %q: %d", query, code)
+		}
+		var out []string
+		for _, st := range items {
+			out = append(out, st.Name)
+		}
+		return out
+	}
+	tests := map[string]string{
+		"?selector=env=prod,region!=eu":       "us-main",
+		"?selector=region=eu,!tier":           "eu-main",
+		"?selector=tier":                      "eu-archive",
+		"?provider=minio":                     "us-main",
+		"?name_prefix=us-":                    "us-dev,us-main",
+		"?name_prefix=us-&selector=env!=prod": "us-dev",
+	}
+	for query, want := range tests {
+		if got := strings.Join(names(query), ","); got != want {
+			t.Errorf("%s: expected %s, got %s", query, want, got)
+		}
+	}
+	if code := env.do(http.MethodGet, "/storages/db?selector=env=pr%20od", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("invalid selector: expected 400, got %d", code)
+	}
+
+	// Walk the pages of two storages
+	var (
+		pages  []string
+		cursor string
+	)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/storages/db?limit=2&cursor="+cursor, nil)
+		rec := httptest.NewRecorder()
+		env.router.ServeHTTP(rec, req)
+		var items []domain.Storage
+		if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("page %d: %d %v", i, rec.Code, err)
+		}
+		for _, st := range items {
+			pages = append(pages, st.Name)
+		}
+		if cursor = rec.Header().Get("X-Next-Cursor"); cursor == "" {
+			break
+		}
+	}
+	if got := strings.Join(pages, ","); got != "eu-archive,eu-main,us-dev,us-main" || cursor != "" {
+		t.Fatalf("unexpected pages %s, cursor %q", got, cursor)
+	}
+
+	// A patch without labels keeps them
+	var dev []domain.Storage
+	env.do(http.MethodGet, "/storages/db?name_prefix=us-dev", nil, &dev)
+	if code := env.do(http.MethodPatch, "/storages/"+dev[0].ID.String(), map[string]any{"description": "sandbox"}, nil); code != http.StatusOK {
+		t.Fatalf("patch storage: %d", code)
+	}
+	if got := names("?selector=env=dev"); len(got) != 1 || got[0] != "us-dev" {
+		t.Fatalf("expected the labels to be kept, got %v", got)
+	}
+
+	// Labels select the storages to export
+	env.promoteToken()
+	req := httptest.NewRequest(http.MethodGet, "/storages/export?selector=region=us", nil)
+	req.Header.Set("Authorization", "Token "+env.token)
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "us-dev:") || strings.Contains(rec.Body.String(), "eu-main:") {
+		t.Fatalf("export by selector: %d\n%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestStorageHealth(t *testing.T) {
+	env := newTestEnv(t)
+
+	create := domain.CreateStorageRequest{
+		Name: "main", Address: "http://main.s3.local", Provider: "Ceph",
+		User: "admin", AccessKey: "access", SecretKey: "secret",
+	}
+	if code := env.do(http.MethodPost, "/storages", create, nil); code != http.StatusCreated {
+		t.Fatalf("create storage: %d", code)
+	}
+	var storages []domain.Storage
+	env.do(http.MethodGet, "/storages/db", nil, &storages)
+	path := "/storages/" + storages[0].ID.String() + "/health"
+
+	var health domain.StorageHealth
+	if code := env.do(http.MethodGet, path, nil, &health); code != http.StatusOK {
+		t.Fatalf("get health: %d", code)
+	}
+	if health.Status != domain.StorageHealthUnknown || health.UptimePercent != nil || len(health.History) != 0 {
+		t.Fatalf("expected an unknown state without probes: %+v", health)
+	}
+
+	// Probes as recorded by the health monitor, the storage went down 30 minutes ago
+	now := time.Now().UTC()
+	probes := []domain.StorageHealthCheck{
+		{Up: true, Changed: true, CheckedAt: now.Add(-48 * time.Hour)},
+		{Up: true, CheckedAt: now.Add(-50 * time.Minute)},
+		{Up: true, CheckedAt: now.Add(-40 * time.Minute)},
+		{Up: false, Changed: true, Error: "connectivity: connection refused", CheckedAt: now.Add(-30 * time.Minute)},
+		{Up: false, Error: "connectivity: connection refused", CheckedAt: now.Add(-20 * time.Minute)},
+	}
+	for i := range probes {
+		probes[i].StorageID = storages[0].ID
+		if err := db.DB().Create(&probes[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if code := env.do(http.MethodGet, path+"?window=1h&limit=3", nil, &health); code != http.StatusOK {
+		t.Fatalf("get health: %d", code)
+	}
+	if health.Status != domain.StorageHealthDown || health.Since == nil || health.Since.Sub(probes[3].CheckedAt).Abs() > time.Millisecond {
+		t.Fatalf("expected the storage to be down since the last change: %+v", health)
+	}
+	if health.Checks != 4 || health.UptimePercent == nil || *health.UptimePercent != 50 {
+		t.Fatalf("expected 50%% uptime of 4 probes in the window: %+v", health)
+	}
+	if len(health.History) != 3 || health.History[0].Error == "" || health.History[2].Up != true {
+		t.Fatalf("expected the 3 newest probes: %+v", health.History)
+	}
+
+	if code := env.do(http.MethodGet, path+"?changes=true", nil, &health); code != http.StatusOK {
+		t.Fatalf("get health changes: %d", code)
+	}
+	if len(health.History) != 2 || health.History[0].Up || !health.History[1].Up {
+		t.Fatalf("expected the state changes: %+v", health.History)
+	}
+
+	if code := env.do(http.MethodGet, path+"?window=2000h", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("window beyond the limit: expected 400, got %d", code)
+	}
+	if code := env.do(http.MethodGet, "/storages/"+uuid.NewString()+"/health", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("unknown storage: expected 404, got %d", code)
+	}
+
+	// Probes go with their storage
+	if code := env.do(http.MethodDelete, "/storages/"+storages[0].ID.String(), nil, nil); code != http.StatusOK {
+		t.Fatalf("delete storage: %d", code)
+	}
+	var count int64
+	db.DB().Model(&domain.StorageHealthCheck{}).Where("storage_id = ?", storages[0].ID).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected the probes to be deleted, %d left", count)
+	}
+}
+
+func TestStorageProviders(t *testing.T) {
+	env := newTestEnv(t)
+
+	region := "eu-west-1"
+	aws := domain.CreateStorageRequest{
+		Name: "aws", Provider: "aws",
+		User: "admin", AccessKey: "access", SecretKey: "secret",
+		StorageSettings: domain.StorageSettings{DefaultRegion: &region},
+	}
+	minio := domain.CreateStorageRequest{
+		Name: "minio", Address: "HTTPS://MinIO.local:9000/", Provider: "minio",
+		User: "admin", AccessKey: "access", SecretKey: "secret",
+	}
+	for _, create := range []domain.CreateStorageRequest{aws, minio} {
+		if code := env.do(http.MethodPost, "/storages", create, nil); code != http.StatusCreated {
+			t.Fatalf("create storage %s: %d", create.Name, code)
+		}
+	}
+
+	var storages []domain.Storage
+	env.do(http.MethodGet, "/storages/db", nil, &storages)
+	if len(storages) != 2 {
+		t.Fatalf("unexpected storages: %+v", storages)
+	}
+	if st := storages[0]; st.Provider != "AWS" || st.Address != "https://s3.eu-west-1.amazonaws.com" || !st.IsSecure {
+		t.Fatalf("expected the aws defaults: %+v", st)
+	}
+	if st := storages[1]; st.Provider != "Minio" || st.Address != "https://minio.local:9000" || !st.IsSecure {
+		t.Fatalf("expected a normalized address: %+v", st)
+	}
+
+	// is_secure has to agree with the scheme
+	path := "/storages/" + storages[1].ID.String()
+	if code := env.do(http.MethodPatch, path, map[string]any{"is_secure": false}, nil); code != http.StatusBadRequest {
+		t.Fatalf("patch is_secure: expected 400, got %d", code)
+	}
+	if code := env.do(http.MethodPatch, path, map[string]any{"address": "http://minio.local:9000"}, nil); code != http.StatusOK {
+		t.Fatalf("patch address: %d", code)
+	}
+	var st domain.Storage
+	env.do(http.MethodGet, path, nil, &st)
+	if st.IsSecure {
+		t.Fatalf("expected is_secure to follow the http address: %+v", st)
+	}
+
+	// Storages saved before the catalog are only checked when the provider, region or address changes
+	if err := db.DB().Exec(`UPDATE storage SET provider = 'backblaze', address = 'minio.local:9000' WHERE name = 'minio'`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code := env.do(http.MethodPatch, path, map[string]any{"description": "legacy"}, nil); code != http.StatusOK {
+		t.Fatalf("patch legacy storage: %d", code)
+	}
+	keys := domain.UpdateStorageCredentialRequest{AccessKey: "rotated", SecretKey: "rotated-secret"}
+	if code := env.do(http.MethodPut, path+"/credentials/admin", keys, nil); code != http.StatusOK {
+		t.Fatalf("rotate keys of legacy storage: %d", code)
+	}
+	if code := env.do(http.MethodPatch, path, map[string]any{"default_region": "us-east-1"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("patch region of legacy storage: expected 400, got %d", code)
+	}
+	if code := env.do(http.MethodPatch, path, map[string]any{"provider": "minio"}, nil); code != http.StatusOK {
+		t.Fatalf("patch provider of legacy storage: %d", code)
+	}
+	env.do(http.MethodGet, path, nil, &st)
+	if st.Provider != "Minio" || st.Address != "http://minio.local:9000" {
+		t.Fatalf("expected the provider rules to apply: %+v", st)
+	}
+}
@@ -0,0 +1,231 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/gin-gonic/gin"
+	"github.com/hantdev/chorus-controller/internal/crypto"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/handler"
+	"github.com/hantdev/chorus-controller/internal/server"
+	"github.com/hantdev/chorus-controller/internal/service"
+)
+
+const (
+	routerToken = "router-token"
+	systemToken = "system-token"
+)
+
+// fakeTokens accepts the router and system tokens without a token table
+type fakeTokens struct {
+	domain.TokenService
+}
+
+func (fakeTokens) ValidateToken(_ context.Context, token string) (*domain.TokenInfo, error) {
+	if token != routerToken && token != systemToken {
+		return nil, errors.NewUnauthorizedError("invalid token", nil)
+	}
+	return &domain.TokenInfo{Name: "router", IsSystem: token == systemToken}, nil
+}
+
+func (fakeTokens) ValidateSystemToken(_ context.Context, token string) error {
+	if token != systemToken {
+		return errors.NewUnauthorizedError("system token required", nil)
+	}
+	return nil
+}
+
+// defaultWorkerOnly skips the worker registry, which lives in the database
+type defaultWorkerOnly struct {
+	*service.WorkerService
+}
+
+func (w defaultWorkerOnly) WorkerNames(context.Context) ([]string, error) {
+	return []string{w.DefaultWorker()}, nil
+}
+
+// newRouterEnv builds the router on top of a fake worker without a database.
+// Only routes which never reach the database can be tested with it, the rest are covered by newTestEnv.
+func newRouterEnv(t *testing.T) (*testEnv, domain.WorkerClient) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	worker, conn, workerService := startWorker(t)
+	workers := defaultWorkerOnly{workerService}
+	client, err := workers.Resolve(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cipher, err := crypto.New("test-encryption-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := server.New(
+		handler.NewHealthHandler(conn),
+		handler.NewStorageHandler(service.NewStorageService(workers, cipher)),
+		handler.NewReplicationHandler(service.NewReplicationService(workers)),
+		handler.NewWorkerHandler(workers),
+		handler.NewAuthHandler(fakeTokens{}),
+		fakeTokens{},
+		0,
+	)
+	return &testEnv{t: t, router: srv.Router(), token: routerToken, worker: worker}, client
+}
+
+func TestHealth(t *testing.T) {
+	env, _ := newRouterEnv(t)
+
+	// Health only reports the connection of the default worker, without its address
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	var health struct {
+		Message string         `json:"message"`
+		Worker  map[string]any `json:"worker"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("health: %d %q", rec.Code, rec.Body.String())
+	}
+	if health.Message != "ok" || health.Worker["state"] == "" || len(health.Worker) != 1 {
+		t.Fatalf("unexpected health: %+v", health)
+	}
+}
+
+func TestStoragesAndBuckets(t *testing.T) {
+	env, _ := newRouterEnv(t)
+
+	var storages struct {
+		Storages []domain.WorkerStorage `json:"storages"`
+	}
+	if code := env.do(http.MethodGet, "/storages", nil, &storages); code != http.StatusOK {
+		t.Fatalf("list storages: %d", code)
+	}
+	if len(storages.Storages) != 2 || storages.Storages[0].Worker != "default" || storages.Storages[0].Name != "follower" {
+		t.Fatalf("unexpected storages: %+v", storages.Storages)
+	}
+
+	var buckets struct {
+		Available  []string `json:"available_buckets"`
+		Replicated []string `json:"replicated_buckets"`
+	}
+	if code := env.do(http.MethodGet, "/buckets?user=admin&from=main&to=follower", nil, &buckets); code != http.StatusOK {
+		t.Fatalf("list buckets: %d", code)
+	}
+	if len(buckets.Available) != 2 || len(buckets.Replicated) != 0 {
+		t.Fatalf("unexpected buckets: %+v", buckets)
+	}
+}
+
+func TestReplicationActions(t *testing.T) {
+	env, client := newRouterEnv(t)
+
+	if _, err := client.AddReplication(context.Background(), &pb.AddReplicationRequest{
+		User: "admin", From: "main", To: "follower", Buckets: []string{"photos"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var replications []domain.WorkerReplication
+	if code := env.do(http.MethodGet, "/replications", nil, &replications); code != http.StatusOK {
+		t.Fatalf("list replications: %d", code)
+	}
+	if len(replications) != 1 || replications[0].Worker != "default" || replications[0].Bucket != "photos" {
+		t.Fatalf("unexpected replications: %+v", replications)
+	}
+
+	// Naming the worker skips the lookup of the owning job
+	id := domain.ReplicationIdentifier{Worker: "default", User: "admin", Bucket: "photos", From: "main", To: "follower"}
+	if code := env.do(http.MethodPost, "/replications/pause", id, nil); code != http.StatusOK {
+		t.Fatalf("pause replication: %d", code)
+	}
+	if code := env.do(http.MethodPost, "/replications/resume", id, nil); code != http.StatusOK {
+		t.Fatalf("resume replication: %d", code)
+	}
+
+	// Worker errors keep their meaning over HTTP
+	var apiErr struct {
+		Upstream struct {
+			Status    string `json:"status"`
+			Retryable bool   `json:"retryable"`
+		} `json:"upstream"`
+	}
+	missing := domain.ReplicationIdentifier{Worker: "default", User: "admin", Bucket: "logs", From: "main", To: "follower"}
+	if code := env.do(http.MethodPost, "/replications/pause", missing, &apiErr); code != http.StatusNotFound {
+		t.Fatalf("pause missing replication: expected 404, got %d", code)
+	}
+	if apiErr.Upstream.Status != "NotFound" || apiErr.Upstream.Retryable {
+		t.Fatalf("unexpected upstream error: %+v", apiErr)
+	}
+	if code := env.do(http.MethodPost, "/replications/switch/zero-downtime", id, nil); code != http.StatusPreconditionFailed {
+		t.Fatalf("switch before initial replication: expected 412, got %d", code)
+	}
+	if code := env.do(http.MethodPost, "/replications/pause", map[string]string{"user": "admin"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("pause without a bucket: expected 400, got %d", code)
+	}
+}
+
+func TestRouterAuthentication(t *testing.T) {
+	env, _ := newRouterEnv(t)
+
+	send := func(method, path, token string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Token "+token)
+		}
+		rec := httptest.NewRecorder()
+		env.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, tc := range []struct {
+		method, path, token string
+		want                int
+	}{
+		{http.MethodPost, "/storages", "", http.StatusUnauthorized},
+		{http.MethodPost, "/storages", "typo", http.StatusUnauthorized},
+		{http.MethodPost, "/replications/pause", "", http.StatusUnauthorized},
+		{http.MethodDelete, "/workers/edge", "", http.StatusUnauthorized},
+		{http.MethodGet, "/workers/status", "", http.StatusUnauthorized},
+		{http.MethodGet, "/storages/export", "", http.StatusUnauthorized},
+		{http.MethodGet, "/storages/export", routerToken, http.StatusUnauthorized},
+		{http.MethodPost, "/storages/rotate-keys", routerToken, http.StatusUnauthorized},
+		// Authenticated requests reach the handler, which rejects the empty body
+		{http.MethodPost, "/storages", routerToken, http.StatusBadRequest},
+	} {
+		if code := send(tc.method, tc.path, tc.token); code != tc.want {
+			t.Errorf("%s %s with token %q: expected %d, got %d", tc.method, tc.path, tc.token, tc.want, code)
+		}
+	}
+}
+
+func TestStorageProviderCatalog(t *testing.T) {
+	env, _ := newRouterEnv(t)
+
+	var catalog []map[string]any
+	if code := env.do(http.MethodGet, "/storages/providers", nil, &catalog); code != http.StatusOK || len(catalog) == 0 || catalog[0]["id"] != "minio" {
+		t.Fatalf("list providers: %d %+v", code, catalog)
+	}
+
+	// Providers are checked before the storage is saved
+	unsupported := domain.CreateStorageRequest{
+		Name: "b2", Address: "https://b2.local", Provider: "backblaze",
+		User: "admin", AccessKey: "access", SecretKey: "secret",
+	}
+	var failure map[string]any
+	if code := env.do(http.MethodPost, "/storages", unsupported, &failure); code != http.StatusBadRequest ||
+		!strings.Contains(fmt.Sprint(failure), "supported providers are") {
+		t.Fatalf("unsupported provider: %d %+v", code, failure)
+	}
+}
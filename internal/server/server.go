@@ -2,13 +2,16 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/hantdev/chorus-controller/internal/domain"
 	"github.com/hantdev/chorus-controller/internal/handler"
+	"github.com/hantdev/chorus-controller/internal/metrics"
 	"github.com/hantdev/chorus-controller/internal/middleware"
 	"github.com/hantdev/chorus-controller/internal/service"
 	swaggerfiles "github.com/swaggo/files"
@@ -20,9 +23,10 @@ type Server struct {
 	healthHandler      *handler.HealthHandler
 	storageHandler     *handler.StorageHandler
 	replicationHandler *handler.ReplicationHandler
+	workerHandler      *handler.WorkerHandler
 	authHandler        *handler.AuthHandler
 	tokenService       domain.TokenService
-	port               int
+	httpServer         *http.Server
 }
 
 // New creates a new HTTP server
@@ -30,18 +34,25 @@ func New(
 	healthHandler *handler.HealthHandler,
 	storageHandler *handler.StorageHandler,
 	replicationHandler *handler.ReplicationHandler,
+	workerHandler *handler.WorkerHandler,
 	authHandler *handler.AuthHandler,
 	tokenService domain.TokenService,
 	port int,
 ) *Server {
-	return &Server{
+	s := &Server{
 		healthHandler:      healthHandler,
 		storageHandler:     storageHandler,
 		replicationHandler: replicationHandler,
+		workerHandler:      workerHandler,
 		authHandler:        authHandler,
 		tokenService:       tokenService,
-		port:               port,
 	}
+	// Built up front so that Shutdown never races with Run
+	s.httpServer = &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: s.Router(),
+	}
+	return s
 }
 
 // Initialize ensures system token exists
@@ -53,16 +64,25 @@ func (s *Server) Initialize() error {
 	return nil
 }
 
-// Run starts the HTTP server
+// Run starts the HTTP server and blocks until it is shut down
 func (s *Server) Run() error {
-	r := gin.Default()
+	if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Router builds the gin engine with all routes and middleware
+func (s *Server) Router() *gin.Engine {
+	r := gin.New()
+	r.Use(middleware.RequestLogger(), gin.Recovery())
 
 	// Add CORS middleware
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // Allow all origins in development
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "X-Next-Cursor", "X-Unreachable-Workers"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -75,6 +95,7 @@ func (s *Server) Run() error {
 
 	// Public endpoints (no authentication required)
 	r.GET("/health", s.healthHandler.Health)
+	r.GET("/metrics", gin.WrapH(metrics.Default))
 
 	// Authentication endpoints (no authentication required)
 	r.POST("/auth/token", s.authHandler.GenerateToken)
@@ -87,14 +108,31 @@ func (s *Server) Run() error {
 		systemProtected.GET("/auth/tokens/detailed", s.authHandler.ListTokensWithValues)
 		systemProtected.POST("/auth/revoke", s.authHandler.RevokeToken)
 		systemProtected.DELETE("/auth/tokens/:id", s.authHandler.DeleteToken)
+		systemProtected.POST("/storages/:id/reveal", s.storageHandler.RevealStorageSecret)
+		systemProtected.POST("/storages/rotate-keys", s.storageHandler.RotateKeys)
+		systemProtected.GET("/storages/export", s.storageHandler.ExportStorages)
 	}
 
 	// Read-only endpoints (no authentication required)
 	r.GET("/storages", s.storageHandler.ListStorages)
 	r.GET("/buckets", s.storageHandler.ListBuckets)
 	r.GET("/storages/db", s.storageHandler.ListStoragesDB)
+	r.GET("/storages/drift", s.storageHandler.GetStorageDrift)
+	r.GET("/storages/providers", s.storageHandler.ListProviders)
+	r.GET("/storages/drift/events", s.storageHandler.ListDriftEvents)
 	r.GET("/storages/:id", s.storageHandler.GetStorage)
+	r.GET("/storages/:id/credentials", s.storageHandler.ListStorageCredentials)
+	r.GET("/storages/:id/health", s.storageHandler.GetStorageHealth)
 	r.GET("/replications", s.replicationHandler.ListReplications)
+	r.GET("/replications/stream", s.replicationHandler.StreamReplications)
+	r.GET("/replications/switch", s.replicationHandler.GetSwitchStatus)
+	r.GET("/replications/switches", s.replicationHandler.ListSwitches)
+	r.GET("/replications/users", s.replicationHandler.ListUserReplications)
+	r.GET("/replications/compare", s.replicationHandler.ListComparisons)
+	r.GET("/replications/compare/:id", s.replicationHandler.GetComparison)
+	r.GET("/replications/compare/:id/keys", s.replicationHandler.GetComparisonKeys)
+	r.GET("/workers", s.workerHandler.ListWorkers)
+	r.GET("/workers/:name", s.workerHandler.GetWorker)
 
 	// Protected endpoints (authentication required for write operations)
 	protected := r.Group("/")
@@ -104,8 +142,15 @@ func (s *Server) Run() error {
 
 		// Storage write operations
 		protected.POST("/storages", s.storageHandler.CreateStorage)
+		protected.POST("/storages/import", s.storageHandler.ImportStorages)
 		protected.PUT("/storages/:id", s.storageHandler.UpdateStorage)
+		protected.PATCH("/storages/:id", s.storageHandler.PatchStorage)
 		protected.DELETE("/storages/:id", s.storageHandler.DeleteStorage)
+		protected.POST("/storages/:id/test", s.storageHandler.TestStorage)
+		protected.POST("/storages/:id/promote", s.storageHandler.PromoteStorage)
+		protected.POST("/storages/:id/credentials", s.storageHandler.CreateStorageCredential)
+		protected.PUT("/storages/:id/credentials/:user", s.storageHandler.UpdateStorageCredential)
+		protected.DELETE("/storages/:id/credentials/:user", s.storageHandler.DeleteStorageCredential)
 
 		// Replication write operations
 		protected.POST("/replications", s.replicationHandler.CreateReplication)
@@ -113,7 +158,26 @@ func (s *Server) Run() error {
 		protected.POST("/replications/resume", s.replicationHandler.ResumeReplication)
 		protected.DELETE("/replications", s.replicationHandler.DeleteReplication)
 		protected.POST("/replications/switch/zero-downtime", s.replicationHandler.SwitchZeroDowntime)
+		protected.POST("/replications/switch", s.replicationHandler.SwitchWithDowntime)
+		protected.DELETE("/replications/switch", s.replicationHandler.DeleteSwitch)
+		protected.POST("/replications/compare", s.replicationHandler.CompareBucket)
+		protected.POST("/replications/users/pause", s.replicationHandler.PauseUserReplication)
+		protected.POST("/replications/users/resume", s.replicationHandler.ResumeUserReplication)
+		protected.DELETE("/replications/users", s.replicationHandler.DeleteUserReplication)
+
+		// Worker connection details
+		protected.GET("/workers/status", s.workerHandler.WorkerStatuses)
+
+		// Worker registry write operations
+		protected.POST("/workers", s.workerHandler.CreateWorker)
+		protected.PUT("/workers/:name", s.workerHandler.UpdateWorker)
+		protected.DELETE("/workers/:name", s.workerHandler.DeleteWorker)
 	}
 
-	return r.Run(fmt.Sprintf(":%d", s.port))
+	return r
+}
+
+// Shutdown gracefully stops the HTTP server, waiting for in-flight requests
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
@@ -2,6 +2,11 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
 	"time"
 
 	pb "github.com/clyso/chorus/proto/gen/go/chorus"
@@ -12,15 +17,30 @@ import (
 
 // ReplicationService implements domain.ReplicationService interface
 type ReplicationService struct {
-	workerClient     domain.WorkerClient
-	replicateJobRepo *repository.ReplicateJobDBRepository
+	workers          domain.WorkerResolver
+	replicateJobRepo replicateJobStore
+	credentialRepo   *repository.StorageCredentialDBRepository
+	comparisonRepo   *repository.BucketComparisonDBRepository
+	pollInterval     time.Duration
+}
+
+// replicateJobStore keeps the jobs of the replications created through the controller,
+// implemented by repository.ReplicateJobDBRepository
+type replicateJobStore interface {
+	Create(ctx context.Context, j *domain.ReplicateJob) error
+	FindWorker(ctx context.Context, user, bucket, from, to string) (string, error)
+	FindUserWorker(ctx context.Context, user, from, to string) (string, error)
+	DeleteByReplication(ctx context.Context, user, from, to, bucket string) error
 }
 
 // NewReplicationService creates a new replication service
-func NewReplicationService(workerClient domain.WorkerClient) *ReplicationService {
+func NewReplicationService(workers domain.WorkerResolver) *ReplicationService {
 	return &ReplicationService{
-		workerClient:     workerClient,
+		workers:          workers,
 		replicateJobRepo: repository.NewReplicateJobDBRepository(),
+		credentialRepo:   repository.NewStorageCredentialDBRepository(),
+		comparisonRepo:   repository.NewBucketComparisonDBRepository(),
+		pollInterval:     defaultStreamPollInterval,
 	}
 }
 
@@ -29,22 +49,37 @@ func (s *ReplicationService) CreateReplication(ctx context.Context, req *domain.
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	if err := s.checkReplicationUser(ctx, req.User, req.From, req.To); err != nil {
+		return err
+	}
+
+	worker := req.Worker
+	if worker == "" {
+		worker = s.workers.DefaultWorker()
+	}
+	workerClient, err := s.workers.Resolve(ctx, worker)
+	if err != nil {
+		return err
+	}
+
 	addReq := &pb.AddReplicationRequest{
 		User:            req.User,
 		From:            req.From,
 		To:              req.To,
 		Buckets:         req.Buckets,
 		IsForAllBuckets: len(req.Buckets) == 0,
-		ToBucket:        req.ToBucket,
 	}
 
+	if req.ToBucket != "" {
+		addReq.ToBucket = &req.ToBucket
+	}
 	if req.AgentURL != "" {
 		addReq.AgentUrl = &req.AgentURL
 	}
 
-	_, err := s.workerClient.AddReplication(ctx, addReq)
+	_, err = workerClient.AddReplication(ctx, addReq)
 	if err != nil {
-		return errors.NewBadGatewayError("failed to create replication", err)
+		return workerError("failed to create replication", err)
 	}
 
 	// Persist ReplicateJob(s) into DB for tracking
@@ -56,24 +91,26 @@ func (s *ReplicationService) CreateReplication(ctx context.Context, req *domain.
 	if len(req.Buckets) > 0 {
 		for _, b := range req.Buckets {
 			jb := &domain.ReplicateJob{
+				Worker:   worker,
 				User:     req.User,
 				Bucket:   b,
 				From:     req.From,
 				To:       req.To,
 				ToBucket: toBucket,
-				Status:   "created",
+				Status:   domain.ReplicateJobCreated,
 			}
 			_ = s.replicateJobRepo.Create(ctx, jb)
 		}
 	} else {
 		// represent all buckets with empty bucket field
 		jb := &domain.ReplicateJob{
+			Worker: worker,
 			User:   req.User,
 			Bucket: "",
 			From:   req.From,
 			To:     req.To,
 			// ToBucket left empty to signify same name
-			Status: "created",
+			Status: domain.ReplicateJobCreated,
 		}
 		_ = s.replicateJobRepo.Create(ctx, jb)
 	}
@@ -81,17 +118,71 @@ func (s *ReplicationService) CreateReplication(ctx context.Context, req *domain.
 	return nil
 }
 
-// ListReplications retrieves all replication jobs
-func (s *ReplicationService) ListReplications(ctx context.Context) ([]*pb.Replication, error) {
+// ListReplications retrieves replication jobs from all workers, workers which cannot be listed are returned with their error
+func (s *ReplicationService) ListReplications(ctx context.Context) ([]domain.WorkerReplication, map[string]string, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	resp, err := s.workerClient.ListReplications(ctx)
+	result, unreachable, err := s.collectReplications(ctx, &domain.ReplicationFilter{})
 	if err != nil {
-		return nil, errors.NewBadGatewayError("failed to list replications", err)
+		return nil, nil, workerError("failed to list replications", err)
 	}
+	return result, unreachable, nil
+}
 
-	return resp.Replications, nil
+// collectReplications lists the replications matching the filter from the selected workers and reports the unreachable ones
+func (s *ReplicationService) collectReplications(ctx context.Context, filter *domain.ReplicationFilter) ([]domain.WorkerReplication, map[string]string, error) {
+	var (
+		mu     sync.Mutex
+		result []domain.WorkerReplication
+	)
+	collect := func(ctx context.Context, name string, client domain.WorkerClient) error {
+		resp, err := client.ListReplications(ctx)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, r := range resp.Replications {
+			if matchReplication(filter, name, r) {
+				result = append(result, domain.WorkerReplication{Worker: name, Replication: r})
+			}
+		}
+		return nil
+	}
+
+	var (
+		unreachable map[string]string
+		err         error
+	)
+	if filter.Worker != "" {
+		var client domain.WorkerClient
+		client, err = s.workers.Resolve(ctx, filter.Worker)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = collect(ctx, filter.Worker, client)
+	} else {
+		unreachable, err = forEachWorker(ctx, s.workers, collect)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Keep the merged output stable across calls
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Worker != b.Worker {
+			return a.Worker < b.Worker
+		}
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		return a.Bucket < b.Bucket
+	})
+
+	return result, unreachable, nil
 }
 
 // PauseReplication pauses a replication job
@@ -99,10 +190,15 @@ func (s *ReplicationService) PauseReplication(ctx context.Context, id *domain.Re
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	workerClient, err := s.resolveOwner(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	req := s.buildReplicationRequest(id)
-	_, err := s.workerClient.PauseReplication(ctx, req)
+	_, err = workerClient.PauseReplication(ctx, req)
 	if err != nil {
-		return errors.NewBadGatewayError("failed to pause replication", err)
+		return workerError("failed to pause replication", err)
 	}
 
 	return nil
@@ -113,10 +209,15 @@ func (s *ReplicationService) ResumeReplication(ctx context.Context, id *domain.R
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	workerClient, err := s.resolveOwner(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	req := s.buildReplicationRequest(id)
-	_, err := s.workerClient.ResumeReplication(ctx, req)
+	_, err = workerClient.ResumeReplication(ctx, req)
 	if err != nil {
-		return errors.NewBadGatewayError("failed to resume replication", err)
+		return workerError("failed to resume replication", err)
 	}
 
 	return nil
@@ -127,12 +228,18 @@ func (s *ReplicationService) DeleteReplication(ctx context.Context, id *domain.R
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	workerClient, err := s.resolveOwner(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	req := s.buildReplicationRequest(id)
-	_, err := s.workerClient.DeleteReplication(ctx, req)
+	_, err = workerClient.DeleteReplication(ctx, req)
 	if err != nil {
-		return errors.NewBadGatewayError("failed to delete replication", err)
+		return workerError("failed to delete replication", err)
 	}
 
+	s.forgetJobs(ctx, id.User, id.From, id.To, id.Bucket)
 	return nil
 }
 
@@ -141,19 +248,50 @@ func (s *ReplicationService) SwitchZeroDowntime(ctx context.Context, id *domain.
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
+	workerClient, err := s.resolveOwner(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	req := s.buildReplicationRequest(id)
 	switchReq := &pb.SwitchBucketZeroDowntimeRequest{
 		ReplicationId: req,
 	}
 
-	_, err := s.workerClient.SwitchBucketZeroDowntime(ctx, switchReq)
+	_, err = workerClient.SwitchBucketZeroDowntime(ctx, switchReq)
 	if err != nil {
-		return errors.NewBadGatewayError("failed to switch buckets", err)
+		return workerError("failed to switch buckets", err)
 	}
 
 	return nil
 }
 
+// resolveOwner returns the client of the worker owning the replication
+func (s *ReplicationService) resolveOwner(ctx context.Context, id *domain.ReplicationIdentifier) (domain.WorkerClient, error) {
+	return s.workers.Resolve(ctx, s.ownerOf(ctx, id))
+}
+
+// ownerOf returns the name of the worker owning the replication.
+// Replications without a recorded owner belong to the default worker.
+func (s *ReplicationService) ownerOf(ctx context.Context, id *domain.ReplicationIdentifier) string {
+	if id.Worker != "" {
+		return id.Worker
+	}
+	owner, err := s.replicateJobRepo.FindWorker(ctx, id.User, id.Bucket, id.From, id.To)
+	if err != nil || owner == "" || owner == domain.LegacyDefaultWorker {
+		return s.workers.DefaultWorker()
+	}
+	return owner
+}
+
+// forgetJobs deletes the jobs of replications the worker deleted, empty bucket forgets every job of the user between the storages.
+// The replications are gone already, so a failure is only logged.
+func (s *ReplicationService) forgetJobs(ctx context.Context, user, from, to, bucket string) {
+	if err := s.replicateJobRepo.DeleteByReplication(ctx, user, from, to, bucket); err != nil {
+		log.Printf("Warning: failed to delete replication jobs of user %q from %q to %q: %v", user, from, to, err)
+	}
+}
+
 // buildReplicationRequest builds a pb.ReplicationRequest from domain.ReplicationIdentifier
 func (s *ReplicationService) buildReplicationRequest(id *domain.ReplicationIdentifier) *pb.ReplicationRequest {
 	toBucket := id.ToBucket
@@ -166,9 +304,26 @@ func (s *ReplicationService) buildReplicationRequest(id *domain.ReplicationIdent
 		Bucket:   id.Bucket,
 		From:     id.From,
 		To:       id.To,
-		ToBucket: toBucket,
+		ToBucket: &toBucket,
 	}
 }
 
 // Ensure ReplicationService implements domain.ReplicationService interface
 var _ domain.ReplicationService = (*ReplicationService)(nil)
+
+// checkReplicationUser rejects a replication whose user is missing on the from or to storage.
+// Only stored storages are checked, storages configured on the worker alone are left to the worker.
+func (s *ReplicationService) checkReplicationUser(ctx context.Context, user, from, to string) error {
+	users, err := s.credentialRepo.UsersByStorage(ctx, []string{from, to})
+	if err != nil {
+		return errors.NewInternalServerError("failed to look up storage users", err)
+	}
+	for _, name := range []string{from, to} {
+		storageUsers, ok := users[name]
+		if ok && !slices.Contains(storageUsers, user) {
+			return errors.NewBadRequestError(fmt.Sprintf(
+				"user %q does not exist on storage %q, add it to the storage credentials first", user, name), nil)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	// compareTimeout bounds a bucket comparison, the worker lists both buckets completely
+	compareTimeout = 5 * time.Minute
+	// defaultComparisonKeysLimit is the page size of key lists when none is requested
+	defaultComparisonKeysLimit = 100
+	// defaultComparisonListLimit is the number of comparisons listed when none is requested
+	defaultComparisonListLimit = 50
+)
+
+// comparisonKeyKinds lists the key kinds in the order they are reported
+var comparisonKeyKinds = []string{
+	domain.ComparisonKeysMissFrom,
+	domain.ComparisonKeysMissTo,
+	domain.ComparisonKeysDiffer,
+	domain.ComparisonKeysErrors,
+}
+
+// CompareBucket compares the source and destination buckets of a replication and stores the result
+func (s *ReplicationService) CompareBucket(ctx context.Context, req *domain.CompareBucketRequest) (*domain.BucketComparisonResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, compareTimeout)
+	defer cancel()
+
+	id := &req.ReplicationIdentifier
+	worker := s.ownerOf(ctx, id)
+	workerClient, err := s.workers.Resolve(ctx, worker)
+	if err != nil {
+		return nil, err
+	}
+
+	replication := s.buildReplicationRequest(id)
+	resp, err := workerClient.CompareBucket(ctx, &pb.CompareBucketRequest{
+		User:     replication.User,
+		Bucket:   replication.Bucket,
+		From:     replication.From,
+		To:       replication.To,
+		ToBucket: replication.ToBucket,
+	})
+	if err != nil {
+		return nil, workerError("failed to compare buckets", err)
+	}
+
+	comparison := comparisonFromPb(resp)
+	comparison.Worker = worker
+	comparison.User = id.User
+	comparison.Bucket = id.Bucket
+	comparison.From = id.From
+	comparison.To = id.To
+	comparison.ToBucket = id.ToBucket
+
+	if err := s.comparisonRepo.Create(ctx, comparison); err != nil {
+		return nil, errors.NewInternalServerError("failed to store bucket comparison", err)
+	}
+	if !comparison.IsMatch {
+		log.Printf("Bucket %q of user %q differs between %q and %q: %d missing in source, %d missing in destination, %d differ",
+			id.Bucket, id.User, id.From, id.To, comparison.MissFromCount, comparison.MissToCount, comparison.DifferCount)
+	}
+
+	result := &domain.BucketComparisonResult{BucketComparison: *comparison}
+	if req.ShowKeys {
+		result.Keys = make(map[string]*domain.ComparisonKeysPage, len(comparisonKeyKinds))
+		for _, kind := range comparisonKeyKinds {
+			keys, _ := comparison.Keys(kind)
+			result.Keys[kind] = pageKeys(kind, keys, 0, req.Limit)
+		}
+	}
+	return result, nil
+}
+
+// ListComparisons lists stored bucket comparisons, newest first
+func (s *ReplicationService) ListComparisons(ctx context.Context, filter *domain.ComparisonFilter) ([]domain.BucketComparison, error) {
+	if filter.Limit == 0 {
+		filter.Limit = defaultComparisonListLimit
+	}
+	items, err := s.comparisonRepo.List(ctx, filter)
+	if err != nil {
+		return nil, errors.NewInternalServerError("failed to list bucket comparisons", err)
+	}
+	return items, nil
+}
+
+// GetComparison retrieves the summary of a stored bucket comparison
+func (s *ReplicationService) GetComparison(ctx context.Context, id string) (*domain.BucketComparison, error) {
+	comparisonID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid comparison ID format", err)
+	}
+
+	comparison, err := s.comparisonRepo.GetSummaryByID(ctx, comparisonID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NewNotFoundError("bucket comparison not found", err)
+		}
+		return nil, err
+	}
+	return comparison, nil
+}
+
+// GetComparisonKeys retrieves a page of the mismatched keys of a stored bucket comparison
+func (s *ReplicationService) GetComparisonKeys(ctx context.Context, id string, req *domain.ComparisonKeysRequest) (*domain.ComparisonKeysPage, error) {
+	comparisonID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid comparison ID format", err)
+	}
+
+	comparison, err := s.comparisonRepo.GetByID(ctx, comparisonID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NewNotFoundError("bucket comparison not found", err)
+		}
+		return nil, err
+	}
+
+	keys, ok := comparison.Keys(req.Kind)
+	if !ok {
+		return nil, errors.NewBadRequestError("unknown key kind "+req.Kind, nil)
+	}
+	return pageKeys(req.Kind, keys, req.Offset, req.Limit), nil
+}
+
+// comparisonFromPb counts the keys of a worker comparison, matched keys are only counted when the worker lists them
+func comparisonFromPb(resp *pb.CompareBucketResponse) *domain.BucketComparison {
+	c := &domain.BucketComparison{
+		IsMatch:       resp.IsMatch,
+		MissFromCount: int64(len(resp.MissFrom)),
+		MissToCount:   int64(len(resp.MissTo)),
+		DifferCount:   int64(len(resp.Differ)),
+		ErrorCount:    int64(len(resp.Error)),
+		MissFrom:      resp.MissFrom,
+		MissTo:        resp.MissTo,
+		Differ:        resp.Differ,
+		Errors:        resp.Error,
+	}
+	if len(resp.Match) > 0 {
+		matched := int64(len(resp.Match))
+		c.MatchCount = &matched
+	}
+	// Store empty lists rather than JSON null
+	for _, keys := range []*[]string{&c.MissFrom, &c.MissTo, &c.Differ, &c.Errors} {
+		if *keys == nil {
+			*keys = []string{}
+		}
+	}
+	return c
+}
+
+// pageKeys returns the keys in [offset, offset+limit), a zero limit selects the default page size
+func pageKeys(kind string, keys []string, offset, limit int) *domain.ComparisonKeysPage {
+	if limit <= 0 {
+		limit = defaultComparisonKeysLimit
+	}
+	page := &domain.ComparisonKeysPage{
+		Kind:   kind,
+		Total:  len(keys),
+		Offset: offset,
+		Limit:  limit,
+		Keys:   []string{},
+	}
+	if offset >= len(keys) {
+		return page
+	}
+	end := offset + limit
+	if end > len(keys) {
+		end = len(keys)
+	}
+	page.Keys = keys[offset:end]
+	return page
+}
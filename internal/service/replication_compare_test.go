@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/domain"
+)
+
+func TestComparisonFromPbCountsKeys(t *testing.T) {
+	_, client := newFakeWorkerService(t)
+	ctx := context.Background()
+
+	// Without a replication nothing has been copied yet
+	resp, err := client.CompareBucket(ctx, &pb.CompareBucketRequest{
+		User: "admin", Bucket: "photos", From: "main", To: "follower",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := comparisonFromPb(resp)
+	if c.IsMatch || c.MatchCount != nil || c.MissToCount != 100 || len(c.MissTo) != 100 {
+		t.Fatalf("unexpected comparison: match=%v counts=%v/%d", c.IsMatch, c.MatchCount, c.MissToCount)
+	}
+	if c.MissFrom == nil || c.Differ == nil || c.Errors == nil {
+		t.Fatal("expected empty key lists instead of nil")
+	}
+
+	keys, ok := c.Keys(domain.ComparisonKeysMissTo)
+	if !ok || keys[0] != "object-000000" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+	if _, ok := c.Keys("match"); ok {
+		t.Fatal("matched keys must not be exposed")
+	}
+
+	// Matched keys listed by the worker are counted
+	c = comparisonFromPb(&pb.CompareBucketResponse{IsMatch: true, Match: []string{"a", "b"}})
+	if c.MatchCount == nil || *c.MatchCount != 2 {
+		t.Fatalf("expected 2 matched keys, got %v", c.MatchCount)
+	}
+}
+
+func TestPageKeys(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e"}
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		want          []string
+		wantLimit     int
+	}{
+		{name: "first page", offset: 0, limit: 2, want: []string{"a", "b"}, wantLimit: 2},
+		{name: "last page", offset: 4, limit: 2, want: []string{"e"}, wantLimit: 2},
+		{name: "past the end", offset: 10, limit: 2, want: []string{}, wantLimit: 2},
+		{name: "default limit", offset: 1, limit: 0, want: []string{"b", "c", "d", "e"}, wantLimit: defaultComparisonKeysLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := pageKeys(domain.ComparisonKeysMissTo, keys, tt.offset, tt.limit)
+			if page.Total != len(keys) || page.Limit != tt.wantLimit || page.Offset != tt.offset {
+				t.Fatalf("unexpected page: %+v", page)
+			}
+			if len(page.Keys) != len(tt.want) {
+				t.Fatalf("got keys %v, want %v", page.Keys, tt.want)
+			}
+			for i := range tt.want {
+				if page.Keys[i] != tt.want[i] {
+					t.Fatalf("got keys %v, want %v", page.Keys, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// defaultStreamPollInterval is how often the diffing poller lists replications
+	defaultStreamPollInterval = 2 * time.Second
+	// streamBufferSize bounds the number of pending events of a subscriber
+	streamBufferSize = 1024
+)
+
+// StreamReplications pushes changes of the replications matching the filter.
+// A filter naming a single bucket replication is served by the worker's
+// replication stream, any other filter by polling and diffing replication lists.
+// The returned channel is closed when ctx is done or the replication stream ends.
+func (s *ReplicationService) StreamReplications(ctx context.Context, filter *domain.ReplicationFilter) (<-chan domain.ReplicationEvent, error) {
+	buf := newEventBuffer(streamBufferSize)
+
+	if filter.User != "" && filter.Bucket != "" && filter.From != "" && filter.To != "" {
+		stream, worker, first, err := s.openBucketStream(ctx, filter)
+		switch {
+		case err == nil:
+			go s.forwardBucketStream(ctx, worker, stream, first, buf)
+			return buf.subscribe(ctx), nil
+		case status.Code(err) != codes.Unimplemented:
+			return nil, workerError("failed to stream replication", err)
+		}
+		// Older workers do not stream replications, fall back to polling
+	}
+
+	if filter.Worker != "" {
+		// Fail before streaming starts when the worker does not exist
+		if _, err := s.workers.Resolve(ctx, filter.Worker); err != nil {
+			return nil, err
+		}
+	}
+	go s.pollReplications(ctx, filter, buf)
+	return buf.subscribe(ctx), nil
+}
+
+// openBucketStream opens the worker stream of a bucket replication and waits for the first update,
+// so that unknown replications and unsupported workers are reported before streaming starts
+func (s *ReplicationService) openBucketStream(ctx context.Context, filter *domain.ReplicationFilter) (domain.ReplicationStream, string, *pb.Replication, error) {
+	id := &domain.ReplicationIdentifier{
+		Worker: filter.Worker,
+		User:   filter.User,
+		Bucket: filter.Bucket,
+		From:   filter.From,
+		To:     filter.To,
+	}
+	worker := s.ownerOf(ctx, id)
+	client, err := s.workers.Resolve(ctx, worker)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	stream, err := client.StreamBucketReplication(ctx, &pb.ReplicationRequest{
+		User:   filter.User,
+		Bucket: filter.Bucket,
+		From:   filter.From,
+		To:     filter.To,
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return stream, worker, first, nil
+}
+
+// forwardBucketStream pushes updates of a worker replication stream into the buffer.
+// When the stream breaks the remaining updates are served by the poller.
+func (s *ReplicationService) forwardBucketStream(ctx context.Context, worker string, stream domain.ReplicationStream, r *pb.Replication, buf *eventBuffer) {
+	var last *domain.WorkerReplication
+	for {
+		last = &domain.WorkerReplication{Worker: worker, Replication: r}
+		buf.push(replicationKey(last), domain.ReplicationEvent{Type: domain.ReplicationEventUpdated, Replication: last})
+
+		var err error
+		r, err = stream.Recv()
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			buf.close()
+			return
+		}
+		if err == io.EOF || status.Code(err) == codes.NotFound {
+			// The worker ends the stream when the replication is deleted
+			buf.push(replicationKey(last), domain.ReplicationEvent{Type: domain.ReplicationEventRemoved, Replication: last})
+			buf.close()
+			return
+		}
+
+		log.Printf("Warning: replication stream from worker %q failed, polling instead: %v", worker, err)
+		filter := &domain.ReplicationFilter{
+			Worker: worker,
+			User:   last.User,
+			Bucket: last.Bucket,
+			From:   last.From,
+			To:     last.To,
+		}
+		s.pollReplications(ctx, filter, buf)
+		return
+	}
+}
+
+// pollReplications lists replications periodically and pushes the differences into the buffer
+func (s *ReplicationService) pollReplications(ctx context.Context, filter *domain.ReplicationFilter, buf *eventBuffer) {
+	defer buf.close()
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	known := make(map[string]*domain.WorkerReplication)
+	for {
+		listCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		current, _, err := s.collectReplications(listCtx, filter)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			buf.push(domain.ReplicationEventError, domain.ReplicationEvent{
+				Type:  domain.ReplicationEventError,
+				Error: workerError("failed to list replications", err).Error(),
+			})
+		default:
+			seen := make(map[string]struct{}, len(current))
+			for i := range current {
+				r := &current[i]
+				key := replicationKey(r)
+				seen[key] = struct{}{}
+				if prev, ok := known[key]; ok && proto.Equal(prev.Replication, r.Replication) {
+					continue
+				}
+				known[key] = r
+				buf.push(key, domain.ReplicationEvent{Type: domain.ReplicationEventUpdated, Replication: r})
+			}
+			for key, r := range known {
+				if _, ok := seen[key]; !ok {
+					delete(known, key)
+					buf.push(key, domain.ReplicationEvent{Type: domain.ReplicationEventRemoved, Replication: r})
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// matchReplication reports whether a replication of the worker matches the filter
+func matchReplication(filter *domain.ReplicationFilter, worker string, r *pb.Replication) bool {
+	return (filter.Worker == "" || filter.Worker == worker) &&
+		(filter.User == "" || filter.User == r.User) &&
+		(filter.Bucket == "" || filter.Bucket == r.Bucket) &&
+		(filter.From == "" || filter.From == r.From) &&
+		(filter.To == "" || filter.To == r.To)
+}
+
+// replicationKey identifies a replication across workers
+func replicationKey(r *domain.WorkerReplication) string {
+	return strings.Join([]string{r.Worker, r.User, r.Bucket, r.From, r.To, r.GetToBucket()}, "\x00")
+}
+
+// eventBuffer is a bounded queue of events for a single subscriber.
+// Pending events with the same key are coalesced so that slow subscribers
+// only receive the latest state of every replication, when the buffer is
+// full the oldest pending event is dropped.
+type eventBuffer struct {
+	mu      sync.Mutex
+	order   []string
+	pending map[string]domain.ReplicationEvent
+	size    int
+	closed  bool
+	notify  chan struct{}
+}
+
+func newEventBuffer(size int) *eventBuffer {
+	return &eventBuffer{
+		pending: make(map[string]domain.ReplicationEvent),
+		size:    size,
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// push queues an event, replacing a pending event with the same key
+func (b *eventBuffer) push(key string, ev domain.ReplicationEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	if _, ok := b.pending[key]; !ok {
+		if len(b.order) >= b.size {
+			oldest := b.order[0]
+			b.order = b.order[1:]
+			delete(b.pending, oldest)
+			log.Printf("Warning: replication stream subscriber is too slow, dropping event")
+		}
+		b.order = append(b.order, key)
+	}
+	b.pending[key] = ev
+	b.signal()
+}
+
+// close marks the end of the event sequence, pending events are still delivered
+func (b *eventBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.signal()
+}
+
+// pop returns the oldest pending event
+func (b *eventBuffer) pop() (ev domain.ReplicationEvent, ok bool, closed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.order) == 0 {
+		return ev, false, b.closed
+	}
+	key := b.order[0]
+	b.order = b.order[1:]
+	ev = b.pending[key]
+	delete(b.pending, key)
+	return ev, true, false
+}
+
+func (b *eventBuffer) signal() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// subscribe delivers the buffered events on a channel until the buffer is closed or ctx is done
+func (b *eventBuffer) subscribe(ctx context.Context) <-chan domain.ReplicationEvent {
+	out := make(chan domain.ReplicationEvent)
+	go func() {
+		defer close(out)
+		for {
+			ev, ok, closed := b.pop()
+			if closed {
+				return
+			}
+			if ok {
+				select {
+				case out <- ev:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-b.notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/fakeworker"
+	"github.com/hantdev/chorus-controller/internal/repository"
+)
+
+// newFakeWorkerService serves a fake worker as the default worker.
+// Tests name the worker explicitly so no database lookups are needed.
+func newFakeWorkerService(t *testing.T) (*ReplicationService, domain.WorkerClient) {
+	t.Helper()
+
+	worker := fakeworker.New(fakeworker.Options{ObjectsPerBucket: 100, ObjectsPerSecond: 200})
+	worker.AddStorage("main", "main.s3.local", pb.Storage_Ceph, true, "admin")
+	worker.AddStorage("follower", "follower.s3.local", pb.Storage_Minio, false, "admin")
+	if err := worker.AddBuckets("main", "admin", "photos", "logs"); err != nil {
+		t.Fatal(err)
+	}
+	addr, err := worker.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(worker.Stop)
+
+	conn, err := repository.NewWorkerRepository(addr.String(), repository.WorkerTLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers := NewWorkerService("default", conn, repository.ResilienceConfig{MaxAttempts: 1, FailureThreshold: 100})
+	t.Cleanup(func() { workers.Close() })
+
+	svc := NewReplicationService(workers)
+	svc.replicateJobRepo = &memoryJobs{}
+	svc.pollInterval = 20 * time.Millisecond
+	return svc, conn
+}
+
+func nextEvent(t *testing.T, events <-chan domain.ReplicationEvent) domain.ReplicationEvent {
+	t.Helper()
+
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("stream closed")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return domain.ReplicationEvent{}
+}
+
+func TestStreamSingleReplication(t *testing.T) {
+	svc, client := newFakeWorkerService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := client.AddReplication(ctx, &pb.AddReplicationRequest{
+		User: "admin", From: "main", To: "follower", Buckets: []string{"photos"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := svc.StreamReplications(ctx, &domain.ReplicationFilter{
+		Worker: "default", User: "admin", Bucket: "photos", From: "main", To: "follower",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		ev := nextEvent(t, events)
+		if ev.Type != domain.ReplicationEventUpdated || ev.Replication.Worker != "default" {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+		if ev.Replication.IsInitDone {
+			break
+		}
+	}
+
+	if _, err := client.DeleteReplication(ctx, &pb.ReplicationRequest{
+		User: "admin", Bucket: "photos", From: "main", To: "follower",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, events); ev.Type != domain.ReplicationEventRemoved {
+		t.Fatalf("expected removed event, got %+v", ev)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("expected stream to end after the replication was removed")
+	}
+}
+
+func TestStreamUnknownReplication(t *testing.T) {
+	svc, _ := newFakeWorkerService(t)
+
+	_, err := svc.StreamReplications(context.Background(), &domain.ReplicationFilter{
+		Worker: "default", User: "admin", Bucket: "missing", From: "main", To: "follower",
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown replication")
+	}
+}
+
+func TestStreamPollerDiffsReplications(t *testing.T) {
+	svc, client := newFakeWorkerService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	events, err := svc.StreamReplications(ctx, &domain.ReplicationFilter{Worker: "default", User: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.AddReplication(ctx, &pb.AddReplicationRequest{
+		User: "admin", From: "main", To: "follower", Buckets: []string{"logs"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, events); ev.Type != domain.ReplicationEventUpdated || ev.Replication.Bucket != "logs" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+
+	if _, err := client.DeleteReplication(ctx, &pb.ReplicationRequest{
+		User: "admin", Bucket: "logs", From: "main", To: "follower",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		ev := nextEvent(t, events)
+		if ev.Type == domain.ReplicationEventRemoved {
+			break
+		}
+	}
+
+	// Cancelling the subscriber closes the stream
+	cancel()
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not closed after cancel")
+	}
+}
+
+func TestEventBufferCoalescesPendingEvents(t *testing.T) {
+	buf := newEventBuffer(2)
+	for i := 0; i < 10; i++ {
+		buf.push("a", domain.ReplicationEvent{Type: domain.ReplicationEventUpdated, Error: "a"})
+	}
+	buf.push("b", domain.ReplicationEvent{Type: domain.ReplicationEventUpdated, Error: "b"})
+	// Full buffer drops the oldest pending key
+	buf.push("c", domain.ReplicationEvent{Type: domain.ReplicationEventUpdated, Error: "c"})
+	buf.close()
+
+	var got []string
+	for {
+		ev, ok, closed := buf.pop()
+		if closed {
+			break
+		}
+		if !ok {
+			t.Fatal("expected pending event")
+		}
+		got = append(got, ev.Error)
+	}
+	if len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Fatalf("unexpected events: %v", got)
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// switchStatuses maps worker switch statuses to domain statuses
+var switchStatuses = map[pb.GetBucketSwitchStatusResponse_Status]string{
+	pb.GetBucketSwitchStatusResponse_NotStarted:      domain.SwitchStatusNotStarted,
+	pb.GetBucketSwitchStatusResponse_InProgress:      domain.SwitchStatusInProgress,
+	pb.GetBucketSwitchStatusResponse_CheckInProgress: domain.SwitchStatusCheckInProgress,
+	pb.GetBucketSwitchStatusResponse_Error:           domain.SwitchStatusError,
+	pb.GetBucketSwitchStatusResponse_Skipped:         domain.SwitchStatusSkipped,
+	pb.GetBucketSwitchStatusResponse_Done:            domain.SwitchStatusDone,
+}
+
+// SwitchWithDowntime schedules a bucket switch which blocks writes while it runs
+func (s *ReplicationService) SwitchWithDowntime(ctx context.Context, req *domain.SwitchBucketRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	switchReq := &pb.SwitchBucketRequest{
+		ReplicationId: s.buildReplicationRequest(&req.ReplicationIdentifier),
+	}
+	if req.Downtime != nil {
+		opts, err := downtimeOptsToPb(req.Downtime)
+		if err != nil {
+			return err
+		}
+		switchReq.DowntimeOpts = opts
+	}
+
+	workerClient, err := s.resolveOwner(ctx, &req.ReplicationIdentifier)
+	if err != nil {
+		return err
+	}
+	if _, err := workerClient.SwitchBucket(ctx, switchReq); err != nil {
+		return workerError("failed to switch buckets", err)
+	}
+	return nil
+}
+
+// GetSwitchStatus retrieves the status of the switch of a replication
+func (s *ReplicationService) GetSwitchStatus(ctx context.Context, id *domain.ReplicationIdentifier) (*domain.BucketSwitch, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	worker := s.ownerOf(ctx, id)
+	workerClient, err := s.workers.Resolve(ctx, worker)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := workerClient.GetBucketSwitchStatus(ctx, s.buildReplicationRequest(id))
+	if err != nil {
+		return nil, workerError("failed to get switch status", err)
+	}
+
+	sw := switchFromPb(resp)
+	sw.Replication.Worker = worker
+	return sw, nil
+}
+
+// ListSwitches retrieves bucket switches from all workers
+func (s *ReplicationService) ListSwitches(ctx context.Context) ([]domain.BucketSwitch, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		result []domain.BucketSwitch
+	)
+	_, err := forEachWorker(ctx, s.workers, func(ctx context.Context, name string, client domain.WorkerClient) error {
+		resp, err := client.ListReplicationSwitches(ctx)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, sw := range resp.Switches {
+			converted := switchFromPb(sw)
+			converted.Replication.Worker = name
+			result = append(result, *converted)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, workerError("failed to list switches", err)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i].Replication, result[j].Replication
+		if a.Worker != b.Worker {
+			return a.Worker < b.Worker
+		}
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		return a.Bucket < b.Bucket
+	})
+
+	return result, nil
+}
+
+// DeleteSwitch aborts the switch of a replication and removes its metadata
+func (s *ReplicationService) DeleteSwitch(ctx context.Context, id *domain.ReplicationIdentifier) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	workerClient, err := s.resolveOwner(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := workerClient.DeleteBucketSwitch(ctx, s.buildReplicationRequest(id)); err != nil {
+		return workerError("failed to delete switch", err)
+	}
+	return nil
+}
+
+// downtimeOptsToPb validates a downtime window and converts it into worker options
+func downtimeOptsToPb(w *domain.SwitchDowntimeWindow) (*pb.SwitchDowntimeOpts, error) {
+	if w.Cron != "" && w.StartAt != nil {
+		return nil, errors.NewBadRequestError("either cron or start_at can be set, but not both", nil)
+	}
+
+	opts := &pb.SwitchDowntimeOpts{
+		StartOnInitDone:     w.StartOnInitDone,
+		MaxEventLag:         w.MaxEventLag,
+		SkipBucketCheck:     w.SkipBucketCheck,
+		ContinueReplication: w.ContinueReplication,
+	}
+	if w.Cron != "" {
+		cron := w.Cron
+		opts.Cron = &cron
+	}
+	if w.StartAt != nil {
+		opts.StartAt = timestamppb.New(*w.StartAt)
+	}
+	if w.MaxDuration != "" {
+		d, err := time.ParseDuration(w.MaxDuration)
+		if err != nil || d <= 0 {
+			return nil, errors.NewBadRequestError("max_duration must be a positive duration such as 30m", err)
+		}
+		opts.MaxDuration = durationpb.New(d)
+	}
+	return opts, nil
+}
+
+// switchFromPb converts a worker switch status into the domain model
+func switchFromPb(resp *pb.GetBucketSwitchStatusResponse) *domain.BucketSwitch {
+	sw := &domain.BucketSwitch{
+		Status:       switchStatuses[resp.LastStatus],
+		ZeroDowntime: resp.ZeroDowntime,
+		History:      resp.History,
+	}
+	if sw.History == nil {
+		sw.History = []string{}
+	}
+	if id := resp.ReplicationId; id != nil {
+		sw.Replication = domain.ReplicationIdentifier{
+			User:     id.User,
+			Bucket:   id.Bucket,
+			From:     id.From,
+			To:       id.To,
+			ToBucket: id.GetToBucket(),
+		}
+	}
+	if resp.MultipartTtl != nil {
+		sw.MultipartTTL = resp.MultipartTtl.AsDuration().String()
+	}
+	if opts := resp.DowntimeOpts; opts != nil {
+		sw.Downtime = &domain.SwitchDowntimeWindow{
+			StartOnInitDone:     opts.StartOnInitDone,
+			Cron:                opts.GetCron(),
+			MaxEventLag:         opts.MaxEventLag,
+			SkipBucketCheck:     opts.SkipBucketCheck,
+			ContinueReplication: opts.ContinueReplication,
+		}
+		if opts.StartAt != nil {
+			startAt := opts.StartAt.AsTime()
+			sw.Downtime.StartAt = &startAt
+		}
+		if opts.MaxDuration != nil {
+			sw.Downtime.MaxDuration = opts.MaxDuration.AsDuration().String()
+		}
+	}
+	if resp.LastStartedAt != nil {
+		startedAt := resp.LastStartedAt.AsTime()
+		sw.LastStartedAt = &startedAt
+	}
+	if resp.DoneAt != nil {
+		doneAt := resp.DoneAt.AsTime()
+		sw.DoneAt = &doneAt
+	}
+	return sw
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+)
+
+func TestSwitchWithDowntimeLifecycle(t *testing.T) {
+	svc, client := newFakeWorkerService(t)
+	ctx := context.Background()
+
+	if _, err := client.AddReplication(ctx, &pb.AddReplicationRequest{
+		User: "admin", From: "main", To: "follower", Buckets: []string{"photos"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	id := domain.ReplicationIdentifier{Worker: "default", User: "admin", Bucket: "photos", From: "main", To: "follower"}
+	err := svc.SwitchWithDowntime(ctx, &domain.SwitchBucketRequest{
+		ReplicationIdentifier: id,
+		Downtime: &domain.SwitchDowntimeWindow{
+			StartOnInitDone: true,
+			MaxDuration:     "30m",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sw, err := svc.GetSwitchStatus(ctx, &id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sw.Replication.Worker != "default" || sw.Downtime == nil || sw.Downtime.MaxDuration != "30m0s" {
+			t.Fatalf("unexpected switch: %+v", sw)
+		}
+		if sw.Status == domain.SwitchStatusDone {
+			if sw.DoneAt == nil || len(sw.History) == 0 {
+				t.Fatalf("expected done time and history: %+v", sw)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("switch did not finish, last status %s", sw.Status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := svc.DeleteSwitch(ctx, &id); err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.GetSwitchStatus(ctx, &id)
+	var apiErr *errors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %v", err)
+	}
+}
+
+func TestSwitchWithDowntimeValidation(t *testing.T) {
+	svc, _ := newFakeWorkerService(t)
+
+	startAt := time.Now().Add(time.Hour)
+	err := svc.SwitchWithDowntime(context.Background(), &domain.SwitchBucketRequest{
+		ReplicationIdentifier: domain.ReplicationIdentifier{Worker: "default", User: "admin", Bucket: "photos", From: "main", To: "follower"},
+		Downtime:              &domain.SwitchDowntimeWindow{Cron: "0 2 * * *", StartAt: &startAt},
+	})
+	var apiErr *errors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// userReplicationTimeout bounds an action on all replications of a user, which may touch many buckets
+const userReplicationTimeout = time.Minute
+
+// ListUserReplications retrieves the replications of users grouped by source and destination storage
+func (s *ReplicationService) ListUserReplications(ctx context.Context, filter *domain.UserReplicationFilter) ([]domain.UserReplication, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		result []domain.UserReplication
+	)
+	collect := func(ctx context.Context, name string, client domain.WorkerClient) error {
+		groups, err := userReplicationsOf(ctx, name, client, filter)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		result = append(result, groups...)
+		return nil
+	}
+
+	var err error
+	if filter.Worker != "" {
+		var client domain.WorkerClient
+		client, err = s.workers.Resolve(ctx, filter.Worker)
+		if err != nil {
+			return nil, err
+		}
+		err = collect(ctx, filter.Worker, client)
+	} else {
+		_, err = forEachWorker(ctx, s.workers, collect)
+	}
+	if err != nil {
+		return nil, workerError("failed to list user replications", err)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Worker != b.Worker {
+			return a.Worker < b.Worker
+		}
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+
+	return result, nil
+}
+
+// PauseUserReplication pauses every bucket replication of a user between two storages.
+// Buckets created later are still replicated when the user replication covers all buckets.
+func (s *ReplicationService) PauseUserReplication(ctx context.Context, req *domain.UserReplicationRequest) (*domain.UserReplicationReport, error) {
+	return s.runUserReplicationAction(ctx, req, domain.UserReplicationPause)
+}
+
+// ResumeUserReplication resumes every paused bucket replication of a user between two storages
+func (s *ReplicationService) ResumeUserReplication(ctx context.Context, req *domain.UserReplicationRequest) (*domain.UserReplicationReport, error) {
+	return s.runUserReplicationAction(ctx, req, domain.UserReplicationResume)
+}
+
+// DeleteUserReplication deletes the user replication and every bucket replication of a user between two storages.
+// The worker deletes them as a unit when it knows the user replication, otherwise buckets are deleted one by one.
+func (s *ReplicationService) DeleteUserReplication(ctx context.Context, req *domain.UserReplicationRequest) (*domain.UserReplicationReport, error) {
+	return s.runUserReplicationAction(ctx, req, domain.UserReplicationDelete)
+}
+
+// runUserReplicationAction applies an action to the replications of a user and reports the outcome per bucket
+func (s *ReplicationService) runUserReplicationAction(ctx context.Context, req *domain.UserReplicationRequest, action string) (*domain.UserReplicationReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, userReplicationTimeout)
+	defer cancel()
+
+	worker := s.userOwnerOf(ctx, req)
+	client, err := s.workers.Resolve(ctx, worker)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.ListReplications(ctx)
+	if err != nil {
+		return nil, workerError("failed to list replications", err)
+	}
+	filter := &domain.ReplicationFilter{User: req.User, From: req.From, To: req.To}
+	var buckets []*pb.Replication
+	for _, r := range resp.Replications {
+		if matchReplication(filter, worker, r) {
+			buckets = append(buckets, r)
+		}
+	}
+
+	report := &domain.UserReplicationReport{
+		Worker:  worker,
+		User:    req.User,
+		From:    req.From,
+		To:      req.To,
+		Action:  action,
+		Buckets: []domain.BucketOperationResult{},
+	}
+
+	if action == domain.UserReplicationDelete {
+		_, err := client.DeleteUserReplication(ctx, &pb.DeleteUserReplicationRequest{
+			User:                     req.User,
+			From:                     req.From,
+			To:                       req.To,
+			DeleteBucketReplications: true,
+		})
+		switch status.Code(err) {
+		case codes.OK:
+			report.UserLevel = true
+			for _, r := range buckets {
+				addBucketResult(report, r, domain.BucketOperationDone, "")
+			}
+			s.forgetJobs(ctx, req.User, req.From, req.To, "")
+			return report, nil
+		case codes.NotFound, codes.Unimplemented:
+			// Buckets were replicated one by one or the worker has no user replications
+		default:
+			return nil, workerError("failed to delete user replication", err)
+		}
+	}
+
+	if len(buckets) == 0 {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("no replications of user %q from %q to %q", req.User, req.From, req.To), nil)
+	}
+
+	for _, r := range buckets {
+		if reason := skipReason(r, action); reason != "" {
+			addBucketResult(report, r, domain.BucketOperationSkipped, reason)
+			continue
+		}
+
+		bucketReq := replicationRequestOf(r)
+		var err error
+		switch action {
+		case domain.UserReplicationPause:
+			_, err = client.PauseReplication(ctx, bucketReq)
+		case domain.UserReplicationResume:
+			_, err = client.ResumeReplication(ctx, bucketReq)
+		case domain.UserReplicationDelete:
+			_, err = client.DeleteReplication(ctx, bucketReq)
+		}
+		if err != nil {
+			addBucketResult(report, r, domain.BucketOperationFailed, errorMessage(workerError("failed to "+action+" replication", err)))
+			continue
+		}
+		addBucketResult(report, r, domain.BucketOperationDone, "")
+		if action == domain.UserReplicationDelete {
+			s.forgetJobs(ctx, req.User, req.From, req.To, r.Bucket)
+		}
+	}
+	if action == domain.UserReplicationDelete && report.Failed == 0 {
+		// Every bucket is gone, so is the all-buckets job
+		s.forgetJobs(ctx, req.User, req.From, req.To, "")
+	}
+
+	return report, nil
+}
+
+// userOwnerOf returns the name of the worker owning the replications of a user.
+// Users without recorded replications, or recorded before the worker registry, belong to the default worker.
+func (s *ReplicationService) userOwnerOf(ctx context.Context, req *domain.UserReplicationRequest) string {
+	if req.Worker != "" {
+		return req.Worker
+	}
+	owner, err := s.replicateJobRepo.FindUserWorker(ctx, req.User, req.From, req.To)
+	if err != nil || owner == "" || owner == domain.LegacyDefaultWorker {
+		return s.workers.DefaultWorker()
+	}
+	return owner
+}
+
+// userReplicationsOf groups the bucket replications of a worker by user, source and destination
+func userReplicationsOf(ctx context.Context, worker string, client domain.WorkerClient, filter *domain.UserReplicationFilter) ([]domain.UserReplication, error) {
+	replications, err := client.ListReplications(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := client.ListUserReplications(ctx)
+	if err != nil && status.Code(err) != codes.Unimplemented {
+		return nil, err
+	}
+
+	match := func(user, from, to string) bool {
+		return (filter.User == "" || filter.User == user) &&
+			(filter.From == "" || filter.From == from) &&
+			(filter.To == "" || filter.To == to)
+	}
+
+	var result []domain.UserReplication
+	index := make(map[[3]string]int)
+	group := func(user, from, to string) *domain.UserReplication {
+		key := [3]string{user, from, to}
+		if i, ok := index[key]; ok {
+			return &result[i]
+		}
+		index[key] = len(result)
+		result = append(result, domain.UserReplication{
+			Worker:       worker,
+			User:         user,
+			From:         from,
+			To:           to,
+			Replications: []*pb.Replication{},
+		})
+		return &result[len(result)-1]
+	}
+
+	for _, u := range users.GetReplications() {
+		if match(u.User, u.From, u.To) {
+			group(u.User, u.From, u.To).AllBuckets = true
+		}
+	}
+	for _, r := range replications.Replications {
+		if match(r.User, r.From, r.To) {
+			g := group(r.User, r.From, r.To)
+			g.Replications = append(g.Replications, r)
+		}
+	}
+	return result, nil
+}
+
+// skipReason tells why an action does not apply to a bucket replication, empty when it applies
+func skipReason(r *pb.Replication, action string) string {
+	switch {
+	case action == domain.UserReplicationDelete:
+		return ""
+	case r.IsArchived:
+		return "replication is archived"
+	case action == domain.UserReplicationPause && r.IsPaused:
+		return "replication is already paused"
+	case action == domain.UserReplicationResume && !r.IsPaused:
+		return "replication is not paused"
+	}
+	return ""
+}
+
+// replicationRequestOf identifies a bucket replication reported by a worker
+func replicationRequestOf(r *pb.Replication) *pb.ReplicationRequest {
+	return &pb.ReplicationRequest{
+		User:     r.User,
+		Bucket:   r.Bucket,
+		From:     r.From,
+		To:       r.To,
+		ToBucket: r.ToBucket,
+	}
+}
+
+// addBucketResult records the outcome of the action for a bucket
+func addBucketResult(report *domain.UserReplicationReport, r *pb.Replication, result string, reason string) {
+	report.Buckets = append(report.Buckets, domain.BucketOperationResult{
+		Bucket:   r.Bucket,
+		ToBucket: r.GetToBucket(),
+		Status:   result,
+		Error:    reason,
+	})
+	switch result {
+	case domain.BucketOperationDone:
+		report.Done++
+	case domain.BucketOperationSkipped:
+		report.Skipped++
+	case domain.BucketOperationFailed:
+		report.Failed++
+	}
+}
+
+// errorMessage returns the client facing message of an error
+func errorMessage(err error) string {
+	var apiErr *errors.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Message
+	}
+	return err.Error()
+}
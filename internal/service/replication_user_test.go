@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// legacyWorker is a worker without user replication RPCs which fails to pause one bucket
+type legacyWorker struct {
+	domain.WorkerClient
+	failBucket string
+}
+
+func (w *legacyWorker) ListUserReplications(context.Context) (*pb.ListUserReplicationsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "unknown method")
+}
+
+func (w *legacyWorker) DeleteUserReplication(context.Context, *pb.DeleteUserReplicationRequest) (*emptypb.Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "unknown method")
+}
+
+func (w *legacyWorker) PauseReplication(ctx context.Context, req *pb.ReplicationRequest) (*emptypb.Empty, error) {
+	if req.Bucket == w.failBucket {
+		return nil, status.Error(codes.Internal, "storage error")
+	}
+	return w.WorkerClient.PauseReplication(ctx, req)
+}
+
+// memoryJobs keeps replication jobs in memory instead of the database
+type memoryJobs struct {
+	mu   sync.Mutex
+	jobs []domain.ReplicateJob
+}
+
+func (m *memoryJobs) Create(_ context.Context, j *domain.ReplicateJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs = append(m.jobs, *j)
+	return nil
+}
+
+func (m *memoryJobs) FindWorker(_ context.Context, user, bucket, from, to string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.User == user && j.From == from && j.To == to && (j.Bucket == bucket || j.Bucket == "") {
+			return j.Worker, nil
+		}
+	}
+	return "", nil
+}
+
+func (m *memoryJobs) FindUserWorker(ctx context.Context, user, from, to string) (string, error) {
+	return m.FindWorker(ctx, user, "", from, to)
+}
+
+func (m *memoryJobs) DeleteByReplication(_ context.Context, user, from, to, bucket string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs = slices.DeleteFunc(m.jobs, func(j domain.ReplicateJob) bool {
+		return j.User == user && j.From == from && j.To == to && (bucket == "" || j.Bucket == bucket)
+	})
+	return nil
+}
+
+// singleWorker resolves every name to the same client
+type singleWorker struct {
+	client domain.WorkerClient
+}
+
+func (r singleWorker) Resolve(context.Context, string) (domain.WorkerClient, error) {
+	return r.client, nil
+}
+
+func (r singleWorker) WorkerNames(context.Context) ([]string, error) {
+	return []string{"default"}, nil
+}
+
+func (r singleWorker) DefaultWorker() string {
+	return "default"
+}
+
+func TestUserReplicationLifecycle(t *testing.T) {
+	svc, client := newFakeWorkerService(t)
+	ctx := context.Background()
+
+	if _, err := client.AddReplication(ctx, &pb.AddReplicationRequest{
+		User: "admin", From: "main", To: "follower", IsForAllBuckets: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := &memoryJobs{jobs: []domain.ReplicateJob{
+		{Worker: "default", User: "admin", From: "main", To: "follower"},
+		{Worker: "default", User: "admin", From: "main", To: "archive"},
+	}}
+	svc.replicateJobRepo = jobs
+
+	groups, err := svc.ListUserReplications(ctx, &domain.UserReplicationFilter{Worker: "default", User: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || !groups[0].AllBuckets || len(groups[0].Replications) != 2 {
+		t.Fatalf("unexpected user replications: %+v", groups)
+	}
+
+	req := &domain.UserReplicationRequest{Worker: "default", User: "admin", From: "main", To: "follower"}
+	report, err := svc.PauseUserReplication(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.UserLevel || report.Done != 2 || report.Failed != 0 {
+		t.Fatalf("unexpected pause report: %+v", report)
+	}
+
+	// Paused buckets are skipped when pausing again
+	report, err = svc.PauseUserReplication(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped != 2 || report.Buckets[0].Status != domain.BucketOperationSkipped {
+		t.Fatalf("unexpected second pause report: %+v", report)
+	}
+
+	report, err = svc.DeleteUserReplication(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.UserLevel || report.Done != 2 {
+		t.Fatalf("unexpected delete report: %+v", report)
+	}
+	if len(jobs.jobs) != 1 || jobs.jobs[0].To != "archive" {
+		t.Fatalf("expected only the job to archive to be kept, got %+v", jobs.jobs)
+	}
+
+	_, err = svc.DeleteUserReplication(ctx, req)
+	var apiErr *errors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+}
+
+func TestUserReplicationFallsBackToBuckets(t *testing.T) {
+	_, client := newFakeWorkerService(t)
+	ctx := context.Background()
+
+	if _, err := client.AddReplication(ctx, &pb.AddReplicationRequest{
+		User: "admin", From: "main", To: "follower", Buckets: []string{"photos", "logs"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewReplicationService(singleWorker{client: &legacyWorker{WorkerClient: client, failBucket: "logs"}})
+	jobs := &memoryJobs{}
+	svc.replicateJobRepo = jobs
+	for _, bucket := range []string{"photos", "logs"} {
+		_ = jobs.Create(ctx, &domain.ReplicateJob{Worker: "default", User: "admin", From: "main", To: "follower", Bucket: bucket})
+	}
+	req := &domain.UserReplicationRequest{Worker: "default", User: "admin", From: "main", To: "follower"}
+
+	groups, err := svc.ListUserReplications(ctx, &domain.UserReplicationFilter{Worker: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].AllBuckets {
+		t.Fatalf("unexpected user replications: %+v", groups)
+	}
+
+	report, err := svc.PauseUserReplication(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Done != 1 || report.Failed != 1 {
+		t.Fatalf("unexpected pause report: %+v", report)
+	}
+	for _, b := range report.Buckets {
+		if b.Bucket == "logs" && (b.Status != domain.BucketOperationFailed || b.Error == "") {
+			t.Fatalf("expected failed bucket with error: %+v", b)
+		}
+	}
+
+	report, err = svc.DeleteUserReplication(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.UserLevel || report.Done != 2 {
+		t.Fatalf("unexpected delete report: %+v", report)
+	}
+	if len(jobs.jobs) != 0 {
+		t.Fatalf("expected the jobs of deleted buckets to be deleted, got %+v", jobs.jobs)
+	}
+}
+
+// renamedDefault is a resolver whose default worker is not called default
+type renamedDefault struct {
+	singleWorker
+}
+
+func (renamedDefault) DefaultWorker() string {
+	return "primary"
+}
+
+func TestLegacyJobsBelongToDefaultWorker(t *testing.T) {
+	ctx := context.Background()
+	svc := NewReplicationService(renamedDefault{})
+	svc.replicateJobRepo = &memoryJobs{jobs: []domain.ReplicateJob{
+		{Worker: domain.LegacyDefaultWorker, User: "admin", From: "main", To: "follower"},
+		{Worker: "edge", User: "admin", From: "main", To: "archive"},
+	}}
+
+	if owner := svc.ownerOf(ctx, &domain.ReplicationIdentifier{User: "admin", Bucket: "photos", From: "main", To: "follower"}); owner != "primary" {
+		t.Fatalf("expected the default worker, got %q", owner)
+	}
+	if owner := svc.userOwnerOf(ctx, &domain.UserReplicationRequest{User: "admin", From: "main", To: "follower"}); owner != "primary" {
+		t.Fatalf("expected the default worker for the user, got %q", owner)
+	}
+	if owner := svc.ownerOf(ctx, &domain.ReplicationIdentifier{User: "admin", Bucket: "photos", From: "main", To: "archive"}); owner != "edge" {
+		t.Fatalf("expected the recorded worker, got %q", owner)
+	}
+}
+
+// partialWorkers has a second worker which cannot be reached
+type partialWorkers struct {
+	singleWorker
+}
+
+func (r partialWorkers) Resolve(ctx context.Context, name string) (domain.WorkerClient, error) {
+	if name == "edge" {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	return r.singleWorker.Resolve(ctx, name)
+}
+
+func (partialWorkers) WorkerNames(context.Context) ([]string, error) {
+	return []string{"default", "edge"}, nil
+}
+
+func TestListReplicationsReportsUnreachableWorkers(t *testing.T) {
+	_, client := newFakeWorkerService(t)
+	ctx := context.Background()
+	if _, err := client.AddReplication(ctx, &pb.AddReplicationRequest{
+		User: "admin", From: "main", To: "follower", Buckets: []string{"photos"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewReplicationService(partialWorkers{singleWorker{client: client}})
+	replications, unreachable, err := svc.ListReplications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replications) != 1 || replications[0].Worker != "default" {
+		t.Fatalf("expected the replication of the default worker, got %+v", replications)
+	}
+	if len(unreachable) != 1 || !strings.Contains(unreachable["edge"], "connection refused") {
+		t.Fatalf("expected edge to be unreachable, got %v", unreachable)
+	}
+}
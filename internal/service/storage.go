@@ -2,6 +2,11 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
 	"time"
 
 	pb "github.com/clyso/chorus/proto/gen/go/chorus"
@@ -9,43 +14,71 @@ import (
 	"github.com/hantdev/chorus-controller/internal/crypto"
 	"github.com/hantdev/chorus-controller/internal/domain"
 	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/labels"
 	"github.com/hantdev/chorus-controller/internal/repository"
 	"gorm.io/gorm"
 )
 
 // StorageService implements domain.StorageService interface
 type StorageService struct {
-	workerClient domain.WorkerClient
-	storageRepo  *repository.StorageDBRepository
-	crypto       *crypto.Crypto
+	workers        domain.WorkerResolver
+	storageRepo    *repository.StorageDBRepository
+	credentialRepo *repository.StorageCredentialDBRepository
+	driftRepo      *repository.StorageDriftDBRepository
+	healthRepo     *repository.StorageHealthDBRepository
+	auditRepo      *repository.AuditDBRepository
+	crypto         *crypto.Crypto
+	httpClient     *http.Client
 }
 
-// NewStorageService creates a new storage service
-func NewStorageService(workerClient domain.WorkerClient, encryptionKey string) *StorageService {
-	crypto, err := crypto.New(encryptionKey)
-	if err != nil {
-		// In production, you might want to handle this error more gracefully
-		panic("failed to initialize crypto: " + err.Error())
-	}
-
+// NewStorageService creates a new storage service, secrets are encrypted with the given keys
+func NewStorageService(workers domain.WorkerResolver, cipher *crypto.Crypto) *StorageService {
 	return &StorageService{
-		workerClient: workerClient,
-		storageRepo:  repository.NewStorageDBRepository(),
-		crypto:       crypto,
+		workers:        workers,
+		storageRepo:    repository.NewStorageDBRepository(),
+		credentialRepo: repository.NewStorageCredentialDBRepository(),
+		driftRepo:      repository.NewStorageDriftDBRepository(),
+		healthRepo:     repository.NewStorageHealthDBRepository(),
+		auditRepo:      repository.NewAuditDBRepository(),
+		crypto:         cipher,
+		httpClient:     &http.Client{},
 	}
 }
 
-// ListStorages retrieves all configured storages
-func (s *StorageService) ListStorages(ctx context.Context) (*pb.GetStoragesResponse, error) {
+// ListStorages retrieves storages configured on all workers, workers which cannot be listed are returned with their error
+func (s *StorageService) ListStorages(ctx context.Context) ([]domain.WorkerStorage, map[string]string, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	resp, err := s.workerClient.GetStorages(ctx)
+	var (
+		mu     sync.Mutex
+		result []domain.WorkerStorage
+	)
+	unreachable, err := forEachWorker(ctx, s.workers, func(ctx context.Context, name string, client domain.WorkerClient) error {
+		resp, err := client.GetStorages(ctx)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, st := range resp.Storages {
+			result = append(result, domain.WorkerStorage{Worker: name, Storage: st})
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, errors.NewBadGatewayError("failed to list storages", err)
+		return nil, nil, workerError("failed to list storages", err)
 	}
 
-	return resp, nil
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Worker != result[j].Worker {
+			return result[i].Worker < result[j].Worker
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return result, unreachable, nil
 }
 
 // ListBuckets retrieves buckets available for replication
@@ -53,6 +86,11 @@ func (s *StorageService) ListBuckets(ctx context.Context, req *domain.ListBucket
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	workerClient, err := s.workers.Resolve(ctx, req.Worker)
+	if err != nil {
+		return nil, err
+	}
+
 	listReq := &pb.ListBucketsForReplicationRequest{
 		User:           req.User,
 		From:           req.From,
@@ -60,9 +98,9 @@ func (s *StorageService) ListBuckets(ctx context.Context, req *domain.ListBucket
 		ShowReplicated: req.ShowReplicated,
 	}
 
-	resp, err := s.workerClient.ListBucketsForReplication(ctx, listReq)
+	resp, err := workerClient.ListBucketsForReplication(ctx, listReq)
 	if err != nil {
-		return nil, errors.NewBadGatewayError("failed to list buckets", err)
+		return nil, workerError("failed to list buckets", err)
 	}
 
 	return resp, nil
@@ -71,41 +109,108 @@ func (s *StorageService) ListBuckets(ctx context.Context, req *domain.ListBucket
 // CreateStorage persists a storage config
 func (s *StorageService) CreateStorage(ctx context.Context, storage *domain.Storage) error {
 	// Encrypt sensitive data before saving
-	if err := s.encryptStorage(storage); err != nil {
+	if err := s.encryptStorage(ctx, storage); err != nil {
 		return err
 	}
 	return s.storageRepo.Create(ctx, storage)
 }
 
-// CreateStorageFromRequest creates a storage from simplified request
+// CreateStorageFromRequest creates a storage from a request, omitted settings use their defaults
 func (s *StorageService) CreateStorageFromRequest(ctx context.Context, req *domain.CreateStorageRequest) error {
-	// Create storage with default values
 	storage := &domain.Storage{
 		Name:                  req.Name,
 		Address:               req.Address,
 		Provider:              req.Provider,
-		IsMain:                false,  // Default to false
-		IsSecure:              false,  // Default to false
-		DefaultRegion:         "",     // Default region
-		HealthCheckIntervalMs: 5000,   // Default 5 seconds
-		HttpTimeoutMs:         300000, // Default 5 minutes
-		RateLimitEnabled:      false,  // Default disabled
-		RateLimitRPM:          0,      // Default no limit
+		HealthCheckIntervalMs: domain.DefaultHealthCheckIntervalMs,
+		HttpTimeoutMs:         domain.DefaultHttpTimeoutMs,
+		Labels:                map[string]string{},
 		User:                  req.User,
 		AccessKeyID:           req.AccessKey,
 		SecretAccessKey:       req.SecretKey,
 	}
+	applyStorageSettings(storage, &req.StorageSettings)
+	if err := applyProvider(storage, req.IsSecure); err != nil {
+		return err
+	}
+	if err := checkStorageSettings(storage); err != nil {
+		return err
+	}
+
+	if req.Options.Validate {
+		if err := s.validateStorage(ctx, storage); err != nil {
+			return err
+		}
+	}
 
 	// Encrypt sensitive data before saving
-	if err := s.encryptStorage(storage); err != nil {
+	if err := s.encryptStorage(ctx, storage); err != nil {
 		return err
 	}
 
-	return s.storageRepo.Create(ctx, storage)
+	return s.saveStorage(ctx, storage, true, req.Options.ReplaceMain)
+}
+
+// ListStorageFromDB lists a page of stored storages with masked secrets.
+// The cursor of the next page is returned when the page is full and more storages match.
+func (s *StorageService) ListStorageFromDB(ctx context.Context, req *domain.ListStoragesRequest) ([]domain.Storage, string, error) {
+	filter, err := storageFilter(req)
+	if err != nil {
+		return nil, "", err
+	}
+	// One more storage tells whether there is a next page
+	if filter.Limit > 0 {
+		filter.Limit++
+	}
+	storages, err := s.storageRepo.ListFiltered(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if req.Limit > 0 && len(storages) > req.Limit {
+		storages = storages[:req.Limit]
+		next = base64.RawURLEncoding.EncodeToString([]byte(storages[req.Limit-1].Name))
+	}
+	for i := range storages {
+		if err := s.decryptStorage(ctx, &storages[i]); err != nil {
+			return nil, "", err
+		}
+		maskStorage(&storages[i])
+	}
+	return storages, next, nil
+}
+
+// storageFilter parses the selector and cursor of a storage listing
+func storageFilter(req *domain.ListStoragesRequest) (*domain.StorageFilter, error) {
+	sel, err := labels.Parse(req.Selector)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error(), err)
+	}
+	after, err := base64.RawURLEncoding.DecodeString(req.Cursor)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid cursor", err)
+	}
+	return &domain.StorageFilter{
+		Selector:   sel,
+		Provider:   req.Provider,
+		NamePrefix: req.NamePrefix,
+		After:      string(after),
+		Limit:      req.Limit,
+	}, nil
 }
 
-// ListStorageFromDB lists storages from DB
-func (s *StorageService) ListStorageFromDB(ctx context.Context) ([]domain.Storage, error) {
+// GetStorageByID retrieves a storage by ID with a masked secret
+func (s *StorageService) GetStorageByID(ctx context.Context, id string) (*domain.Storage, error) {
+	storage, err := s.getStorage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	maskStorage(storage)
+	return storage, nil
+}
+
+// listStorages lists storages with decrypted secrets
+func (s *StorageService) listStorages(ctx context.Context) ([]domain.Storage, error) {
 	storages, err := s.storageRepo.List(ctx)
 	if err != nil {
 		return nil, err
@@ -113,7 +218,7 @@ func (s *StorageService) ListStorageFromDB(ctx context.Context) ([]domain.Storag
 
 	// Decrypt sensitive data for each storage
 	for i := range storages {
-		if err := s.decryptStorage(&storages[i]); err != nil {
+		if err := s.decryptStorage(ctx, &storages[i]); err != nil {
 			return nil, err
 		}
 	}
@@ -121,8 +226,8 @@ func (s *StorageService) ListStorageFromDB(ctx context.Context) ([]domain.Storag
 	return storages, nil
 }
 
-// GetStorageByID retrieves a storage by ID
-func (s *StorageService) GetStorageByID(ctx context.Context, id string) (*domain.Storage, error) {
+// getStorage retrieves a storage by ID with a decrypted secret
+func (s *StorageService) getStorage(ctx context.Context, id string) (*domain.Storage, error) {
 	uuid, err := uuid.Parse(id)
 	if err != nil {
 		return nil, errors.NewBadRequestError("invalid storage ID format", err)
@@ -137,15 +242,33 @@ func (s *StorageService) GetStorageByID(ctx context.Context, id string) (*domain
 	}
 
 	// Decrypt sensitive data before returning
-	if err := s.decryptStorage(storage); err != nil {
+	if err := s.decryptStorage(ctx, storage); err != nil {
 		return nil, err
 	}
 
 	return storage, nil
 }
 
-// UpdateStorageByID updates a storage configuration by ID
+// UpdateStorageByID replaces a storage configuration by ID, omitted settings keep their current value
 func (s *StorageService) UpdateStorageByID(ctx context.Context, id string, req *domain.CreateStorageRequest) error {
+	return s.patchStorage(ctx, id, &domain.UpdateStorageRequest{
+		Name:            &req.Name,
+		Address:         &req.Address,
+		Provider:        &req.Provider,
+		User:            &req.User,
+		AccessKey:       &req.AccessKey,
+		SecretKey:       &req.SecretKey,
+		StorageSettings: req.StorageSettings,
+		Options:         req.Options,
+	})
+}
+
+// PatchStorageByID changes the fields of a storage which are set in the request
+func (s *StorageService) PatchStorageByID(ctx context.Context, id string, req *domain.UpdateStorageRequest) error {
+	return s.patchStorage(ctx, id, req)
+}
+
+func (s *StorageService) patchStorage(ctx context.Context, id string, req *domain.UpdateStorageRequest) error {
 	uuid, err := uuid.Parse(id)
 	if err != nil {
 		return errors.NewBadRequestError("invalid storage ID format", err)
@@ -159,51 +282,115 @@ func (s *StorageService) UpdateStorageByID(ctx context.Context, id string, req *
 		}
 		return err
 	}
+	// The stored secret is re-encrypted below, whether it changes or not
+	if err := s.decryptStorage(ctx, existingStorage); err != nil {
+		return err
+	}
 
-	// Update the storage with new values
-	existingStorage.Name = req.Name
-	existingStorage.Address = req.Address
-	existingStorage.Provider = req.Provider
-	existingStorage.DefaultRegion = ""
-	existingStorage.HealthCheckIntervalMs = 5000
-	existingStorage.HttpTimeoutMs = 300000
-	existingStorage.RateLimitEnabled = false
-	existingStorage.RateLimitRPM = 0
+	if req.User != nil {
+		if err := s.checkUserRename(ctx, existingStorage, *req.User); err != nil {
+			return err
+		}
+	}
 
-	// Update credentials
-	existingStorage.User = req.User
-	existingStorage.AccessKeyID = req.AccessKey
-	existingStorage.SecretAccessKey = req.SecretKey
+	stored := *existingStorage
+	setIfPresent(&existingStorage.Name, req.Name)
+	setIfPresent(&existingStorage.Address, req.Address)
+	setIfPresent(&existingStorage.Provider, req.Provider)
+	setIfPresent(&existingStorage.User, req.User)
+	setIfPresent(&existingStorage.AccessKeyID, req.AccessKey)
+	// A masked secret sent back from a read endpoint keeps the stored one
+	if req.SecretKey == nil || *req.SecretKey != domain.MaskedSecret {
+		setIfPresent(&existingStorage.SecretAccessKey, req.SecretKey)
+	}
+	applyStorageSettings(existingStorage, &req.StorageSettings)
+	// Storages saved before the provider catalog keep their provider until it, the region or the address changes
+	if providerChanged(&stored, existingStorage) {
+		if err := applyProvider(existingStorage, req.IsSecure); err != nil {
+			return err
+		}
+	}
+	if err := checkStorageSettings(existingStorage); err != nil {
+		return err
+	}
+
+	if req.Options.Validate {
+		if err := s.validateStorage(ctx, existingStorage); err != nil {
+			return err
+		}
+	}
 
 	// Encrypt sensitive data before saving
-	if err := s.encryptStorage(existingStorage); err != nil {
+	if err := s.encryptStorage(ctx, existingStorage); err != nil {
 		return err
 	}
 
-	return s.storageRepo.Update(ctx, existingStorage)
+	return s.saveStorage(ctx, existingStorage, false, req.Options.ReplaceMain)
 }
 
-// DeleteStorageByID deletes a storage by ID
-func (s *StorageService) DeleteStorageByID(ctx context.Context, id string) error {
+// DeleteStorageByID deletes a storage by ID.
+// Storages referenced by replication jobs are only deleted with force, which deletes the jobs as well.
+func (s *StorageService) DeleteStorageByID(ctx context.Context, id string, force bool) error {
 	uuid, err := uuid.Parse(id)
 	if err != nil {
 		return errors.NewBadRequestError("invalid storage ID format", err)
 	}
 
-	err = s.storageRepo.DeleteByID(ctx, uuid)
+	jobs, err := s.storageRepo.DeleteByID(ctx, uuid, force)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return errors.NewNotFoundError("storage not found", err)
 		}
+		if errors.Is(err, repository.ErrStorageInUse) {
+			return errors.NewConflictError(fmt.Sprintf("storage is used by %d replication jobs, delete them first or set force=true", len(jobs)), err).
+				WithDetails(map[string]any{"jobs": jobs})
+		}
 		return err
 	}
 	return nil
 }
 
-// encryptStorage encrypts sensitive fields before saving to database
-func (s *StorageService) encryptStorage(storage *domain.Storage) error {
+// applyStorageSettings copies the settings which are set to a storage
+func applyStorageSettings(storage *domain.Storage, settings *domain.StorageSettings) {
+	setIfPresent(&storage.IsMain, settings.IsMain)
+	setIfPresent(&storage.IsSecure, settings.IsSecure)
+	setIfPresent(&storage.DefaultRegion, settings.DefaultRegion)
+	setIfPresent(&storage.HealthCheckIntervalMs, settings.HealthCheckIntervalMs)
+	setIfPresent(&storage.HttpTimeoutMs, settings.HttpTimeoutMs)
+	setIfPresent(&storage.RateLimitEnabled, settings.RateLimitEnabled)
+	setIfPresent(&storage.RateLimitRPM, settings.RateLimitRPM)
+	setIfPresent(&storage.Description, settings.Description)
+	setIfPresent(&storage.Labels, settings.Labels)
+	if storage.Labels == nil {
+		storage.Labels = map[string]string{}
+	}
+}
+
+// checkStorageSettings validates the rules spanning several fields, single field ranges are checked on binding
+func checkStorageSettings(storage *domain.Storage) error {
+	if storage.RateLimitEnabled && storage.RateLimitRPM <= 0 {
+		return errors.NewBadRequestError("rate_limit_rpm must be positive when rate limiting is enabled", nil)
+	}
+	if err := labels.Validate(storage.Labels); err != nil {
+		return errors.NewBadRequestError(err.Error(), err)
+	}
+	return nil
+}
+
+func setIfPresent[T any](dst *T, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+// encryptStorage encrypts sensitive fields before saving to database.
+// New storages get their ID here, the secret is bound to it.
+func (s *StorageService) encryptStorage(ctx context.Context, storage *domain.Storage) error {
+	if storage.ID == uuid.Nil {
+		storage.ID = uuid.New()
+	}
 	if storage.SecretAccessKey != "" {
-		encrypted, err := s.crypto.Encrypt(storage.SecretAccessKey)
+		encrypted, err := s.crypto.Encrypt(ctx, storage.SecretAccessKey, secretAAD(storage))
 		if err != nil {
 			return errors.NewInternalServerError("failed to encrypt secret access key", err)
 		}
@@ -213,9 +400,9 @@ func (s *StorageService) encryptStorage(storage *domain.Storage) error {
 }
 
 // decryptStorage decrypts sensitive fields after loading from database
-func (s *StorageService) decryptStorage(storage *domain.Storage) error {
+func (s *StorageService) decryptStorage(ctx context.Context, storage *domain.Storage) error {
 	if storage.SecretAccessKey != "" {
-		decrypted, err := s.crypto.Decrypt(storage.SecretAccessKey)
+		decrypted, err := s.crypto.Decrypt(ctx, storage.SecretAccessKey, secretAAD(storage))
 		if err != nil {
 			return errors.NewInternalServerError("failed to decrypt secret access key", err)
 		}
@@ -224,5 +411,10 @@ func (s *StorageService) decryptStorage(storage *domain.Storage) error {
 	return nil
 }
 
+// secretAAD binds an encrypted secret to the row and column of its storage
+func secretAAD(storage *domain.Storage) []byte {
+	return crypto.AAD("storage", storage.ID.String(), "secret_access_key")
+}
+
 // Ensure StorageService implements domain.StorageService interface
 var _ domain.StorageService = (*StorageService)(nil)
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/providers"
+	"github.com/hantdev/chorus-controller/internal/s3check"
+)
+
+// storageCheckTimeout bounds all pre-flight checks of a storage
+const storageCheckTimeout = 15 * time.Second
+
+// TestStorage connects to the S3 endpoint of a stored storage with its credentials and reports the checks.
+// Failed checks are part of the report, not an error.
+func (s *StorageService) TestStorage(ctx context.Context, id string) (*domain.StorageTestReport, error) {
+	storage, err := s.getStorage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkStorage(ctx, storage), nil
+}
+
+// validateStorage fails with a bad request naming the first failed check
+func (s *StorageService) validateStorage(ctx context.Context, storage *domain.Storage) error {
+	report := s.checkStorage(ctx, storage)
+	if report.OK {
+		return nil
+	}
+	for _, c := range report.Checks {
+		if c.Status != domain.StorageCheckFailed {
+			continue
+		}
+		status := c.Code
+		if status == "" {
+			status = c.Name
+		}
+		return errors.NewBadRequestError(fmt.Sprintf("storage validation failed: %s: %s", c.Name, c.Message), nil).
+			WithUpstream(&errors.UpstreamError{
+				Source:    "s3",
+				Status:    status,
+				Message:   c.Message,
+				Retryable: c.Name == s3check.CheckConnectivity,
+			})
+	}
+	return nil
+}
+
+// checkStorage runs the pre-flight checks with the plain-text credentials of a storage
+func (s *StorageService) checkStorage(ctx context.Context, storage *domain.Storage) *domain.StorageTestReport {
+	return s.runCheck(ctx, storage, s3check.Run)
+}
+
+// runCheck runs check, s3check.Run or s3check.Probe, against a storage and reports its outcome
+func (s *StorageService) runCheck(ctx context.Context, storage *domain.Storage,
+	check func(context.Context, *http.Client, s3check.Target) *s3check.Result) *domain.StorageTestReport {
+	ctx, cancel := context.WithTimeout(ctx, storageCheckTimeout)
+	defer cancel()
+
+	provider, _ := providers.Lookup(storage.Provider)
+	res := check(ctx, s.httpClient, s3check.Target{
+		Address:         storage.Address,
+		Secure:          storage.IsSecure,
+		VirtualHost:     provider.Addressing == providers.VirtualHost,
+		Region:          storage.DefaultRegion,
+		AccessKeyID:     storage.AccessKeyID,
+		SecretAccessKey: storage.SecretAccessKey,
+	})
+
+	report := &domain.StorageTestReport{
+		Storage:   storage.Name,
+		Endpoint:  res.Endpoint,
+		Region:    res.Region,
+		OK:        res.OK(),
+		LatencyMs: res.Latency.Milliseconds(),
+		Buckets:   res.Buckets,
+		Checks:    make([]domain.StorageCheck, 0, len(res.Checks)),
+		CheckedAt: time.Now().UTC(),
+	}
+	for _, c := range res.Checks {
+		report.Checks = append(report.Checks, domain.StorageCheck{Name: c.Name, Status: c.Status, Message: c.Message, Code: c.Code})
+	}
+	return report
+}
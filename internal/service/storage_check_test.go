@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hantdev/chorus-controller/internal/crypto"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/fakes3"
+)
+
+func TestValidateStorage(t *testing.T) {
+	s3 := fakes3.New("us-east-1")
+	s3.AddUser("access", "secret")
+	s3.AddBucket("photos", "")
+	srv := httptest.NewServer(s3)
+	defer srv.Close()
+
+	cipher, err := crypto.New("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewStorageService(nil, cipher)
+	storage := &domain.Storage{Name: "main", Address: srv.URL, AccessKeyID: "access", SecretAccessKey: "secret"}
+
+	report := svc.checkStorage(context.Background(), storage)
+	if !report.OK || report.Buckets != 1 || report.Region != "us-east-1" || len(report.Checks) != 5 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if err := svc.validateStorage(context.Background(), storage); err != nil {
+		t.Fatalf("expected valid storage, got %v", err)
+	}
+
+	storage.SecretAccessKey = "typo"
+	err = svc.validateStorage(context.Background(), storage)
+	var apiErr *errors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, got %v", err)
+	}
+	if apiErr.Upstream == nil || apiErr.Upstream.Source != "s3" || apiErr.Upstream.Status != "SignatureDoesNotMatch" {
+		t.Fatalf("unexpected upstream: %+v", apiErr.Upstream)
+	}
+}
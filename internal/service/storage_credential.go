@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/crypto"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ListStorageCredentials lists the users of a storage with masked secrets, the storage's own user first
+func (s *StorageService) ListStorageCredentials(ctx context.Context, id string) ([]domain.StorageCredential, error) {
+	storage, err := s.getStorage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := s.listCredentials(ctx, storage.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	creds = append([]domain.StorageCredential{primaryCredential(storage)}, creds...)
+	for i := range creds {
+		maskCredential(&creds[i])
+	}
+	return creds, nil
+}
+
+// CreateStorageCredential adds a user to a storage
+func (s *StorageService) CreateStorageCredential(ctx context.Context, id string, req *domain.CreateStorageCredentialRequest) (*domain.StorageCredential, error) {
+	storage, err := s.getStorage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.User == storage.User {
+		return nil, errors.NewConflictError(fmt.Sprintf("user %q is the storage's own user", req.User), nil)
+	}
+
+	cred := &domain.StorageCredential{
+		StorageID:       storage.ID,
+		User:            req.User,
+		AccessKeyID:     req.AccessKey,
+		SecretAccessKey: req.SecretKey,
+	}
+	if err := s.encryptCredential(ctx, cred); err != nil {
+		return nil, err
+	}
+	if err := s.credentialRepo.Create(ctx, cred); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, errors.NewConflictError(fmt.Sprintf("storage already has user %q", req.User), err)
+		}
+		return nil, errors.NewInternalServerError("failed to save storage user", err)
+	}
+
+	cred.SecretAccessKey = req.SecretKey
+	maskCredential(cred)
+	return cred, nil
+}
+
+// UpdateStorageCredential replaces the keys of a user of a storage, the storage's own user included
+func (s *StorageService) UpdateStorageCredential(ctx context.Context, id, user string, req *domain.UpdateStorageCredentialRequest) error {
+	storage, err := s.getStorage(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user == storage.User {
+		return s.patchStorage(ctx, id, &domain.UpdateStorageRequest{AccessKey: &req.AccessKey, SecretKey: &req.SecretKey})
+	}
+
+	cred, err := s.credentialRepo.Get(ctx, storage.ID, user)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.NewNotFoundError("storage user not found", err)
+		}
+		return err
+	}
+	cred.AccessKeyID = req.AccessKey
+	// A masked secret sent back from a read endpoint keeps the stored one
+	if req.SecretKey != domain.MaskedSecret {
+		cred.SecretAccessKey = req.SecretKey
+		if err := s.encryptCredential(ctx, cred); err != nil {
+			return err
+		}
+	}
+	if err := s.credentialRepo.Update(ctx, cred); err != nil {
+		return errors.NewInternalServerError("failed to save storage user", err)
+	}
+	return nil
+}
+
+// DeleteStorageCredential removes a user from a storage.
+// The storage's own user and users with replication jobs on the storage cannot be removed.
+func (s *StorageService) DeleteStorageCredential(ctx context.Context, id, user string) error {
+	storage, err := s.getStorage(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user == storage.User {
+		return errors.NewConflictError(fmt.Sprintf("user %q is the storage's own user, change the storage user first", user), nil)
+	}
+
+	jobs, err := s.credentialRepo.Delete(ctx, storage.ID, user)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.NewNotFoundError("storage user not found", err)
+		}
+		if errors.Is(err, repository.ErrCredentialInUse) {
+			return errors.NewConflictError(fmt.Sprintf("user %q has %d replication jobs on the storage, delete them first", user, len(jobs)), err).
+				WithDetails(map[string]any{"jobs": jobs})
+		}
+		return err
+	}
+	return nil
+}
+
+// listCredentials lists the credentials of a storage with decrypted secrets
+func (s *StorageService) listCredentials(ctx context.Context, storageID uuid.UUID) ([]domain.StorageCredential, error) {
+	creds, err := s.credentialRepo.List(ctx, storageID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range creds {
+		if err := s.decryptCredential(ctx, &creds[i]); err != nil {
+			return nil, err
+		}
+	}
+	return creds, nil
+}
+
+// credentialsByStorage returns the credentials of every storage with decrypted secrets, grouped by storage ID
+func (s *StorageService) credentialsByStorage(ctx context.Context) (map[uuid.UUID][]domain.StorageCredential, error) {
+	creds, err := s.credentialRepo.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byStorage := make(map[uuid.UUID][]domain.StorageCredential)
+	for i := range creds {
+		if err := s.decryptCredential(ctx, &creds[i]); err != nil {
+			return nil, err
+		}
+		byStorage[creds[i].StorageID] = append(byStorage[creds[i].StorageID], creds[i])
+	}
+	return byStorage, nil
+}
+
+// checkUserRename rejects changing the storage's own user to one of its credentials
+func (s *StorageService) checkUserRename(ctx context.Context, storage *domain.Storage, user string) error {
+	if user == storage.User {
+		return nil
+	}
+	_, err := s.credentialRepo.Get(ctx, storage.ID, user)
+	switch {
+	case err == nil:
+		return errors.NewConflictError(fmt.Sprintf("storage already has user %q, delete it from the credentials first", user), nil)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+// primaryCredential returns the storage's own user as a credential
+func primaryCredential(storage *domain.Storage) domain.StorageCredential {
+	return domain.StorageCredential{
+		StorageID:       storage.ID,
+		User:            storage.User,
+		AccessKeyID:     storage.AccessKeyID,
+		SecretAccessKey: storage.SecretAccessKey,
+		Primary:         true,
+	}
+}
+
+// maskCredential replaces the plain-text secret of a credential with a mask and its fingerprint
+func maskCredential(cred *domain.StorageCredential) {
+	cred.SecretFingerprint = SecretFingerprint(cred.SecretAccessKey)
+	if cred.SecretAccessKey != "" {
+		cred.SecretAccessKey = domain.MaskedSecret
+	}
+}
+
+// encryptCredential encrypts the secret of a credential, new credentials get their ID here
+func (s *StorageService) encryptCredential(ctx context.Context, cred *domain.StorageCredential) error {
+	if cred.ID == uuid.Nil {
+		cred.ID = uuid.New()
+	}
+	encrypted, err := s.crypto.Encrypt(ctx, cred.SecretAccessKey, credentialAAD(cred))
+	if err != nil {
+		return errors.NewInternalServerError("failed to encrypt secret access key", err)
+	}
+	cred.SecretAccessKey = encrypted
+	return nil
+}
+
+// decryptCredential decrypts the secret of a credential loaded from the database
+func (s *StorageService) decryptCredential(ctx context.Context, cred *domain.StorageCredential) error {
+	decrypted, err := s.crypto.Decrypt(ctx, cred.SecretAccessKey, credentialAAD(cred))
+	if err != nil {
+		return errors.NewInternalServerError("failed to decrypt secret access key", err)
+	}
+	cred.SecretAccessKey = decrypted
+	return nil
+}
+
+// credentialAAD binds an encrypted secret to the row and column of its credential
+func credentialAAD(cred *domain.StorageCredential) []byte {
+	return crypto.AAD("storage_credential", cred.ID.String(), "secret_access_key")
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/metrics"
+)
+
+// defaultDriftEventLimit is the number of drift events listed when none is requested
+const defaultDriftEventLimit = 100
+
+var (
+	driftGauge = metrics.Default.Gauge("chorus_controller_storage_drifts",
+		"Differences between the storage table and the storages of a worker found by the last check.", "worker", "kind")
+	driftEventsCounter = metrics.Default.Counter("chorus_controller_storage_drift_events_total",
+		"Drift events recorded by the periodic check.", "kind")
+	driftChecksCounter = metrics.Default.Counter("chorus_controller_storage_drift_checks_total",
+		"Periodic storage drift checks by result.", "result")
+	driftLastCheckGauge = metrics.Default.Gauge("chorus_controller_storage_drift_last_check_timestamp_seconds",
+		"Time of the last successful storage drift check.")
+)
+
+// DetectDrift compares the stored storages with the storages configured on every worker.
+// Every worker is expected to serve all stored storages, as rendered by ExportWorkerConfig.
+func (s *StorageService) DetectDrift(ctx context.Context) (*domain.StorageDriftReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	stored, err := s.storageRepo.List(ctx)
+	if err != nil {
+		return nil, errors.NewInternalServerError("failed to list storages", err)
+	}
+
+	names, err := s.workers.WorkerNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu          sync.Mutex
+		wg          sync.WaitGroup
+		configured  = make(map[string][]*pb.Storage, len(names))
+		unreachable = make(map[string]string)
+		lastErr     error
+	)
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			client, err := s.workers.Resolve(ctx, name)
+			var resp *pb.GetStoragesResponse
+			if err == nil {
+				resp, err = client.GetStorages(ctx)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				// Storages of unreachable workers are unknown, comparing them would report false drift
+				unreachable[name] = err.Error()
+				lastErr = err
+				return
+			}
+			configured[name] = resp.Storages
+		}(name)
+	}
+	wg.Wait()
+
+	if len(names) > 0 && len(unreachable) == len(names) {
+		return nil, workerError("failed to get storages", lastErr)
+	}
+
+	report := &domain.StorageDriftReport{
+		CheckedAt: time.Now().UTC(),
+		Workers:   make([]string, 0, len(configured)),
+		Drifts:    []domain.StorageDrift{},
+	}
+	for name := range configured {
+		report.Workers = append(report.Workers, name)
+	}
+	sort.Strings(report.Workers)
+	for _, name := range report.Workers {
+		report.Drifts = append(report.Drifts, compareStorages(name, stored, configured[name])...)
+	}
+	if len(unreachable) > 0 {
+		report.UnreachableWorkers = unreachable
+	}
+	report.InSync = len(report.Drifts) == 0
+	return report, nil
+}
+
+// ListDriftEvents lists drift events recorded by the periodic check, newest first
+func (s *StorageService) ListDriftEvents(ctx context.Context, filter *domain.DriftEventFilter) ([]domain.StorageDriftEvent, error) {
+	if filter.Limit == 0 {
+		filter.Limit = defaultDriftEventLimit
+	}
+	events, err := s.driftRepo.List(ctx, filter)
+	if err != nil {
+		return nil, errors.NewInternalServerError("failed to list drift events", err)
+	}
+	return events, nil
+}
+
+// RecordDrift stores an event for every new drift of the report and resolves the events of drifts which are gone.
+// Drifts of unreachable workers are left open, their state is unknown.
+func (s *StorageService) RecordDrift(ctx context.Context, report *domain.StorageDriftReport) error {
+	open, err := s.driftRepo.List(ctx, &domain.DriftEventFilter{Open: true})
+	if err != nil {
+		return err
+	}
+	known := make(map[string]domain.StorageDriftEvent, len(open))
+	for _, e := range open {
+		known[driftKey(e.Worker, e.Storage, e.Kind)] = e
+	}
+
+	for _, d := range report.Drifts {
+		key := driftKey(d.Worker, d.Storage, d.Kind)
+		if _, ok := known[key]; ok {
+			delete(known, key)
+			continue
+		}
+		event := &domain.StorageDriftEvent{
+			Worker:      d.Worker,
+			Storage:     d.Storage,
+			Kind:        d.Kind,
+			DBValue:     d.DBValue,
+			WorkerValue: d.WorkerValue,
+			DetectedAt:  report.CheckedAt,
+		}
+		if err := s.driftRepo.Create(ctx, event); err != nil {
+			return err
+		}
+		driftEventsCounter.Inc(d.Kind)
+		log.Printf("Warning: storage drift on worker %q: storage %q %s (db %q, worker %q)",
+			d.Worker, d.Storage, d.Kind, d.DBValue, d.WorkerValue)
+	}
+
+	var resolved []uuid.UUID
+	for _, e := range known {
+		if _, ok := report.UnreachableWorkers[e.Worker]; ok {
+			continue
+		}
+		resolved = append(resolved, e.ID)
+	}
+	return s.driftRepo.Resolve(ctx, resolved, report.CheckedAt)
+}
+
+// DriftMonitor periodically checks the storages for drift and records drift events
+type DriftMonitor struct {
+	storages *StorageService
+	interval time.Duration
+}
+
+// NewDriftMonitor creates a drift monitor running every interval
+func NewDriftMonitor(storages *StorageService, interval time.Duration) *DriftMonitor {
+	return &DriftMonitor{storages: storages, interval: interval}
+}
+
+// Run checks for drift until ctx is done
+func (m *DriftMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.check(ctx); err != nil && ctx.Err() == nil {
+			driftChecksCounter.Inc("error")
+			log.Printf("Warning: storage drift check failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *DriftMonitor) check(ctx context.Context) error {
+	report, err := m.storages.DetectDrift(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.storages.RecordDrift(ctx, report); err != nil {
+		return err
+	}
+
+	driftGauge.Reset()
+	for _, worker := range report.Workers {
+		// Export zero samples so that alerts can tell "no drift" from "not checked"
+		for _, kind := range driftKinds {
+			driftGauge.Set(0, worker, kind)
+		}
+	}
+	for _, d := range report.Drifts {
+		driftGauge.Inc(d.Worker, d.Kind)
+	}
+	driftChecksCounter.Inc("ok")
+	driftLastCheckGauge.Set(float64(report.CheckedAt.Unix()))
+	return nil
+}
+
+// driftKinds lists every drift kind
+var driftKinds = []string{
+	domain.DriftMissingInWorker,
+	domain.DriftMissingInDB,
+	domain.DriftAddress,
+	domain.DriftProvider,
+	domain.DriftMain,
+}
+
+// compareStorages reports the differences between the stored storages and the storages of a worker
+func compareStorages(worker string, stored []domain.Storage, configured []*pb.Storage) []domain.StorageDrift {
+	byName := make(map[string]*pb.Storage, len(configured))
+	for _, st := range configured {
+		byName[st.Name] = st
+	}
+
+	var drifts []domain.StorageDrift
+	seen := make(map[string]struct{}, len(stored))
+	for i := range stored {
+		db := &stored[i]
+		seen[db.Name] = struct{}{}
+
+		st, ok := byName[db.Name]
+		if !ok {
+			drifts = append(drifts, domain.StorageDrift{Worker: worker, Storage: db.Name, Kind: domain.DriftMissingInWorker, DBValue: db.Address})
+			continue
+		}
+		if normalizeAddress(db.Address, db.IsSecure) != normalizeAddress(st.Address, db.IsSecure) {
+			drifts = append(drifts, domain.StorageDrift{Worker: worker, Storage: db.Name, Kind: domain.DriftAddress, DBValue: db.Address, WorkerValue: st.Address})
+		}
+		if !sameProvider(db.Provider, st.Provider) {
+			drifts = append(drifts, domain.StorageDrift{Worker: worker, Storage: db.Name, Kind: domain.DriftProvider, DBValue: db.Provider, WorkerValue: st.Provider.String()})
+		}
+		if db.IsMain != st.IsMain {
+			drifts = append(drifts, domain.StorageDrift{Worker: worker, Storage: db.Name, Kind: domain.DriftMain,
+				DBValue: fmt.Sprint(db.IsMain), WorkerValue: fmt.Sprint(st.IsMain)})
+		}
+	}
+	for _, st := range configured {
+		if _, ok := seen[st.Name]; !ok {
+			drifts = append(drifts, domain.StorageDrift{Worker: worker, Storage: st.Name, Kind: domain.DriftMissingInDB, WorkerValue: st.Address})
+		}
+	}
+
+	sort.SliceStable(drifts, func(i, j int) bool {
+		if drifts[i].Storage != drifts[j].Storage {
+			return drifts[i].Storage < drifts[j].Storage
+		}
+		return drifts[i].Kind < drifts[j].Kind
+	})
+	return drifts
+}
+
+// normalizeAddress makes addresses comparable, the worker adds the scheme when it is missing
+func normalizeAddress(address string, secure bool) string {
+	a := strings.ToLower(strings.TrimSpace(address))
+	a = strings.TrimSuffix(a, "/")
+	if !strings.Contains(a, "://") {
+		if secure {
+			a = "https://" + a
+		} else {
+			a = "http://" + a
+		}
+	}
+	return a
+}
+
+// sameProvider compares a stored provider with the provider reported by a worker.
+// Providers unknown to the worker protocol are reported as Other.
+func sameProvider(stored string, reported pb.Storage_Provider) bool {
+	if strings.EqualFold(stored, reported.String()) {
+		return true
+	}
+	for name := range pb.Storage_Provider_value {
+		if strings.EqualFold(stored, name) {
+			return false
+		}
+	}
+	return reported == pb.Storage_Other
+}
+
+func driftKey(worker, storage, kind string) string {
+	return worker + "\x00" + storage + "\x00" + kind
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/hantdev/chorus-controller/internal/domain"
+)
+
+func TestCompareStorages(t *testing.T) {
+	_, client := newFakeWorkerService(t)
+	resp, err := client.GetStorages(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := []domain.Storage{
+		// Same storage, address written without scheme and provider in another case
+		{Name: "main", Address: "MAIN.s3.local/", Provider: "ceph", IsMain: true},
+		// Moved to another address and no longer the main storage on the worker side
+		{Name: "follower", Address: "other.s3.local", Provider: "Minio", IsMain: true},
+		{Name: "archive", Address: "archive.s3.local", Provider: "Minio"},
+	}
+
+	drifts := compareStorages("default", stored, resp.Storages)
+	want := []domain.StorageDrift{
+		{Worker: "default", Storage: "archive", Kind: domain.DriftMissingInWorker, DBValue: "archive.s3.local"},
+		{Worker: "default", Storage: "follower", Kind: domain.DriftAddress, DBValue: "other.s3.local", WorkerValue: "follower.s3.local"},
+		{Worker: "default", Storage: "follower", Kind: domain.DriftMain, DBValue: "true", WorkerValue: "false"},
+	}
+	if len(drifts) != len(want) {
+		t.Fatalf("expected %d drifts, got %+v", len(want), drifts)
+	}
+	for i := range want {
+		if drifts[i] != want[i] {
+			t.Errorf("drift %d: expected %+v, got %+v", i, want[i], drifts[i])
+		}
+	}
+
+	drifts = compareStorages("default", stored[:1], resp.Storages)
+	if len(drifts) != 1 || drifts[0].Kind != domain.DriftMissingInDB || drifts[0].Storage != "follower" {
+		t.Fatalf("expected follower missing in db, got %+v", drifts)
+	}
+}
+
+func TestSameProvider(t *testing.T) {
+	for _, tc := range []struct {
+		stored   string
+		reported pb.Storage_Provider
+		want     bool
+	}{
+		{"Ceph", pb.Storage_Ceph, true},
+		{"CEPH", pb.Storage_Ceph, true},
+		{"Minio", pb.Storage_Ceph, false},
+		{"SeaweedFS", pb.Storage_Other, true},
+		{"Ceph", pb.Storage_Other, false},
+	} {
+		if got := sameProvider(tc.stored, tc.reported); got != tc.want {
+			t.Errorf("sameProvider(%q, %s) = %v, want %v", tc.stored, tc.reported, got, tc.want)
+		}
+	}
+}
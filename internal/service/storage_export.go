@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/labels"
+	"github.com/hantdev/chorus-controller/internal/workerconfig"
+)
+
+// ExportWorkerConfig renders the stored storages, or those matching the selector, as the storage section of the worker config.
+// Secrets are decrypted, the output must be handled like the worker's own config file.
+// Every export is recorded first, no config is returned when the audit record cannot be written.
+func (s *StorageService) ExportWorkerConfig(ctx context.Context, req *domain.ExportStoragesRequest, actor *domain.AuditActor) ([]byte, error) {
+	sel, err := labels.Parse(req.Selector)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error(), err)
+	}
+	storages, err := s.listStorages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	storages = selectStorages(storages, sel)
+	creds, err := s.credentialsByStorage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &workerconfig.StorageConfig{
+		CreateRouting:     req.CreateRouting,
+		CreateReplication: req.CreateReplication,
+		DefaultRegion:     req.DefaultRegion,
+		Storages:          make(map[string]workerconfig.Storage, len(storages)),
+	}
+	for i := range storages {
+		cfg.Storages[storages[i].Name] = workerStorageOf(&storages[i], creds[storages[i].ID])
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.NewConflictError("storages cannot be loaded by the worker: "+err.Error(), err)
+	}
+
+	out, err := workerconfig.Marshal(&workerconfig.File{Storage: cfg})
+	if err != nil {
+		return nil, errors.NewInternalServerError("failed to render worker config", err)
+	}
+
+	resource := "storages"
+	if req.Selector != "" {
+		resource += "?selector=" + req.Selector
+	}
+	if len(resource) > 255 {
+		// The size of the resource column
+		resource = resource[:255]
+	}
+	if err := s.audit(ctx, domain.AuditExportStorages, resource, actor); err != nil {
+		return nil, errors.NewInternalServerError("failed to record the storage export", err)
+	}
+	return out, nil
+}
+
+// workerStorageOf converts a decrypted storage and its credentials into its worker config entry
+func workerStorageOf(st *domain.Storage, creds []domain.StorageCredential) workerconfig.Storage {
+	credentials := map[string]workerconfig.Credentials{
+		st.User: {
+			AccessKeyID:     st.AccessKeyID,
+			SecretAccessKey: st.SecretAccessKey,
+		},
+	}
+	for _, c := range creds {
+		credentials[c.User] = workerconfig.Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey}
+	}
+
+	return workerconfig.Storage{
+		Address:             st.Address,
+		Credentials:         credentials,
+		Provider:            st.Provider,
+		IsMain:              st.IsMain,
+		HealthCheckInterval: workerconfig.Duration(time.Duration(st.HealthCheckIntervalMs) * time.Millisecond),
+		HttpTimeout:         workerconfig.Duration(time.Duration(st.HttpTimeoutMs) * time.Millisecond),
+		IsSecure:            st.IsSecure,
+		DefaultRegion:       st.DefaultRegion,
+		RateLimit: workerconfig.RateLimit{
+			Enabled: st.RateLimitEnabled,
+			RPM:     st.RateLimitRPM,
+		},
+	}
+}
+
+// selectStorages returns the storages whose labels match the selector
+func selectStorages(storages []domain.Storage, sel labels.Selector) []domain.Storage {
+	if len(sel) == 0 {
+		return storages
+	}
+	var selected []domain.Storage
+	for _, st := range storages {
+		if sel.Matches(st.Labels) {
+			selected = append(selected, st)
+		}
+	}
+	return selected
+}
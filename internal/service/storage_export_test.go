@@ -0,0 +1,63 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/labels"
+	"github.com/hantdev/chorus-controller/internal/workerconfig"
+)
+
+func TestWorkerStorageOf(t *testing.T) {
+	st := workerStorageOf(&domain.Storage{
+		Name:                  "main",
+		Address:               "https://s3.example.com",
+		Provider:              "Ceph",
+		IsMain:                true,
+		IsSecure:              true,
+		HealthCheckIntervalMs: 5000,
+		HttpTimeoutMs:         300000,
+		RateLimitEnabled:      true,
+		RateLimitRPM:          600,
+		User:                  "admin",
+		AccessKeyID:           "access",
+		SecretAccessKey:       "secret",
+	}, []domain.StorageCredential{{User: "backup", AccessKeyID: "backup-access", SecretAccessKey: "backup-secret"}})
+
+	cfg := &workerconfig.StorageConfig{Storages: map[string]workerconfig.Storage{"main": st}}
+	out, err := workerconfig.Marshal(&workerconfig.File{Storage: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"healthCheckInterval: 5s",
+		"httpTimeout: 5m0s",
+		"secretAccessKey: secret",
+		"secretAccessKey: backup-secret",
+		"rpm: 600",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestSelectStorages(t *testing.T) {
+	storages := []domain.Storage{
+		{Name: "a", Labels: map[string]string{"env": "prod", "region": "eu"}},
+		{Name: "b", Labels: map[string]string{"env": "prod", "region": "us"}},
+		{Name: "c", Labels: map[string]string{"env": "dev"}},
+		{Name: "d"},
+	}
+	sel, err := labels.Parse("env=prod,region!=eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected := selectStorages(storages, sel); len(selected) != 1 || selected[0].Name != "b" {
+		t.Fatalf("expected b, got %+v", selected)
+	}
+	if selected := selectStorages(storages, nil); len(selected) != 4 {
+		t.Fatalf("expected every storage without selector, got %+v", selected)
+	}
+}
@@ -0,0 +1,413 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/metrics"
+	"github.com/hantdev/chorus-controller/internal/s3check"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultHealthWindow is the uptime window when none is requested
+	defaultHealthWindow = 24 * time.Hour
+	// maxHealthWindow bounds the uptime window
+	maxHealthWindow = 30 * 24 * time.Hour
+	// defaultHealthHistoryLimit is the number of probes listed when none is requested
+	defaultHealthHistoryLimit = 100
+	// healthTick is how often the health monitor looks for storages due for a probe
+	healthTick = time.Second
+	// healthListInterval is how often the health monitor reloads the storages
+	healthListInterval = 30 * time.Second
+	// healthPruneInterval is how often probes older than the retention are deleted
+	healthPruneInterval = time.Hour
+	// maxHealthErrorLength is the size of the error column
+	maxHealthErrorLength = 1024
+)
+
+var (
+	storageUpGauge = metrics.Default.Gauge("chorus_controller_storage_up",
+		"Whether the last probe of a storage's S3 endpoint succeeded.", "storage")
+	storageProbeLatencyGauge = metrics.Default.Gauge("chorus_controller_storage_probe_latency_seconds",
+		"Latency of the last probe of a storage's S3 endpoint.", "storage")
+	storageProbesCounter = metrics.Default.Counter("chorus_controller_storage_probes_total",
+		"Probes of storage S3 endpoints by state.", "storage", "state")
+	storageHealthChangesCounter = metrics.Default.Counter("chorus_controller_storage_health_changes_total",
+		"State changes of storage S3 endpoints by new state.", "storage", "state")
+)
+
+// GetStorageHealth returns the state of a storage's S3 endpoint, its uptime in a window and its recent probes
+func (s *StorageService) GetStorageHealth(ctx context.Context, id string, req *domain.StorageHealthRequest) (*domain.StorageHealth, error) {
+	storageID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid storage ID format", err)
+	}
+	window := req.Window
+	if window == 0 {
+		window = defaultHealthWindow
+	}
+	if window > maxHealthWindow {
+		return nil, errors.NewBadRequestError(fmt.Sprintf("window must not exceed %s", maxHealthWindow), nil)
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultHealthHistoryLimit
+	}
+
+	storage, err := s.storageRepo.GetByID(ctx, storageID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NewNotFoundError("storage not found", err)
+		}
+		return nil, err
+	}
+
+	history, err := s.healthRepo.List(ctx, storage.ID, limit, req.Changes)
+	if err != nil {
+		return nil, errors.NewInternalServerError("failed to list health checks", err)
+	}
+	latest, err := s.healthRepo.Latest(ctx, storage.ID, false)
+	if err != nil {
+		return nil, errors.NewInternalServerError("failed to get the latest health check", err)
+	}
+	change, err := s.healthRepo.Latest(ctx, storage.ID, true)
+	if err != nil {
+		return nil, errors.NewInternalServerError("failed to get the latest health change", err)
+	}
+	total, up, err := s.healthRepo.Uptime(ctx, storage.ID, time.Now().Add(-window))
+	if err != nil {
+		return nil, errors.NewInternalServerError("failed to compute uptime", err)
+	}
+	return healthOf(storage, window, latest, change, total, up, history), nil
+}
+
+// healthOf assembles the health of a storage from its latest probe and state change and the probe counts of the window
+func healthOf(storage *domain.Storage, window time.Duration, latest, change *domain.StorageHealthCheck, total, up int64, history []domain.StorageHealthCheck) *domain.StorageHealth {
+	health := &domain.StorageHealth{
+		StorageID: storage.ID,
+		Storage:   storage.Name,
+		Status:    domain.StorageHealthUnknown,
+		Window:    window.String(),
+		Checks:    total,
+		History:   history,
+	}
+	if health.History == nil {
+		health.History = []domain.StorageHealthCheck{}
+	}
+	if latest != nil {
+		health.Status = healthState(latest.Up)
+		health.LastCheckedAt = &latest.CheckedAt
+	}
+	if change != nil {
+		health.Since = &change.CheckedAt
+	}
+	if total > 0 {
+		uptime := float64(up) * 100 / float64(total)
+		health.UptimePercent = &uptime
+	}
+	return health
+}
+
+// probeStorage checks the S3 endpoint of a storage read from the database with a single ListBuckets request
+func (s *StorageService) probeStorage(ctx context.Context, storage *domain.Storage) (*domain.StorageHealthCheck, error) {
+	if err := s.decryptStorage(ctx, storage); err != nil {
+		return nil, err
+	}
+	report := s.runCheck(ctx, storage, s3check.Probe)
+	if ctx.Err() != nil {
+		// Shutting down, the endpoint was not the problem
+		return nil, ctx.Err()
+	}
+	return &domain.StorageHealthCheck{
+		StorageID: storage.ID,
+		Up:        report.OK,
+		LatencyMs: report.LatencyMs,
+		Error:     healthError(report),
+		CheckedAt: report.CheckedAt,
+	}, nil
+}
+
+// healthError describes the first failed check of a report, empty when none failed
+func healthError(report *domain.StorageTestReport) string {
+	for _, c := range report.Checks {
+		if c.Status != domain.StorageCheckFailed {
+			continue
+		}
+		msg := c.Name + ": " + c.Message
+		if len(msg) > maxHealthErrorLength {
+			msg = msg[:maxHealthErrorLength]
+		}
+		return msg
+	}
+	return ""
+}
+
+func healthState(up bool) string {
+	if up {
+		return domain.StorageHealthUp
+	}
+	return domain.StorageHealthDown
+}
+
+// HealthMonitor probes the S3 endpoint of every stored storage at its health check interval and records the probes.
+// The interval is meant for the worker, the monitor probes a storage at most once per minInterval.
+// At most concurrency probes run at a time, a storage is not probed again while its previous probe runs.
+type HealthMonitor struct {
+	storages    *StorageService
+	concurrency int
+	retention   time.Duration
+	minInterval time.Duration
+
+	mu      sync.Mutex
+	up      map[uuid.UUID]bool
+	probing map[uuid.UUID]bool
+}
+
+// NewHealthMonitor creates a health monitor running up to concurrency probes at a time.
+// Probes older than retention are deleted, 0 keeps them.
+func NewHealthMonitor(storages *StorageService, concurrency int, retention, minInterval time.Duration) *HealthMonitor {
+	return &HealthMonitor{
+		storages:    storages,
+		concurrency: concurrency,
+		retention:   retention,
+		minInterval: minInterval,
+		up:          make(map[uuid.UUID]bool),
+		probing:     make(map[uuid.UUID]bool),
+	}
+}
+
+// Run probes the storages until ctx is done and waits for the running probes
+func (m *HealthMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(healthTick)
+	defer ticker.Stop()
+
+	var (
+		wg        sync.WaitGroup
+		sem       = make(chan struct{}, m.concurrency)
+		schedule  = newHealthSchedule(m.minInterval)
+		loaded    bool
+		failing   bool
+		storages  []domain.Storage
+		nextList  time.Time
+		nextPrune time.Time
+	)
+	defer wg.Wait()
+
+	for {
+		now := time.Now()
+		if !loaded {
+			loaded = m.load(ctx)
+		}
+		if loaded && !now.Before(nextList) {
+			// The storages are cached between reloads, a failed reload is retried on the next tick
+			list, err := m.storages.storageRepo.List(ctx)
+			if err != nil {
+				// Log once per outage rather than every tick
+				if !failing && ctx.Err() == nil {
+					log.Printf("Warning: storage health monitor failed to list storages: %v", err)
+				}
+				failing = true
+			} else {
+				failing = false
+				storages = list
+				nextList = now.Add(healthListInterval)
+			}
+		}
+		due, stale := schedule.due(storages, now)
+		m.forget(storages, stale)
+		for _, st := range due {
+			if !m.start(st.ID) {
+				continue
+			}
+			wg.Add(1)
+			go func(st domain.Storage) {
+				defer wg.Done()
+				defer m.finish(st.ID)
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				defer func() { <-sem }()
+				m.probe(ctx, &st)
+			}(st)
+		}
+		if m.retention > 0 && !now.Before(nextPrune) {
+			m.prune(ctx, now)
+			nextPrune = now.Add(healthPruneInterval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// load restores the last state of every storage, so that a restart does not record state changes
+func (m *HealthMonitor) load(ctx context.Context) bool {
+	latest, err := m.storages.healthRepo.LatestAll(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Warning: storage health monitor failed to load the last probes: %v", err)
+		}
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range latest {
+		m.up[c.StorageID] = c.Up
+	}
+	return true
+}
+
+// start marks a storage as being probed, false when it already is
+func (m *HealthMonitor) start(id uuid.UUID) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.probing[id] {
+		return false
+	}
+	m.probing[id] = true
+	return true
+}
+
+func (m *HealthMonitor) finish(id uuid.UUID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.probing, id)
+}
+
+// forget drops the state of deleted storages and the metrics of deleted or renamed ones
+func (m *HealthMonitor) forget(storages []domain.Storage, stale []string) {
+	for _, name := range stale {
+		storageUpGauge.Delete(name)
+		storageProbeLatencyGauge.Delete(name)
+		for _, state := range []string{domain.StorageHealthUp, domain.StorageHealthDown} {
+			storageProbesCounter.Delete(name, state)
+			storageHealthChangesCounter.Delete(name, state)
+		}
+	}
+
+	ids := make(map[uuid.UUID]struct{}, len(storages))
+	for _, st := range storages {
+		ids[st.ID] = struct{}{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := range m.up {
+		if _, ok := ids[id]; !ok {
+			delete(m.up, id)
+		}
+	}
+}
+
+// probe checks a storage, records the probe and reports state changes
+func (m *HealthMonitor) probe(ctx context.Context, storage *domain.Storage) {
+	check, err := m.storages.probeStorage(ctx, storage)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Warning: storage %q health probe failed: %v", storage.Name, err)
+		}
+		return
+	}
+
+	m.mu.Lock()
+	prev, known := m.up[storage.ID]
+	m.mu.Unlock()
+	check.Changed = !known || prev != check.Up
+
+	if err := m.storages.healthRepo.Create(ctx, check); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Warning: failed to record health probe of storage %q: %v", storage.Name, err)
+		}
+		return
+	}
+	m.mu.Lock()
+	m.up[storage.ID] = check.Up
+	m.mu.Unlock()
+
+	state := healthState(check.Up)
+	up := 0.0
+	if check.Up {
+		up = 1
+	}
+	storageUpGauge.Set(up, storage.Name)
+	storageProbeLatencyGauge.Set(float64(check.LatencyMs)/1000, storage.Name)
+	storageProbesCounter.Inc(storage.Name, state)
+
+	switch {
+	case known && check.Changed:
+		storageHealthChangesCounter.Inc(storage.Name, state)
+		if check.Up {
+			log.Printf("Storage %q is up again", storage.Name)
+		} else {
+			log.Printf("Warning: storage %q is down: %s", storage.Name, check.Error)
+		}
+	case !known && !check.Up:
+		log.Printf("Warning: storage %q is down: %s", storage.Name, check.Error)
+	}
+}
+
+func (m *HealthMonitor) prune(ctx context.Context, now time.Time) {
+	if _, err := m.storages.healthRepo.DeleteBefore(ctx, now.Add(-m.retention)); err != nil && ctx.Err() == nil {
+		log.Printf("Warning: failed to delete old storage health probes: %v", err)
+	}
+}
+
+// healthSchedule tracks when each storage is due for its next probe.
+// No storage is probed more often than minInterval.
+type healthSchedule struct {
+	minInterval time.Duration
+	next        map[uuid.UUID]time.Time
+	names       map[uuid.UUID]string
+}
+
+func newHealthSchedule(minInterval time.Duration) *healthSchedule {
+	return &healthSchedule{minInterval: minInterval, next: make(map[uuid.UUID]time.Time), names: make(map[uuid.UUID]string)}
+}
+
+// due returns the storages whose interval has passed and schedules their next probe.
+// New storages are due at once. It also returns the names of the storages which were deleted or renamed.
+func (h *healthSchedule) due(storages []domain.Storage, now time.Time) ([]domain.Storage, []string) {
+	var (
+		due   []domain.Storage
+		stale []string
+		seen  = make(map[uuid.UUID]struct{}, len(storages))
+	)
+	for _, st := range storages {
+		seen[st.ID] = struct{}{}
+		if name, ok := h.names[st.ID]; ok && name != st.Name {
+			stale = append(stale, name)
+		}
+		h.names[st.ID] = st.Name
+
+		interval := time.Duration(st.HealthCheckIntervalMs) * time.Millisecond
+		if interval <= 0 {
+			interval = time.Duration(domain.DefaultHealthCheckIntervalMs) * time.Millisecond
+		}
+		interval = max(interval, h.minInterval)
+		// A shortened interval applies at once
+		if next, ok := h.next[st.ID]; ok && now.Before(next) && next.Sub(now) <= interval {
+			continue
+		}
+		h.next[st.ID] = now.Add(interval)
+		due = append(due, st)
+	}
+	for id, name := range h.names {
+		if _, ok := seen[id]; !ok {
+			stale = append(stale, name)
+			delete(h.names, id)
+			delete(h.next, id)
+		}
+	}
+	return due, stale
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/domain"
+)
+
+func TestHealthSchedule(t *testing.T) {
+	now := time.Now()
+	fast := domain.Storage{ID: uuid.New(), Name: "fast", HealthCheckIntervalMs: 1000}
+	slow := domain.Storage{ID: uuid.New(), Name: "slow", HealthCheckIntervalMs: 60000}
+	h := newHealthSchedule(0)
+
+	// New storages are due at once
+	due, stale := h.due([]domain.Storage{fast, slow}, now)
+	if len(due) != 2 || len(stale) != 0 {
+		t.Fatalf("expected both storages due, got %v, stale %v", due, stale)
+	}
+	if due, _ = h.due([]domain.Storage{fast, slow}, now.Add(500*time.Millisecond)); len(due) != 0 {
+		t.Fatalf("expected no storage due, got %v", due)
+	}
+	if due, _ = h.due([]domain.Storage{fast, slow}, now.Add(time.Second)); len(due) != 1 || due[0].Name != "fast" {
+		t.Fatalf("expected fast due, got %v", due)
+	}
+
+	// A shortened interval applies at once
+	slow.HealthCheckIntervalMs = 1000
+	if due, _ = h.due([]domain.Storage{fast, slow}, now.Add(1500*time.Millisecond)); len(due) != 1 || due[0].Name != "slow" {
+		t.Fatalf("expected slow due, got %v", due)
+	}
+
+	// Renamed and deleted storages are stale
+	fast.Name = "faster"
+	due, stale = h.due([]domain.Storage{fast}, now.Add(3*time.Second))
+	if len(due) != 1 || !reflect.DeepEqual(stale, []string{"fast", "slow"}) {
+		t.Fatalf("expected fast and slow to be stale, got %v, due %v", stale, due)
+	}
+}
+
+func TestHealthScheduleMinInterval(t *testing.T) {
+	now := time.Now()
+	fast := domain.Storage{ID: uuid.New(), Name: "fast", HealthCheckIntervalMs: 1000}
+	h := newHealthSchedule(30 * time.Second)
+
+	if due, _ := h.due([]domain.Storage{fast}, now); len(due) != 1 {
+		t.Fatalf("expected fast due, got %v", due)
+	}
+	// The interval of the worker is shorter than the floor of the monitor
+	if due, _ := h.due([]domain.Storage{fast}, now.Add(10*time.Second)); len(due) != 0 {
+		t.Fatalf("expected no storage due before the minimum interval, got %v", due)
+	}
+	if due, _ := h.due([]domain.Storage{fast}, now.Add(30*time.Second)); len(due) != 1 {
+		t.Fatalf("expected fast due after the minimum interval, got %v", due)
+	}
+}
+
+func TestHealthOf(t *testing.T) {
+	storage := &domain.Storage{ID: uuid.New(), Name: "main"}
+
+	health := healthOf(storage, time.Hour, nil, nil, 0, 0, nil)
+	if health.Status != domain.StorageHealthUnknown || health.UptimePercent != nil || health.History == nil || health.Window != "1h0m0s" {
+		t.Fatalf("unexpected health without probes: %+v", health)
+	}
+
+	changed := time.Now().Add(-time.Minute)
+	latest := &domain.StorageHealthCheck{Up: false, CheckedAt: time.Now()}
+	health = healthOf(storage, time.Hour, latest, &domain.StorageHealthCheck{CheckedAt: changed}, 8, 6, nil)
+	if health.Status != domain.StorageHealthDown || !health.Since.Equal(changed) || !health.LastCheckedAt.Equal(latest.CheckedAt) {
+		t.Fatalf("unexpected health: %+v", health)
+	}
+	if health.Checks != 8 || *health.UptimePercent != 75 {
+		t.Fatalf("expected 75%% uptime of 8 probes, got %v of %d", *health.UptimePercent, health.Checks)
+	}
+}
+
+func TestHealthError(t *testing.T) {
+	report := &domain.StorageTestReport{Checks: []domain.StorageCheck{
+		{Name: "connectivity", Status: domain.StorageCheckOK},
+		{Name: "region", Status: domain.StorageCheckWarning, Message: "bucket regions differ"},
+	}}
+	if msg := healthError(report); msg != "" {
+		t.Fatalf("expected no error for warnings, got %q", msg)
+	}
+
+	report.Checks = append(report.Checks, domain.StorageCheck{Name: "authentication", Status: domain.StorageCheckFailed, Message: strings.Repeat("x", 2000)})
+	msg := healthError(report)
+	if !strings.HasPrefix(msg, "authentication: x") || len(msg) != maxHealthErrorLength {
+		t.Fatalf("expected a truncated authentication error, got %d bytes", len(msg))
+	}
+}
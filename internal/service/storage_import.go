@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/workerconfig"
+)
+
+// ImportStorages creates or updates the storages of a worker config file, matched by name.
+// Every user of a storage is stored, users missing from the file are kept like storages missing from it.
+// All changes are saved in one transaction, secrets are encrypted on the way.
+func (s *StorageService) ImportStorages(ctx context.Context, data []byte, opts *domain.ImportStoragesOptions) (*domain.StorageImportReport, error) {
+	cfg, err := workerconfig.Unmarshal(data)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid worker config: "+err.Error(), err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.NewBadRequestError("invalid worker config: "+err.Error(), err)
+	}
+
+	existing, err := s.listStorages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*domain.Storage, len(existing))
+	for i := range existing {
+		byName[existing[i].Name] = &existing[i]
+	}
+	existingCreds, err := s.credentialsByStorage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.StorageImportReport{DryRun: opts.DryRun, Storages: []domain.StorageImportEntry{}}
+	var (
+		create, update []*domain.Storage
+		creds          []*domain.StorageCredential
+	)
+	for _, name := range sortedKeys(cfg.Storages) {
+		ws := cfg.Storages[name]
+		current, ok := byName[name]
+		user := opts.User
+		if ok {
+			// Stored storages keep their own user
+			if _, found := ws.Credentials[current.User]; found {
+				user = current.User
+			}
+		}
+		imported, users, err := storageFromWorker(name, ws, cfg.DefaultRegion, user)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyProvider(imported, &ws.IsSecure); err != nil {
+			return nil, errors.NewBadRequestError(fmt.Sprintf("storage %q: %s", name, err.Error()), err)
+		}
+		if err := checkStorageSettings(imported); err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			imported.ID, imported.Labels = uuid.New(), map[string]string{}
+			for _, c := range users {
+				c.StorageID = imported.ID
+				creds = append(creds, c)
+			}
+			create = append(create, imported)
+			report.Created++
+			report.Storages = append(report.Storages, domain.StorageImportEntry{Name: name, Action: domain.StorageImportCreated})
+			continue
+		}
+
+		changed := changedStorageFields(current, imported)
+		changedCreds, err := changedCredentials(current, imported, existingCreds[current.ID], users)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range changedCreds {
+			changed = append(changed, "credentials."+c.User)
+		}
+		if len(changed) == 0 {
+			report.Unchanged++
+			report.Storages = append(report.Storages, domain.StorageImportEntry{Name: name, Action: domain.StorageImportUnchanged})
+			continue
+		}
+		creds = append(creds, changedCreds...)
+		if len(changed) > len(changedCreds) {
+			// The worker config has no description and labels, keep the stored ones
+			imported.ID, imported.Description, imported.Labels = current.ID, current.Description, current.Labels
+			update = append(update, imported)
+		}
+		report.Updated++
+		report.Storages = append(report.Storages, domain.StorageImportEntry{Name: name, Action: domain.StorageImportUpdated, Changed: changed})
+	}
+
+	if err := checkImportedMain(cfg, existing, opts.ReplaceMain); err != nil {
+		return nil, err
+	}
+	if opts.DryRun || len(create)+len(update)+len(creds) == 0 {
+		return report, nil
+	}
+
+	for _, storage := range append(append([]*domain.Storage(nil), create...), update...) {
+		if err := s.encryptStorage(ctx, storage); err != nil {
+			return nil, err
+		}
+	}
+	for _, cred := range creds {
+		if err := s.encryptCredential(ctx, cred); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.storageRepo.Upsert(ctx, create, update, creds); err != nil {
+		return nil, storageSaveError(err)
+	}
+	return report, nil
+}
+
+// storageFromWorker converts a worker config entry into a storage and the credentials of its other users.
+// user selects the storage's own user when the entry has several.
+func storageFromWorker(name string, ws workerconfig.Storage, defaultRegion, user string) (*domain.Storage, []*domain.StorageCredential, error) {
+	users := sortedKeys(ws.Credentials)
+	if user == "" {
+		if len(users) != 1 {
+			return nil, nil, errors.NewBadRequestError(fmt.Sprintf(
+				"storage %q has users %s, select the storage's own user with user", name, strings.Join(users, ", ")), nil)
+		}
+		user = users[0]
+	}
+	cred, ok := ws.Credentials[user]
+	if !ok {
+		return nil, nil, errors.NewBadRequestError(fmt.Sprintf("storage %q has no user %q", name, user), nil)
+	}
+
+	storage := &domain.Storage{
+		Name:                  name,
+		Address:               ws.Address,
+		Provider:              ws.Provider,
+		IsMain:                ws.IsMain,
+		IsSecure:              ws.IsSecure,
+		DefaultRegion:         ws.DefaultRegion,
+		HealthCheckIntervalMs: time.Duration(ws.HealthCheckInterval).Milliseconds(),
+		HttpTimeoutMs:         time.Duration(ws.HttpTimeout).Milliseconds(),
+		RateLimitEnabled:      ws.RateLimit.Enabled,
+		RateLimitRPM:          ws.RateLimit.RPM,
+		User:                  user,
+		AccessKeyID:           cred.AccessKeyID,
+		SecretAccessKey:       cred.SecretAccessKey,
+	}
+	// Store what the worker uses when the entry leaves it out
+	if storage.DefaultRegion == "" {
+		storage.DefaultRegion = defaultRegion
+	}
+	if storage.HealthCheckIntervalMs == 0 {
+		storage.HealthCheckIntervalMs = domain.DefaultHealthCheckIntervalMs
+	}
+	if storage.HttpTimeoutMs == 0 {
+		storage.HttpTimeoutMs = domain.DefaultHttpTimeoutMs
+	}
+
+	var creds []*domain.StorageCredential
+	for _, u := range users {
+		if u == user {
+			continue
+		}
+		creds = append(creds, &domain.StorageCredential{
+			User:            u,
+			AccessKeyID:     ws.Credentials[u].AccessKeyID,
+			SecretAccessKey: ws.Credentials[u].SecretAccessKey,
+		})
+	}
+	return storage, creds, nil
+}
+
+// changedCredentials returns the imported credentials of a stored storage which are new or have other keys,
+// the changed ones keep their stored ID.
+// A previous own user missing from the file becomes a credential, so that its keys are kept like those of other missing users.
+func changedCredentials(current, imported *domain.Storage, stored []domain.StorageCredential, creds []*domain.StorageCredential) ([]*domain.StorageCredential, error) {
+	byUser := make(map[string]*domain.StorageCredential, len(stored))
+	for i := range stored {
+		byUser[stored[i].User] = &stored[i]
+	}
+	if imported.User != current.User {
+		if _, ok := byUser[imported.User]; ok {
+			return nil, errors.NewConflictError(fmt.Sprintf(
+				"user %q is a credential of storage %q, delete it before making it the storage's own user", imported.User, current.Name), nil)
+		}
+		if !slices.ContainsFunc(creds, func(c *domain.StorageCredential) bool { return c.User == current.User }) {
+			creds = append(creds, &domain.StorageCredential{
+				User:            current.User,
+				AccessKeyID:     current.AccessKeyID,
+				SecretAccessKey: current.SecretAccessKey,
+			})
+		}
+	}
+
+	var changed []*domain.StorageCredential
+	for _, c := range creds {
+		c.StorageID = current.ID
+		s, ok := byUser[c.User]
+		if !ok {
+			changed = append(changed, c)
+			continue
+		}
+		if s.AccessKeyID != c.AccessKeyID || s.SecretAccessKey != c.SecretAccessKey {
+			c.ID, c.CreatedAt = s.ID, s.CreatedAt
+			changed = append(changed, c)
+		}
+	}
+	return changed, nil
+}
+
+// changedStorageFields lists the fields of a decrypted storage which an import changes
+func changedStorageFields(current, imported *domain.Storage) []string {
+	var changed []string
+	add := func(field string, differs bool) {
+		if differs {
+			changed = append(changed, field)
+		}
+	}
+	add("address", current.Address != imported.Address)
+	add("provider", current.Provider != imported.Provider)
+	add("is_main", current.IsMain != imported.IsMain)
+	add("is_secure", current.IsSecure != imported.IsSecure)
+	add("default_region", current.DefaultRegion != imported.DefaultRegion)
+	add("health_check_interval_ms", current.HealthCheckIntervalMs != imported.HealthCheckIntervalMs)
+	add("http_timeout_ms", current.HttpTimeoutMs != imported.HttpTimeoutMs)
+	add("rate_limit_enabled", current.RateLimitEnabled != imported.RateLimitEnabled)
+	add("rate_limit_rpm", current.RateLimitRPM != imported.RateLimitRPM)
+	add("user", current.User != imported.User)
+	add("access_key_id", current.AccessKeyID != imported.AccessKeyID)
+	add("secret_access_key", current.SecretAccessKey != imported.SecretAccessKey)
+	return changed
+}
+
+// checkImportedMain refuses to demote a main storage missing from the file, unless replaceMain is set
+func checkImportedMain(cfg *workerconfig.StorageConfig, existing []domain.Storage, replaceMain bool) error {
+	if replaceMain {
+		return nil
+	}
+	for _, st := range existing {
+		if _, ok := cfg.Storages[st.Name]; !ok && st.IsMain {
+			// Validate ensured the file has a main storage
+			return errors.NewConflictError(fmt.Sprintf(
+				"%s is the main storage and not in the file, set replace_main=true to demote it", st.Name), nil)
+		}
+	}
+	return nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/workerconfig"
+)
+
+func TestStorageFromWorker(t *testing.T) {
+	stored := &domain.Storage{
+		Name:                  "main",
+		Address:               "https://s3.example.com",
+		Provider:              "Ceph",
+		IsMain:                true,
+		IsSecure:              true,
+		DefaultRegion:         "us-east-1",
+		HealthCheckIntervalMs: 5000,
+		HttpTimeoutMs:         300000,
+		RateLimitEnabled:      true,
+		RateLimitRPM:          600,
+		User:                  "admin",
+		AccessKeyID:           "access",
+		SecretAccessKey:       "secret",
+	}
+
+	// An exported storage imports unchanged
+	imported, creds, err := storageFromWorker("main", workerStorageOf(stored, nil), "", "")
+	if err != nil || len(creds) != 0 {
+		t.Fatal(creds, err)
+	}
+	if changed := changedStorageFields(stored, imported); len(changed) != 0 {
+		t.Fatalf("expected no changes, got %v", changed)
+	}
+
+	// Omitted settings take the worker defaults
+	ws := workerconfig.Storage{
+		Address:     "s3.example.com",
+		Provider:    "Ceph",
+		IsMain:      true,
+		Credentials: map[string]workerconfig.Credentials{"admin": {AccessKeyID: "access", SecretAccessKey: "rotated"}},
+	}
+	imported, _, err = storageFromWorker("main", ws, "us-east-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.DefaultRegion != "us-east-1" || imported.HealthCheckIntervalMs != domain.DefaultHealthCheckIntervalMs || imported.HttpTimeoutMs != domain.DefaultHttpTimeoutMs {
+		t.Fatalf("unexpected defaults: %+v", imported)
+	}
+	want := []string{"address", "is_secure", "rate_limit_enabled", "rate_limit_rpm", "secret_access_key"}
+	if changed := changedStorageFields(stored, imported); !reflect.DeepEqual(changed, want) {
+		t.Fatalf("expected changes %v, got %v", want, changed)
+	}
+}
+
+func TestStorageFromWorkerUsers(t *testing.T) {
+	ws := workerconfig.Storage{
+		Address: "s3.example.com",
+		Credentials: map[string]workerconfig.Credentials{
+			"admin":  {AccessKeyID: "a", SecretAccessKey: "a"},
+			"backup": {AccessKeyID: "b", SecretAccessKey: "b"},
+		},
+	}
+	if _, _, err := storageFromWorker("main", ws, "", ""); err == nil {
+		t.Fatal("expected an error without a user for several credentials")
+	}
+	if _, _, err := storageFromWorker("main", ws, "", "other"); err == nil {
+		t.Fatal("expected an error for an unknown user")
+	}
+	imported, creds, err := storageFromWorker("main", ws, "", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.User != "backup" || imported.AccessKeyID != "b" {
+		t.Fatalf("expected the backup credentials, got %+v", imported)
+	}
+	if len(creds) != 1 || creds[0].User != "admin" || creds[0].AccessKeyID != "a" {
+		t.Fatalf("expected admin as a credential, got %+v", creds)
+	}
+}
+
+func TestChangedCredentials(t *testing.T) {
+	current := &domain.Storage{ID: uuid.New(), Name: "main", User: "admin"}
+	stored := []domain.StorageCredential{
+		{ID: uuid.New(), StorageID: current.ID, User: "backup", AccessKeyID: "b", SecretAccessKey: "b"},
+		{ID: uuid.New(), StorageID: current.ID, User: "sync", AccessKeyID: "s", SecretAccessKey: "s", CreatedAt: time.Now().Add(-time.Hour)},
+	}
+	imported := []*domain.StorageCredential{
+		{User: "backup", AccessKeyID: "b", SecretAccessKey: "b"},
+		{User: "sync", AccessKeyID: "s", SecretAccessKey: "rotated"},
+		{User: "audit", AccessKeyID: "a", SecretAccessKey: "a"},
+	}
+
+	changed, err := changedCredentials(current, &domain.Storage{User: "admin"}, stored, imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 || changed[0].User != "sync" || changed[1].User != "audit" {
+		t.Fatalf("expected sync and audit to change, got %+v", changed)
+	}
+	// Updates keep the stored row, new credentials get an ID when they are encrypted
+	if changed[0].ID != stored[1].ID || !changed[0].CreatedAt.Equal(stored[1].CreatedAt) || changed[1].ID != uuid.Nil || changed[1].StorageID != current.ID {
+		t.Fatalf("unexpected IDs: %+v", changed)
+	}
+
+	if _, err := changedCredentials(current, &domain.Storage{User: "backup"}, stored, nil); err == nil {
+		t.Fatal("expected a conflict when a credential becomes the storage's own user")
+	}
+
+	// An own user replaced by the import keeps its keys as a credential
+	current.AccessKeyID, current.SecretAccessKey = "admin-key", "admin-secret"
+	changed, err = changedCredentials(current, &domain.Storage{User: "audit"}, stored, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 || changed[0].User != "admin" || changed[0].AccessKeyID != "admin-key" ||
+		changed[0].SecretAccessKey != "admin-secret" || changed[0].StorageID != current.ID {
+		t.Fatalf("expected admin to become a credential, got %+v", changed)
+	}
+	// An own user still in the file keeps the keys of the file
+	imported = []*domain.StorageCredential{{User: "admin", AccessKeyID: "file-key", SecretAccessKey: "file-secret"}}
+	changed, err = changedCredentials(current, &domain.Storage{User: "audit"}, stored, imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 || changed[0].AccessKeyID != "file-key" {
+		t.Fatalf("expected the keys of the file, got %+v", changed)
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hantdev/chorus-controller/internal/crypto"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+)
+
+// defaultRotationBatchSize is the number of storages re-encrypted per transaction when none is requested
+const defaultRotationBatchSize = 100
+
+// RotateKeys re-encrypts the secrets of storages and their credentials which are not encrypted with the active key in the current format.
+// It is also the upgrade path of secrets written in older ciphertext formats.
+// Every batch is committed on its own, a failed rotation reports the last committed storage to resume after.
+// Secrets already on the active key are skipped, so running the rotation again is safe as well.
+func (s *StorageService) RotateKeys(ctx context.Context, req *domain.RotateKeysRequest) (*domain.KeyRotationReport, error) {
+	if req.BatchSize == 0 {
+		req.BatchSize = defaultRotationBatchSize
+	}
+
+	report := &domain.KeyRotationReport{
+		ActiveKey: s.crypto.ActiveKeyID(),
+		DryRun:    req.DryRun,
+		Keys:      map[string]int{},
+		Formats:   map[string]int{},
+		LastID:    req.After,
+	}
+	for {
+		// Count per batch, a rolled back batch must not show up in the report
+		batch := &domain.KeyRotationReport{Keys: map[string]int{}, Formats: map[string]int{}}
+		n, last, err := s.storageRepo.RotateSecrets(ctx, report.LastID, req.BatchSize,
+			func(storage *domain.Storage) (string, error) {
+				batch.Scanned++
+				secret, err := s.rotateSecret(ctx, storage.SecretAccessKey, secretAAD(storage), req.DryRun, batch)
+				if err != nil {
+					return "", fmt.Errorf("storage %q: %w", storage.Name, err)
+				}
+				return secret, nil
+			},
+			func(cred *domain.StorageCredential) (string, error) {
+				secret, err := s.rotateSecret(ctx, cred.SecretAccessKey, credentialAAD(cred), req.DryRun, batch)
+				if err != nil {
+					return "", fmt.Errorf("storage %s, user %q: %w", cred.StorageID, cred.User, err)
+				}
+				return secret, nil
+			})
+		if err != nil {
+			return nil, errors.NewInternalServerError(
+				fmt.Sprintf("key rotation failed, resume with after=%s", report.LastID), err).WithDetails(report)
+		}
+		if n == 0 {
+			return report, nil
+		}
+
+		report.Batches++
+		report.Scanned += batch.Scanned
+		report.Rotated += batch.Rotated
+		for id, count := range batch.Keys {
+			report.Keys[id] += count
+		}
+		for format, count := range batch.Formats {
+			report.Formats[format] += count
+		}
+		report.LastID = last
+	}
+}
+
+// rotateSecret returns a secret encrypted with the active key, or an empty string when it is kept
+func (s *StorageService) rotateSecret(ctx context.Context, ciphertext string, aad []byte, dryRun bool, batch *domain.KeyRotationReport) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	batch.Keys[crypto.KeyID(ciphertext)]++
+	batch.Formats[fmt.Sprintf("v%d", crypto.Version(ciphertext))]++
+	if !s.crypto.NeedsRotation(ciphertext) {
+		return "", nil
+	}
+
+	secret, err := s.crypto.Decrypt(ctx, ciphertext, aad)
+	if err != nil {
+		return "", err
+	}
+	batch.Rotated++
+	if dryRun {
+		return "", nil
+	}
+	return s.crypto.Encrypt(ctx, secret, aad)
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"gorm.io/gorm"
+)
+
+// saveStorage creates or updates a storage keeping a single main storage.
+// A storage becoming main is rejected while another main exists, unless replaceMain demotes it in the same transaction.
+func (s *StorageService) saveStorage(ctx context.Context, storage *domain.Storage, create, replaceMain bool) error {
+	if storage.IsMain {
+		mains, err := s.storageRepo.ListMain(ctx)
+		if err != nil {
+			return errors.NewInternalServerError("failed to look up the main storage", err)
+		}
+		if others := otherStorageNames(mains, storage.ID); len(others) > 0 {
+			if !replaceMain {
+				return errors.NewConflictError(fmt.Sprintf(
+					"%s is already the main storage, set replace_main=true to demote it or promote this storage", strings.Join(others, ", ")), nil)
+			}
+			return storageSaveError(s.storageRepo.SaveAsMain(ctx, storage, create))
+		}
+	}
+
+	if create {
+		return storageSaveError(s.storageRepo.Create(ctx, storage))
+	}
+	return storageSaveError(s.storageRepo.Update(ctx, storage))
+}
+
+// PromoteStorage makes a storage the main storage.
+// The promotion is refused when buckets of the current main are not replicated to the storage, unless forced.
+func (s *StorageService) PromoteStorage(ctx context.Context, id string, req *domain.PromoteStorageRequest) (*domain.PromotionReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	storageID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid storage ID format", err)
+	}
+	storage, err := s.storageRepo.GetByID(ctx, storageID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NewNotFoundError("storage not found", err)
+		}
+		return nil, err
+	}
+	if storage.IsMain {
+		return nil, errors.NewBadRequestError(fmt.Sprintf("storage %q is already the main storage", storage.Name), nil)
+	}
+
+	report := &domain.PromotionReport{
+		Storage: storage.Name,
+		Users:   []domain.UserCoverage{},
+		DryRun:  req.DryRun,
+	}
+
+	mains, err := s.storageRepo.ListMain(ctx)
+	if err != nil {
+		return nil, errors.NewInternalServerError("failed to look up the main storage", err)
+	}
+	if len(mains) > 0 {
+		previous := mains[0]
+		report.PreviousMain = previous.Name
+		users, err := s.credentialRepo.UsersByStorage(ctx, []string{previous.Name})
+		if err != nil {
+			return nil, errors.NewInternalServerError("failed to look up storage users", err)
+		}
+		if err := s.checkCoverage(ctx, req.Worker, users[previous.Name], &previous, storage, report); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.DryRun || (report.Uncovered() && !req.Force) {
+		return report, nil
+	}
+
+	if err := storageSaveError(s.storageRepo.SaveAsMain(ctx, storage, false)); err != nil {
+		return nil, err
+	}
+	report.Promoted = true
+	return report, nil
+}
+
+// checkCoverage sorts the buckets of every user of the previous main into replicated and not replicated to the storage.
+// The own user of the previous main is always checked, even when users does not list it.
+func (s *StorageService) checkCoverage(ctx context.Context, worker string, users []string, previous, storage *domain.Storage, report *domain.PromotionReport) error {
+	if worker == "" {
+		worker = s.workers.DefaultWorker()
+	}
+	client, err := s.workers.Resolve(ctx, worker)
+	if err != nil {
+		return err
+	}
+	report.Worker = worker
+
+	if !slices.Contains(users, previous.User) {
+		users = append([]string{previous.User}, users...)
+	}
+	for _, user := range users {
+		resp, err := client.ListBucketsForReplication(ctx, &pb.ListBucketsForReplicationRequest{
+			User:           user,
+			From:           previous.Name,
+			To:             storage.Name,
+			ShowReplicated: true,
+		})
+		if err != nil {
+			return workerError(fmt.Sprintf("failed to list buckets of user %q on the main storage", user), err)
+		}
+
+		coverage := domain.UserCoverage{
+			User:      user,
+			Covered:   append([]string{}, resp.ReplicatedBuckets...),
+			Uncovered: append([]string{}, resp.Buckets...),
+		}
+		sort.Strings(coverage.Covered)
+		sort.Strings(coverage.Uncovered)
+		report.Users = append(report.Users, coverage)
+	}
+	return nil
+}
+
+// otherStorageNames returns the names of the storages other than id
+func otherStorageNames(storages []domain.Storage, id uuid.UUID) []string {
+	var names []string
+	for _, st := range storages {
+		if st.ID != id {
+			names = append(names, st.Name)
+		}
+	}
+	return names
+}
+
+// storageSaveError maps unique index violations, which concurrent writes can still hit, to a conflict
+func storageSaveError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return errors.NewConflictError("storage name is taken or another storage is already main", err)
+	}
+	return errors.NewInternalServerError("failed to save storage", err)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	pb "github.com/clyso/chorus/proto/gen/go/chorus"
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/fakeworker"
+	"github.com/hantdev/chorus-controller/internal/repository"
+)
+
+func TestCheckCoverage(t *testing.T) {
+	worker := fakeworker.New(fakeworker.Options{})
+	worker.AddStorage("main", "main.s3.local", pb.Storage_Ceph, true, "admin", "backup")
+	worker.AddStorage("follower", "follower.s3.local", pb.Storage_Minio, false, "admin", "backup")
+	if err := worker.AddBuckets("main", "admin", "photos"); err != nil {
+		t.Fatal(err)
+	}
+	if err := worker.AddBuckets("main", "backup", "archive", "db"); err != nil {
+		t.Fatal(err)
+	}
+	addr, err := worker.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(worker.Stop)
+	conn, err := repository.NewWorkerRepository(addr.String(), repository.WorkerTLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers := NewWorkerService("default", conn, repository.ResilienceConfig{MaxAttempts: 1, FailureThreshold: 100})
+	t.Cleanup(func() { workers.Close() })
+
+	ctx := context.Background()
+	for _, req := range []*pb.AddReplicationRequest{
+		{User: "admin", From: "main", To: "follower", Buckets: []string{"photos"}},
+		{User: "backup", From: "main", To: "follower", Buckets: []string{"db"}},
+	} {
+		if _, err := conn.AddReplication(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc := &StorageService{workers: workers}
+	previous := &domain.Storage{Name: "main", User: "admin", IsMain: true}
+	storage := &domain.Storage{Name: "follower", User: "admin"}
+	report := &domain.PromotionReport{Storage: "follower", Users: []domain.UserCoverage{}}
+	// The own user is checked even when the credential users leave it out
+	if err := svc.checkCoverage(ctx, "", []string{"backup"}, previous, storage, report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Worker != "default" {
+		t.Errorf("expected default worker, got %q", report.Worker)
+	}
+	want := []domain.UserCoverage{
+		{User: "admin", Covered: []string{"photos"}, Uncovered: []string{}},
+		{User: "backup", Covered: []string{"db"}, Uncovered: []string{"archive"}},
+	}
+	if !reflect.DeepEqual(report.Users, want) {
+		t.Fatalf("unexpected coverage: %+v", report.Users)
+	}
+	// Buckets of a credential user block the promotion like those of the own user
+	if !report.Uncovered() {
+		t.Fatal("expected the archive bucket of backup to be uncovered")
+	}
+}
+
+func TestOtherStorageNames(t *testing.T) {
+	id := uuid.New()
+	storages := []domain.Storage{{ID: id, Name: "main"}, {ID: uuid.New(), Name: "old"}}
+
+	if got := otherStorageNames(storages, id); !reflect.DeepEqual(got, []string{"old"}) {
+		t.Fatalf("unexpected names: %v", got)
+	}
+	if got := otherStorageNames(storages[:1], id); len(got) != 0 {
+		t.Fatalf("expected no other storages, got %v", got)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/providers"
+)
+
+// ListProviders returns the catalog of supported storage providers
+func (s *StorageService) ListProviders(_ context.Context) []providers.Provider {
+	return providers.All()
+}
+
+// applyProvider applies the rules of a storage's provider: the provider is stored by its worker name,
+// the region and address get the provider defaults, and the address is normalized with is_secure derived from its scheme.
+// secure is the is_secure of the request, it has to agree with the scheme when it is set.
+func applyProvider(storage *domain.Storage, secure *bool) error {
+	p, ok := providers.Lookup(storage.Provider)
+	if !ok {
+		return errors.NewBadRequestError(fmt.Sprintf("unsupported provider %q, supported providers are %s (use other for any other S3 compatible storage)",
+			storage.Provider, strings.Join(providers.IDs(), ", ")), nil).
+			WithDetails(map[string]any{"providers": providers.IDs()})
+	}
+	storage.Provider = p.Name
+
+	if storage.DefaultRegion == "" {
+		storage.DefaultRegion = p.DefaultRegion
+	}
+	if err := p.CheckRegion(storage.DefaultRegion); err != nil {
+		return errors.NewBadRequestError(err.Error(), err)
+	}
+
+	if strings.TrimSpace(storage.Address) == "" {
+		storage.Address = p.Address(storage.DefaultRegion)
+		if storage.Address == "" {
+			return errors.NewBadRequestError(fmt.Sprintf("provider %s has no default address, address is required", p.ID), nil)
+		}
+	}
+	if secure == nil && !strings.Contains(storage.Address, "://") {
+		// Addresses without a scheme keep is_secure, providers which only serve https default to it
+		isSecure := storage.IsSecure || p.SecureOnly
+		secure = &isSecure
+	}
+	address, isSecure, err := providers.NormalizeAddress(storage.Address, secure)
+	if err != nil {
+		return errors.NewBadRequestError(err.Error(), err)
+	}
+	if p.SecureOnly && !isSecure {
+		return errors.NewBadRequestError(fmt.Sprintf("provider %s requires an https address", p.ID), nil)
+	}
+	storage.Address, storage.IsSecure = address, isSecure
+	return nil
+}
+
+// providerChanged reports whether an update changes the fields checked by applyProvider
+func providerChanged(stored, updated *domain.Storage) bool {
+	return stored.Provider != updated.Provider ||
+		stored.DefaultRegion != updated.DefaultRegion ||
+		stored.Address != updated.Address ||
+		stored.IsSecure != updated.IsSecure
+}
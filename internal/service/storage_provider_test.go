@@ -0,0 +1,70 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hantdev/chorus-controller/internal/domain"
+)
+
+func TestApplyProvider(t *testing.T) {
+	// Defaults of a cloud provider
+	storage := &domain.Storage{Provider: "aws", DefaultRegion: "eu-west-1"}
+	if err := applyProvider(storage, nil); err != nil {
+		t.Fatal(err)
+	}
+	if storage.Provider != "AWS" || storage.Address != "https://s3.eu-west-1.amazonaws.com" || !storage.IsSecure {
+		t.Fatalf("unexpected storage: %+v", storage)
+	}
+
+	// is_secure follows the scheme
+	storage = &domain.Storage{Provider: "MinIO", Address: "HTTPS://minio.local:9000/"}
+	if err := applyProvider(storage, nil); err != nil {
+		t.Fatal(err)
+	}
+	if storage.Provider != "Minio" || storage.Address != "https://minio.local:9000" || !storage.IsSecure || storage.DefaultRegion != "" {
+		t.Fatalf("unexpected storage: %+v", storage)
+	}
+
+	// Addresses without a scheme keep is_secure
+	storage = &domain.Storage{Provider: "ceph", Address: "rgw.local", IsSecure: true}
+	if err := applyProvider(storage, nil); err != nil || storage.Address != "https://rgw.local" {
+		t.Fatalf("unexpected storage: %+v, %v", storage, err)
+	}
+	storage = &domain.Storage{Provider: "gcs", Address: "storage.googleapis.com"}
+	if err := applyProvider(storage, nil); err != nil || storage.Address != "https://storage.googleapis.com" || storage.DefaultRegion != "auto" {
+		t.Fatalf("unexpected storage: %+v, %v", storage, err)
+	}
+
+	no := false
+	for name, tc := range map[string]struct {
+		storage *domain.Storage
+		secure  *bool
+		message string
+	}{
+		"unsupported":     {&domain.Storage{Provider: "backblaze", Address: "http://b2.local"}, nil, "supported providers are minio, ceph"},
+		"region required": {&domain.Storage{Provider: "wasabi"}, nil, "requires default_region"},
+		"no address":      {&domain.Storage{Provider: "cloudflare"}, nil, "address is required"},
+		"http":            {&domain.Storage{Provider: "aws", DefaultRegion: "eu-west-1", Address: "http://s3.local"}, nil, "requires an https address"},
+		"conflict":        {&domain.Storage{Provider: "minio", Address: "https://minio.local"}, &no, "conflicts"},
+	} {
+		err := applyProvider(tc.storage, tc.secure)
+		if err == nil || !strings.Contains(err.Error(), tc.message) {
+			t.Errorf("%s: expected an error with %q, got %v", name, tc.message, err)
+		}
+	}
+}
+
+func TestProviderChanged(t *testing.T) {
+	stored := domain.Storage{Provider: "backblaze", Address: "b2.local", Description: "legacy"}
+
+	updated := stored
+	updated.Description = "changed"
+	if providerChanged(&stored, &updated) {
+		t.Fatal("expected a description change to keep the provider")
+	}
+	updated.DefaultRegion = "us-east-1"
+	if !providerChanged(&stored, &updated) {
+		t.Fatal("expected a region change to change the provider fields")
+	}
+}
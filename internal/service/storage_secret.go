@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/google/uuid"
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+)
+
+// RevealStorageSecret returns the plain-text secret of a storage.
+// The reveal is recorded first, no secret is returned when the audit record cannot be written.
+func (s *StorageService) RevealStorageSecret(ctx context.Context, id string, actor *domain.AuditActor) (*domain.StorageSecret, error) {
+	storage, err := s.getStorage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.audit(ctx, domain.AuditRevealStorageSecret, "storage/"+storage.ID.String(), actor); err != nil {
+		return nil, errors.NewInternalServerError("failed to record the secret reveal", err)
+	}
+
+	return &domain.StorageSecret{
+		StorageID:         storage.ID,
+		Storage:           storage.Name,
+		User:              storage.User,
+		AccessKeyID:       storage.AccessKeyID,
+		SecretAccessKey:   storage.SecretAccessKey,
+		SecretFingerprint: SecretFingerprint(storage.SecretAccessKey),
+	}, nil
+}
+
+// audit records a sensitive action of an actor on a resource
+func (s *StorageService) audit(ctx context.Context, action, resource string, actor *domain.AuditActor) error {
+	event := &domain.AuditEvent{
+		Action:    action,
+		Resource:  resource,
+		TokenName: actor.TokenName,
+		ClientIP:  actor.ClientIP,
+	}
+	if actor.TokenID != uuid.Nil {
+		event.TokenID = &actor.TokenID
+	}
+	return s.auditRepo.Create(ctx, event)
+}
+
+// SecretFingerprint identifies a secret without disclosing it.
+// It is the start of the SHA-256 of the secret, operators can compare it with `printf %s "$SECRET" | sha256sum`.
+func SecretFingerprint(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// maskStorage replaces the plain-text secret of a storage with a mask and its fingerprint
+func maskStorage(storage *domain.Storage) {
+	storage.SecretFingerprint = SecretFingerprint(storage.SecretAccessKey)
+	if storage.SecretAccessKey != "" {
+		storage.SecretAccessKey = domain.MaskedSecret
+	}
+}
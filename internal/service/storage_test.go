@@ -0,0 +1,68 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/hantdev/chorus-controller/internal/domain"
+)
+
+func TestApplyStorageSettings(t *testing.T) {
+	storage := &domain.Storage{
+		HealthCheckIntervalMs: domain.DefaultHealthCheckIntervalMs,
+		HttpTimeoutMs:         domain.DefaultHttpTimeoutMs,
+		DefaultRegion:         "eu-west-1",
+	}
+	enabled, rpm, secure := true, 600, true
+	applyStorageSettings(storage, &domain.StorageSettings{IsSecure: &secure, RateLimitEnabled: &enabled, RateLimitRPM: &rpm})
+
+	if !storage.IsSecure || !storage.RateLimitEnabled || storage.RateLimitRPM != 600 {
+		t.Fatalf("settings not applied: %+v", storage)
+	}
+	// Omitted settings are left unchanged
+	if storage.DefaultRegion != "eu-west-1" || storage.HttpTimeoutMs != domain.DefaultHttpTimeoutMs || storage.IsMain {
+		t.Fatalf("omitted settings changed: %+v", storage)
+	}
+	if err := checkStorageSettings(storage); err != nil {
+		t.Fatal(err)
+	}
+
+	zero := 0
+	applyStorageSettings(storage, &domain.StorageSettings{RateLimitRPM: &zero})
+	if err := checkStorageSettings(storage); err == nil {
+		t.Fatal("expected error for enabled rate limit without rpm")
+	}
+
+	applyStorageSettings(storage, &domain.StorageSettings{RateLimitRPM: &rpm, Labels: &map[string]string{"env": "prod!"}})
+	if err := checkStorageSettings(storage); err == nil {
+		t.Fatal("expected error for an invalid label value")
+	}
+}
+
+func TestStorageFilter(t *testing.T) {
+	filter, err := storageFilter(&domain.ListStoragesRequest{Selector: "env=prod,region!=eu", Cursor: "ZXUtbWFpbg", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filter.Selector) != 2 || filter.After != "eu-main" || filter.Limit != 10 {
+		t.Fatalf("unexpected filter: %+v", filter)
+	}
+
+	for _, req := range []domain.ListStoragesRequest{{Selector: "env=pr od"}, {Cursor: "not a cursor"}} {
+		if _, err := storageFilter(&req); err == nil {
+			t.Errorf("%+v: expected an error", req)
+		}
+	}
+}
+
+func TestMaskStorage(t *testing.T) {
+	storage := &domain.Storage{AccessKeyID: "access", SecretAccessKey: "password"}
+	maskStorage(storage)
+
+	if storage.SecretAccessKey != domain.MaskedSecret || storage.AccessKeyID != "access" {
+		t.Fatalf("unexpected masked storage: %+v", storage)
+	}
+	// printf %s password | sha256sum
+	if want := "sha256:5e884898da280471"; storage.SecretFingerprint != want {
+		t.Fatalf("expected fingerprint %s, got %s", want, storage.SecretFingerprint)
+	}
+}
@@ -0,0 +1,390 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/repository"
+	"gorm.io/gorm"
+)
+
+// workerEntry is a cached connection to a registered worker
+type workerEntry struct {
+	worker domain.Worker
+	conn   *repository.WorkerRepository
+	client *repository.ResilientWorker
+}
+
+// workerStatusName cannot name a worker, GET /workers/status would shadow GET /workers/{name} of the worker
+const (
+	workerStatusName     = "status"
+	workerStatusReserved = "worker name status is reserved for the worker status endpoint"
+)
+
+// WorkerService implements domain.WorkerService interface
+// It keeps one managed connection per worker and resolves clients by worker name
+type WorkerService struct {
+	workerRepo       *repository.WorkerDBRepository
+	replicateJobRepo *repository.ReplicateJobDBRepository
+	resilience       repository.ResilienceConfig
+	defaultWorker    *workerEntry
+
+	mu      sync.Mutex
+	entries map[string]*workerEntry
+}
+
+// NewWorkerService creates a new worker service
+// The default worker comes from configuration and is always available
+// Calls to every worker go through retries and a circuit breaker configured by resilience
+func NewWorkerService(defaultName string, defaultConn *repository.WorkerRepository, resilience repository.ResilienceConfig) *WorkerService {
+	s := &WorkerService{
+		workerRepo:       repository.NewWorkerDBRepository(),
+		replicateJobRepo: repository.NewReplicateJobDBRepository(),
+		resilience:       resilience,
+		entries:          make(map[string]*workerEntry),
+	}
+	s.defaultWorker = s.newEntry(domain.Worker{Name: defaultName, Address: defaultConn.Addr()}, defaultConn)
+	return s
+}
+
+// DefaultWorker returns the name of the configured default worker
+func (s *WorkerService) DefaultWorker() string {
+	return s.defaultWorker.worker.Name
+}
+
+// Resolve returns the client of the named worker, connecting on first use
+func (s *WorkerService) Resolve(ctx context.Context, name string) (domain.WorkerClient, error) {
+	entry, err := s.entry(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return entry.client, nil
+}
+
+// WorkerNames returns the default worker followed by all registered workers
+func (s *WorkerService) WorkerNames(ctx context.Context) ([]string, error) {
+	workers, err := s.workerRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{s.DefaultWorker()}
+	for _, w := range workers {
+		names = append(names, w.Name)
+	}
+	return names, nil
+}
+
+// CreateWorker registers a new worker
+func (s *WorkerService) CreateWorker(ctx context.Context, req *domain.CreateWorkerRequest) (*domain.Worker, error) {
+	if req.Name == s.DefaultWorker() || req.Name == domain.LegacyDefaultWorker {
+		return nil, errors.NewConflictError("worker name is reserved for the configured default worker", nil)
+	}
+	if req.Name == workerStatusName {
+		return nil, errors.NewConflictError(workerStatusReserved, nil)
+	}
+
+	worker := &domain.Worker{}
+	applyWorkerRequest(worker, req)
+
+	// Validate address and TLS settings before persisting
+	conn, err := dialWorker(worker)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid worker connection settings", err)
+	}
+
+	if err := s.workerRepo.Create(ctx, worker); err != nil {
+		conn.Close()
+		return nil, workerSaveError(err)
+	}
+
+	s.mu.Lock()
+	s.entries[worker.Name] = s.newEntry(*worker, conn)
+	s.mu.Unlock()
+
+	return worker, nil
+}
+
+// workerSaveError maps a failed save of a worker to an API error, a taken name is a conflict
+func workerSaveError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return errors.NewConflictError("worker already exists", err)
+	}
+	return errors.NewInternalServerError("failed to save worker", err)
+}
+
+// ListWorkers lists the default worker and all registered workers
+func (s *WorkerService) ListWorkers(ctx context.Context) ([]domain.Worker, error) {
+	workers, err := s.workerRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append([]domain.Worker{s.defaultWorker.worker}, workers...), nil
+}
+
+// GetWorker retrieves a worker by name
+func (s *WorkerService) GetWorker(ctx context.Context, name string) (*domain.Worker, error) {
+	if name == s.DefaultWorker() {
+		worker := s.defaultWorker.worker
+		return &worker, nil
+	}
+
+	worker, err := s.workerRepo.GetByName(ctx, name)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NewNotFoundError("worker not found", err)
+		}
+		return nil, err
+	}
+	return worker, nil
+}
+
+// UpdateWorker updates a registered worker and reconnects with the new settings
+func (s *WorkerService) UpdateWorker(ctx context.Context, name string, req *domain.CreateWorkerRequest) error {
+	if name == s.DefaultWorker() || req.Name == s.DefaultWorker() {
+		return errors.NewConflictError("the default worker is configured through environment", nil)
+	}
+	if req.Name == domain.LegacyDefaultWorker {
+		return errors.NewConflictError("worker name is reserved for the configured default worker", nil)
+	}
+	if req.Name == workerStatusName {
+		return errors.NewConflictError(workerStatusReserved, nil)
+	}
+
+	worker, err := s.workerRepo.GetByName(ctx, name)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errors.NewNotFoundError("worker not found", err)
+		}
+		return err
+	}
+	if req.Name != name {
+		count, err := s.replicateJobRepo.CountByWorker(ctx, name)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.NewConflictError(fmt.Sprintf("worker owns %d replication jobs and cannot be renamed", count), nil)
+		}
+	}
+
+	applyWorkerRequest(worker, req)
+	conn, err := dialWorker(worker)
+	if err != nil {
+		return errors.NewBadRequestError("invalid worker connection settings", err)
+	}
+
+	if err := s.workerRepo.Update(ctx, worker); err != nil {
+		conn.Close()
+		return workerSaveError(err)
+	}
+
+	s.mu.Lock()
+	s.evictLocked(name)
+	s.entries[worker.Name] = s.newEntry(*worker, conn)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// DeleteWorker removes a registered worker that no longer owns replication jobs
+func (s *WorkerService) DeleteWorker(ctx context.Context, name string) error {
+	if name == s.DefaultWorker() {
+		return errors.NewConflictError("the default worker is configured through environment", nil)
+	}
+
+	count, err := s.replicateJobRepo.CountByWorker(ctx, name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.NewConflictError(fmt.Sprintf("worker owns %d replication jobs", count), nil)
+	}
+
+	if err := s.workerRepo.DeleteByName(ctx, name); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errors.NewNotFoundError("worker not found", err)
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	s.evictLocked(name)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// WorkerStatuses reports the connection and circuit breaker state of every worker
+func (s *WorkerService) WorkerStatuses(ctx context.Context) ([]domain.WorkerStatus, error) {
+	names, err := s.WorkerNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]domain.WorkerStatus, 0, len(names))
+	for _, name := range names {
+		entry, err := s.entry(ctx, name)
+		if err != nil {
+			statuses = append(statuses, domain.WorkerStatus{Name: name, State: "UNAVAILABLE"})
+			continue
+		}
+		breaker := entry.client.BreakerState()
+		statuses = append(statuses, domain.WorkerStatus{
+			Name:    name,
+			Address: entry.conn.Addr(),
+			Builtin: entry == s.defaultWorker,
+			State:   entry.conn.State().String(),
+			Breaker: &breaker,
+		})
+	}
+	return statuses, nil
+}
+
+// Close closes all worker connections
+func (s *WorkerService) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.entries {
+		s.evictLocked(name)
+	}
+	return s.defaultWorker.conn.Close()
+}
+
+// entry returns the cached connection of a worker or opens a new one
+func (s *WorkerService) entry(ctx context.Context, name string) (*workerEntry, error) {
+	if name == "" || name == s.DefaultWorker() || name == domain.LegacyDefaultWorker {
+		return s.defaultWorker, nil
+	}
+
+	s.mu.Lock()
+	entry, ok := s.entries[name]
+	s.mu.Unlock()
+	if ok {
+		return entry, nil
+	}
+
+	worker, err := s.workerRepo.GetByName(ctx, name)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NewNotFoundError(fmt.Sprintf("worker %q not found", name), err)
+		}
+		return nil, err
+	}
+	conn, err := dialWorker(worker)
+	if err != nil {
+		return nil, errors.NewInternalServerError(fmt.Sprintf("failed to connect to worker %q", name), err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.entries[name]; ok {
+		// Another request connected in the meantime
+		conn.Close()
+		return existing, nil
+	}
+	entry = s.newEntry(*worker, conn)
+	s.entries[name] = entry
+	return entry, nil
+}
+
+// newEntry wraps a worker connection with retries and a circuit breaker
+func (s *WorkerService) newEntry(worker domain.Worker, conn *repository.WorkerRepository) *workerEntry {
+	return &workerEntry{
+		worker: worker,
+		conn:   conn,
+		client: repository.NewResilientWorker(worker.Name, conn, s.resilience),
+	}
+}
+
+// evictLocked closes and forgets the cached connection of a worker
+func (s *WorkerService) evictLocked(name string) {
+	entry, ok := s.entries[name]
+	if !ok {
+		return
+	}
+	delete(s.entries, name)
+	if err := entry.conn.Close(); err != nil {
+		log.Printf("Warning: failed to close connection to worker %q: %v", name, err)
+	}
+}
+
+// dialWorker opens a managed connection to a worker
+func dialWorker(worker *domain.Worker) (*repository.WorkerRepository, error) {
+	return repository.NewWorkerRepository(worker.Address, repository.WorkerTLSConfig{
+		Enabled:    worker.TlsEnabled,
+		CAFile:     worker.TlsCaFile,
+		CertFile:   worker.TlsCertFile,
+		KeyFile:    worker.TlsKeyFile,
+		ServerName: worker.TlsServerName,
+	})
+}
+
+// applyWorkerRequest copies request fields into the worker model
+func applyWorkerRequest(worker *domain.Worker, req *domain.CreateWorkerRequest) {
+	worker.Name = req.Name
+	worker.Address = req.Address
+	worker.TlsEnabled = req.TlsEnabled
+	worker.TlsCaFile = req.TlsCaFile
+	worker.TlsCertFile = req.TlsCertFile
+	worker.TlsKeyFile = req.TlsKeyFile
+	worker.TlsServerName = req.TlsServerName
+	worker.Labels = req.Labels
+	if worker.Labels == nil {
+		worker.Labels = map[string]string{}
+	}
+	worker.Description = req.Description
+}
+
+// forEachWorker calls fn concurrently for every worker known to the resolver.
+// Failing workers are logged, skipped and returned with their error, an error is returned only when all workers fail.
+func forEachWorker(ctx context.Context, resolver domain.WorkerResolver, fn func(ctx context.Context, name string, client domain.WorkerClient) error) (map[string]string, error) {
+	names, err := resolver.WorkerNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		firstErr    error
+		unreachable map[string]string
+	)
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			client, err := resolver.Resolve(ctx, name)
+			if err == nil {
+				err = fn(ctx, name, client)
+			}
+			if err != nil {
+				log.Printf("Warning: worker %q: %v", name, err)
+				mu.Lock()
+				if unreachable == nil {
+					unreachable = make(map[string]string)
+				}
+				unreachable[name] = err.Error()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	if len(unreachable) == len(names) {
+		return nil, firstErr
+	}
+	return unreachable, nil
+}
+
+// Ensure WorkerService implements domain.WorkerService interface
+var _ domain.WorkerService = (*WorkerService)(nil)
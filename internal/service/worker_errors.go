@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// workerErrorSource identifies the worker in upstream error details
+const workerErrorSource = "worker"
+
+// workerError converts an error returned by a worker call into an API error.
+// gRPC status codes are mapped to the closest HTTP status so clients can tell
+// invalid requests apart from transient worker failures, the worker's own
+// message is kept in the upstream details.
+func workerError(message string, err error) error {
+	if errors.Is(err, errors.ErrWorkerUnavailable) {
+		// Rejected by the circuit breaker without reaching the worker
+		return errors.NewServiceUnavailableError(message+": "+errors.ErrWorkerUnavailable.Error(), err).
+			WithUpstream(&errors.UpstreamError{
+				Source:    workerErrorSource,
+				Status:    codes.Unavailable.String(),
+				Message:   err.Error(),
+				Retryable: true,
+			})
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		if errors.Is(err, context.DeadlineExceeded) {
+			st = status.New(codes.DeadlineExceeded, err.Error())
+		} else {
+			return errors.NewBadGatewayError(message, err)
+		}
+	}
+
+	var apiErr *errors.APIError
+	switch st.Code() {
+	case codes.NotFound:
+		apiErr = errors.NewNotFoundError(message+": "+st.Message(), err)
+	case codes.AlreadyExists:
+		apiErr = errors.NewConflictError(message+": "+st.Message(), err)
+	case codes.InvalidArgument:
+		apiErr = errors.NewBadRequestError(message+": "+st.Message(), err)
+	case codes.FailedPrecondition:
+		apiErr = errors.NewPreconditionFailedError(message+": "+st.Message(), err)
+	case codes.Unavailable:
+		apiErr = errors.NewServiceUnavailableError(message+": worker is unavailable", err)
+	case codes.DeadlineExceeded:
+		apiErr = errors.NewGatewayTimeoutError(message+": worker did not respond in time", err)
+	default:
+		apiErr = errors.NewBadGatewayError(message, err)
+	}
+
+	return apiErr.WithUpstream(&errors.UpstreamError{
+		Source:    workerErrorSource,
+		Status:    st.Code().String(),
+		Message:   st.Message(),
+		Retryable: isRetryableCode(st.Code()),
+	})
+}
+
+// isRetryableCode reports whether a request failing with the code may succeed when repeated
+func isRetryableCode(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
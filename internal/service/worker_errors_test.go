@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWorkerErrorMapsStatusCodes(t *testing.T) {
+	tests := []struct {
+		code      codes.Code
+		want      int
+		retryable bool
+	}{
+		{codes.NotFound, http.StatusNotFound, false},
+		{codes.AlreadyExists, http.StatusConflict, false},
+		{codes.InvalidArgument, http.StatusBadRequest, false},
+		{codes.FailedPrecondition, http.StatusPreconditionFailed, false},
+		{codes.Unavailable, http.StatusServiceUnavailable, true},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout, true},
+		{codes.Internal, http.StatusBadGateway, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			err := workerError("failed to pause replication", status.Error(tt.code, "replication is missing"))
+
+			var apiErr *errors.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected APIError, got %T", err)
+			}
+			if apiErr.Code != tt.want {
+				t.Fatalf("expected HTTP %d, got %d", tt.want, apiErr.Code)
+			}
+			if apiErr.Upstream == nil {
+				t.Fatal("expected upstream details")
+			}
+			if apiErr.Upstream.Status != tt.code.String() || apiErr.Upstream.Message != "replication is missing" {
+				t.Fatalf("unexpected upstream details: %+v", apiErr.Upstream)
+			}
+			if apiErr.Upstream.Retryable != tt.retryable {
+				t.Fatalf("expected retryable=%v", tt.retryable)
+			}
+		})
+	}
+}
+
+func TestWorkerErrorBreakerOpen(t *testing.T) {
+	err := workerError("failed to list replications", fmt.Errorf("%w: breaker is open", errors.ErrWorkerUnavailable))
+
+	var apiErr *errors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %v", err)
+	}
+	if apiErr.Upstream == nil || !apiErr.Upstream.Retryable {
+		t.Fatalf("expected retryable upstream details, got %+v", apiErr.Upstream)
+	}
+}
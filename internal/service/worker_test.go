@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hantdev/chorus-controller/internal/domain"
+	"github.com/hantdev/chorus-controller/internal/errors"
+	"github.com/hantdev/chorus-controller/internal/repository"
+	"gorm.io/gorm"
+)
+
+func TestReservedWorkerNames(t *testing.T) {
+	conn, err := repository.NewWorkerRepository("127.0.0.1:1", repository.WorkerTLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers := NewWorkerService("primary", conn, repository.ResilienceConfig{MaxAttempts: 1})
+	t.Cleanup(func() { workers.Close() })
+
+	for _, name := range []string{"primary", domain.LegacyDefaultWorker, "status"} {
+		_, err := workers.CreateWorker(context.Background(), &domain.CreateWorkerRequest{Name: name, Address: "127.0.0.1:9670"})
+		var apiErr *errors.APIError
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusConflict {
+			t.Errorf("create worker %q: expected a conflict, got %v", name, err)
+		}
+		err = workers.UpdateWorker(context.Background(), "edge", &domain.CreateWorkerRequest{Name: name, Address: "127.0.0.1:9670"})
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusConflict {
+			t.Errorf("rename worker to %q: expected a conflict, got %v", name, err)
+		}
+	}
+}
+
+func TestWorkerSaveError(t *testing.T) {
+	var apiErr *errors.APIError
+	if err := workerSaveError(fmt.Errorf("create: %w", gorm.ErrDuplicatedKey)); !errors.As(err, &apiErr) || apiErr.Code != http.StatusConflict {
+		t.Fatalf("expected a conflict for a taken name, got %v", err)
+	}
+	if err := workerSaveError(gorm.ErrInvalidDB); !errors.As(err, &apiErr) || apiErr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected an internal error, got %v", err)
+	}
+}
+
+func TestWorkerRejectsHalfKeyPair(t *testing.T) {
+	conn, err := repository.NewWorkerRepository("127.0.0.1:1", repository.WorkerTLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers := NewWorkerService("primary", conn, repository.ResilienceConfig{MaxAttempts: 1})
+	t.Cleanup(func() { workers.Close() })
+
+	// The key pair is checked before the worker is saved
+	_, err = workers.CreateWorker(context.Background(), &domain.CreateWorkerRequest{Name: "edge", Address: "127.0.0.1:9670", TlsKeyFile: "client-key.pem"})
+	var apiErr *errors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a bad request for a key file without a certificate, got %v", err)
+	}
+}
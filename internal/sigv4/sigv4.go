@@ -0,0 +1,186 @@
+// Package sigv4 signs S3 requests with AWS Signature Version 4, the scheme
+// the Chorus worker uses for every storage.
+//
+// Only header based signing with an empty payload is supported, which covers
+// the read-only requests sent by the controller.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Algorithm is the signing algorithm name used in the Authorization header
+	Algorithm = "AWS4-HMAC-SHA256"
+	// EmptyPayloadHash is the SHA-256 of an empty body
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	service    = "s3"
+	terminator = "aws4_request"
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+)
+
+// Credentials are the S3 keys of a user
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// Authorization is a parsed Authorization header
+type Authorization struct {
+	AccessKeyID   string
+	Date          string
+	Region        string
+	Service       string
+	SignedHeaders []string
+	Signature     string
+}
+
+// Sign adds the x-amz-date, x-amz-content-sha256 and Authorization headers to a request without body
+func Sign(req *http.Request, creds Credentials, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(timeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", EmptyPayloadHash)
+
+	auth := &Authorization{
+		AccessKeyID:   creds.AccessKeyID,
+		Date:          now.Format(dateFormat),
+		Region:        region,
+		Service:       service,
+		SignedHeaders: []string{"host", "x-amz-content-sha256", "x-amz-date"},
+	}
+	auth.Signature = Signature(req, creds.SecretAccessKey, auth)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		Algorithm, auth.AccessKeyID, auth.scope(), strings.Join(auth.SignedHeaders, ";"), auth.Signature))
+}
+
+// Parse parses an Authorization header of a request signed with Signature Version 4
+func Parse(header string) (*Authorization, error) {
+	rest, ok := strings.CutPrefix(header, Algorithm+" ")
+	if !ok {
+		return nil, fmt.Errorf("unsupported authorization algorithm")
+	}
+
+	auth := &Authorization{}
+	for _, part := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed authorization field %q", part)
+		}
+		switch key {
+		case "Credential":
+			fields := strings.Split(value, "/")
+			if len(fields) != 5 || fields[4] != terminator {
+				return nil, fmt.Errorf("malformed credential %q", value)
+			}
+			auth.AccessKeyID, auth.Date, auth.Region, auth.Service = fields[0], fields[1], fields[2], fields[3]
+		case "SignedHeaders":
+			auth.SignedHeaders = strings.Split(value, ";")
+		case "Signature":
+			auth.Signature = value
+		}
+	}
+	if auth.AccessKeyID == "" || len(auth.SignedHeaders) == 0 || auth.Signature == "" {
+		return nil, fmt.Errorf("incomplete authorization header")
+	}
+	return auth, nil
+}
+
+// Signature computes the signature of a request for the scope and signed headers of auth
+func Signature(req *http.Request, secretAccessKey string, auth *Authorization) string {
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders(req, auth.SignedHeaders),
+		strings.Join(auth.SignedHeaders, ";"),
+		payloadHash(req),
+	}, "\n")
+	digest := sha256.Sum256([]byte(canonical))
+
+	stringToSign := strings.Join([]string{
+		Algorithm,
+		req.Header.Get("X-Amz-Date"),
+		auth.scope(),
+		hex.EncodeToString(digest[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), auth.Date)
+	key = hmacSHA256(key, auth.Region)
+	key = hmacSHA256(key, auth.Service)
+	key = hmacSHA256(key, terminator)
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func (a *Authorization) scope() string {
+	return strings.Join([]string{a.Date, a.Region, a.Service, terminator}, "/")
+}
+
+func canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		vals := append([]string(nil), values[key]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			pairs = append(pairs, escape(key)+"="+escape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func canonicalHeaders(req *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		b.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	return b.String()
+}
+
+func payloadHash(req *http.Request) string {
+	if h := req.Header.Get("X-Amz-Content-Sha256"); h != "" {
+		return h
+	}
+	return EmptyPayloadHash
+}
+
+// escape encodes everything except the unreserved characters of RFC 3986
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
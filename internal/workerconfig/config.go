@@ -0,0 +1,186 @@
+// Package workerconfig renders and parses the storage section of the Chorus worker config.
+//
+// The layout mirrors github.com/clyso/chorus/pkg/s3.StorageConfig, so rendered
+// files can be dropped into the worker's config without changes.
+package workerconfig
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// header is written on top of rendered files
+const header = "# Chorus worker storage configuration generated by chorus-controller.\n" +
+	"# Changes are overwritten by the next export, edit the storages through the controller API instead.\n"
+
+// File is the part of the worker config holding storages
+type File struct {
+	Storage *StorageConfig `yaml:"storage"`
+}
+
+// StorageConfig lists the storages of a worker
+type StorageConfig struct {
+	CreateRouting     bool               `yaml:"createRouting"`
+	CreateReplication bool               `yaml:"createReplication"`
+	DefaultRegion     string             `yaml:"defaultRegion,omitempty"`
+	Storages          map[string]Storage `yaml:"storages"`
+}
+
+// Storage is a single S3 storage with the credentials of every user
+type Storage struct {
+	Address             string                 `yaml:"address"`
+	Credentials         map[string]Credentials `yaml:"credentials"`
+	Provider            string                 `yaml:"provider"`
+	IsMain              bool                   `yaml:"isMain"`
+	HealthCheckInterval Duration               `yaml:"healthCheckInterval,omitempty"`
+	HttpTimeout         Duration               `yaml:"httpTimeout,omitempty"`
+	IsSecure            bool                   `yaml:"isSecure"`
+	DefaultRegion       string                 `yaml:"defaultRegion,omitempty"`
+	RateLimit           RateLimit              `yaml:"rateLimit"`
+}
+
+// RateLimit limits the requests per minute sent to a storage
+type RateLimit struct {
+	Enabled bool `yaml:"enabled"`
+	RPM     int  `yaml:"rpm"`
+}
+
+// Credentials are the S3 v4 credentials of a user
+type Credentials struct {
+	AccessKeyID     string `yaml:"accessKeyID"`
+	SecretAccessKey string `yaml:"secretAccessKey"`
+}
+
+// Duration is a time.Duration written in Go duration syntax, e.g. "5m0s"
+type Duration time.Duration
+
+// MarshalYAML writes the duration as a string
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// UnmarshalYAML reads a duration string, or an integer number of nanoseconds as the worker accepts it too
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var ns int64
+	if err := value.Decode(&ns); err == nil {
+		*d = Duration(ns)
+		return nil
+	}
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return fmt.Errorf("line %d: invalid duration: %w", value.Line, err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// IsZero reports whether the duration is unset, so that it can be omitted
+func (d Duration) IsZero() bool {
+	return d == 0
+}
+
+// Validate applies the checks the worker runs when it loads its config
+func (c *StorageConfig) Validate() error {
+	if len(c.Storages) == 0 {
+		return fmt.Errorf("no storages configured")
+	}
+
+	var (
+		mains []string
+		users []string
+		first string
+	)
+	for _, name := range sortedNames(c.Storages) {
+		s := c.Storages[name]
+		if s.Address == "" {
+			return fmt.Errorf("storage %q: address required", name)
+		}
+		if s.Provider == "" {
+			return fmt.Errorf("storage %q: provider required", name)
+		}
+		if len(s.Credentials) == 0 {
+			return fmt.Errorf("storage %q: credentials not set", name)
+		}
+		for user, cred := range s.Credentials {
+			if cred.AccessKeyID == "" || cred.SecretAccessKey == "" {
+				return fmt.Errorf("storage %q, user %q: accessKeyID and secretAccessKey required", name, user)
+			}
+		}
+		if s.IsMain {
+			mains = append(mains, name)
+		}
+
+		// Every storage must know the same users
+		storageUsers := sortedNames(s.Credentials)
+		if users == nil {
+			users, first = storageUsers, name
+		} else if strings.Join(users, ",") != strings.Join(storageUsers, ",") {
+			return fmt.Errorf("storages %q and %q have different users: %v and %v", first, name, users, storageUsers)
+		}
+	}
+
+	switch len(mains) {
+	case 0:
+		return fmt.Errorf("main storage is not set")
+	case 1:
+		return nil
+	default:
+		return fmt.Errorf("multiple main storages: %s", strings.Join(mains, ", "))
+	}
+}
+
+// Marshal renders the config as YAML.
+// Map keys are sorted, so the same storages always produce the same bytes.
+func Marshal(f *File) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal parses the storage section of a worker config.
+// It accepts a whole worker config with a top-level storage key, or the storage section alone.
+func Unmarshal(data []byte) (*StorageConfig, error) {
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Storage != nil {
+		return f.Storage, nil
+	}
+
+	var cfg StorageConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Storages == nil {
+		return nil, fmt.Errorf("no storage section found")
+	}
+	return &cfg, nil
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,172 @@
+package workerconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig() *StorageConfig {
+	creds := func(user string) map[string]Credentials {
+		return map[string]Credentials{user: {AccessKeyID: "ak-" + user, SecretAccessKey: "sk-" + user}}
+	}
+	return &StorageConfig{
+		CreateRouting: true,
+		DefaultRegion: "us-east-1",
+		Storages: map[string]Storage{
+			"follower": {Address: "http://follower:9000", Provider: "Minio", Credentials: creds("admin")},
+			"main": {
+				Address:             "https://main.example.com",
+				Provider:            "Ceph",
+				IsMain:              true,
+				IsSecure:            true,
+				HealthCheckInterval: Duration(5 * time.Second),
+				HttpTimeout:         Duration(5 * time.Minute),
+				RateLimit:           RateLimit{Enabled: true, RPM: 60},
+				Credentials:         creds("admin"),
+			},
+		},
+	}
+}
+
+func TestMarshalIsDeterministic(t *testing.T) {
+	first, err := Marshal(&File{Storage: testConfig()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		out, err := Marshal(&File{Storage: testConfig()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != string(first) {
+			t.Fatalf("output changed between runs:\n%s\n---\n%s", first, out)
+		}
+	}
+
+	want := `storage:
+  createRouting: true
+  createReplication: false
+  defaultRegion: us-east-1
+  storages:
+    follower:
+      address: http://follower:9000
+      credentials:
+        admin:
+          accessKeyID: ak-admin
+          secretAccessKey: sk-admin
+      provider: Minio
+      isMain: false
+      isSecure: false
+      rateLimit:
+        enabled: false
+        rpm: 0
+    main:
+      address: https://main.example.com
+      credentials:
+        admin:
+          accessKeyID: ak-admin
+          secretAccessKey: sk-admin
+      provider: Ceph
+      isMain: true
+      healthCheckInterval: 5s
+      httpTimeout: 5m0s
+      isSecure: true
+      rateLimit:
+        enabled: true
+        rpm: 60
+`
+	if body := strings.TrimPrefix(string(first), header); body != want {
+		t.Fatalf("unexpected output:\n%s", body)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *StorageConfig)
+		wantErr string
+	}{
+		{name: "valid", modify: func(*StorageConfig) {}},
+		{name: "empty", modify: func(c *StorageConfig) { c.Storages = nil }, wantErr: "no storages"},
+		{name: "no main", modify: func(c *StorageConfig) {
+			s := c.Storages["main"]
+			s.IsMain = false
+			c.Storages["main"] = s
+		}, wantErr: "main storage is not set"},
+		{name: "two mains", modify: func(c *StorageConfig) {
+			s := c.Storages["follower"]
+			s.IsMain = true
+			c.Storages["follower"] = s
+		}, wantErr: "multiple main storages: follower, main"},
+		{name: "different users", modify: func(c *StorageConfig) {
+			s := c.Storages["follower"]
+			s.Credentials = map[string]Credentials{"other": {AccessKeyID: "a", SecretAccessKey: "b"}}
+			c.Storages["follower"] = s
+		}, wantErr: "different users"},
+		{name: "missing secret", modify: func(c *StorageConfig) {
+			s := c.Storages["follower"]
+			s.Credentials = map[string]Credentials{"admin": {AccessKeyID: "a"}}
+			c.Storages["follower"] = s
+		}, wantErr: "secretAccessKey required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalRoundTrip(t *testing.T) {
+	out, err := Marshal(&File{Storage: testConfig()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Unmarshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, testConfig()) {
+		t.Fatalf("round trip changed the config:\n%+v\n%+v", cfg, testConfig())
+	}
+}
+
+func TestUnmarshalStorageSection(t *testing.T) {
+	// The storage section alone, as found in separate worker config files
+	cfg, err := Unmarshal([]byte(`
+storages:
+  main:
+    address: s3.local
+    provider: Minio
+    isMain: true
+    healthCheckInterval: 5000000000
+    httpTimeout: 1m
+    credentials:
+      admin: {accessKeyID: ak, secretAccessKey: sk}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := cfg.Storages["main"]
+	if main.HealthCheckInterval != Duration(5*time.Second) || main.HttpTimeout != Duration(time.Minute) || main.Credentials["admin"].SecretAccessKey != "sk" {
+		t.Fatalf("unexpected storage: %+v", main)
+	}
+
+	for _, data := range []string{"log:\n  level: info\n", "storages:\n  main:\n    httpTimeout: soon\n", "storage: ["} {
+		if _, err := Unmarshal([]byte(data)); err == nil {
+			t.Fatalf("expected an error for %q", data)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+-- Create "worker" table
+CREATE TABLE "worker" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "name" character varying(255) NOT NULL,
+  "address" character varying(1024) NOT NULL,
+  "tls_enabled" boolean NOT NULL DEFAULT false,
+  "tls_ca_file" character varying(1024) NOT NULL DEFAULT '',
+  "tls_cert_file" character varying(1024) NOT NULL DEFAULT '',
+  "tls_key_file" character varying(1024) NOT NULL DEFAULT '',
+  "tls_server_name" character varying(255) NOT NULL DEFAULT '',
+  "labels" jsonb NOT NULL DEFAULT '{}',
+  "description" character varying(500) NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_worker_name" to table: "worker"
+CREATE UNIQUE INDEX "idx_worker_name" ON "worker" ("name");
+-- Modify "replicate_job" table
+ALTER TABLE "replicate_job" ADD COLUMN "worker" character varying(255) NOT NULL DEFAULT 'default';
+-- Create index "idx_replicate_job_worker" to table: "replicate_job"
+CREATE INDEX "idx_replicate_job_worker" ON "replicate_job" ("worker");
@@ -0,0 +1,26 @@
+-- Create "bucket_comparison" table
+CREATE TABLE "bucket_comparison" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "worker" character varying(255) NOT NULL,
+  "user" character varying(255) NOT NULL,
+  "bucket" character varying(255) NOT NULL,
+  "from" character varying(255) NOT NULL,
+  "to" character varying(255) NOT NULL,
+  "to_bucket" character varying(255) NOT NULL DEFAULT '',
+  "is_match" boolean NOT NULL DEFAULT false,
+  "match_count" bigint NOT NULL DEFAULT 0,
+  "miss_from_count" bigint NOT NULL DEFAULT 0,
+  "miss_to_count" bigint NOT NULL DEFAULT 0,
+  "differ_count" bigint NOT NULL DEFAULT 0,
+  "error_count" bigint NOT NULL DEFAULT 0,
+  "miss_from" jsonb NOT NULL DEFAULT '[]',
+  "miss_to" jsonb NOT NULL DEFAULT '[]',
+  "differ" jsonb NOT NULL DEFAULT '[]',
+  "errors" jsonb NOT NULL DEFAULT '[]',
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_bucket_comparison_bucket" to table: "bucket_comparison"
+CREATE INDEX "idx_bucket_comparison_bucket" ON "bucket_comparison" ("bucket");
+-- Create index "idx_bucket_comparison_created_at" to table: "bucket_comparison"
+CREATE INDEX "idx_bucket_comparison_created_at" ON "bucket_comparison" ("created_at");
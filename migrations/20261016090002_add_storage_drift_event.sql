@@ -0,0 +1,18 @@
+-- Create "storage_drift_event" table
+CREATE TABLE "storage_drift_event" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "worker" character varying(255) NOT NULL,
+  "storage" character varying(255) NOT NULL,
+  "kind" character varying(64) NOT NULL,
+  "db_value" character varying(1024) NULL,
+  "worker_value" character varying(1024) NULL,
+  "detected_at" timestamptz NOT NULL,
+  "resolved_at" timestamptz NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_storage_drift_event_worker" to table: "storage_drift_event"
+CREATE INDEX "idx_storage_drift_event_worker" ON "storage_drift_event" ("worker");
+-- Create index "idx_storage_drift_event_storage" to table: "storage_drift_event"
+CREATE INDEX "idx_storage_drift_event_storage" ON "storage_drift_event" ("storage");
+-- Create index "idx_storage_drift_event_detected_at" to table: "storage_drift_event"
+CREATE INDEX "idx_storage_drift_event_detected_at" ON "storage_drift_event" ("detected_at");
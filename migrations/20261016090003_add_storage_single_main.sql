@@ -0,0 +1,14 @@
+-- Storages do not record their age, so several main storages are not resolved here: the operator keeps the right one
+DO $$
+DECLARE
+  mains text;
+BEGIN
+  SELECT string_agg(quote_literal("name"), ', ' ORDER BY "name") INTO mains FROM "storage" WHERE "is_main" HAVING count(*) > 1;
+  IF mains IS NOT NULL THEN
+    RAISE EXCEPTION 'only one storage can be main, storages % are main', mains
+      USING HINT = 'keep one main storage with UPDATE storage SET is_main = false WHERE is_main AND name <> ''<main storage>'', then apply the migrations again';
+  END IF;
+END
+$$;
+-- Create index "idx_storage_single_main" to table: "storage"
+CREATE UNIQUE INDEX "idx_storage_single_main" ON "storage" ("is_main") WHERE is_main;
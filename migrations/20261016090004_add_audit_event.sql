@@ -0,0 +1,17 @@
+-- Create "audit_event" table
+CREATE TABLE "audit_event" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "action" character varying(64) NOT NULL,
+  "resource" character varying(255) NOT NULL,
+  "token_id" uuid NULL,
+  "token_name" character varying(255) NULL,
+  "client_ip" character varying(64) NULL,
+  "created_at" timestamptz NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_audit_event_action" to table: "audit_event"
+CREATE INDEX "idx_audit_event_action" ON "audit_event" ("action");
+-- Create index "idx_audit_event_resource" to table: "audit_event"
+CREATE INDEX "idx_audit_event_resource" ON "audit_event" ("resource");
+-- Create index "idx_audit_event_created_at" to table: "audit_event"
+CREATE INDEX "idx_audit_event_created_at" ON "audit_event" ("created_at");
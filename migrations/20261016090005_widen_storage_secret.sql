@@ -0,0 +1,2 @@
+-- Modify "storage" table: envelope ciphertexts carry a wrapped data key next to the secret
+ALTER TABLE "storage" ALTER COLUMN "secret_access_key" TYPE character varying(1024);
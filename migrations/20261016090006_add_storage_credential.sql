@@ -0,0 +1,14 @@
+-- Create "storage_credential" table
+CREATE TABLE "storage_credential" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "storage_id" uuid NOT NULL,
+  "user" character varying(255) NOT NULL,
+  "access_key_id" character varying(255) NOT NULL,
+  "secret_access_key" character varying(1024) NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_storage_credential_storage" FOREIGN KEY ("storage_id") REFERENCES "storage" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "idx_storage_credential_storage_user" to table: "storage_credential"
+CREATE UNIQUE INDEX "idx_storage_credential_storage_user" ON "storage_credential" ("storage_id", "user");
@@ -0,0 +1,4 @@
+-- Modify "storage" table
+ALTER TABLE "storage" ADD COLUMN "labels" jsonb NOT NULL DEFAULT '{}';
+-- Create index "idx_storage_labels" to table: "storage"
+CREATE INDEX "idx_storage_labels" ON "storage" USING gin ("labels");
@@ -0,0 +1,16 @@
+-- Create "storage_health_check" table
+CREATE TABLE "storage_health_check" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "storage_id" uuid NOT NULL,
+  "up" boolean NOT NULL,
+  "latency_ms" bigint NOT NULL,
+  "error" character varying(1024) NULL,
+  "changed" boolean NOT NULL DEFAULT false,
+  "checked_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_storage_health_check_storage" FOREIGN KEY ("storage_id") REFERENCES "storage" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "idx_storage_health_check_storage_checked_at" to table: "storage_health_check"
+CREATE INDEX "idx_storage_health_check_storage_checked_at" ON "storage_health_check" ("storage_id", "checked_at");
+-- Create index "idx_storage_health_check_checked_at" to table: "storage_health_check"
+CREATE INDEX "idx_storage_health_check_checked_at" ON "storage_health_check" ("checked_at");
@@ -0,0 +1,22 @@
+-- Store the providers of storages saved before the provider catalog by their catalog name
+UPDATE "storage" SET "provider" = 'Minio' WHERE lower(trim("provider")) = 'minio' AND "provider" <> 'Minio';
+UPDATE "storage" SET "provider" = 'Ceph' WHERE lower(trim("provider")) IN ('ceph', 'rgw') AND "provider" <> 'Ceph';
+UPDATE "storage" SET "provider" = 'AWS' WHERE lower(trim("provider")) IN ('aws', 's3', 'amazon') AND "provider" <> 'AWS';
+UPDATE "storage" SET "provider" = 'GCS' WHERE lower(trim("provider")) IN ('gcs', 'gcs-interop', 'google') AND "provider" <> 'GCS';
+UPDATE "storage" SET "provider" = 'Wasabi' WHERE lower(trim("provider")) = 'wasabi' AND "provider" <> 'Wasabi';
+UPDATE "storage" SET "provider" = 'Cloudflare' WHERE lower(trim("provider")) IN ('cloudflare', 'cloudflare-r2', 'r2') AND "provider" <> 'Cloudflare';
+UPDATE "storage" SET "provider" = 'DigitalOcean' WHERE lower(trim("provider")) IN ('digitalocean', 'spaces') AND "provider" <> 'DigitalOcean';
+UPDATE "storage" SET "provider" = 'Alibaba' WHERE lower(trim("provider")) IN ('alibaba', 'oss', 'aliyun') AND "provider" <> 'Alibaba';
+UPDATE "storage" SET "provider" = 'Other' WHERE lower(trim("provider")) = 'other' AND "provider" <> 'Other';
+-- Report the storages whose provider is not in the catalog, they keep it until their provider is changed
+DO $$
+DECLARE
+  unknown text;
+BEGIN
+  SELECT string_agg(format('%s (%s)', quote_literal("name"), "provider"), ', ' ORDER BY "name") INTO unknown FROM "storage"
+    WHERE "provider" NOT IN ('Minio', 'Ceph', 'AWS', 'GCS', 'Wasabi', 'Cloudflare', 'DigitalOcean', 'Alibaba', 'Other');
+  IF unknown IS NOT NULL THEN
+    RAISE NOTICE 'storages with a provider outside the catalog: %, set their provider to other or a supported provider', unknown;
+  END IF;
+END
+$$;
@@ -0,0 +1,2 @@
+-- Matched keys are no longer requested from the worker, their count is unknown for new comparisons
+ALTER TABLE "bucket_comparison" ALTER COLUMN "match_count" DROP NOT NULL, ALTER COLUMN "match_count" DROP DEFAULT;